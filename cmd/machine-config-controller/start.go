@@ -7,6 +7,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/openshift/machine-config-operator/cmd/common"
 	"github.com/openshift/machine-config-operator/internal/clients"
+	bootstraptoken "github.com/openshift/machine-config-operator/pkg/controller/bootstrap-token"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	containerruntimeconfig "github.com/openshift/machine-config-operator/pkg/controller/container-runtime-config"
 	kubeletconfig "github.com/openshift/machine-config-operator/pkg/controller/kubelet-config"
@@ -29,8 +30,10 @@ var (
 	}
 
 	startOpts struct {
-		kubeconfig string
-		templates  string
+		kubeconfig      string
+		templates       string
+		promMetricsURL  string
+		bootstrapTokens bool
 
 		resourceLockNamespace string
 	}
@@ -39,6 +42,8 @@ var (
 func init() {
 	rootCmd.AddCommand(startCmd)
 	startCmd.PersistentFlags().StringVar(&startOpts.kubeconfig, "kubeconfig", "", "Kubeconfig file to access a remote cluster (testing only)")
+	startCmd.PersistentFlags().StringVar(&startOpts.promMetricsURL, "metrics-url", "127.0.0.1:8799", "URL for prometheus metrics listener")
+	startCmd.PersistentFlags().BoolVar(&startOpts.bootstrapTokens, "bootstrap-tokens", false, "Mint the bootstrap tokens required by the machine-config-server")
 	startCmd.PersistentFlags().StringVar(&startOpts.resourceLockNamespace, "resourcelock-namespace", metav1.NamespaceSystem, "Path to the template files used for creating MachineConfig objects")
 }
 
@@ -62,11 +67,14 @@ func runStartCmd(cmd *cobra.Command, args []string) {
 		ctrlctx.InformerFactory.Start(ctrlctx.Stop)
 		ctrlctx.KubeInformerFactory.Start(ctrlctx.Stop)
 		ctrlctx.OpenShiftConfigKubeNamespacedInformerFactory.Start(ctrlctx.Stop)
+		ctrlctx.BootstrapTokenKubeNamespacedInformerFactory.Start(ctrlctx.Stop)
 		ctrlctx.ConfigInformerFactory.Start(ctrlctx.Stop)
 		ctrlctx.OperatorInformerFactory.Start(ctrlctx.Stop)
 
 		close(ctrlctx.InformersStarted)
 
+		go ctrlcommon.StartMetricsListener(startOpts.promMetricsURL, ctrlctx.Stop)
+
 		for _, c := range controllers {
 			go c.Run(2, ctrlctx.Stop)
 		}
@@ -130,6 +138,7 @@ func createControllers(ctx *ctrlcommon.ControllerContext) []ctrlcommon.Controlle
 			ctx.InformerFactory.Machineconfiguration().V1().MachineConfigPools(),
 			ctx.InformerFactory.Machineconfiguration().V1().MachineConfigs(),
 			ctx.InformerFactory.Machineconfiguration().V1().ControllerConfigs(),
+			ctx.KubeInformerFactory.Core().V1().Nodes(),
 			ctx.ClientBuilder.KubeClientOrDie("render-controller"),
 			ctx.ClientBuilder.MachineConfigClientOrDie("render-controller"),
 		),
@@ -137,12 +146,24 @@ func createControllers(ctx *ctrlcommon.ControllerContext) []ctrlcommon.Controlle
 		node.New(
 			ctx.InformerFactory.Machineconfiguration().V1().ControllerConfigs(),
 			ctx.InformerFactory.Machineconfiguration().V1().MachineConfigPools(),
+			ctx.InformerFactory.Machineconfiguration().V1().MachineConfigDisruptionBudgets(),
 			ctx.KubeInformerFactory.Core().V1().Nodes(),
 			ctx.ConfigInformerFactory.Config().V1().Schedulers(),
+			ctx.OpenShiftConfigKubeNamespacedInformerFactory.Core().V1().Secrets(),
+			ctx.OpenShiftConfigKubeNamespacedInformerFactory.Core().V1().ConfigMaps(),
 			ctx.ClientBuilder.KubeClientOrDie("node-update-controller"),
 			ctx.ClientBuilder.MachineConfigClientOrDie("node-update-controller"),
 		),
 	)
 
+	if startOpts.bootstrapTokens {
+		// Mints the bootstrap tokens the machine-config-server requires
+		controllers = append(controllers, bootstraptoken.New(
+			ctx.BootstrapTokenKubeNamespacedInformerFactory.Core().V1().Secrets(),
+			ctx.KubeInformerFactory.Core().V1().Nodes(),
+			ctx.ClientBuilder.KubeClientOrDie("bootstrap-token-controller"),
+		))
+	}
+
 	return controllers
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	daemon "github.com/openshift/machine-config-operator/pkg/daemon"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var diffCmd = &cobra.Command{
+	Use:                   "diff OLD NEW",
+	DisableFlagsInUseLine: true,
+	Short:                 "Report how the MCD would update a node from one MachineConfig or Ignition config to another",
+	Long: `Compares two MachineConfig or Ignition config files without a cluster, and prints a JSON report
+of the changed sections, whether the MCD can apply them in place and the actions it takes after
+writing the new config. Exits with 1 if the new config can't be applied in place.`,
+	Args: cobra.ExactArgs(2),
+	Run:  executeDiff,
+}
+
+// init executes upon import
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
+}
+
+func runDiff(_ *cobra.Command, args []string) (bool, error) {
+	flag.Set("logtostderr", "true")
+	flag.Parse()
+
+	oldConfig, err := daemon.LoadMachineConfig(args[0])
+	if err != nil {
+		return false, err
+	}
+	newConfig, err := daemon.LoadMachineConfig(args[1])
+	if err != nil {
+		return false, err
+	}
+	report := daemon.DiffMachineConfigs(oldConfig, newConfig)
+	if err := printJSON(report); err != nil {
+		return false, err
+	}
+	return report.Reconcilable, nil
+}
+
+func executeDiff(cmd *cobra.Command, args []string) {
+	reconcilable, err := runDiff(cmd, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	if !reconcilable {
+		os.Exit(1)
+	}
+}
+
+// printJSON writes the indented JSON encoding of v to stdout.
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -61,7 +61,7 @@ func run(_ *cobra.Command, args []string) (retErr error) {
 
 	client := daemon.NewNodeUpdaterClient()
 
-	osImageContentDir, err := daemon.ExtractOSImage(container)
+	osImageContentDir, err := daemon.ExtractOSImage(daemon.NewHostRunner(), container)
 	if err != nil {
 		return err
 	}
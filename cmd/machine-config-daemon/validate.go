@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	daemon "github.com/openshift/machine-config-operator/pkg/daemon"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	validateCmd = &cobra.Command{
+		Use:   "validate CONFIG",
+		Short: "Validate a root directory against a MachineConfig or Ignition config",
+		Long: `Checks without a cluster that the files and units of a MachineConfig or Ignition config
+match the ones in a root directory, as the MCD does when it starts, and prints a JSON report
+for each file and unit. Exits with 1 if any of them doesn't match.`,
+		Args: cobra.ExactArgs(1),
+		Run:  executeValidate,
+	}
+
+	validateOpts struct {
+		rootDir string
+	}
+)
+
+// init executes upon import
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	validateCmd.PersistentFlags().StringVar(&validateOpts.rootDir, "root", "/", "root directory to validate")
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
+}
+
+func runValidate(_ *cobra.Command, args []string) (bool, error) {
+	flag.Set("logtostderr", "true")
+	flag.Parse()
+
+	config, err := daemon.LoadMachineConfig(args[0])
+	if err != nil {
+		return false, err
+	}
+	report, err := daemon.ValidateOnDiskState(config, validateOpts.rootDir)
+	if err != nil {
+		return false, err
+	}
+	if err := printJSON(report); err != nil {
+		return false, err
+	}
+	return report.Valid, nil
+}
+
+func executeValidate(cmd *cobra.Command, args []string) {
+	valid, err := runValidate(cmd, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	if !valid {
+		os.Exit(1)
+	}
+}
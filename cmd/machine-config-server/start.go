@@ -1,7 +1,9 @@
 package main
 
 import (
+	"crypto/x509"
 	"flag"
+	"io/ioutil"
 
 	"github.com/golang/glog"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
@@ -19,8 +21,11 @@ var (
 	}
 
 	startOpts struct {
-		kubeconfig   string
-		apiserverURL string
+		kubeconfig     string
+		apiserverURL   string
+		requireAuth    bool
+		clientCA       string
+		promMetricsURL string
 	}
 )
 
@@ -28,6 +33,9 @@ func init() {
 	rootCmd.AddCommand(startCmd)
 	startCmd.PersistentFlags().StringVar(&startOpts.kubeconfig, "kubeconfig", "", "Kubeconfig file to access a remote cluster (testing only)")
 	startCmd.PersistentFlags().StringVar(&startOpts.apiserverURL, "apiserver-url", "", "URL for apiserver; Used to generate kubeconfig")
+	startCmd.PersistentFlags().BoolVar(&startOpts.requireAuth, "require-auth", false, "Only serve requests presenting a bootstrap token or a client certificate")
+	startCmd.PersistentFlags().StringVar(&startOpts.promMetricsURL, "metrics-url", "127.0.0.1:8798", "URL for prometheus metrics listener")
+	startCmd.PersistentFlags().StringVar(&startOpts.clientCA, "client-ca", "", "CA bundle file verifying the client certificates presented to the secure port")
 }
 
 func runStartCmd(cmd *cobra.Command, args []string) {
@@ -41,16 +49,36 @@ func runStartCmd(cmd *cobra.Command, args []string) {
 		glog.Exitf("--apiserver-url cannot be empty")
 	}
 
-	cs, err := server.NewClusterServer(startOpts.kubeconfig, startOpts.apiserverURL)
+	stopCh := make(chan struct{})
+	cs, err := server.NewClusterServer(startOpts.kubeconfig, startOpts.apiserverURL, stopCh)
 	if err != nil {
 		ctrlcommon.WriteTerminationError(err)
 	}
 
+	var clientCAs *x509.CertPool
+	if startOpts.clientCA != "" {
+		pem, err := ioutil.ReadFile(startOpts.clientCA)
+		if err != nil {
+			glog.Exitf("failed to read --client-ca: %v", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			glog.Exitf("no certificate found in --client-ca %s", startOpts.clientCA)
+		}
+	}
+
 	apiHandler := server.NewServerAPIHandler(cs)
-	secureServer := server.NewAPIServer(apiHandler, rootOpts.sport, false, rootOpts.cert, rootOpts.key)
-	insecureServer := server.NewAPIServer(apiHandler, rootOpts.isport, true, "", "")
+	if startOpts.requireAuth {
+		auth, err := server.NewAuthenticator(startOpts.kubeconfig, stopCh)
+		if err != nil {
+			ctrlcommon.WriteTerminationError(err)
+		}
+		apiHandler = server.NewAuthenticatedServerAPIHandler(cs, auth)
+	}
+	secureServer := server.NewAPIServer(apiHandler, rootOpts.sport, false, rootOpts.cert, rootOpts.key, clientCAs)
+	insecureServer := server.NewAPIServer(apiHandler, rootOpts.isport, true, "", "", nil)
 
-	stopCh := make(chan struct{})
+	go server.StartMetricsListener(startOpts.promMetricsURL, stopCh)
 	go secureServer.Serve()
 	go insecureServer.Serve()
 	<-stopCh
@@ -32,6 +32,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&KubeletConfigList{},
 		&MachineConfig{},
 		&MachineConfigList{},
+		&MachineConfigDisruptionBudget{},
+		&MachineConfigDisruptionBudgetList{},
 		&MachineConfigPool{},
 		&MachineConfigPoolList{},
 	)
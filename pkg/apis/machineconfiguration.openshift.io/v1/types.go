@@ -190,8 +190,48 @@ type MachineConfigSpec struct {
 
 	FIPS       bool   `json:"fips"`
 	KernelType string `json:"kernelType"`
+
+	// postConfigChangeActions maps the files changed by an update to the action taken
+	// to apply them instead of rebooting the node. The rules of all the MachineConfigs of
+	// a pool are merged in the same order as their Ignition configs, and the last rule
+	// matching a changed file wins. Files matched by no rule reboot the node.
+	// +optional
+	PostConfigChangeActions []PostConfigChangeActionRule `json:"postConfigChangeActions,omitempty"`
+
+	// liveRestartUnits lists the systemd units whose changes are applied without rebooting the node:
+	// systemd is reloaded, and the units are restarted, or stopped when they are removed, masked or disabled.
+	// Changes to any other unit reboot the node.
+	// +optional
+	LiveRestartUnits []string `json:"liveRestartUnits,omitempty"`
+}
+
+// PostConfigChangeActionRule specifies the action taken after files matching its paths changed.
+type PostConfigChangeActionRule struct {
+	// paths are the globs, in path.Match syntax, matched against the absolute paths of the changed files.
+	Paths []string `json:"paths"`
+
+	// action is taken after a matching file changed, one of ('None', 'Reload', 'Restart', 'Reboot').
+	Action PostConfigChangeActionType `json:"action"`
+
+	// unit is the systemd unit reloaded or restarted by the Reload and Restart actions.
+	// +optional
+	Unit string `json:"unit,omitempty"`
 }
 
+// PostConfigChangeActionType is the action taken after files changed.
+type PostConfigChangeActionType string
+
+const (
+	// PostConfigChangeActionNone applies the files without any further action.
+	PostConfigChangeActionNone PostConfigChangeActionType = "None"
+	// PostConfigChangeActionReload reloads a systemd unit.
+	PostConfigChangeActionReload PostConfigChangeActionType = "Reload"
+	// PostConfigChangeActionRestart restarts a systemd unit.
+	PostConfigChangeActionRestart PostConfigChangeActionType = "Restart"
+	// PostConfigChangeActionReboot drains and reboots the node.
+	PostConfigChangeActionReboot PostConfigChangeActionType = "Reboot"
+)
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // MachineConfigList is a list of MachineConfig resources
@@ -234,10 +274,246 @@ type MachineConfigPoolSpec struct {
 	// default is 1.
 	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
 
+	// rolloutStrategy specifies how the nodes of the pool are picked for an update.
+	// +optional
+	RolloutStrategy *MachineConfigPoolRolloutStrategy `json:"rolloutStrategy,omitempty"`
+
+	// rollbackPolicy specifies when the pool is automatically rolled back to its previous configuration.
+	// Automatic rollback is disabled when unset.
+	// +optional
+	RollbackPolicy *MachineConfigPoolRollbackPolicy `json:"rollbackPolicy,omitempty"`
+
+	// drainPolicy specifies how the nodes of the pool are drained before being updated.
+	// +optional
+	DrainPolicy *MachineConfigPoolDrainPolicy `json:"drainPolicy,omitempty"`
+
+	// maintenanceSchedule restricts when nodes of the pool start updating to recurring maintenance windows.
+	// Nodes may start updating at any time when unset.
+	// +optional
+	MaintenanceSchedule *MachineConfigPoolMaintenanceSchedule `json:"maintenanceSchedule,omitempty"`
+
+	// driftPolicy specifies how the nodes of the pool handle files and units which drifted
+	// from their current configuration. Drift is reported by default.
+	// +optional
+	DriftPolicy *MachineConfigPoolDriftPolicy `json:"driftPolicy,omitempty"`
+
+	// healthCheckPolicy enables a health check of the nodes of the pool after they reboot into
+	// a new configuration. Nodes which don't pass it are rolled back to their previous
+	// configuration and marked Degraded. Nodes aren't checked by default.
+	// +optional
+	HealthCheckPolicy *MachineConfigPoolHealthCheckPolicy `json:"healthCheckPolicy,omitempty"`
+
+	// updateHooks are run by the nodes of the pool at defined phases of their updates,
+	// e.g. to deregister the node from an external load balancer before it is drained.
+	// +optional
+	UpdateHooks []MachineConfigPoolUpdateHook `json:"updateHooks,omitempty"`
+
+	// renderedConfigHistoryLimit specifies the number of most recent rendered MachineConfigs
+	// to keep for this pool, in addition to the ones still referenced by nodes or by the pool itself.
+	// Older rendered MachineConfigs are garbage collected. default is 5.
+	// +optional
+	RenderedConfigHistoryLimit *int32 `json:"renderedConfigHistoryLimit,omitempty"`
+
 	// The targeted MachineConfig object for the machine config pool.
 	Configuration MachineConfigPoolStatusConfiguration `json:"configuration"`
 }
 
+// MachineConfigPoolRolloutStrategy specifies how the nodes of a pool are picked for an update.
+type MachineConfigPoolRolloutStrategy struct {
+	// nodeOrdering specifies the order in which the nodes are updated, one of
+	// ('', 'ZoneSpread', 'Priority', 'NodeAge'). By default nodes are updated in no particular order.
+	// +optional
+	NodeOrdering NodeOrderingPolicy `json:"nodeOrdering,omitempty"`
+
+	// priorityLabel is the node label holding an integer priority, used by the Priority ordering.
+	// Nodes with a higher priority are updated first, nodes without a valid priority are updated last.
+	// +optional
+	PriorityLabel string `json:"priorityLabel,omitempty"`
+
+	// canary specifies whether a single node must complete the update to a new configuration
+	// before any other node of the pool starts updating.
+	// +optional
+	Canary bool `json:"canary,omitempty"`
+}
+
+// NodeOrderingPolicy is the order in which the nodes of a pool are updated.
+type NodeOrderingPolicy string
+
+const (
+	// NodeOrderingZoneSpread updates at most one node per topology zone at a time, and never
+	// starts on a zone which already has a node updating.
+	NodeOrderingZoneSpread NodeOrderingPolicy = "ZoneSpread"
+
+	// NodeOrderingPriority updates nodes by decreasing value of the rollout strategy priorityLabel.
+	NodeOrderingPriority NodeOrderingPolicy = "Priority"
+
+	// NodeOrderingNodeAge updates the oldest nodes first.
+	NodeOrderingNodeAge NodeOrderingPolicy = "NodeAge"
+)
+
+// MachineConfigPoolRollbackPolicy specifies when a pool is rolled back to the configuration in its status
+// because its nodes fail to update to the targeted one.
+type MachineConfigPoolRollbackPolicy struct {
+	// maxFailedNodes is the number of nodes allowed to fail updating to the targeted configuration
+	// within the window. When more nodes fail, the pool is rolled back. default is 0.
+	// +optional
+	MaxFailedNodes int32 `json:"maxFailedNodes,omitempty"`
+
+	// window is the period of time over which failed nodes are counted. When unset, all the
+	// nodes currently failing to update to the targeted configuration are counted.
+	// +optional
+	Window *metav1.Duration `json:"window,omitempty"`
+}
+
+// MachineConfigPoolDriftPolicy specifies how the nodes of a pool handle files and units which
+// drifted from their current configuration, e.g. because they were edited by hand.
+type MachineConfigPoolDriftPolicy struct {
+	// mode is one of ('', 'Report', 'Remediate', 'Disabled'). Report, the default, reports the
+	// drifted paths on the node and in the daemon metrics. Remediate also rewrites them from the
+	// current configuration. Disabled turns off drift detection.
+	// +optional
+	Mode DriftMode `json:"mode,omitempty"`
+
+	// interval is how often the nodes are checked for drift. default is 10m.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// DriftMode is how the nodes of a pool handle drift from their current configuration.
+type DriftMode string
+
+const (
+	// DriftModeReport reports the drifted paths.
+	DriftModeReport DriftMode = "Report"
+
+	// DriftModeRemediate reports the drifted paths and rewrites them from the current configuration.
+	DriftModeRemediate DriftMode = "Remediate"
+
+	// DriftModeDisabled turns off drift detection.
+	DriftModeDisabled DriftMode = "Disabled"
+)
+
+// MachineConfigPoolHealthCheckPolicy specifies how the nodes of a pool are checked after
+// rebooting into a new configuration.
+type MachineConfigPoolHealthCheckPolicy struct {
+	// timeout is how long the node has after booting to pass the health check. default is 10m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// units are the systemd units which must be active for the node to pass the health check,
+	// in addition to the kubelet being healthy and CRI-O responding.
+	// +optional
+	Units []string `json:"units,omitempty"`
+}
+
+// MachineConfigPoolUpdateHook is a systemd unit or a command run by the nodes of a pool at a phase
+// of their updates. Exactly one of unit and command must be set.
+type MachineConfigPoolUpdateHook struct {
+	// name identifies the hook in the node events.
+	Name string `json:"name"`
+
+	// phase is one of ('BeforeDrain', 'BeforeReboot', 'AfterBoot'). BeforeDrain hooks run before
+	// the node is cordoned and drained, BeforeReboot hooks once the new configuration is written,
+	// right before rebooting, and AfterBoot hooks once the node booted into the new configuration.
+	Phase UpdateHookPhase `json:"phase"`
+
+	// unit is a systemd unit started by the hook, typically a oneshot service, which is waited for.
+	// +optional
+	Unit string `json:"unit,omitempty"`
+
+	// command is run by the hook on the node, e.g. a script written by a MachineConfig.
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// timeout is how long the hook can run. default is 5m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// failurePolicy is one of ('', 'Abort', 'Continue'). Abort, the default, fails the update
+	// if the hook fails or times out. Continue only reports the failure.
+	// +optional
+	FailurePolicy UpdateHookFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// UpdateHookPhase is the phase of the update of a node a hook runs at.
+type UpdateHookPhase string
+
+const (
+	// UpdateHookPhaseBeforeDrain hooks run before the node is cordoned and drained.
+	UpdateHookPhaseBeforeDrain UpdateHookPhase = "BeforeDrain"
+
+	// UpdateHookPhaseBeforeReboot hooks run before the node reboots into the new configuration.
+	UpdateHookPhaseBeforeReboot UpdateHookPhase = "BeforeReboot"
+
+	// UpdateHookPhaseAfterBoot hooks run once the node booted into the new configuration.
+	UpdateHookPhaseAfterBoot UpdateHookPhase = "AfterBoot"
+)
+
+// UpdateHookFailurePolicy is how the failure of a hook is handled.
+type UpdateHookFailurePolicy string
+
+const (
+	// UpdateHookFailurePolicyAbort fails the update.
+	UpdateHookFailurePolicyAbort UpdateHookFailurePolicy = "Abort"
+
+	// UpdateHookFailurePolicyContinue reports the failure and goes on with the update.
+	UpdateHookFailurePolicyContinue UpdateHookFailurePolicy = "Continue"
+)
+
+// MachineConfigPoolDrainPolicy specifies how the nodes of a pool are drained before being updated.
+type MachineConfigPoolDrainPolicy struct {
+	// timeout is how long the drain of a node is retried before the update fails, or the drain
+	// is forced if forceAfterTimeout is set. By default the drain is attempted 5 times with an
+	// exponential backoff starting at 10s.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// gracePeriodSeconds overrides the termination grace period of the drained pods.
+	// By default the grace period of each pod is used.
+	// +optional
+	GracePeriodSeconds *int32 `json:"gracePeriodSeconds,omitempty"`
+
+	// deleteEmptyDirData specifies whether pods using emptyDir volumes are drained, deleting their
+	// data. When false, such pods fail the drain unless they are skipped. default is true.
+	// +optional
+	DeleteEmptyDirData *bool `json:"deleteEmptyDirData,omitempty"`
+
+	// skipPodSelector selects pods which are left in place when draining.
+	// +optional
+	SkipPodSelector *metav1.LabelSelector `json:"skipPodSelector,omitempty"`
+
+	// skipNamespaces lists namespaces whose pods are left in place when draining.
+	// +optional
+	SkipNamespaces []string `json:"skipNamespaces,omitempty"`
+
+	// forceAfterTimeout specifies whether the remaining pods are deleted, bypassing their
+	// PodDisruptionBudgets, when the drain times out instead of failing the update.
+	// +optional
+	ForceAfterTimeout bool `json:"forceAfterTimeout,omitempty"`
+}
+
+// MachineConfigPoolMaintenanceSchedule specifies the recurring windows during which nodes of a pool
+// may start updating. Nodes already updating when a window closes complete their update.
+type MachineConfigPoolMaintenanceSchedule struct {
+	// windows lists the maintenance windows of the pool.
+	Windows []MaintenanceWindow `json:"windows"`
+
+	// timeZone is the IANA name of the time zone the window schedules are expressed in, e.g. "Europe/Paris".
+	// default is UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// MaintenanceWindow is a recurring period of time during which nodes may start updating.
+type MaintenanceWindow struct {
+	// schedule is a cron expression with five fields (minute, hour, day of month, month, day of week)
+	// specifying when the window opens, e.g. "0 22 * * 1-5".
+	Schedule string `json:"schedule"`
+
+	// duration specifies how long the window stays open.
+	Duration metav1.Duration `json:"duration"`
+}
+
 // MachineConfigPoolStatus is the status for MachineConfigPool resource.
 type MachineConfigPoolStatus struct {
 	// observedGeneration represents the generation observed by the controller.
@@ -264,6 +540,11 @@ type MachineConfigPoolStatus struct {
 	// A node is marked degraded if applying a configuration failed..
 	DegradedMachineCount int32 `json:"degradedMachineCount"`
 
+	// nextMaintenanceWindow is the time the next maintenance window of the pool opens,
+	// set while no window is open.
+	// +optional
+	NextMaintenanceWindow *metav1.Time `json:"nextMaintenanceWindow,omitempty"`
+
 	// conditions represents the latest available observations of current state.
 	// +optional
 	Conditions []MachineConfigPoolCondition `json:"conditions"`
@@ -322,6 +603,14 @@ const (
 
 	// MachineConfigPoolDegraded is the overall status of the pool based, today, on whether we fail with NodeDegraded or RenderDegraded
 	MachineConfigPoolDegraded MachineConfigPoolConditionType = "Degraded"
+
+	// MachineConfigPoolRolledBack means the pool was rolled back to its previous configuration because
+	// too many nodes failed to update to the targeted one
+	MachineConfigPoolRolledBack MachineConfigPoolConditionType = "RolledBack"
+
+	// MachineConfigPoolWaitingForMaintenanceWindow means nodes of the pool don't start updating
+	// until the next maintenance window opens
+	MachineConfigPoolWaitingForMaintenanceWindow MachineConfigPoolConditionType = "WaitingForMaintenanceWindow"
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -506,3 +795,87 @@ type ContainerRuntimeConfigList struct {
 
 	Items []ContainerRuntimeConfig `json:"items"`
 }
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MachineConfigDisruptionBudget limits the number of nodes which are unavailable at once
+// because of config updates, across all the MachineConfigPools.
+type MachineConfigDisruptionBudget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +required
+	Spec MachineConfigDisruptionBudgetSpec `json:"spec"`
+	// +optional
+	Status MachineConfigDisruptionBudgetStatus `json:"status"`
+}
+
+// MachineConfigDisruptionBudgetSpec is the spec for MachineConfigDisruptionBudget resource.
+type MachineConfigDisruptionBudgetSpec struct {
+	// nodeSelector specifies a label selector for the nodes the budget applies to.
+	// The budget applies to all the nodes when unset.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+
+	// maxUnavailable specifies the percentage or constant number of the selected nodes which can be
+	// unavailable at any given time, in each topology domain. A node doesn't start updating if its
+	// update would exceed it. default is 1.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// topologyKey is the node label splitting the selected nodes in topology domains, e.g.
+	// topology.kubernetes.io/zone. The budget then applies to each domain separately,
+	// nodes without the label forming a domain of their own. The budget applies to all
+	// the selected nodes together when unset.
+	// +optional
+	TopologyKey string `json:"topologyKey,omitempty"`
+}
+
+// MachineConfigDisruptionBudgetStatus is the status for MachineConfigDisruptionBudget resource.
+type MachineConfigDisruptionBudgetStatus struct {
+	// observedGeneration represents the generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// machineCount represents the total number of nodes selected by the budget.
+	MachineCount int32 `json:"machineCount"`
+
+	// unavailableMachineCount represents the total number of selected nodes which are unavailable.
+	UnavailableMachineCount int32 `json:"unavailableMachineCount"`
+
+	// domains represents the usage of the budget in each topology domain.
+	// +optional
+	Domains []MachineConfigDisruptionBudgetDomainStatus `json:"domains,omitempty"`
+}
+
+// MachineConfigDisruptionBudgetDomainStatus is the usage of a MachineConfigDisruptionBudget in a topology domain.
+type MachineConfigDisruptionBudgetDomainStatus struct {
+	// name is the value of the topology label of the nodes of the domain.
+	// It's empty for the nodes without the label, or if the budget has no topologyKey.
+	Name string `json:"name"`
+
+	// machineCount represents the number of selected nodes in the domain.
+	MachineCount int32 `json:"machineCount"`
+
+	// maxUnavailable represents the number of nodes of the domain which can be unavailable at once.
+	MaxUnavailable int32 `json:"maxUnavailable"`
+
+	// unavailableMachineCount represents the number of nodes of the domain which are unavailable.
+	UnavailableMachineCount int32 `json:"unavailableMachineCount"`
+
+	// unavailableMachines are the names of the nodes of the domain which are unavailable.
+	// +optional
+	UnavailableMachines []string `json:"unavailableMachines,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MachineConfigDisruptionBudgetList is a list of MachineConfigDisruptionBudget resources
+type MachineConfigDisruptionBudgetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []MachineConfigDisruptionBudget `json:"items"`
+}
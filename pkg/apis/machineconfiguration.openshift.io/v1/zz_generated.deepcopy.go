@@ -495,6 +495,137 @@ func (in *MachineConfig) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigDisruptionBudget) DeepCopyInto(out *MachineConfigDisruptionBudget) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigDisruptionBudget.
+func (in *MachineConfigDisruptionBudget) DeepCopy() *MachineConfigDisruptionBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigDisruptionBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MachineConfigDisruptionBudget) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigDisruptionBudgetDomainStatus) DeepCopyInto(out *MachineConfigDisruptionBudgetDomainStatus) {
+	*out = *in
+	if in.UnavailableMachines != nil {
+		in, out := &in.UnavailableMachines, &out.UnavailableMachines
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigDisruptionBudgetDomainStatus.
+func (in *MachineConfigDisruptionBudgetDomainStatus) DeepCopy() *MachineConfigDisruptionBudgetDomainStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigDisruptionBudgetDomainStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigDisruptionBudgetList) DeepCopyInto(out *MachineConfigDisruptionBudgetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MachineConfigDisruptionBudget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigDisruptionBudgetList.
+func (in *MachineConfigDisruptionBudgetList) DeepCopy() *MachineConfigDisruptionBudgetList {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigDisruptionBudgetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MachineConfigDisruptionBudgetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigDisruptionBudgetSpec) DeepCopyInto(out *MachineConfigDisruptionBudgetSpec) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigDisruptionBudgetSpec.
+func (in *MachineConfigDisruptionBudgetSpec) DeepCopy() *MachineConfigDisruptionBudgetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigDisruptionBudgetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigDisruptionBudgetStatus) DeepCopyInto(out *MachineConfigDisruptionBudgetStatus) {
+	*out = *in
+	if in.Domains != nil {
+		in, out := &in.Domains, &out.Domains
+		*out = make([]MachineConfigDisruptionBudgetDomainStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigDisruptionBudgetStatus.
+func (in *MachineConfigDisruptionBudgetStatus) DeepCopy() *MachineConfigDisruptionBudgetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigDisruptionBudgetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineConfigList) DeepCopyInto(out *MachineConfigList) {
 	*out = *in
@@ -573,6 +704,94 @@ func (in *MachineConfigPoolCondition) DeepCopy() *MachineConfigPoolCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigPoolDrainPolicy) DeepCopyInto(out *MachineConfigPoolDrainPolicy) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.GracePeriodSeconds != nil {
+		in, out := &in.GracePeriodSeconds, &out.GracePeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DeleteEmptyDirData != nil {
+		in, out := &in.DeleteEmptyDirData, &out.DeleteEmptyDirData
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SkipPodSelector != nil {
+		in, out := &in.SkipPodSelector, &out.SkipPodSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SkipNamespaces != nil {
+		in, out := &in.SkipNamespaces, &out.SkipNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigPoolDrainPolicy.
+func (in *MachineConfigPoolDrainPolicy) DeepCopy() *MachineConfigPoolDrainPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigPoolDrainPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigPoolDriftPolicy) DeepCopyInto(out *MachineConfigPoolDriftPolicy) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigPoolDriftPolicy.
+func (in *MachineConfigPoolDriftPolicy) DeepCopy() *MachineConfigPoolDriftPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigPoolDriftPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigPoolHealthCheckPolicy) DeepCopyInto(out *MachineConfigPoolHealthCheckPolicy) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Units != nil {
+		in, out := &in.Units, &out.Units
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigPoolHealthCheckPolicy.
+func (in *MachineConfigPoolHealthCheckPolicy) DeepCopy() *MachineConfigPoolHealthCheckPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigPoolHealthCheckPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineConfigPoolList) DeepCopyInto(out *MachineConfigPoolList) {
 	*out = *in
@@ -606,6 +825,64 @@ func (in *MachineConfigPoolList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigPoolMaintenanceSchedule) DeepCopyInto(out *MachineConfigPoolMaintenanceSchedule) {
+	*out = *in
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = make([]MaintenanceWindow, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigPoolMaintenanceSchedule.
+func (in *MachineConfigPoolMaintenanceSchedule) DeepCopy() *MachineConfigPoolMaintenanceSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigPoolMaintenanceSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigPoolRollbackPolicy) DeepCopyInto(out *MachineConfigPoolRollbackPolicy) {
+	*out = *in
+	if in.Window != nil {
+		in, out := &in.Window, &out.Window
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigPoolRollbackPolicy.
+func (in *MachineConfigPoolRollbackPolicy) DeepCopy() *MachineConfigPoolRollbackPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigPoolRollbackPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigPoolRolloutStrategy) DeepCopyInto(out *MachineConfigPoolRolloutStrategy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigPoolRolloutStrategy.
+func (in *MachineConfigPoolRolloutStrategy) DeepCopy() *MachineConfigPoolRolloutStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigPoolRolloutStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineConfigPoolSpec) DeepCopyInto(out *MachineConfigPoolSpec) {
 	*out = *in
@@ -624,6 +901,48 @@ func (in *MachineConfigPoolSpec) DeepCopyInto(out *MachineConfigPoolSpec) {
 		*out = new(intstr.IntOrString)
 		**out = **in
 	}
+	if in.RolloutStrategy != nil {
+		in, out := &in.RolloutStrategy, &out.RolloutStrategy
+		*out = new(MachineConfigPoolRolloutStrategy)
+		**out = **in
+	}
+	if in.RollbackPolicy != nil {
+		in, out := &in.RollbackPolicy, &out.RollbackPolicy
+		*out = new(MachineConfigPoolRollbackPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DrainPolicy != nil {
+		in, out := &in.DrainPolicy, &out.DrainPolicy
+		*out = new(MachineConfigPoolDrainPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaintenanceSchedule != nil {
+		in, out := &in.MaintenanceSchedule, &out.MaintenanceSchedule
+		*out = new(MachineConfigPoolMaintenanceSchedule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DriftPolicy != nil {
+		in, out := &in.DriftPolicy, &out.DriftPolicy
+		*out = new(MachineConfigPoolDriftPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthCheckPolicy != nil {
+		in, out := &in.HealthCheckPolicy, &out.HealthCheckPolicy
+		*out = new(MachineConfigPoolHealthCheckPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpdateHooks != nil {
+		in, out := &in.UpdateHooks, &out.UpdateHooks
+		*out = make([]MachineConfigPoolUpdateHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RenderedConfigHistoryLimit != nil {
+		in, out := &in.RenderedConfigHistoryLimit, &out.RenderedConfigHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
 	in.Configuration.DeepCopyInto(&out.Configuration)
 	return
 }
@@ -642,6 +961,10 @@ func (in *MachineConfigPoolSpec) DeepCopy() *MachineConfigPoolSpec {
 func (in *MachineConfigPoolStatus) DeepCopyInto(out *MachineConfigPoolStatus) {
 	*out = *in
 	in.Configuration.DeepCopyInto(&out.Configuration)
+	if in.NextMaintenanceWindow != nil {
+		in, out := &in.NextMaintenanceWindow, &out.NextMaintenanceWindow
+		*out = (*in).DeepCopy()
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]MachineConfigPoolCondition, len(*in))
@@ -684,6 +1007,32 @@ func (in *MachineConfigPoolStatusConfiguration) DeepCopy() *MachineConfigPoolSta
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigPoolUpdateHook) DeepCopyInto(out *MachineConfigPoolUpdateHook) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigPoolUpdateHook.
+func (in *MachineConfigPoolUpdateHook) DeepCopy() *MachineConfigPoolUpdateHook {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigPoolUpdateHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineConfigSpec) DeepCopyInto(out *MachineConfigSpec) {
 	*out = *in
@@ -698,6 +1047,18 @@ func (in *MachineConfigSpec) DeepCopyInto(out *MachineConfigSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.PostConfigChangeActions != nil {
+		in, out := &in.PostConfigChangeActions, &out.PostConfigChangeActions
+		*out = make([]PostConfigChangeActionRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LiveRestartUnits != nil {
+		in, out := &in.LiveRestartUnits, &out.LiveRestartUnits
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -710,3 +1071,41 @@ func (in *MachineConfigSpec) DeepCopy() *MachineConfigSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	out.Duration = in.Duration
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostConfigChangeActionRule) DeepCopyInto(out *PostConfigChangeActionRule) {
+	*out = *in
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostConfigChangeActionRule.
+func (in *PostConfigChangeActionRule) DeepCopy() *PostConfigChangeActionRule {
+	if in == nil {
+		return nil
+	}
+	out := new(PostConfigChangeActionRule)
+	in.DeepCopyInto(out)
+	return out
+}
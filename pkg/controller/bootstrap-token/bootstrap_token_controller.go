@@ -0,0 +1,355 @@
+package bootstraptoken
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// maxRetries is the number of times a secret will be retried before it is dropped out of the queue.
+	// With the current rate-limiter in use (5ms*2^(maxRetries-1)) the following numbers represent the times
+	// a secret is going to be requeued:
+	//
+	// 5ms, 10ms, 20ms, 40ms, 80ms, 160ms, 320ms, 640ms, 1.3s, 2.6s, 5.1s, 10.2s, 20.4s, 41s, 82s
+	maxRetries = 15
+
+	// defaultTokenTTL is how long a bootstrap token is valid when its Secret doesn't set a ttl.
+	defaultTokenTTL = time.Hour
+
+	// tokenBytes is the number of random bytes of a bootstrap token.
+	tokenBytes = 32
+
+	// masterNodeRoleLabel is the role label of the nodes provisioned from the master config.
+	masterNodeRoleLabel = "node-role.kubernetes.io/master"
+)
+
+// Controller defines the bootstrap token controller. It requests a bootstrap token for every node,
+// mints the bootstrap tokens requested through Secrets of type BootstrapTokenSecretType and deletes
+// them once expired.
+type Controller struct {
+	kubeClient clientset.Interface
+
+	syncHandler func(key string) error
+
+	secretLister       corelisterv1.SecretLister
+	secretListerSynced cache.InformerSynced
+	nodeLister         corelisterv1.NodeLister
+	nodeListerSynced   cache.InformerSynced
+
+	queue workqueue.RateLimitingInterface
+
+	now func() time.Time
+}
+
+// New returns a new bootstrap token controller.
+func New(
+	secretsInformer coreinformersv1.SecretInformer,
+	nodeInformer coreinformersv1.NodeInformer,
+	kubeClient clientset.Interface,
+) *Controller {
+	ctrl := &Controller{
+		kubeClient: kubeClient,
+		queue:      workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "machineconfigcontroller-bootstraptokencontroller"),
+		now:        time.Now,
+	}
+
+	secretsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctrl.addSecret,
+		UpdateFunc: ctrl.updateSecret,
+		DeleteFunc: ctrl.deleteSecret,
+	})
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: ctrl.addNode,
+	})
+
+	ctrl.syncHandler = ctrl.sync
+
+	ctrl.secretLister = secretsInformer.Lister()
+	ctrl.secretListerSynced = secretsInformer.Informer().HasSynced
+	ctrl.nodeLister = nodeInformer.Lister()
+	ctrl.nodeListerSynced = nodeInformer.Informer().HasSynced
+
+	return ctrl
+}
+
+// Run executes the bootstrap token controller
+func (ctrl *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer ctrl.queue.ShutDown()
+
+	if !cache.WaitForCacheSync(stopCh, ctrl.secretListerSynced, ctrl.nodeListerSynced) {
+		return
+	}
+
+	glog.Info("Starting MachineConfigController-BootstrapTokenController")
+	defer glog.Info("Shutting down MachineConfigController-BootstrapTokenController")
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(ctrl.worker, time.Second, stopCh)
+	}
+
+	<-stopCh
+}
+
+func (ctrl *Controller) addSecret(obj interface{}) {
+	secret := obj.(*corev1.Secret)
+	if secret.Type != ctrlcommon.BootstrapTokenSecretType || secret.DeletionTimestamp != nil {
+		return
+	}
+	glog.V(4).Infof("Adding bootstrap token Secret %s", secret.Name)
+	ctrl.enqueue(secret)
+}
+
+func (ctrl *Controller) updateSecret(old, cur interface{}) {
+	secret := cur.(*corev1.Secret)
+	if secret.Type != ctrlcommon.BootstrapTokenSecretType || secret.DeletionTimestamp != nil {
+		return
+	}
+	glog.V(4).Infof("Updating bootstrap token Secret %s", secret.Name)
+	ctrl.enqueue(secret)
+}
+
+func (ctrl *Controller) deleteSecret(obj interface{}) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("Couldn't get object from tombstone %#v", obj))
+			return
+		}
+		secret, ok = tombstone.Obj.(*corev1.Secret)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("Tombstone contained object that is not a Secret %#v", obj))
+			return
+		}
+	}
+	if secret.Type != ctrlcommon.BootstrapTokenSecretType {
+		return
+	}
+	// request a new token for the node once its token has expired
+	if node := string(secret.Data[ctrlcommon.BootstrapTokenNodeKey]); node != "" && secret.Name == nodeTokenSecretName(node) {
+		glog.V(4).Infof("Bootstrap token Secret %s of node %s deleted", secret.Name, node)
+		ctrl.queue.Add(node)
+	}
+}
+
+func (ctrl *Controller) addNode(obj interface{}) {
+	node := obj.(*corev1.Node)
+	if node.DeletionTimestamp != nil {
+		return
+	}
+	glog.V(4).Infof("Node %s added", node.Name)
+	// nodes are cluster scoped, their keys are their names
+	ctrl.queue.Add(node.Name)
+}
+
+func (ctrl *Controller) enqueue(secret *corev1.Secret) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(secret)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("Couldn't get key for object %#v: %v", secret, err))
+		return
+	}
+
+	ctrl.queue.Add(key)
+}
+
+// worker runs a worker thread that just dequeues items, processes them, and marks them done.
+// It enforces that the syncHandler is never invoked concurrently with the same key.
+func (ctrl *Controller) worker() {
+	for ctrl.processNextWorkItem() {
+	}
+}
+
+func (ctrl *Controller) processNextWorkItem() bool {
+	key, quit := ctrl.queue.Get()
+	if quit {
+		return false
+	}
+	defer ctrl.queue.Done(key)
+
+	err := ctrl.syncHandler(key.(string))
+	ctrl.handleErr(err, key)
+
+	return true
+}
+
+func (ctrl *Controller) handleErr(err error, key interface{}) {
+	if err == nil {
+		ctrl.queue.Forget(key)
+		return
+	}
+
+	if ctrl.queue.NumRequeues(key) < maxRetries {
+		glog.V(2).Infof("Error syncing bootstrap token Secret %v: %v", key, err)
+		ctrl.queue.AddRateLimited(key)
+		return
+	}
+
+	utilruntime.HandleError(err)
+	glog.V(2).Infof("Dropping bootstrap token Secret %q out of the queue: %v", key, err)
+	ctrl.queue.Forget(key)
+	ctrl.queue.AddAfter(key, 1*time.Minute)
+}
+
+// sync syncs the node or the bootstrap token Secret with the given key.
+func (ctrl *Controller) sync(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	if namespace == "" {
+		return ctrl.syncNode(name)
+	}
+	return ctrl.syncSecret(key)
+}
+
+// nodeTokenSecretName returns the name of the bootstrap token Secret of a node.
+func nodeTokenSecretName(node string) string {
+	return node
+}
+
+// nodeTokenPool returns the pool whose config a node is provisioned from.
+func nodeTokenPool(node *corev1.Node) string {
+	if _, ok := node.Labels[masterNodeRoleLabel]; ok {
+		return "master"
+	}
+	return "worker"
+}
+
+// syncNode creates the bootstrap token Secret of the node with the given name if it has none.
+// The Secret is owned by the node, so that it is deleted with the node.
+func (ctrl *Controller) syncNode(name string) error {
+	node, err := ctrl.nodeLister.Get(name)
+	if errors.IsNotFound(err) {
+		glog.V(2).Infof("Node %v has been deleted", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	secretName := nodeTokenSecretName(node.Name)
+	if _, err := ctrl.secretLister.Secrets(ctrlcommon.BootstrapTokenNamespace).Get(secretName); err == nil || !errors.IsNotFound(err) {
+		return err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: ctrlcommon.BootstrapTokenNamespace,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Node",
+				Name:       node.Name,
+				UID:        node.UID,
+			}},
+		},
+		Type: ctrlcommon.BootstrapTokenSecretType,
+		Data: map[string][]byte{
+			ctrlcommon.BootstrapTokenPoolKey: []byte(nodeTokenPool(node)),
+			ctrlcommon.BootstrapTokenNodeKey: []byte(node.Name),
+		},
+	}
+	_, err = ctrl.kubeClient.CoreV1().Secrets(ctrlcommon.BootstrapTokenNamespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	glog.Infof("Requested bootstrap token Secret %s/%s for node %s", ctrlcommon.BootstrapTokenNamespace, secretName, node.Name)
+	return nil
+}
+
+// syncSecret mints the token of the bootstrap token Secret with the given key if it
+// has none yet, and deletes the Secret once the token has expired.
+// This function is not meant to be invoked concurrently with the same key.
+func (ctrl *Controller) syncSecret(key string) error {
+	startTime := time.Now()
+	glog.V(4).Infof("Started syncing bootstrap token Secret %q (%v)", key, startTime)
+	defer func() {
+		glog.V(4).Infof("Finished syncing bootstrap token Secret %q (%v)", key, time.Since(startTime))
+	}()
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	secret, err := ctrl.secretLister.Secrets(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		glog.V(2).Infof("Bootstrap token Secret %v has been deleted", key)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if secret.Type != ctrlcommon.BootstrapTokenSecretType {
+		return nil
+	}
+
+	now := ctrl.now()
+	if len(secret.Data[ctrlcommon.BootstrapTokenKey]) > 0 {
+		expiration, err := time.Parse(time.RFC3339, string(secret.Data[ctrlcommon.BootstrapTokenExpirationKey]))
+		if err == nil {
+			if !now.Before(expiration) {
+				glog.Infof("Deleting expired bootstrap token Secret %s", key)
+				err := ctrl.kubeClient.CoreV1().Secrets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+				if errors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			}
+			ctrl.queue.AddAfter(key, expiration.Sub(now))
+			return nil
+		}
+		glog.Warningf("Reissuing bootstrap token Secret %s with invalid expiration: %v", key, err)
+	}
+
+	ttl := defaultTokenTTL
+	if raw, ok := secret.Data[ctrlcommon.BootstrapTokenTTLKey]; ok {
+		ttl, err = time.ParseDuration(string(raw))
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid ttl %q of bootstrap token Secret %s", raw, key)
+		}
+	}
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	secret = secret.DeepCopy()
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[ctrlcommon.BootstrapTokenKey] = []byte(token)
+	secret.Data[ctrlcommon.BootstrapTokenExpirationKey] = []byte(now.Add(ttl).UTC().Format(time.RFC3339))
+	if _, err := ctrl.kubeClient.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	glog.Infof("Minted bootstrap token Secret %s valid for %v", key, ttl)
+	return nil
+}
+
+// newToken returns a random URL-safe token.
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate bootstrap token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,146 @@
+package bootstraptoken
+
+import (
+	"testing"
+	"time"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+)
+
+func newBootstrapTokenSecret(name string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ctrlcommon.BootstrapTokenNamespace, Name: name},
+		Type:       ctrlcommon.BootstrapTokenSecretType,
+		Data:       map[string][]byte{},
+	}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func newTestController(t *testing.T, now time.Time, nodes []*corev1.Node, secrets ...*corev1.Secret) (*Controller, *k8sfake.Clientset) {
+	var objs []runtime.Object
+	for _, node := range nodes {
+		objs = append(objs, node)
+	}
+	for _, secret := range secrets {
+		objs = append(objs, secret)
+	}
+	client := k8sfake.NewSimpleClientset(objs...)
+	i := informers.NewSharedInformerFactory(client, 0)
+	ctrl := New(i.Core().V1().Secrets(), i.Core().V1().Nodes(), client)
+	ctrl.now = func() time.Time { return now }
+	for _, node := range nodes {
+		require.Nil(t, i.Core().V1().Nodes().Informer().GetIndexer().Add(node))
+	}
+	for _, secret := range secrets {
+		require.Nil(t, i.Core().V1().Secrets().Informer().GetIndexer().Add(secret))
+	}
+	return ctrl, client
+}
+
+func filterActions(actions []core.Action) []core.Action {
+	var ret []core.Action
+	for _, action := range actions {
+		if action.GetVerb() == "list" || action.GetVerb() == "watch" {
+			continue
+		}
+		ret = append(ret, action)
+	}
+	return ret
+}
+
+func TestSyncSecretMintsToken(t *testing.T) {
+	now := time.Date(2021, time.March, 10, 10, 0, 0, 0, time.UTC)
+	ctrl, client := newTestController(t, now, nil,
+		newBootstrapTokenSecret("worker-0", map[string]string{ctrlcommon.BootstrapTokenPoolKey: "worker"}),
+		newBootstrapTokenSecret("worker-1", map[string]string{ctrlcommon.BootstrapTokenPoolKey: "worker", ctrlcommon.BootstrapTokenTTLKey: "30m"}),
+	)
+
+	require.Nil(t, ctrl.syncSecret(ctrlcommon.BootstrapTokenNamespace+"/worker-0"))
+	require.Nil(t, ctrl.syncSecret(ctrlcommon.BootstrapTokenNamespace+"/worker-1"))
+
+	actions := filterActions(client.Actions())
+	require.Len(t, actions, 2)
+	first := actions[0].(core.UpdateAction).GetObject().(*corev1.Secret)
+	second := actions[1].(core.UpdateAction).GetObject().(*corev1.Secret)
+	assert.Len(t, first.Data[ctrlcommon.BootstrapTokenKey], 43)
+	assert.NotEqual(t, first.Data[ctrlcommon.BootstrapTokenKey], second.Data[ctrlcommon.BootstrapTokenKey])
+	assert.Equal(t, "2021-03-10T11:00:00Z", string(first.Data[ctrlcommon.BootstrapTokenExpirationKey]))
+	assert.Equal(t, "2021-03-10T10:30:00Z", string(second.Data[ctrlcommon.BootstrapTokenExpirationKey]))
+	assert.Equal(t, "worker", string(first.Data[ctrlcommon.BootstrapTokenPoolKey]))
+}
+
+func TestSyncSecretDeletesExpired(t *testing.T) {
+	now := time.Date(2021, time.March, 10, 10, 0, 0, 0, time.UTC)
+	ctrl, client := newTestController(t, now, nil,
+		newBootstrapTokenSecret("expired", map[string]string{
+			ctrlcommon.BootstrapTokenPoolKey:       "worker",
+			ctrlcommon.BootstrapTokenKey:           "token",
+			ctrlcommon.BootstrapTokenExpirationKey: "2021-03-10T10:00:00Z",
+		}),
+		newBootstrapTokenSecret("valid", map[string]string{
+			ctrlcommon.BootstrapTokenPoolKey:       "worker",
+			ctrlcommon.BootstrapTokenKey:           "token",
+			ctrlcommon.BootstrapTokenExpirationKey: "2021-03-10T10:30:00Z",
+		}),
+		newBootstrapTokenSecret("invalid-ttl", map[string]string{
+			ctrlcommon.BootstrapTokenPoolKey: "worker",
+			ctrlcommon.BootstrapTokenTTLKey:  "forever",
+		}),
+	)
+
+	require.Nil(t, ctrl.syncSecret(ctrlcommon.BootstrapTokenNamespace+"/expired"))
+	require.Nil(t, ctrl.syncSecret(ctrlcommon.BootstrapTokenNamespace+"/valid"))
+	assert.NotNil(t, ctrl.syncSecret(ctrlcommon.BootstrapTokenNamespace+"/invalid-ttl"))
+	require.Nil(t, ctrl.syncSecret(ctrlcommon.BootstrapTokenNamespace+"/missing"))
+
+	actions := filterActions(client.Actions())
+	require.Len(t, actions, 1)
+	assert.True(t, actions[0].Matches("delete", "secrets"))
+	assert.Equal(t, "expired", actions[0].(core.DeleteAction).GetName())
+}
+
+func TestSyncNodeRequestsToken(t *testing.T) {
+	master := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "master-0", UID: "uid-0", Labels: map[string]string{masterNodeRoleLabel: ""}}}
+	worker := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", UID: "uid-1", Labels: map[string]string{"node-role.kubernetes.io/worker": ""}}}
+	withToken := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1", UID: "uid-2"}}
+	ctrl, client := newTestController(t, time.Now(), []*corev1.Node{master, worker, withToken},
+		newBootstrapTokenSecret("worker-1", map[string]string{ctrlcommon.BootstrapTokenPoolKey: "worker", ctrlcommon.BootstrapTokenNodeKey: "worker-1"}),
+	)
+
+	for _, key := range []string{"master-0", "worker-0", "worker-1", "missing"} {
+		require.Nil(t, ctrl.sync(key))
+	}
+
+	actions := filterActions(client.Actions())
+	require.Len(t, actions, 2)
+	for i, node := range []*corev1.Node{master, worker} {
+		require.True(t, actions[i].Matches("create", "secrets"))
+		secret := actions[i].(core.CreateAction).GetObject().(*corev1.Secret)
+		assert.Equal(t, ctrlcommon.BootstrapTokenNamespace, secret.Namespace)
+		assert.Equal(t, node.Name, secret.Name)
+		assert.Equal(t, corev1.SecretType(ctrlcommon.BootstrapTokenSecretType), secret.Type)
+		assert.Equal(t, node.Name, string(secret.Data[ctrlcommon.BootstrapTokenNodeKey]))
+		require.Len(t, secret.OwnerReferences, 1)
+		assert.Equal(t, node.UID, secret.OwnerReferences[0].UID)
+	}
+	assert.Equal(t, "master", string(actions[0].(core.CreateAction).GetObject().(*corev1.Secret).Data[ctrlcommon.BootstrapTokenPoolKey]))
+	assert.Equal(t, "worker", string(actions[1].(core.CreateAction).GetObject().(*corev1.Secret).Data[ctrlcommon.BootstrapTokenPoolKey]))
+
+	// the node gets a new token once its token expired and was deleted
+	ctrl.deleteSecret(newBootstrapTokenSecret("worker-1", map[string]string{ctrlcommon.BootstrapTokenNodeKey: "worker-1"}))
+	ctrl.deleteSecret(newBootstrapTokenSecret("other", map[string]string{ctrlcommon.BootstrapTokenNodeKey: "worker-0"}))
+	require.Equal(t, 1, ctrl.queue.Len())
+	key, _ := ctrl.queue.Get()
+	assert.Equal(t, "worker-1", key)
+}
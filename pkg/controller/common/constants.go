@@ -13,9 +13,41 @@ const (
 	// KernelTypeRealtime denominates the realtime kernel type
 	KernelTypeRealtime = "realtime"
 
+	// MasterPoolName is the name of the control plane MachineConfigPool, and of the role of its MachineConfigs
+	MasterPoolName = "master"
+
 	// MasterLabel defines the label associated with master node. The master taint uses the same label as taint's key
 	MasterLabel = "node-role.kubernetes.io/master"
 
+	// RolledBackConfigAnnotationKey is set on a MachineConfigPool by the node controller to the name of the
+	// rendered MachineConfig it was rolled back from. The render controller won't target that config again.
+	RolledBackConfigAnnotationKey = "machineconfiguration.openshift.io/rolled-back-config"
+
+	// BootstrapTokenNamespace is the namespace of the bootstrap token Secrets. It is kept apart from the
+	// namespace of the MCO components so that the machine-config-server can only read the bootstrap tokens.
+	BootstrapTokenNamespace = "openshift-machine-config-bootstrap-tokens"
+
+	// BootstrapTokenSecretType is the type of the Secrets in the BootstrapTokenNamespace holding the bootstrap token
+	// a machine presents to the machine-config-server. The Secret is created with the BootstrapTokenPoolKey and,
+	// optionally, the BootstrapTokenNodeKey and BootstrapTokenTTLKey; the bootstrap token controller mints
+	// the BootstrapTokenKey and BootstrapTokenExpirationKey, and deletes the Secret once it has expired.
+	BootstrapTokenSecretType = "machineconfiguration.openshift.io/bootstrap-token"
+
+	// BootstrapTokenPoolKey is the key of the bootstrap token Secret data naming the pool whose config the token gives access to
+	BootstrapTokenPoolKey = "pool"
+
+	// BootstrapTokenNodeKey is the key of the bootstrap token Secret data naming the node whose config the token gives access to
+	BootstrapTokenNodeKey = "node"
+
+	// BootstrapTokenTTLKey is the key of the bootstrap token Secret data holding how long the token is valid, one hour by default
+	BootstrapTokenTTLKey = "ttl"
+
+	// BootstrapTokenKey is the key of the bootstrap token Secret data holding the token
+	BootstrapTokenKey = "token"
+
+	// BootstrapTokenExpirationKey is the key of the bootstrap token Secret data holding the RFC 3339 expiration time of the token
+	BootstrapTokenExpirationKey = "expiration"
+
 	// MCNameSuffixAnnotationKey is used to keep track of the machine config name associated with a CR
 	MCNameSuffixAnnotationKey = "machineconfiguration.openshift.io/mc-name-suffix"
 )
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"path"
 	"reflect"
 	"sort"
 
@@ -31,6 +32,7 @@ import (
 	"github.com/pkg/errors"
 	kerr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
@@ -110,6 +112,17 @@ func MergeMachineConfigs(configs []*mcfgv1.MachineConfig, osImageURL string) (*m
 		extensions = append(extensions, cfg.Spec.Extensions...)
 	}
 
+	var postConfigChangeActions []mcfgv1.PostConfigChangeActionRule
+	var liveRestartUnits []string
+	for _, cfg := range configs {
+		postConfigChangeActions = append(postConfigChangeActions, cfg.Spec.PostConfigChangeActions...)
+		for _, unit := range cfg.Spec.LiveRestartUnits {
+			if !InSlice(unit, liveRestartUnits) {
+				liveRestartUnits = append(liveRestartUnits, unit)
+			}
+		}
+	}
+
 	// Ensure that kernel-devel extension is applied only with default kernel.
 	if kernelType != KernelTypeDefault {
 		if InSlice("kernel-devel", extensions) {
@@ -124,9 +137,11 @@ func MergeMachineConfigs(configs []*mcfgv1.MachineConfig, osImageURL string) (*m
 			Config: runtime.RawExtension{
 				Raw: rawOutIgn,
 			},
-			FIPS:       fips,
-			KernelType: kernelType,
-			Extensions: extensions,
+			FIPS:                    fips,
+			KernelType:              kernelType,
+			Extensions:              extensions,
+			PostConfigChangeActions: postConfigChangeActions,
+			LiveRestartUnits:        liveRestartUnits,
 		},
 	}, nil
 }
@@ -322,6 +337,10 @@ func ValidateMachineConfig(cfg mcfgv1.MachineConfigSpec) error {
 		return errors.Errorf("kernelType=%s is invalid", cfg.KernelType)
 	}
 
+	if err := validatePostConfigChangeActions(cfg.PostConfigChangeActions); err != nil {
+		return err
+	}
+
 	if cfg.Config.Raw != nil {
 		ignCfg, err := IgnParseWrapper(cfg.Config.Raw)
 		if err != nil {
@@ -334,6 +353,34 @@ func ValidateMachineConfig(cfg mcfgv1.MachineConfigSpec) error {
 	return nil
 }
 
+// validatePostConfigChangeActions validates that the paths of the rules are valid globs and
+// that the Reload and Restart actions name a unit.
+func validatePostConfigChangeActions(rules []mcfgv1.PostConfigChangeActionRule) error {
+	for i, rule := range rules {
+		switch rule.Action {
+		case mcfgv1.PostConfigChangeActionNone, mcfgv1.PostConfigChangeActionReboot:
+		case mcfgv1.PostConfigChangeActionReload, mcfgv1.PostConfigChangeActionRestart:
+			if rule.Unit == "" {
+				return errors.Errorf("postConfigChangeActions[%d]: action %s requires a unit", i, rule.Action)
+			}
+		default:
+			return errors.Errorf("postConfigChangeActions[%d]: action=%s is invalid", i, rule.Action)
+		}
+		if len(rule.Paths) == 0 {
+			return errors.Errorf("postConfigChangeActions[%d]: no paths", i)
+		}
+		for _, p := range rule.Paths {
+			if !path.IsAbs(p) {
+				return errors.Errorf("postConfigChangeActions[%d]: path %q is not absolute", i, p)
+			}
+			if _, err := path.Match(p, ""); err != nil {
+				return errors.Errorf("postConfigChangeActions[%d]: invalid path %q: %v", i, p, err)
+			}
+		}
+	}
+	return nil
+}
+
 // IgnParseWrapper parses rawIgn for both V2 and V3 ignition configs and returns
 // a V2 or V3 Config or an error. This wrapper is necessary since V2 and V3 use different parsers.
 func IgnParseWrapper(rawIgn []byte) (interface{}, error) {
@@ -593,6 +640,31 @@ func GetManagedKey(pool *mcfgv1.MachineConfigPool, client mcfgclientset.Interfac
 	return managedKey, err
 }
 
+// IsControlPlanePool checks whether the pool is the master pool or a custom pool inheriting from it,
+// that is a pool whose machineConfigSelector selects the MachineConfigs of the master role.
+func IsControlPlanePool(pool *mcfgv1.MachineConfigPool) bool {
+	if pool.Name == MasterPoolName {
+		return true
+	}
+	if pool.Spec.MachineConfigSelector == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(pool.Spec.MachineConfigSelector)
+	if err != nil || selector.Empty() {
+		return false
+	}
+	return selector.Matches(labels.Set{mcfgv1.MachineConfigRoleLabelKey: MasterPoolName})
+}
+
+// GetPoolTemplateRole returns the role whose templates render the default configs of the pool:
+// master for the control plane pools, otherwise the pool name, custom pools using the worker templates.
+func GetPoolTemplateRole(pool *mcfgv1.MachineConfigPool) string {
+	if IsControlPlanePool(pool) {
+		return MasterPoolName
+	}
+	return pool.Name
+}
+
 // Ensures SSH keys are unique for a given Ign 2 PasswdUser
 // See: https://bugzilla.redhat.com/show_bug.cgi?id=1934176
 func dedupePasswdUserSSHKeys(passwdUser ign2types.PasswdUser) ign2types.PasswdUser {
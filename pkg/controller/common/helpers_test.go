@@ -8,6 +8,7 @@ import (
 	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
@@ -225,6 +226,15 @@ func TestMergeMachineConfigs(t *testing.T) {
 			Extensions: extensions,
 		},
 	}
+	postConfigChangeActions := []mcfgv1.PostConfigChangeActionRule{
+		{Paths: []string{"/etc/chrony.conf"}, Action: mcfgv1.PostConfigChangeActionRestart, Unit: "chronyd.service"},
+	}
+	machineConfigPostConfigChangeActions := &mcfgv1.MachineConfig{
+		Spec: mcfgv1.MachineConfigSpec{
+			PostConfigChangeActions: postConfigChangeActions,
+			LiveRestartUnits:        []string{"chronyd.service"},
+		},
+	}
 	outIgn = ign3types.Config{
 		Ignition: ign3types.Ignition{
 			Version: ign3types.MaxVersion.String(),
@@ -251,6 +261,7 @@ func TestMergeMachineConfigs(t *testing.T) {
 		machineConfigKernelArgs,
 		machineConfigKernelType,
 		machineConfigExtensions,
+		machineConfigPostConfigChangeActions,
 		machineConfigIgn,
 	}
 	mergedMachineConfig, err = MergeMachineConfigs(inMachineConfigs, osImageURL)
@@ -263,15 +274,43 @@ func TestMergeMachineConfigs(t *testing.T) {
 			Config: runtime.RawExtension{
 				Raw: rawOutIgn,
 			},
-			FIPS:       true,
-			KernelType: KernelTypeRealtime,
-			Extensions: extensions,
+			FIPS:                    true,
+			KernelType:              KernelTypeRealtime,
+			Extensions:              extensions,
+			PostConfigChangeActions: postConfigChangeActions,
+			LiveRestartUnits:        []string{"chronyd.service"},
 		},
 	}
 	assert.Equal(t, *mergedMachineConfig, *expectedMachineConfig)
 
 }
 
+func TestValidateMachineConfigPostConfigChangeActions(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  mcfgv1.PostConfigChangeActionRule
+		valid bool
+	}{
+		{name: "none", rule: mcfgv1.PostConfigChangeActionRule{Paths: []string{"/etc/foo"}, Action: mcfgv1.PostConfigChangeActionNone}, valid: true},
+		{name: "reload", rule: mcfgv1.PostConfigChangeActionRule{Paths: []string{"/etc/rsyslog.d/*.conf"}, Action: mcfgv1.PostConfigChangeActionReload, Unit: "rsyslog.service"}, valid: true},
+		{name: "restart without unit", rule: mcfgv1.PostConfigChangeActionRule{Paths: []string{"/etc/chrony.conf"}, Action: mcfgv1.PostConfigChangeActionRestart}},
+		{name: "unknown action", rule: mcfgv1.PostConfigChangeActionRule{Paths: []string{"/etc/foo"}, Action: "Drain"}},
+		{name: "no paths", rule: mcfgv1.PostConfigChangeActionRule{Action: mcfgv1.PostConfigChangeActionNone}},
+		{name: "relative path", rule: mcfgv1.PostConfigChangeActionRule{Paths: []string{"etc/foo"}, Action: mcfgv1.PostConfigChangeActionNone}},
+		{name: "bad glob", rule: mcfgv1.PostConfigChangeActionRule{Paths: []string{"/etc/[foo"}, Action: mcfgv1.PostConfigChangeActionNone}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateMachineConfig(mcfgv1.MachineConfigSpec{PostConfigChangeActions: []mcfgv1.PostConfigChangeActionRule{test.rule}})
+			if test.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
+
 func TestRemoveIgnDuplicateFilesAndUnits(t *testing.T) {
 	mode := 420
 	testDataOld := "data:,old"
@@ -380,3 +419,31 @@ func TestRemoveIgnDuplicateFilesAndUnits(t *testing.T) {
 
 	assert.Equal(t, expectedIgn2Config, convertedIgn2Config)
 }
+
+func TestIsControlPlanePool(t *testing.T) {
+	roles := func(roles ...string) *metav1.LabelSelector {
+		return &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: mcfgv1.MachineConfigRoleLabelKey, Operator: metav1.LabelSelectorOpIn, Values: roles},
+		}}
+	}
+	tests := []struct {
+		pool         *mcfgv1.MachineConfigPool
+		controlPlane bool
+		templateRole string
+	}{
+		{pool: helpers.NewMachineConfigPool("master", nil, helpers.MasterSelector, "v0"), controlPlane: true, templateRole: "master"},
+		{pool: helpers.NewMachineConfigPool("worker", roles("worker"), helpers.WorkerSelector, "v0"), templateRole: "worker"},
+		{pool: helpers.NewMachineConfigPool("infra", roles("worker", "infra"), helpers.InfraSelector, "v0"), templateRole: "infra"},
+		{pool: helpers.NewMachineConfigPool("master-nic", roles("master", "master-nic"), helpers.MasterSelector, "v0"), controlPlane: true, templateRole: "master"},
+		{pool: helpers.NewMachineConfigPool("master-nic", metav1.AddLabelToSelector(&metav1.LabelSelector{}, mcfgv1.MachineConfigRoleLabelKey, "master"), helpers.MasterSelector, "v0"), controlPlane: true, templateRole: "master"},
+		// A pool with no or an empty selector renders no MachineConfig.
+		{pool: helpers.NewMachineConfigPool("custom", nil, helpers.InfraSelector, "v0"), templateRole: "custom"},
+		{pool: helpers.NewMachineConfigPool("custom", &metav1.LabelSelector{}, helpers.InfraSelector, "v0"), templateRole: "custom"},
+	}
+	for _, test := range tests {
+		t.Run(test.pool.Name, func(t *testing.T) {
+			assert.Equal(t, test.controlPlane, IsControlPlanePool(test.pool))
+			assert.Equal(t, test.templateRole, GetPoolTemplateRole(test.pool))
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// DefaultBindAddress is the port for the metrics listener
+	DefaultBindAddress = ":8799"
+
+	// MCCEtcdLeaderDecisions counts the control plane updates checked against the etcd leader, by decision:
+	// deferred when the leader was a candidate, not_candidate when it wasn't, unknown when it couldn't be found.
+	MCCEtcdLeaderDecisions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcc_etcd_leader_decisions_total",
+			Help: "control plane updates checked against the etcd leader, by decision",
+		}, []string{"decision"})
+
+	metricsList = []prometheus.Collector{
+		MCCEtcdLeaderDecisions,
+	}
+)
+
+func registerMCCMetrics() error {
+	for _, metric := range metricsList {
+		err := prometheus.Register(metric)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartMetricsListener is metrics listener via http
+func StartMetricsListener(addr string, stopCh <-chan struct{}) {
+	if addr == "" {
+		addr = DefaultBindAddress
+	}
+
+	glog.Info("Registering Prometheus metrics")
+	if err := registerMCCMetrics(); err != nil {
+		glog.Errorf("unable to register metrics: %v", err)
+	}
+
+	glog.Infof("Starting metrics listener on %s", addr)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	s := http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			glog.Errorf("metrics listener exited with error: %v", err)
+		}
+	}()
+	<-stopCh
+	if err := s.Shutdown(context.Background()); err != http.ErrServerClosed {
+		glog.Errorf("error stopping metrics listener: %v", err)
+	}
+}
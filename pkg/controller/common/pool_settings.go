@@ -0,0 +1,66 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+// PoolSettingsVersion is the version of the PoolSettings written by this controller.
+// Bump it on incompatible changes, the MCD ignores the settings of other versions.
+const PoolSettingsVersion = 1
+
+// PoolSettings are the settings of a MachineConfigPool the MCD of its nodes uses, set by the node
+// controller as JSON in the poolSettings annotation of the nodes. They're written in the same
+// patch as the desiredConfig annotation, so that the MCD never sees a new config without them.
+type PoolSettings struct {
+	Version           int                                        `json:"version"`
+	DrainPolicy       *mcfgv1.MachineConfigPoolDrainPolicy       `json:"drainPolicy,omitempty"`
+	DriftPolicy       *mcfgv1.MachineConfigPoolDriftPolicy       `json:"driftPolicy,omitempty"`
+	HealthCheckPolicy *mcfgv1.MachineConfigPoolHealthCheckPolicy `json:"healthCheckPolicy,omitempty"`
+	UpdateHooks       []mcfgv1.MachineConfigPoolUpdateHook       `json:"updateHooks,omitempty"`
+	// TargetConfig is the config targeted by the pool, set on the nodes next in line for the
+	// update for the MCD to stage its OS image before the node is selected.
+	TargetConfig string `json:"targetConfig,omitempty"`
+}
+
+// NewPoolSettings returns the settings of the pool for its nodes, without a target config.
+func NewPoolSettings(pool *mcfgv1.MachineConfigPool) *PoolSettings {
+	return &PoolSettings{
+		Version:           PoolSettingsVersion,
+		DrainPolicy:       pool.Spec.DrainPolicy,
+		DriftPolicy:       pool.Spec.DriftPolicy,
+		HealthCheckPolicy: pool.Spec.HealthCheckPolicy,
+		UpdateHooks:       pool.Spec.UpdateHooks,
+	}
+}
+
+// Encode returns the settings as the value of the poolSettings annotation, or an empty
+// string if there are none, for the annotation to be removed.
+func (s *PoolSettings) Encode() (string, error) {
+	if s.DrainPolicy == nil && s.DriftPolicy == nil && s.HealthCheckPolicy == nil && len(s.UpdateHooks) == 0 && s.TargetConfig == "" {
+		return "", nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ParsePoolSettings parses the value of the poolSettings annotation, returning
+// empty settings if it's unset.
+func ParsePoolSettings(data string) (*PoolSettings, error) {
+	settings := &PoolSettings{}
+	if data == "" {
+		return settings, nil
+	}
+	if err := json.Unmarshal([]byte(data), settings); err != nil {
+		return nil, err
+	}
+	if settings.Version != PoolSettingsVersion {
+		return nil, fmt.Errorf("unsupported pool settings version %d, expected %d", settings.Version, PoolSettingsVersion)
+	}
+	return settings, nil
+}
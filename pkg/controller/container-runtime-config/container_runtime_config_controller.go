@@ -546,7 +546,7 @@ func (ctrl *Controller) syncContainerRuntimeConfig(key string) error {
 			}
 		}
 		// Generate the original ContainerRuntimeConfig
-		originalStorageIgn, _, _, err := generateOriginalContainerRuntimeConfigs(ctrl.templatesDir, controllerConfig, role)
+		originalStorageIgn, _, _, err := generateOriginalContainerRuntimeConfigs(ctrl.templatesDir, controllerConfig, ctrlcommon.GetPoolTemplateRole(pool))
 		if err != nil {
 			return ctrl.syncStatusOnly(cfg, err, "could not generate origin ContainerRuntime Configs: %v", err)
 		}
@@ -711,7 +711,7 @@ func (ctrl *Controller) syncImageConfig(key string) error {
 			return err
 		}
 		if err := retry.RetryOnConflict(updateBackoff, func() error {
-			registriesIgn, err := registriesConfigIgnition(ctrl.templatesDir, controllerConfig, role,
+			registriesIgn, err := registriesConfigIgnition(ctrl.templatesDir, controllerConfig, ctrlcommon.GetPoolTemplateRole(pool),
 				imgcfg.Spec.RegistrySources.InsecureRegistries, blockedRegs, imgcfg.Spec.RegistrySources.AllowedRegistries,
 				imgcfg.Spec.RegistrySources.ContainerRuntimeSearchRegistries, icspRules)
 			if err != nil {
@@ -856,7 +856,7 @@ func RunImageBootstrap(templateDir string, controllerConfig *mcfgv1.ControllerCo
 		if err != nil {
 			return nil, err
 		}
-		registriesIgn, err := registriesConfigIgnition(templateDir, controllerConfig, role,
+		registriesIgn, err := registriesConfigIgnition(templateDir, controllerConfig, ctrlcommon.GetPoolTemplateRole(pool),
 			insecureRegs, blockedRegs, allowedRegs, searchRegs, icspRules)
 		if err != nil {
 			return nil, err
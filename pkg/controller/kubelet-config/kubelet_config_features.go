@@ -97,7 +97,7 @@ func (ctrl *Controller) syncFeatureHandler(key string) error {
 			}
 		}
 		// Generate the original KubeletConfig
-		originalKubeletIgn, err := ctrl.generateOriginalKubeletConfig(role)
+		originalKubeletIgn, err := ctrl.generateOriginalKubeletConfig(ctrlcommon.GetPoolTemplateRole(pool))
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,343 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// budgetReservationTimeout is how long a node whose desiredConfig was just set counts as unavailable
+	// while the node lister doesn't reflect the update yet.
+	budgetReservationTimeout = time.Minute
+
+	// controlPlaneBudgetName is the name of the implicit disruption budget spanning the control plane pools.
+	controlPlaneBudgetName = "control-plane"
+)
+
+// budgetReservation records a desiredConfig set by the controller, until the node lister catches up.
+type budgetReservation struct {
+	config string
+	at     time.Time
+}
+
+// budgetUsage is the usage of a disruption budget, per topology domain.
+type budgetUsage struct {
+	budget   *mcfgv1.MachineConfigDisruptionBudget
+	selector labels.Selector
+	domains  map[string]*mcfgv1.MachineConfigDisruptionBudgetDomainStatus
+}
+
+// newBudgetUsage computes the usage of the budget over the nodes, unavailable telling which are unavailable.
+func newBudgetUsage(budget *mcfgv1.MachineConfigDisruptionBudget, nodes []*corev1.Node, unavailable func(*corev1.Node) bool) (*budgetUsage, error) {
+	selector := labels.Everything()
+	if budget.Spec.NodeSelector != nil {
+		var err error
+		selector, err = metav1.LabelSelectorAsSelector(budget.Spec.NodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector: %v", err)
+		}
+	}
+	usage := &budgetUsage{
+		budget:   budget,
+		selector: selector,
+		domains:  map[string]*mcfgv1.MachineConfigDisruptionBudgetDomainStatus{},
+	}
+	for _, node := range nodes {
+		if !usage.selects(node) {
+			continue
+		}
+		domain := usage.domainOf(node)
+		domain.MachineCount++
+		if unavailable(node) {
+			domain.UnavailableMachineCount++
+			domain.UnavailableMachines = append(domain.UnavailableMachines, node.Name)
+		}
+	}
+
+	intOrPercent := intstrutil.FromInt(1)
+	if budget.Spec.MaxUnavailable != nil {
+		intOrPercent = *budget.Spec.MaxUnavailable
+	}
+	for _, domain := range usage.domains {
+		maxunavail, err := intstrutil.GetScaledValueFromIntOrPercent(&intOrPercent, int(domain.MachineCount), false)
+		if err != nil {
+			return nil, err
+		}
+		if maxunavail == 0 {
+			maxunavail = 1
+		}
+		domain.MaxUnavailable = int32(maxunavail)
+		sort.Strings(domain.UnavailableMachines)
+	}
+	return usage, nil
+}
+
+// selects checks whether the budget applies to the node.
+func (u *budgetUsage) selects(node *corev1.Node) bool {
+	return isNodeManaged(node) && u.selector.Matches(labels.Set(node.Labels))
+}
+
+// domainOf returns the usage of the topology domain of the node.
+func (u *budgetUsage) domainOf(node *corev1.Node) *mcfgv1.MachineConfigDisruptionBudgetDomainStatus {
+	var name string
+	if u.budget.Spec.TopologyKey != "" {
+		name = node.Labels[u.budget.Spec.TopologyKey]
+	}
+	domain, ok := u.domains[name]
+	if !ok {
+		domain = &mcfgv1.MachineConfigDisruptionBudgetDomainStatus{Name: name}
+		u.domains[name] = domain
+	}
+	return domain
+}
+
+// allows checks whether the node can become unavailable without exceeding the budget.
+func (u *budgetUsage) allows(node *corev1.Node) bool {
+	if !u.selects(node) {
+		return true
+	}
+	domain := u.domainOf(node)
+	return domain.UnavailableMachineCount < domain.MaxUnavailable
+}
+
+// take counts the node as unavailable.
+func (u *budgetUsage) take(node *corev1.Node) {
+	if !u.selects(node) {
+		return
+	}
+	domain := u.domainOf(node)
+	domain.UnavailableMachineCount++
+	domain.UnavailableMachines = append(domain.UnavailableMachines, node.Name)
+	sort.Strings(domain.UnavailableMachines)
+}
+
+// status returns the status of the budget for its usage.
+func (u *budgetUsage) status() mcfgv1.MachineConfigDisruptionBudgetStatus {
+	status := mcfgv1.MachineConfigDisruptionBudgetStatus{
+		ObservedGeneration: u.budget.Generation,
+	}
+	for _, domain := range u.domains {
+		status.MachineCount += domain.MachineCount
+		status.UnavailableMachineCount += domain.UnavailableMachineCount
+		status.Domains = append(status.Domains, *domain)
+	}
+	sort.Slice(status.Domains, func(i, j int) bool {
+		return status.Domains[i].Name < status.Domains[j].Name
+	})
+	return status
+}
+
+func (ctrl *Controller) addMachineConfigDisruptionBudget(obj interface{}) {
+	budget := obj.(*mcfgv1.MachineConfigDisruptionBudget)
+	glog.V(4).Infof("Adding MachineConfigDisruptionBudget %s", budget.Name)
+	ctrl.enqueueAllMachineConfigPools()
+}
+
+func (ctrl *Controller) updateMachineConfigDisruptionBudget(old, cur interface{}) {
+	oldBudget := old.(*mcfgv1.MachineConfigDisruptionBudget)
+	curBudget := cur.(*mcfgv1.MachineConfigDisruptionBudget)
+
+	// The status of the budgets is updated on every pool sync, only requeue the pools for spec changes.
+	if oldBudget.Generation == curBudget.Generation {
+		return
+	}
+	glog.V(4).Infof("Updating MachineConfigDisruptionBudget %s", curBudget.Name)
+	ctrl.enqueueAllMachineConfigPools()
+}
+
+func (ctrl *Controller) deleteMachineConfigDisruptionBudget(obj interface{}) {
+	budget, ok := obj.(*mcfgv1.MachineConfigDisruptionBudget)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("Couldn't get object from tombstone %#v", obj))
+			return
+		}
+		budget, ok = tombstone.Obj.(*mcfgv1.MachineConfigDisruptionBudget)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("Tombstone contained object that is not a MachineConfigDisruptionBudget %#v", obj))
+			return
+		}
+	}
+	glog.V(4).Infof("Deleting MachineConfigDisruptionBudget %s", budget.Name)
+	ctrl.enqueueAllMachineConfigPools()
+}
+
+func (ctrl *Controller) enqueueAllMachineConfigPools() {
+	pools, err := ctrl.mcpLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("error listing MachineConfigPools: %v", err))
+		return
+	}
+	for _, pool := range pools {
+		ctrl.enqueueMachineConfigPool(pool)
+	}
+}
+
+// enqueueBudgetDeferredPools requeues the pools which had nodes deferred by a disruption budget,
+// as the nodes of other pools becoming available may free the budget.
+func (ctrl *Controller) enqueueBudgetDeferredPools() {
+	ctrl.budgetLock.Lock()
+	names := ctrl.budgetDeferredPools.List()
+	ctrl.budgetDeferredPools = sets.NewString()
+	ctrl.budgetLock.Unlock()
+
+	for _, name := range names {
+		pool, err := ctrl.mcpLister.Get(name)
+		if err != nil {
+			continue
+		}
+		ctrl.enqueueMachineConfigPool(pool)
+	}
+}
+
+// isNodeBudgetUnavailable checks whether the node counts against the disruption budgets.
+// Besides the unavailable nodes, it's the case of the nodes whose desiredConfig was just set
+// while the node lister doesn't reflect it yet. The caller must hold the budget lock.
+func (ctrl *Controller) isNodeBudgetUnavailable(node *corev1.Node) bool {
+	if reservation, ok := ctrl.budgetReservations[node.Name]; ok {
+		if node.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey] != reservation.config &&
+			time.Since(reservation.at) < budgetReservationTimeout {
+			return true
+		}
+		delete(ctrl.budgetReservations, node.Name)
+	}
+	return isNodeUnavailable(node)
+}
+
+// getControlPlaneBudget returns the implicit disruption budget keeping the masters of the master pool
+// and of the custom pools inheriting from it within the maxUnavailable of the master pool, or nil
+// without a master pool.
+func (ctrl *Controller) getControlPlaneBudget() (*mcfgv1.MachineConfigDisruptionBudget, error) {
+	master, err := ctrl.mcpLister.Get(masterPoolName)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mcfgv1.MachineConfigDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: controlPlaneBudgetName},
+		Spec: mcfgv1.MachineConfigDisruptionBudgetSpec{
+			NodeSelector:   master.Spec.NodeSelector,
+			MaxUnavailable: master.Spec.MaxUnavailable,
+		},
+	}, nil
+}
+
+// getBudgetUsages returns the usage of the disruption budgets. The caller must hold the budget lock.
+func (ctrl *Controller) getBudgetUsages(budgets []*mcfgv1.MachineConfigDisruptionBudget) ([]*budgetUsage, error) {
+	if len(budgets) == 0 {
+		return nil, nil
+	}
+	nodes, err := ctrl.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var usages []*budgetUsage
+	for _, budget := range budgets {
+		usage, err := newBudgetUsage(budget, nodes, ctrl.isNodeBudgetUnavailable)
+		if err != nil {
+			return nil, fmt.Errorf("disruption budget %s: %v", budget.Name, err)
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// filterDisruptionBudgetCandidates picks up to capacity candidates, in order, whose update keeps
+// all the disruption budgets selecting them within their maxUnavailable. The control plane pools
+// also share the control plane budget. The picked candidates are reserved against the budgets,
+// the caller must hold the budget lock and set their desiredConfig.
+func (ctrl *Controller) filterDisruptionBudgetCandidates(pool *mcfgv1.MachineConfigPool, candidates []*corev1.Node, capacity uint) ([]*corev1.Node, error) {
+	budgets, err := ctrl.mcdbLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	if ctrlcommon.IsControlPlanePool(pool) {
+		budget, err := ctrl.getControlPlaneBudget()
+		if err != nil {
+			return nil, err
+		}
+		if budget != nil {
+			budgets = append(budgets, budget)
+		}
+	}
+	usages, err := ctrl.getBudgetUsages(budgets)
+	if err != nil {
+		return nil, err
+	}
+
+	var picked []*corev1.Node
+	for _, node := range candidates {
+		if uint(len(picked)) >= capacity {
+			break
+		}
+		var blocking *budgetUsage
+		for _, usage := range usages {
+			if !usage.allows(node) {
+				blocking = usage
+				break
+			}
+		}
+		if blocking != nil {
+			domain := blocking.domainOf(node)
+			ctrl.eventRecorder.Eventf(pool, corev1.EventTypeNormal, "DeferringUpdateForDisruptionBudget",
+				"Deferring update of node %s, disruption budget %s allows %d unavailable nodes in %q", node.Name, blocking.budget.Name, domain.MaxUnavailable, domain.Name)
+			ctrl.logPoolNode(pool, node, "Deferring update, disruption budget %s is in use by %v", blocking.budget.Name, domain.UnavailableMachines)
+			ctrl.budgetDeferredPools.Insert(pool.Name)
+			continue
+		}
+		for _, usage := range usages {
+			if usage.selects(node) {
+				ctrl.budgetReservations[node.Name] = budgetReservation{config: pool.Spec.Configuration.Name, at: time.Now()}
+			}
+			usage.take(node)
+		}
+		picked = append(picked, node)
+	}
+	return picked, nil
+}
+
+// syncDisruptionBudgetStatus updates the status of the disruption budgets with their current usage.
+func (ctrl *Controller) syncDisruptionBudgetStatus() error {
+	ctrl.budgetLock.Lock()
+	defer ctrl.budgetLock.Unlock()
+
+	budgets, err := ctrl.mcdbLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	usages, err := ctrl.getBudgetUsages(budgets)
+	if err != nil {
+		return err
+	}
+	for _, usage := range usages {
+		newStatus := usage.status()
+		if equality.Semantic.DeepEqual(usage.budget.Status, newStatus) {
+			continue
+		}
+		budget := usage.budget.DeepCopy()
+		budget.Status = newStatus
+		if _, err := ctrl.client.MachineconfigurationV1().MachineConfigDisruptionBudgets().UpdateStatus(context.TODO(), budget, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("updating status of disruption budget %s: %v", budget.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/generated/clientset/versioned/fake"
+	mcfglistersv1 "github.com/openshift/machine-config-operator/pkg/generated/listers/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func newDisruptionBudget(name string, nodeSelector *metav1.LabelSelector, maxUnavail *intstr.IntOrString, topologyKey string) *mcfgv1.MachineConfigDisruptionBudget {
+	return &mcfgv1.MachineConfigDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Generation: 1},
+		Spec: mcfgv1.MachineConfigDisruptionBudgetSpec{
+			NodeSelector:   nodeSelector,
+			MaxUnavailable: maxUnavail,
+			TopologyKey:    topologyKey,
+		},
+	}
+}
+
+// newBudgetController returns a controller whose listers hold the budgets and the nodes.
+func newBudgetController(budgets []*mcfgv1.MachineConfigDisruptionBudget, nodes []*corev1.Node) *Controller {
+	var objects []runtime.Object
+	budgetIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, budget := range budgets {
+		budgetIndexer.Add(budget)
+		objects = append(objects, budget)
+	}
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range nodes {
+		nodeIndexer.Add(node)
+	}
+	return &Controller{
+		client:              fake.NewSimpleClientset(objects...),
+		eventRecorder:       &record.FakeRecorder{},
+		mcdbLister:          mcfglistersv1.NewMachineConfigDisruptionBudgetLister(budgetIndexer),
+		nodeLister:          corelisterv1.NewNodeLister(nodeIndexer),
+		budgetReservations:  map[string]budgetReservation{},
+		budgetDeferredPools: sets.NewString(),
+	}
+}
+
+func TestNewBudgetUsage(t *testing.T) {
+	nodes := []*corev1.Node{
+		newNodeInZone("node-0", "v0", "v0", corev1.ConditionTrue, "a"),
+		newNodeInZone("node-1", "v0", "v1", corev1.ConditionTrue, "a"),
+		newNodeInZone("node-2", "v0", "v0", corev1.ConditionTrue, "b"),
+		newNodeInZone("node-3", "v0", "v0", corev1.ConditionFalse, "b"),
+		newNodeInZone("node-4", "v0", "v0", corev1.ConditionTrue, "b"),
+		newNodeInZone("node-5", "v0", "v0", corev1.ConditionTrue, ""),
+		// not managed by the MCD yet
+		newNodeInZone("node-6", "", "", corev1.ConditionFalse, "a"),
+	}
+
+	budget := newDisruptionBudget("zones", nil, intStrPtr(intstr.FromString("50%")), corev1.LabelTopologyZone)
+	usage, err := newBudgetUsage(budget, nodes, isNodeUnavailable)
+	require.Nil(t, err)
+	assert.Equal(t, mcfgv1.MachineConfigDisruptionBudgetStatus{
+		ObservedGeneration:      1,
+		MachineCount:            6,
+		UnavailableMachineCount: 2,
+		Domains: []mcfgv1.MachineConfigDisruptionBudgetDomainStatus{
+			{Name: "", MachineCount: 1, MaxUnavailable: 1},
+			{Name: "a", MachineCount: 2, MaxUnavailable: 1, UnavailableMachineCount: 1, UnavailableMachines: []string{"node-1"}},
+			{Name: "b", MachineCount: 3, MaxUnavailable: 1, UnavailableMachineCount: 1, UnavailableMachines: []string{"node-3"}},
+		},
+	}, usage.status())
+	assert.False(t, usage.allows(nodes[0]))
+	assert.True(t, usage.allows(nodes[5]))
+	usage.take(nodes[5])
+	assert.False(t, usage.allows(nodes[5]))
+
+	// Without a topology key, the budget applies to all the selected nodes together.
+	selector := metav1.AddLabelToSelector(&metav1.LabelSelector{}, corev1.LabelTopologyZone, "b")
+	budget = newDisruptionBudget("zone-b", selector, intStrPtr(intstr.FromInt(2)), "")
+	usage, err = newBudgetUsage(budget, nodes, isNodeUnavailable)
+	require.Nil(t, err)
+	assert.Equal(t, []mcfgv1.MachineConfigDisruptionBudgetDomainStatus{
+		{Name: "", MachineCount: 3, MaxUnavailable: 2, UnavailableMachineCount: 1, UnavailableMachines: []string{"node-3"}},
+	}, usage.status().Domains)
+	assert.True(t, usage.allows(nodes[2]))
+	// nodes which aren't selected aren't limited by the budget
+	assert.True(t, usage.allows(nodes[1]))
+}
+
+func TestFilterDisruptionBudgetCandidates(t *testing.T) {
+	nodes := []*corev1.Node{
+		newNodeInZone("node-0", "v0", "v1", corev1.ConditionTrue, "a"),
+		newNodeInZone("node-1", "v0", "v0", corev1.ConditionTrue, "a"),
+		newNodeInZone("node-2", "v0", "v0", corev1.ConditionTrue, "b"),
+		newNodeInZone("node-3", "v0", "v0", corev1.ConditionTrue, "b"),
+		newNodeInZone("node-4", "v0", "v0", corev1.ConditionTrue, "c"),
+	}
+	pool := newPoolWithRolloutStrategy("v1", nil)
+	pool.Name = "worker"
+	candidates := nodes[1:]
+
+	// Without budgets, the first candidates up to the capacity are picked.
+	ctrl := newBudgetController(nil, nodes)
+	picked, err := ctrl.filterDisruptionBudgetCandidates(pool, candidates, 2)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"node-1", "node-2"}, nodeNames(picked))
+
+	// One node per zone: zone a is busy updating node-0, one node of zone b and c are picked.
+	ctrl = newBudgetController([]*mcfgv1.MachineConfigDisruptionBudget{
+		newDisruptionBudget("zones", nil, nil, corev1.LabelTopologyZone),
+	}, nodes)
+	picked, err = ctrl.filterDisruptionBudgetCandidates(pool, candidates, 4)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"node-2", "node-4"}, nodeNames(picked))
+	assert.True(t, ctrl.budgetDeferredPools.Has(pool.Name))
+
+	// The picked nodes count against the budget until the lister reflects their desiredConfig.
+	picked, err = ctrl.filterDisruptionBudgetCandidates(pool, []*corev1.Node{nodes[3]}, 1)
+	require.Nil(t, err)
+	assert.Empty(t, picked)
+
+	// Cluster-wide budget, shared with other pools.
+	ctrl = newBudgetController([]*mcfgv1.MachineConfigDisruptionBudget{
+		newDisruptionBudget("zones", nil, intStrPtr(intstr.FromInt(2)), corev1.LabelTopologyZone),
+		newDisruptionBudget("cluster", nil, intStrPtr(intstr.FromInt(2)), ""),
+	}, nodes)
+	picked, err = ctrl.filterDisruptionBudgetCandidates(pool, candidates, 4)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"node-1"}, nodeNames(picked))
+}
+
+func TestControlPlaneBudget(t *testing.T) {
+	var masters []*corev1.Node
+	for _, node := range []*corev1.Node{
+		newNodeInZone("master-0", "v0", "v1", corev1.ConditionTrue, ""),
+		newNodeInZone("master-1", "v0", "v0", corev1.ConditionTrue, ""),
+		newNodeInZone("master-2", "v0", "v0", corev1.ConditionTrue, ""),
+	} {
+		node.Labels = map[string]string{"node-role/master": ""}
+		masters = append(masters, node)
+	}
+	masterPool := helpers.NewMachineConfigPool("master", nil, helpers.MasterSelector, "v1")
+	childPool := helpers.NewMachineConfigPool("master-nic", masterChildSelector, metav1.AddLabelToSelector(&metav1.LabelSelector{}, "node-role/master-nic", ""), "v1")
+	newController := func() *Controller {
+		ctrl := newBudgetController(nil, masters)
+		poolIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		poolIndexer.Add(masterPool)
+		ctrl.mcpLister = mcfglistersv1.NewMachineConfigPoolLister(poolIndexer)
+		return ctrl
+	}
+
+	// master-0 is updating in the master pool, the masters of the custom pool wait for it.
+	ctrl := newController()
+	picked, err := ctrl.filterDisruptionBudgetCandidates(childPool, masters[1:], 1)
+	require.Nil(t, err)
+	assert.Empty(t, picked)
+	assert.True(t, ctrl.budgetDeferredPools.Has(childPool.Name))
+
+	// The budget follows the maxUnavailable of the master pool.
+	masterPool.Spec.MaxUnavailable = intStrPtr(intstr.FromInt(2))
+	ctrl = newController()
+	picked, err = ctrl.filterDisruptionBudgetCandidates(childPool, masters[1:], 2)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"master-1"}, nodeNames(picked))
+
+	// Other pools don't use the control plane budget.
+	workerPool := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v1")
+	picked, err = ctrl.filterDisruptionBudgetCandidates(workerPool, masters[2:], 1)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"master-2"}, nodeNames(picked))
+}
+
+func TestBudgetReservations(t *testing.T) {
+	node := newNodeInZone("node-0", "v0", "v0", corev1.ConditionTrue, "a")
+	ctrl := newBudgetController(nil, nil)
+	ctrl.budgetReservations[node.Name] = budgetReservation{config: "v1", at: time.Now()}
+	assert.True(t, ctrl.isNodeBudgetUnavailable(node))
+
+	// Once the lister reflects the desiredConfig, the state of the node is used.
+	node.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey] = "v1"
+	assert.True(t, ctrl.isNodeBudgetUnavailable(node))
+	assert.NotContains(t, ctrl.budgetReservations, node.Name)
+	node.Annotations[daemonconsts.CurrentMachineConfigAnnotationKey] = "v1"
+	assert.False(t, ctrl.isNodeBudgetUnavailable(node))
+
+	// Stale reservations expire.
+	ctrl.budgetReservations[node.Name] = budgetReservation{config: "v2", at: time.Now().Add(-budgetReservationTimeout)}
+	assert.False(t, ctrl.isNodeBudgetUnavailable(node))
+	assert.NotContains(t, ctrl.budgetReservations, node.Name)
+}
+
+func TestSyncDisruptionBudgetStatus(t *testing.T) {
+	nodes := []*corev1.Node{
+		newNodeInZone("node-0", "v0", "v1", corev1.ConditionTrue, "a"),
+		newNodeInZone("node-1", "v0", "v0", corev1.ConditionTrue, "a"),
+	}
+	budget := newDisruptionBudget("cluster", nil, nil, "")
+	ctrl := newBudgetController([]*mcfgv1.MachineConfigDisruptionBudget{budget}, nodes)
+	require.Nil(t, ctrl.syncDisruptionBudgetStatus())
+
+	updated, err := ctrl.client.MachineconfigurationV1().MachineConfigDisruptionBudgets().Get(context.TODO(), budget.Name, metav1.GetOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, mcfgv1.MachineConfigDisruptionBudgetStatus{
+		ObservedGeneration:      1,
+		MachineCount:            2,
+		UnavailableMachineCount: 1,
+		Domains: []mcfgv1.MachineConfigDisruptionBudgetDomainStatus{
+			{Name: "", MachineCount: 2, MaxUnavailable: 1, UnavailableMachineCount: 1, UnavailableMachines: []string{"node-0"}},
+		},
+	}, updated.Status)
+}
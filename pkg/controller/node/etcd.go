@@ -0,0 +1,167 @@
+package node
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// etcdClientPort is the port the etcd members listen on for clients, on the control plane nodes.
+	etcdClientPort = 2379
+
+	// etcdCertsNamespace holds the etcd client certificate and serving CA used by the apiservers.
+	etcdCertsNamespace         = "openshift-config"
+	etcdClientSecretName       = "etcd-client"
+	etcdServingCAConfigMapName = "etcd-serving-ca"
+	etcdServingCAKey           = "ca-bundle.crt"
+
+	// etcdStatusTimeout bounds the status requests to the etcd members.
+	etcdStatusTimeout = 5 * time.Second
+)
+
+// etcdMemberID is the ID of an etcd member. The etcd gRPC gateway encodes it as a string.
+type etcdMemberID string
+
+func (id *etcdMemberID) UnmarshalJSON(data []byte) error {
+	*id = etcdMemberID(strings.Trim(string(data), `"`))
+	return nil
+}
+
+// etcdStatus is the part of the status of an etcd member used to find the leader,
+// as returned by /v3/maintenance/status on the etcd gRPC gateway.
+type etcdStatus struct {
+	Header struct {
+		MemberID etcdMemberID `json:"member_id"`
+	} `json:"header"`
+	Leader etcdMemberID `json:"leader"`
+}
+
+// etcdMemberURL returns the client URL of the etcd member running on the control plane node.
+func etcdMemberURL(node *corev1.Node) (string, error) {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			return "https://" + net.JoinHostPort(addr.Address, strconv.Itoa(etcdClientPort)), nil
+		}
+	}
+	return "", fmt.Errorf("node %s has no internal IP", node.Name)
+}
+
+// getEtcdHTTPClient returns an HTTP client authenticating to the etcd members with the etcd
+// client certificate of the cluster. The client is reused until the certificates change.
+func (ctrl *Controller) getEtcdHTTPClient() (*http.Client, error) {
+	secret, err := ctrl.secretLister.Secrets(etcdCertsNamespace).Get(etcdClientSecretName)
+	if err != nil {
+		return nil, fmt.Errorf("getting the etcd client certificate: %v", err)
+	}
+	cm, err := ctrl.configMapLister.ConfigMaps(etcdCertsNamespace).Get(etcdServingCAConfigMapName)
+	if err != nil {
+		return nil, fmt.Errorf("getting the etcd serving CA: %v", err)
+	}
+
+	ctrl.etcdClientLock.Lock()
+	defer ctrl.etcdClientLock.Unlock()
+	version := secret.ResourceVersion + "/" + cm.ResourceVersion
+	if ctrl.etcdClient != nil && ctrl.etcdClientVersion == version {
+		return ctrl.etcdClient, nil
+	}
+	client, err := newEtcdHTTPClient(secret, cm)
+	if err != nil {
+		return nil, err
+	}
+	ctrl.etcdClient = client
+	ctrl.etcdClientVersion = version
+	return client, nil
+}
+
+// newEtcdHTTPClient returns an HTTP client authenticating with the client certificate of
+// the secret, and trusting the etcd serving CA of the config map.
+func newEtcdHTTPClient(secret *corev1.Secret, cm *corev1.ConfigMap) (*http.Client, error) {
+	cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid etcd client certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(cm.Data[etcdServingCAKey])) {
+		return nil, fmt.Errorf("no certificate in %s/%s", etcdCertsNamespace, etcdServingCAConfigMapName)
+	}
+	return &http.Client{
+		Timeout: etcdStatusTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				RootCAs:      roots,
+			},
+		},
+	}, nil
+}
+
+// getEtcdStatus returns the status of the etcd member at url.
+func getEtcdStatus(client *http.Client, url string) (*etcdStatus, error) {
+	resp, err := client.Post(url+"/v3/maintenance/status", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting the status of etcd member %s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var status etcdStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("invalid status of etcd member %s: %v", url, err)
+	}
+	return &status, nil
+}
+
+// getCurrentEtcdLeader returns the candidate running the etcd leader, or nil if the leader runs
+// on another node. It asks the etcd member of each candidate for its status until it finds the leader.
+func (ctrl *Controller) getCurrentEtcdLeader(candidates []*corev1.Node) (*corev1.Node, error) {
+	client, err := ctrl.getEtcdHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	var leader etcdMemberID
+	for _, node := range candidates {
+		url, err := ctrl.etcdMemberURL(node)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		status, err := getEtcdStatus(client, url)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if status.Leader == "" || status.Leader == "0" {
+			errs = append(errs, fmt.Errorf("etcd member on node %s has no leader", node.Name))
+			continue
+		}
+		if status.Header.MemberID == status.Leader {
+			return node, nil
+		}
+		leader = status.Leader
+	}
+	// The leader could be one of the candidates whose member didn't answer.
+	if len(errs) > 0 {
+		return nil, v1helpers.NewMultiLineAggregate(errs)
+	}
+	if leader == "" {
+		return nil, fmt.Errorf("no etcd leader found")
+	}
+	return nil, nil
+}
@@ -0,0 +1,218 @@
+package node
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// fakeEtcdCluster serves the status of etcd members like the etcd gRPC gateway,
+// requiring client certificates signed by its CA.
+type fakeEtcdCluster struct {
+	t       *testing.T
+	ca      *crypto.CA
+	leader  string
+	members map[string]*httptest.Server
+}
+
+func newFakeEtcdCluster(t *testing.T) *fakeEtcdCluster {
+	caConfig, err := crypto.MakeSelfSignedCAConfigForDuration("etcd-signer", time.Hour)
+	require.Nil(t, err)
+	return &fakeEtcdCluster{
+		t:       t,
+		ca:      &crypto.CA{Config: caConfig, SerialGenerator: &crypto.RandomSerialGenerator{}},
+		members: map[string]*httptest.Server{},
+	}
+}
+
+// addMember starts the etcd member with the ID on the node.
+func (c *fakeEtcdCluster) addMember(node, id string) {
+	serverCert, err := c.ca.MakeServerCertForDuration(sets.NewString("127.0.0.1"), time.Hour)
+	require.Nil(c.t, err)
+	certPEM, keyPEM, err := serverCert.GetPEMBytes()
+	require.Nil(c.t, err)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.Nil(c.t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(c.ca.Config.Certs[0])
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3/maintenance/status" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"header":{"cluster_id":"1","member_id":"%s","revision":"10","raft_term":"3"},"version":"3.4.14","dbSize":"4096","leader":"%s"}`, id, c.leader)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	c.t.Cleanup(server.Close)
+	c.members[node] = server
+}
+
+// etcdClientUser is the subject of the etcd client certificate.
+type etcdClientUser struct{}
+
+func (etcdClientUser) GetName() string               { return "etcd" }
+func (etcdClientUser) GetUID() string                { return "" }
+func (etcdClientUser) GetGroups() []string           { return nil }
+func (etcdClientUser) GetExtra() map[string][]string { return nil }
+
+// certs returns the etcd client certificate secret and serving CA config map of the cluster,
+// whose resource version is specific to the cluster.
+func (c *fakeEtcdCluster) certs() (*corev1.Secret, *corev1.ConfigMap) {
+	clientCert, err := c.ca.MakeClientCertificateForDuration(etcdClientUser{}, time.Hour)
+	require.Nil(c.t, err)
+	certPEM, keyPEM, err := clientCert.GetPEMBytes()
+	require.Nil(c.t, err)
+	caPEM, err := crypto.EncodeCertificates(c.ca.Config.Certs...)
+	require.Nil(c.t, err)
+	version := fmt.Sprintf("%x", sha256.Sum256(caPEM))
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: etcdClientSecretName, Namespace: etcdCertsNamespace, ResourceVersion: version},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: etcdServingCAConfigMapName, Namespace: etcdCertsNamespace, ResourceVersion: version},
+		Data:       map[string]string{etcdServingCAKey: string(caPEM)},
+	}
+	return secret, cm
+}
+
+// listers returns the listers of the etcd certificates of the cluster.
+func (c *fakeEtcdCluster) listers() (corelisterv1.SecretLister, corelisterv1.ConfigMapLister) {
+	secret, cm := c.certs()
+	secrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.Nil(c.t, secrets.Add(secret))
+	configMaps := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.Nil(c.t, configMaps.Add(cm))
+	return corelisterv1.NewSecretLister(secrets), corelisterv1.NewConfigMapLister(configMaps)
+}
+
+func (c *fakeEtcdCluster) newController() *Controller {
+	secretLister, configMapLister := c.listers()
+	return &Controller{
+		secretLister:    secretLister,
+		configMapLister: configMapLister,
+		eventRecorder:   &record.FakeRecorder{},
+		etcdMemberURL: func(node *corev1.Node) (string, error) {
+			if member, ok := c.members[node.Name]; ok {
+				return member.URL, nil
+			}
+			return "", fmt.Errorf("no etcd member on node %s", node.Name)
+		},
+	}
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	var m dto.Metric
+	require.Nil(t, c.Write(&m))
+	return m.GetCounter().GetValue()
+}
+
+func TestEtcdMemberURL(t *testing.T) {
+	node := newNode("master-0", "v0", "v0")
+	_, err := etcdMemberURL(node)
+	assert.NotNil(t, err)
+
+	node.Status.Addresses = []corev1.NodeAddress{
+		{Type: corev1.NodeHostName, Address: "master-0"},
+		{Type: corev1.NodeInternalIP, Address: "fd00::10"},
+	}
+	url, err := etcdMemberURL(node)
+	require.Nil(t, err)
+	assert.Equal(t, "https://[fd00::10]:2379", url)
+}
+
+func TestGetCurrentEtcdLeader(t *testing.T) {
+	etcd := newFakeEtcdCluster(t)
+	etcd.addMember("master-0", "100")
+	etcd.addMember("master-1", "101")
+	etcd.addMember("master-2", "102")
+	etcd.leader = "101"
+	masters := []*corev1.Node{newNode("master-0", "v0", "v0"), newNode("master-1", "v0", "v0"), newNode("master-2", "v0", "v0")}
+	ctrl := etcd.newController()
+
+	leader, err := ctrl.getCurrentEtcdLeader(masters)
+	require.Nil(t, err)
+	assert.Equal(t, masters[1], leader)
+
+	// The leader isn't a candidate.
+	leader, err = ctrl.getCurrentEtcdLeader([]*corev1.Node{masters[0], masters[2]})
+	require.Nil(t, err)
+	assert.Nil(t, leader)
+
+	// A candidate whose member doesn't answer could be the leader.
+	leader, err = ctrl.getCurrentEtcdLeader([]*corev1.Node{masters[0], newNode("master-3", "v0", "v0")})
+	assert.NotNil(t, err)
+	assert.Nil(t, leader)
+
+	// The client is reused until the certificates change, and the members
+	// reject clients without a certificate of the cluster.
+	client, err := ctrl.getEtcdHTTPClient()
+	require.Nil(t, err)
+	cached, err := ctrl.getEtcdHTTPClient()
+	require.Nil(t, err)
+	assert.True(t, client == cached)
+	other := newFakeEtcdCluster(t)
+	ctrl.secretLister, ctrl.configMapLister = other.listers()
+	_, err = ctrl.getCurrentEtcdLeader(masters)
+	assert.NotNil(t, err)
+	assert.False(t, client == ctrl.etcdClient)
+}
+
+func TestFilterControlPlaneCandidateNodes(t *testing.T) {
+	etcd := newFakeEtcdCluster(t)
+	etcd.addMember("master-0", "100")
+	etcd.addMember("master-1", "101")
+	etcd.addMember("master-2", "102")
+	etcd.leader = "100"
+	masters := []*corev1.Node{newNode("master-0", "v0", "v0"), newNode("master-1", "v0", "v0"), newNode("master-2", "v0", "v0")}
+	pool := &mcfgv1.MachineConfigPool{ObjectMeta: metav1.ObjectMeta{Name: masterPoolName}}
+	ctrl := etcd.newController()
+
+	deferred := counterValue(t, ctrlcommon.MCCEtcdLeaderDecisions.WithLabelValues("deferred"))
+	candidates, capacity, err := ctrl.filterControlPlaneCandidateNodes(pool, masters, 1)
+	require.Nil(t, err)
+	assert.Equal(t, uint(1), capacity)
+	assert.Equal(t, []string{"master-1", "master-2"}, nodeNames(candidates))
+	assert.Equal(t, deferred+1, counterValue(t, ctrlcommon.MCCEtcdLeaderDecisions.WithLabelValues("deferred")))
+
+	// The leader is updated last.
+	candidates, _, err = ctrl.filterControlPlaneCandidateNodes(pool, masters[:1], 1)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"master-0"}, nodeNames(candidates))
+
+	// The update goes on when the leader can't be found.
+	unknown := counterValue(t, ctrlcommon.MCCEtcdLeaderDecisions.WithLabelValues("unknown"))
+	etcd.leader = "0"
+	candidates, _, err = ctrl.filterControlPlaneCandidateNodes(pool, masters, 1)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"master-0", "master-1", "master-2"}, nodeNames(candidates))
+	assert.Equal(t, unknown+1, counterValue(t, ctrlcommon.MCCEtcdLeaderDecisions.WithLabelValues("unknown")))
+}
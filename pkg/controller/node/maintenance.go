@@ -0,0 +1,226 @@
+package node
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxCronSearch bounds the search for the next time matching a cron schedule,
+// so that schedules which never match (e.g. "0 0 31 2 *") don't loop forever.
+const maxCronSearch = 5 * 365 * 24 * time.Hour
+
+// cronField is the set of values matched by a field of a cron schedule, one bit per value.
+type cronField uint64
+
+func (f cronField) has(v int) bool {
+	return f&(1<<uint(v)) != 0
+}
+
+// cronSchedule is a parsed cron expression.
+type cronSchedule struct {
+	minute, hour, dom, month, dow cronField
+	// domAny and dowAny are set when the day of month or day of week field is unrestricted.
+	domAny, dowAny bool
+}
+
+var cronFieldBounds = [5]struct{ min, max int }{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, both 0 and 7 are Sunday
+}
+
+// parseCronSchedule parses a standard five fields cron expression. Each field is a comma
+// separated list of "*", values or "a-b" ranges, optionally followed by a "/step".
+func parseCronSchedule(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFieldBounds) {
+		return nil, fmt.Errorf("schedule %q: expected %d fields, got %d", spec, len(cronFieldBounds), len(fields))
+	}
+	var parsed [5]cronField
+	for i, field := range fields {
+		f, err := parseCronField(field, cronFieldBounds[i].min, cronFieldBounds[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %v", spec, err)
+		}
+		parsed[i] = f
+	}
+	if parsed[4].has(7) {
+		parsed[4] |= 1
+	}
+	return &cronSchedule{
+		minute: parsed[0],
+		hour:   parsed[1],
+		dom:    parsed[2],
+		month:  parsed[3],
+		dow:    parsed[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (cronField, error) {
+	var f cronField
+	for _, part := range strings.Split(field, ",") {
+		values, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			values, step = part[:i], s
+		}
+
+		lo, hi := min, max
+		if values != "*" {
+			bounds := strings.SplitN(values, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", part)
+			}
+			switch {
+			case len(bounds) == 2:
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value in %q", part)
+				}
+			case step == 1:
+				hi = lo
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dom, dow := s.dom.has(t.Day()), s.dow.has(int(t.Weekday()))
+	// Like cron, match either day field when both are restricted.
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first minute at or after t matching the schedule, in the location of t.
+// It returns false if there is none within maxCronSearch.
+func (s *cronSchedule) next(t time.Time) (time.Time, bool) {
+	if rounded := t.Truncate(time.Minute); !rounded.Equal(t) {
+		t = rounded.Add(time.Minute)
+	}
+	limit := t.Add(maxCronSearch)
+	for t.Before(limit) {
+		switch {
+		case !s.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// getMaintenanceWindowState returns whether a window of the schedule is open at now. When one is,
+// it also returns when it closes, otherwise when the next window opens. The returned time is zero
+// if no window ever opens.
+func getMaintenanceWindowState(schedule *mcfgv1.MachineConfigPoolMaintenanceSchedule, now time.Time) (bool, time.Time, error) {
+	loc, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid time zone %q: %v", schedule.TimeZone, err)
+	}
+	now = now.In(loc)
+
+	var open bool
+	var closes, opens time.Time
+	for _, window := range schedule.Windows {
+		cron, err := parseCronSchedule(window.Schedule)
+		if err != nil {
+			return false, time.Time{}, err
+		}
+		if window.Duration.Duration <= 0 {
+			return false, time.Time{}, fmt.Errorf("schedule %q: duration must be positive", window.Schedule)
+		}
+		// A window is open if it opened after now - duration.
+		if start, ok := cron.next(now.Add(-window.Duration.Duration).Add(time.Nanosecond)); ok && !start.After(now) {
+			open = true
+			if end := start.Add(window.Duration.Duration); end.After(closes) {
+				closes = end
+			}
+		}
+		if start, ok := cron.next(now); ok && (opens.IsZero() || start.Before(opens)) {
+			opens = start
+		}
+	}
+	if open {
+		return true, closes, nil
+	}
+	return false, opens, nil
+}
+
+// waitForMaintenanceWindow returns whether the pool must wait for a maintenance window before
+// starting to update more nodes, and requeues the pool for when the window opens or closes.
+func (ctrl *Controller) waitForMaintenanceWindow(pool *mcfgv1.MachineConfigPool) bool {
+	schedule := pool.Spec.MaintenanceSchedule
+	if schedule == nil {
+		return false
+	}
+	open, at, err := getMaintenanceWindowState(schedule, time.Now())
+	if err != nil {
+		ctrl.logPool(pool, "Not updating nodes, invalid maintenance schedule: %v", err)
+		return true
+	}
+	if !at.IsZero() {
+		ctrl.enqueueAfter(pool, time.Until(at))
+	}
+	if !open {
+		glog.V(4).Infof("Pool %s: waiting for the maintenance window opening at %v", pool.Name, at)
+	}
+	return !open
+}
+
+// setMaintenanceWindowStatus sets the next maintenance window and the WaitingForMaintenanceWindow
+// condition of the pool status.
+func setMaintenanceWindowStatus(pool *mcfgv1.MachineConfigPool, status *mcfgv1.MachineConfigPoolStatus, allUpdated bool, now time.Time) {
+	schedule := pool.Spec.MaintenanceSchedule
+	if schedule == nil {
+		mcfgv1.RemoveMachineConfigPoolCondition(status, mcfgv1.MachineConfigPoolWaitingForMaintenanceWindow)
+		return
+	}
+
+	open, at, err := getMaintenanceWindowState(schedule, now)
+	var swaiting *mcfgv1.MachineConfigPoolCondition
+	switch {
+	case err != nil:
+		swaiting = mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolWaitingForMaintenanceWindow, corev1.ConditionTrue, "InvalidMaintenanceSchedule", err.Error())
+	case open:
+		swaiting = mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolWaitingForMaintenanceWindow, corev1.ConditionFalse, "MaintenanceWindowOpen", "")
+	default:
+		if !at.IsZero() {
+			status.NextMaintenanceWindow = &metav1.Time{Time: at}
+		}
+		if allUpdated {
+			swaiting = mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolWaitingForMaintenanceWindow, corev1.ConditionFalse, "MaintenanceWindowClosed", "")
+		} else {
+			swaiting = mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolWaitingForMaintenanceWindow, corev1.ConditionTrue, "MaintenanceWindowClosed", fmt.Sprintf("Nodes will start updating to %s in the next maintenance window", pool.Spec.Configuration.Name))
+		}
+	}
+	mcfgv1.SetMachineConfigPoolCondition(status, *swaiting)
+}
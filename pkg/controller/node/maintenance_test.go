@@ -0,0 +1,189 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// Wednesday
+	from := time.Date(2021, time.March, 10, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		schedule string
+		expected time.Time
+		never    bool
+	}{{
+		schedule: "* * * * *",
+		expected: time.Date(2021, time.March, 10, 10, 31, 0, 0, time.UTC),
+	}, {
+		schedule: "0 22 * * *",
+		expected: time.Date(2021, time.March, 10, 22, 0, 0, 0, time.UTC),
+	}, {
+		schedule: "0 2 * * *",
+		expected: time.Date(2021, time.March, 11, 2, 0, 0, 0, time.UTC),
+	}, {
+		schedule: "*/20 10-12 * * *",
+		expected: time.Date(2021, time.March, 10, 10, 40, 0, 0, time.UTC),
+	}, {
+		schedule: "0 1 * * 6,7",
+		expected: time.Date(2021, time.March, 13, 1, 0, 0, 0, time.UTC),
+	}, {
+		schedule: "0 1 * * 0",
+		expected: time.Date(2021, time.March, 14, 1, 0, 0, 0, time.UTC),
+	}, {
+		// either day field matches when both are set
+		schedule: "0 1 15 * 5",
+		expected: time.Date(2021, time.March, 12, 1, 0, 0, 0, time.UTC),
+	}, {
+		schedule: "30 3 1 */6 *",
+		expected: time.Date(2021, time.July, 1, 3, 30, 0, 0, time.UTC),
+	}, {
+		schedule: "0 0 31 2 *",
+		never:    true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.schedule, func(t *testing.T) {
+			cron, err := parseCronSchedule(test.schedule)
+			require.Nil(t, err)
+			next, ok := cron.next(from)
+			assert.Equal(t, !test.never, ok)
+			assert.Equal(t, test.expected, next)
+		})
+	}
+}
+
+func TestParseCronScheduleInvalid(t *testing.T) {
+	for _, schedule := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := parseCronSchedule(schedule)
+		assert.NotNil(t, err, "schedule %q", schedule)
+	}
+}
+
+func TestGetMaintenanceWindowState(t *testing.T) {
+	schedule := &mcfgv1.MachineConfigPoolMaintenanceSchedule{
+		TimeZone: "America/New_York",
+		Windows: []mcfgv1.MaintenanceWindow{
+			{Schedule: "0 22 * * 1-5", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+			{Schedule: "0 8 * * 6", Duration: metav1.Duration{Duration: 24 * time.Hour}},
+		},
+	}
+	loc, err := time.LoadLocation(schedule.TimeZone)
+	require.Nil(t, err)
+
+	tests := []struct {
+		now      time.Time
+		open     bool
+		expected time.Time
+	}{{
+		// Wednesday afternoon
+		now:      time.Date(2021, time.February, 10, 15, 0, 0, 0, loc),
+		expected: time.Date(2021, time.February, 10, 22, 0, 0, 0, loc),
+	}, {
+		now:      time.Date(2021, time.February, 10, 23, 0, 0, 0, loc),
+		open:     true,
+		expected: time.Date(2021, time.February, 11, 2, 0, 0, 0, loc),
+	}, {
+		// the window closes at 2AM
+		now:      time.Date(2021, time.February, 11, 2, 0, 0, 0, loc),
+		expected: time.Date(2021, time.February, 11, 22, 0, 0, 0, loc),
+	}, {
+		// Friday night windows opens after Saturday's
+		now:      time.Date(2021, time.February, 13, 12, 0, 0, 0, loc),
+		open:     true,
+		expected: time.Date(2021, time.February, 14, 8, 0, 0, 0, loc),
+	}, {
+		now:      time.Date(2021, time.February, 14, 12, 0, 0, 0, loc),
+		expected: time.Date(2021, time.February, 15, 22, 0, 0, 0, loc),
+	}}
+
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("case#%d", idx), func(t *testing.T) {
+			open, at, err := getMaintenanceWindowState(schedule, test.now.UTC())
+			require.Nil(t, err)
+			assert.Equal(t, test.open, open)
+			assert.True(t, test.expected.Equal(at), "expected %v, got %v", test.expected, at)
+		})
+	}
+
+	_, _, err = getMaintenanceWindowState(&mcfgv1.MachineConfigPoolMaintenanceSchedule{TimeZone: "Nowhere/Atlantis"}, time.Now())
+	assert.NotNil(t, err)
+}
+
+func TestSetMaintenanceWindowStatus(t *testing.T) {
+	now := time.Date(2021, time.March, 10, 15, 0, 0, 0, time.UTC)
+	pool := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v1")
+	pool.Spec.MaintenanceSchedule = &mcfgv1.MachineConfigPoolMaintenanceSchedule{
+		Windows: []mcfgv1.MaintenanceWindow{{Schedule: "0 22 * * *", Duration: metav1.Duration{Duration: time.Hour}}},
+	}
+
+	status := mcfgv1.MachineConfigPoolStatus{}
+	setMaintenanceWindowStatus(pool, &status, false, now)
+	require.NotNil(t, status.NextMaintenanceWindow)
+	assert.True(t, status.NextMaintenanceWindow.Time.Equal(time.Date(2021, time.March, 10, 22, 0, 0, 0, time.UTC)))
+	assert.True(t, mcfgv1.IsMachineConfigPoolConditionTrue(status.Conditions, mcfgv1.MachineConfigPoolWaitingForMaintenanceWindow))
+
+	// nothing to wait for
+	status = mcfgv1.MachineConfigPoolStatus{}
+	setMaintenanceWindowStatus(pool, &status, true, now)
+	assert.NotNil(t, status.NextMaintenanceWindow)
+	assert.True(t, mcfgv1.IsMachineConfigPoolConditionFalse(status.Conditions, mcfgv1.MachineConfigPoolWaitingForMaintenanceWindow))
+
+	status = mcfgv1.MachineConfigPoolStatus{}
+	setMaintenanceWindowStatus(pool, &status, false, now.Add(7*time.Hour+30*time.Minute))
+	assert.Nil(t, status.NextMaintenanceWindow)
+	assert.True(t, mcfgv1.IsMachineConfigPoolConditionFalse(status.Conditions, mcfgv1.MachineConfigPoolWaitingForMaintenanceWindow))
+
+	pool.Spec.MaintenanceSchedule = nil
+	setMaintenanceWindowStatus(pool, &status, false, now)
+	assert.Nil(t, mcfgv1.GetMachineConfigPoolCondition(status, mcfgv1.MachineConfigPoolWaitingForMaintenanceWindow))
+}
+
+func TestShouldWaitForMaintenanceWindow(t *testing.T) {
+	f := newFixture(t)
+	cc := newControllerConfig(ctrlcommon.ControllerConfigName, configv1.TopologyMode(""))
+	mcp := helpers.NewMachineConfigPool("test-cluster-infra", nil, helpers.InfraSelector, "v1")
+	mcpWorker := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v1")
+	mcp.Spec.MaxUnavailable = intStrPtr(intstr.FromInt(1))
+	// a window which opens in 12 hours
+	mcp.Spec.MaintenanceSchedule = &mcfgv1.MachineConfigPoolMaintenanceSchedule{
+		Windows: []mcfgv1.MaintenanceWindow{{
+			Schedule: fmt.Sprintf("0 %d * * *", (time.Now().UTC().Hour()+12)%24),
+			Duration: metav1.Duration{Duration: time.Hour},
+		}},
+	}
+
+	nodes := []*corev1.Node{
+		newNodeWithLabel("node-0", "v1", "v1", map[string]string{"node-role/worker": "", "node-role/infra": ""}),
+		newNodeWithLabel("node-1", "v0", "v0", map[string]string{"node-role/worker": "", "node-role/infra": ""}),
+	}
+
+	f.ccLister = append(f.ccLister, cc)
+	f.mcpLister = append(f.mcpLister, mcp, mcpWorker)
+	f.objects = append(f.objects, mcp, mcpWorker)
+	f.nodeLister = append(f.nodeLister, nodes...)
+	for idx := range nodes {
+		f.kubeobjects = append(f.kubeobjects, nodes[idx])
+	}
+
+	// no node is patched
+	expStatus := calculateStatus(mcp, nodes)
+	expMcp := mcp.DeepCopy()
+	expMcp.Status = expStatus
+	f.expectUpdateMachineConfigPoolStatus(expMcp)
+
+	f.run(getKey(mcp, t))
+	assert.NotNil(t, expStatus.NextMaintenanceWindow)
+	assert.True(t, mcfgv1.IsMachineConfigPoolConditionTrue(expStatus.Conditions, mcfgv1.MachineConfigPoolWaitingForMaintenanceWindow))
+}
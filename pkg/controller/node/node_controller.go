@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -28,6 +30,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/util/wait"
 	coreinformersv1 "k8s.io/client-go/informers/core/v1"
@@ -82,24 +85,48 @@ type Controller struct {
 
 	ccLister   mcfglistersv1.ControllerConfigLister
 	mcpLister  mcfglistersv1.MachineConfigPoolLister
+	mcdbLister mcfglistersv1.MachineConfigDisruptionBudgetLister
 	nodeLister corelisterv1.NodeLister
 
 	ccListerSynced   cache.InformerSynced
 	mcpListerSynced  cache.InformerSynced
+	mcdbListerSynced cache.InformerSynced
 	nodeListerSynced cache.InformerSynced
 
 	schedulerList         cligolistersv1.SchedulerLister
 	schedulerListerSynced cache.InformerSynced
 
+	secretLister          corelisterv1.SecretLister
+	configMapLister       corelisterv1.ConfigMapLister
+	secretListerSynced    cache.InformerSynced
+	configMapListerSynced cache.InformerSynced
+
 	queue workqueue.RateLimitingInterface
+
+	// budgetLock serializes the pools picking nodes against the disruption budgets, which span pools.
+	budgetLock sync.Mutex
+	// budgetReservations are the nodes whose desiredConfig was set, until the node lister reflects it.
+	budgetReservations map[string]budgetReservation
+	// budgetDeferredPools are the pools with nodes deferred by a disruption budget.
+	budgetDeferredPools sets.String
+
+	// etcdMemberURL returns the client URL of the etcd member of a control plane node.
+	etcdMemberURL func(*corev1.Node) (string, error)
+	// etcdClient is the client built from the etcd certificates at etcdClientVersion.
+	etcdClient        *http.Client
+	etcdClientVersion string
+	etcdClientLock    sync.Mutex
 }
 
 // New returns a new node controller.
 func New(
 	ccInformer mcfginformersv1.ControllerConfigInformer,
 	mcpInformer mcfginformersv1.MachineConfigPoolInformer,
+	mcdbInformer mcfginformersv1.MachineConfigDisruptionBudgetInformer,
 	nodeInformer coreinformersv1.NodeInformer,
 	schedulerInformer cligoinformersv1.SchedulerInformer,
+	secretsInformer coreinformersv1.SecretInformer,
+	configMapsInformer coreinformersv1.ConfigMapInformer,
 	kubeClient clientset.Interface,
 	mcfgClient mcfgclientset.Interface,
 ) *Controller {
@@ -112,6 +139,11 @@ func New(
 		kubeClient:    kubeClient,
 		eventRecorder: eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "machineconfigcontroller-nodecontroller"}),
 		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "machineconfigcontroller-nodecontroller"),
+
+		budgetReservations:  map[string]budgetReservation{},
+		budgetDeferredPools: sets.NewString(),
+
+		etcdMemberURL: etcdMemberURL,
 	}
 
 	mcpInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -119,6 +151,11 @@ func New(
 		UpdateFunc: ctrl.updateMachineConfigPool,
 		DeleteFunc: ctrl.deleteMachineConfigPool,
 	})
+	mcdbInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctrl.addMachineConfigDisruptionBudget,
+		UpdateFunc: ctrl.updateMachineConfigDisruptionBudget,
+		DeleteFunc: ctrl.deleteMachineConfigDisruptionBudget,
+	})
 	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    ctrl.addNode,
 		UpdateFunc: ctrl.updateNode,
@@ -134,14 +171,21 @@ func New(
 
 	ctrl.ccLister = ccInformer.Lister()
 	ctrl.mcpLister = mcpInformer.Lister()
+	ctrl.mcdbLister = mcdbInformer.Lister()
 	ctrl.nodeLister = nodeInformer.Lister()
 	ctrl.ccListerSynced = ccInformer.Informer().HasSynced
 	ctrl.mcpListerSynced = mcpInformer.Informer().HasSynced
+	ctrl.mcdbListerSynced = mcdbInformer.Informer().HasSynced
 	ctrl.nodeListerSynced = nodeInformer.Informer().HasSynced
 
 	ctrl.schedulerList = schedulerInformer.Lister()
 	ctrl.schedulerListerSynced = schedulerInformer.Informer().HasSynced
 
+	ctrl.secretLister = secretsInformer.Lister()
+	ctrl.configMapLister = configMapsInformer.Lister()
+	ctrl.secretListerSynced = secretsInformer.Informer().HasSynced
+	ctrl.configMapListerSynced = configMapsInformer.Informer().HasSynced
+
 	return ctrl
 }
 
@@ -150,7 +194,7 @@ func (ctrl *Controller) Run(workers int, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer ctrl.queue.ShutDown()
 
-	if !cache.WaitForCacheSync(stopCh, ctrl.ccListerSynced, ctrl.mcpListerSynced, ctrl.nodeListerSynced, ctrl.schedulerListerSynced) {
+	if !cache.WaitForCacheSync(stopCh, ctrl.ccListerSynced, ctrl.mcpListerSynced, ctrl.mcdbListerSynced, ctrl.nodeListerSynced, ctrl.schedulerListerSynced, ctrl.secretListerSynced, ctrl.configMapListerSynced) {
 		return
 	}
 
@@ -479,7 +523,7 @@ func (ctrl *Controller) updateNode(old, cur interface{}) {
 				ctrl.logPoolNode(pool, curNode, "changed annotation %s = %s", anno, newValue)
 				changed = true
 				// For the control plane, emit events for these since they're important
-				if pool.Name == masterPoolName {
+				if ctrlcommon.IsControlPlanePool(pool) {
 					ctrl.eventRecorder.Eventf(pool, corev1.EventTypeNormal, "AnnotationChange", "Node %s now has %s=%s", curNode.Name, anno, newValue)
 				}
 			}
@@ -493,6 +537,7 @@ func (ctrl *Controller) updateNode(old, cur interface{}) {
 	if !changed {
 		return
 	}
+	ctrl.enqueueBudgetDeferredPools()
 
 	pools, err := ctrl.getPoolsForNode(curNode)
 	if err != nil {
@@ -535,6 +580,7 @@ func (ctrl *Controller) deleteNode(obj interface{}) {
 	for _, pool := range pools {
 		ctrl.enqueueMachineConfigPool(pool)
 	}
+	ctrl.enqueueBudgetDeferredPools()
 }
 
 // getPoolsForNode chooses the MachineConfigPools that should be used for a given node.
@@ -588,9 +634,16 @@ func (ctrl *Controller) getPoolsForNode(node *corev1.Node) ([]*mcfgv1.MachineCon
 	if len(custom) > 1 {
 		return nil, fmt.Errorf("node %s belongs to %d custom roles, cannot proceed with this Node", node.Name, len(custom))
 	} else if len(custom) == 1 {
-		// We don't support making custom pools for masters
+		// Custom pools for masters must inherit from the master pool, so that they keep its configuration,
+		// and only masters may be in such a pool.
 		if master != nil {
-			return nil, fmt.Errorf("node %s has both master role and custom role %s", node.Name, custom[0].Name)
+			if !ctrlcommon.IsControlPlanePool(custom[0]) {
+				return nil, fmt.Errorf("node %s has both master role and custom role %s which doesn't inherit from master", node.Name, custom[0].Name)
+			}
+			return []*mcfgv1.MachineConfigPool{custom[0], master}, nil
+		}
+		if ctrlcommon.IsControlPlanePool(custom[0]) {
+			return nil, fmt.Errorf("node %s has custom role %s which inherits from master but not the master role", node.Name, custom[0].Name)
 		}
 		// One custom role, let's use its pool
 		pls := []*mcfgv1.MachineConfigPool{custom[0]}
@@ -767,16 +820,44 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 		return goerrs.Wrapf(err, "error setting clusterConfig Annotation for node in pool %q, error: %v", pool.Name, err)
 	}
 
+	rolledBack, err := ctrl.rollbackIfNeeded(pool, nodes)
+	if err != nil {
+		if syncErr := ctrl.syncStatusOnly(pool); syncErr != nil {
+			return goerrs.Wrapf(err, "error rolling back pool %q, sync error: %v", pool.Name, syncErr)
+		}
+		return err
+	}
+	if rolledBack {
+		// The pool now targets its previous config, pick up the candidates on the next sync.
+		return nil
+	}
+
+	if ctrl.waitForMaintenanceWindow(pool) {
+		// Nodes already updating complete their update, but no new one starts.
+		// The nodes next in line keep staging the target config of the pool.
+		if err := ctrl.setPoolSettingsAnnotation(pool, nodes, nil, stagingMachines(pool, nodes)); err != nil {
+			return goerrs.Wrapf(err, "error setting poolSettings Annotation for node in pool %q, error: %v", pool.Name, err)
+		}
+		return ctrl.syncStatusOnly(pool)
+	}
+
 	candidates, capacity := getAllCandidateMachines(pool, nodes, maxunavail)
+	var targeted []*corev1.Node
 	if len(candidates) > 0 {
 		ctrl.logPool(pool, "%d candidate nodes for update, capacity: %d", len(candidates), capacity)
-		if err := ctrl.updateCandidateMachines(pool, candidates, capacity); err != nil {
+		targeted, err = ctrl.updateCandidateMachines(pool, candidates, capacity)
+		if err != nil {
 			if syncErr := ctrl.syncStatusOnly(pool); syncErr != nil {
 				return goerrs.Wrapf(err, "error setting desired machine config annotation for pool %q, sync error: %v", pool.Name, syncErr)
 			}
 			return err
 		}
 	}
+
+	next := nextCandidateMachines(pool, nodes, targeted, maxunavail)
+	if err := ctrl.setPoolSettingsAnnotation(pool, nodes, targeted, next); err != nil {
+		return goerrs.Wrapf(err, "error setting poolSettings Annotation for node in pool %q, error: %v", pool.Name, err)
+	}
 	return ctrl.syncStatusOnly(pool)
 }
 
@@ -833,7 +914,9 @@ func (ctrl *Controller) setClusterConfigAnnotation(nodes []*corev1.Node) error {
 	return nil
 }
 
-func (ctrl *Controller) setDesiredMachineConfigAnnotation(nodeName, currentConfig string) error {
+// setDesiredMachineConfigAnnotation sets the desiredConfig annotation of the node together with the
+// poolSettings annotation, so that the MCD reads the settings of the pool for the new config.
+func (ctrl *Controller) setDesiredMachineConfigAnnotation(nodeName, currentConfig, settings string) error {
 	return clientretry.RetryOnConflict(nodeUpdateBackoff, func() error {
 		oldNode, err := ctrl.kubeClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
 		if err != nil {
@@ -849,10 +932,17 @@ func (ctrl *Controller) setDesiredMachineConfigAnnotation(nodeName, currentConfi
 			newNode.Annotations = map[string]string{}
 		}
 
-		if newNode.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey] == currentConfig {
+		if newNode.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey] == currentConfig &&
+			newNode.Annotations[daemonconsts.PoolSettingsAnnotationKey] == settings {
 			return nil
 		}
 		newNode.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey] = currentConfig
+		// without a target config, the node doesn't need to stage the config ahead anymore
+		if settings == "" {
+			delete(newNode.Annotations, daemonconsts.PoolSettingsAnnotationKey)
+		} else {
+			newNode.Annotations[daemonconsts.PoolSettingsAnnotationKey] = settings
+		}
 		newData, err := json.Marshal(newNode)
 		if err != nil {
 			return err
@@ -869,6 +959,7 @@ func (ctrl *Controller) setDesiredMachineConfigAnnotation(nodeName, currentConfi
 
 // getAllCandidateMachines returns all possible nodes which can be updated to the target config, along with a maximum
 // capacity.  It is the reponsibility of the caller to choose a subset of the nodes given the capacity.
+// The nodes are sorted according to the pool rollout strategy, the ones to update first coming first.
 func getAllCandidateMachines(pool *mcfgv1.MachineConfigPool, nodesInPool []*corev1.Node, maxUnavailable int) ([]*corev1.Node, uint) {
 	targetConfig := pool.Spec.Configuration.Name
 
@@ -900,7 +991,22 @@ func getAllCandidateMachines(pool *mcfgv1.MachineConfigPool, nodesInPool []*core
 	}
 	capacity -= failingThisConfig
 
-	return nodes, uint(capacity)
+	// With a canary, wait for the first node to complete the update before starting others.
+	if pending, inProgress := isCanaryPending(pool, nodesInPool); pending {
+		if inProgress {
+			return nil, 0
+		}
+		capacity = 1
+	}
+
+	if getRolloutStrategy(pool).NodeOrdering == mcfgv1.NodeOrderingZoneSpread {
+		nodes, capacity = filterZoneSpreadCandidates(nodes, nodesInPool, capacity)
+		if capacity == 0 {
+			return nil, 0
+		}
+	}
+
+	return orderCandidateMachines(pool, nodes), uint(capacity)
 }
 
 // getCandidateMachines returns the maximum subset of nodes which can be updated to the target config given availability constraints.
@@ -912,11 +1018,6 @@ func getCandidateMachines(pool *mcfgv1.MachineConfigPool, nodesInPool []*corev1.
 	return nodes[:capacity]
 }
 
-// getCurrentEtcdLeader is not yet implemented
-func (ctrl *Controller) getCurrentEtcdLeader(candidates []*corev1.Node) (*corev1.Node, error) {
-	return nil, nil
-}
-
 // filterControlPlaneCandidateNodes adjusts the candidates and capacity specifically
 // for the control plane, e.g. based on which node is the etcd leader at the time.
 // nolint:unparam
@@ -925,8 +1026,14 @@ func (ctrl *Controller) filterControlPlaneCandidateNodes(pool *mcfgv1.MachineCon
 		return candidates, capacity, nil
 	}
 	etcdLeader, err := ctrl.getCurrentEtcdLeader(candidates)
-	if err != nil {
+	switch {
+	case err != nil:
 		glog.Warningf("Failed to find current etcd leader (continuing anyways): %v", err)
+		ctrlcommon.MCCEtcdLeaderDecisions.WithLabelValues("unknown").Inc()
+	case etcdLeader != nil:
+		ctrlcommon.MCCEtcdLeaderDecisions.WithLabelValues("deferred").Inc()
+	default:
+		ctrlcommon.MCCEtcdLeaderDecisions.WithLabelValues("not_candidate").Inc()
 	}
 	var newCandidates []*corev1.Node
 	for _, node := range candidates {
@@ -941,27 +1048,44 @@ func (ctrl *Controller) filterControlPlaneCandidateNodes(pool *mcfgv1.MachineCon
 	return newCandidates, capacity, nil
 }
 
-// updateCandidateMachines sets the desiredConfig annotation the candidate machines
-func (ctrl *Controller) updateCandidateMachines(pool *mcfgv1.MachineConfigPool, candidates []*corev1.Node, capacity uint) error {
-	if pool.Name == masterPoolName {
+// updateCandidateMachines sets the desiredConfig annotation the candidate machines,
+// and returns the ones it was set on.
+func (ctrl *Controller) updateCandidateMachines(pool *mcfgv1.MachineConfigPool, candidates []*corev1.Node, capacity uint) ([]*corev1.Node, error) {
+	if ctrlcommon.IsControlPlanePool(pool) {
 		var err error
 		candidates, capacity, err = ctrl.filterControlPlaneCandidateNodes(pool, candidates, capacity)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		// In practice right now these counts will be 1 but let's stay general to support 5 etcd nodes in the future
 		ctrl.logPool(pool, "filtered to %d candidate nodes for update, capacity: %d", len(candidates), capacity)
 	}
-	if capacity < uint(len(candidates)) {
-		// Arbitrarily pick the first N candidates; no attempt at sorting.
-		// Perhaps later we allow admins to weight somehow, or do something more intelligent.
-		candidates = candidates[:capacity]
+
+	// The disruption budgets span pools: pick the candidates and set their desiredConfig
+	// without another pool doing the same in between.
+	ctrl.budgetLock.Lock()
+	defer ctrl.budgetLock.Unlock()
+	// Pick the first N candidates fitting the disruption budgets; they're already sorted by the pool rollout strategy.
+	candidates, err := ctrl.filterDisruptionBudgetCandidates(pool, candidates, capacity)
+	if err != nil {
+		return nil, goerrs.Wrap(err, "checking disruption budgets")
+	}
+	if len(candidates) == 0 {
+		return nil, nil
 	}
 	targetConfig := pool.Spec.Configuration.Name
-	for _, node := range candidates {
+	settings, err := ctrlcommon.NewPoolSettings(pool).Encode()
+	if err != nil {
+		return nil, err
+	}
+	for i, node := range candidates {
 		ctrl.logPool(pool, "Setting node %s target to %s", node.Name, targetConfig)
-		if err := ctrl.setDesiredMachineConfigAnnotation(node.Name, targetConfig); err != nil {
-			return goerrs.Wrapf(err, "setting desired config for node %s", node.Name)
+		if err := ctrl.setDesiredMachineConfigAnnotation(node.Name, targetConfig, settings); err != nil {
+			// The remaining candidates don't use the disruption budgets after all.
+			for _, n := range candidates[i:] {
+				delete(ctrl.budgetReservations, n.Name)
+			}
+			return candidates[:i], goerrs.Wrapf(err, "setting desired config for node %s", node.Name)
 		}
 	}
 	if len(candidates) == 1 {
@@ -970,6 +1094,91 @@ func (ctrl *Controller) updateCandidateMachines(pool *mcfgv1.MachineConfigPool,
 	} else {
 		ctrl.eventRecorder.Eventf(pool, corev1.EventTypeNormal, "SetDesiredConfig", "Set target for %d nodes to config %s", targetConfig)
 	}
+	return candidates, nil
+}
+
+// nextCandidateMachines returns the nodes next in line for the update of the pool, up to
+// maxUnavailable of them. The targeted nodes, whose desiredConfig was just set, aren't next anymore.
+func nextCandidateMachines(pool *mcfgv1.MachineConfigPool, nodes, targeted []*corev1.Node, maxUnavailable int) map[string]bool {
+	targetConfig := pool.Spec.Configuration.Name
+	skip := map[string]bool{}
+	for _, node := range targeted {
+		skip[node.Name] = true
+	}
+	var pending []*corev1.Node
+	for _, node := range nodes {
+		if node.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey] != targetConfig && !skip[node.Name] {
+			pending = append(pending, node)
+		}
+	}
+	next := map[string]bool{}
+	for _, node := range orderCandidateMachines(pool, pending) {
+		if len(next) == maxUnavailable {
+			break
+		}
+		next[node.Name] = true
+	}
+	return next
+}
+
+// stagingMachines returns the nodes whose settings already have the target config of the pool.
+func stagingMachines(pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node) map[string]bool {
+	staging := map[string]bool{}
+	for _, node := range nodes {
+		settings, err := ctrlcommon.ParsePoolSettings(node.Annotations[daemonconsts.PoolSettingsAnnotationKey])
+		if err == nil && settings.TargetConfig == pool.Spec.Configuration.Name {
+			staging[node.Name] = true
+		}
+	}
+	return staging
+}
+
+// setPoolSettingsAnnotation sets the settings of the pool on its nodes, with one patch per node
+// whose settings changed. The config targeted by the pool is added to the settings of the next
+// nodes, for their MCD to stage the OS image before they are selected. The targeted nodes, whose
+// desiredConfig and settings were just set together, are skipped.
+func (ctrl *Controller) setPoolSettingsAnnotation(pool *mcfgv1.MachineConfigPool, nodes, targeted []*corev1.Node, next map[string]bool) error {
+	skip := map[string]bool{}
+	for _, node := range targeted {
+		skip[node.Name] = true
+	}
+
+	settings := ctrlcommon.NewPoolSettings(pool)
+	value, err := settings.Encode()
+	if err != nil {
+		return err
+	}
+	settings.TargetConfig = pool.Spec.Configuration.Name
+	nextValue, err := settings.Encode()
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if skip[node.Name] {
+			continue
+		}
+		newValue := value
+		if next[node.Name] {
+			newValue = nextValue
+		}
+		if node.Annotations[daemonconsts.PoolSettingsAnnotationKey] == newValue {
+			continue
+		}
+		_, err := internal.UpdateNodeRetry(ctrl.kubeClient.CoreV1().Nodes(), ctrl.nodeLister, node.Name, func(node *corev1.Node) {
+			if newValue == "" {
+				delete(node.Annotations, daemonconsts.PoolSettingsAnnotationKey)
+				return
+			}
+			if node.Annotations == nil {
+				node.Annotations = map[string]string{}
+			}
+			node.Annotations[daemonconsts.PoolSettingsAnnotationKey] = newValue
+		})
+		if err != nil {
+			return err
+		}
+		glog.Infof("Updated %s annotation of node %s to %q", daemonconsts.PoolSettingsAnnotationKey, node.Name, newValue)
+	}
 	return nil
 }
 
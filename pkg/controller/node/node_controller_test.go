@@ -1,6 +1,7 @@
 package node
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -33,6 +34,7 @@ import (
 	"github.com/openshift/machine-config-operator/pkg/version"
 	"github.com/openshift/machine-config-operator/test/helpers"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	utilrand "k8s.io/apimachinery/pkg/util/rand"
 )
 
@@ -77,13 +79,17 @@ func (f *fixture) newController() *Controller {
 	i := informers.NewSharedInformerFactory(f.client, noResyncPeriodFunc())
 	k8sI := kubeinformers.NewSharedInformerFactory(f.kubeclient, noResyncPeriodFunc())
 	ci := configv1informer.NewSharedInformerFactory(f.schedulerClient, noResyncPeriodFunc())
-	c := New(i.Machineconfiguration().V1().ControllerConfigs(), i.Machineconfiguration().V1().MachineConfigPools(), k8sI.Core().V1().Nodes(),
-		ci.Config().V1().Schedulers(), f.kubeclient, f.client)
+	c := New(i.Machineconfiguration().V1().ControllerConfigs(), i.Machineconfiguration().V1().MachineConfigPools(),
+		i.Machineconfiguration().V1().MachineConfigDisruptionBudgets(), k8sI.Core().V1().Nodes(),
+		ci.Config().V1().Schedulers(), k8sI.Core().V1().Secrets(), k8sI.Core().V1().ConfigMaps(), f.kubeclient, f.client)
 
 	c.ccListerSynced = alwaysReady
 	c.mcpListerSynced = alwaysReady
+	c.mcdbListerSynced = alwaysReady
 	c.nodeListerSynced = alwaysReady
 	c.schedulerListerSynced = alwaysReady
+	c.secretListerSynced = alwaysReady
+	c.configMapListerSynced = alwaysReady
 	c.eventRecorder = &record.FakeRecorder{}
 
 	stopCh := make(chan struct{})
@@ -214,8 +220,14 @@ func filterInformerActions(actions []core.Action) []core.Action {
 				action.Matches("watch", "machineconfigpools") ||
 				action.Matches("list", "controllerconfigs") ||
 				action.Matches("watch", "controllerconfigs") ||
+				action.Matches("list", "machineconfigdisruptionbudgets") ||
+				action.Matches("watch", "machineconfigdisruptionbudgets") ||
 				action.Matches("list", "nodes") ||
-				action.Matches("watch", "nodes")) {
+				action.Matches("watch", "nodes") ||
+				action.Matches("list", "secrets") ||
+				action.Matches("watch", "secrets") ||
+				action.Matches("list", "configmaps") ||
+				action.Matches("watch", "configmaps")) {
 			continue
 		}
 		ret = append(ret, action)
@@ -296,6 +308,11 @@ func TestGetNodesForPool(t *testing.T) {
 	}
 }
 
+// masterChildSelector selects the MachineConfigs of a custom pool inheriting from master.
+var masterChildSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+	{Key: mcfgv1.MachineConfigRoleLabelKey, Operator: metav1.LabelSelectorOpIn, Values: []string{"master", "master-nic"}},
+}}
+
 func TestGetPrimaryPoolForNode(t *testing.T) {
 	tests := []struct {
 		pools     []*mcfgv1.MachineConfigPool
@@ -348,6 +365,28 @@ func TestGetPrimaryPoolForNode(t *testing.T) {
 		},
 		nodeLabel: map[string]string{"node-role/master": "", "node-role/infra": ""},
 
+		expected: nil,
+		err:      true,
+	}, {
+		// custom pool inheriting from master
+		pools: []*mcfgv1.MachineConfigPool{
+			helpers.NewMachineConfigPool("master", nil, helpers.MasterSelector, "v0"),
+			helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v0"),
+			helpers.NewMachineConfigPool("master-nic", masterChildSelector, metav1.AddLabelToSelector(&metav1.LabelSelector{}, "node-role/master-nic", ""), "v0"),
+		},
+		nodeLabel: map[string]string{"node-role/master": "", "node-role/worker": "", "node-role/master-nic": ""},
+
+		expected: helpers.NewMachineConfigPool("master-nic", masterChildSelector, metav1.AddLabelToSelector(&metav1.LabelSelector{}, "node-role/master-nic", ""), "v0"),
+		err:      false,
+	}, {
+		// custom pool inheriting from master on a node without the master role
+		pools: []*mcfgv1.MachineConfigPool{
+			helpers.NewMachineConfigPool("master", nil, helpers.MasterSelector, "v0"),
+			helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v0"),
+			helpers.NewMachineConfigPool("master-nic", masterChildSelector, metav1.AddLabelToSelector(&metav1.LabelSelector{}, "node-role/master-nic", ""), "v0"),
+		},
+		nodeLabel: map[string]string{"node-role/worker": "", "node-role/master-nic": ""},
+
 		expected: nil,
 		err:      true,
 	}, {
@@ -772,7 +811,7 @@ func TestSetDesiredMachineConfigAnnotation(t *testing.T) {
 
 			c := f.newController()
 
-			err := c.setDesiredMachineConfigAnnotation(test.node.Name, "v1")
+			err := c.setDesiredMachineConfigAnnotation(test.node.Name, "v1", "")
 			if !assert.Nil(t, err) {
 				return
 			}
@@ -972,6 +1011,75 @@ func TestControlPlaneTopology(t *testing.T) {
 	f.run(getKey(mcp, t))
 }
 
+func TestSetPoolSettingsAnnotation(t *testing.T) {
+	f := newFixture(t)
+	mcp := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v1")
+	mcp.Spec.DrainPolicy = &mcfgv1.MachineConfigPoolDrainPolicy{
+		Timeout:        &metav1.Duration{Duration: 10 * time.Minute},
+		SkipNamespaces: []string{"monitoring"},
+	}
+	mcp.Spec.DriftPolicy = &mcfgv1.MachineConfigPoolDriftPolicy{
+		Mode:     mcfgv1.DriftModeRemediate,
+		Interval: &metav1.Duration{Duration: 5 * time.Minute},
+	}
+	mcp.Spec.HealthCheckPolicy = &mcfgv1.MachineConfigPoolHealthCheckPolicy{
+		Timeout: &metav1.Duration{Duration: 15 * time.Minute},
+		Units:   []string{"ovs-vswitchd.service"},
+	}
+	mcp.Spec.UpdateHooks = []mcfgv1.MachineConfigPoolUpdateHook{{
+		Name:          "deregister",
+		Phase:         mcfgv1.UpdateHookPhaseBeforeDrain,
+		Command:       []string{"/usr/local/bin/deregister"},
+		FailurePolicy: mcfgv1.UpdateHookFailurePolicyContinue,
+	}}
+	settings := `{"version":1,` +
+		`"drainPolicy":{"timeout":"10m0s","skipNamespaces":["monitoring"]},` +
+		`"driftPolicy":{"mode":"Remediate","interval":"5m0s"},` +
+		`"healthCheckPolicy":{"timeout":"15m0s","units":["ovs-vswitchd.service"]},` +
+		`"updateHooks":[{"name":"deregister","phase":"BeforeDrain","command":["/usr/local/bin/deregister"],"failurePolicy":"Continue"}]`
+	nodes := []*corev1.Node{
+		newNodeWithLabel("node-0", "v0", "v0", map[string]string{"node-role/worker": ""}),
+		newNodeWithLabel("node-1", "v0", "v0", map[string]string{"node-role/worker": ""}),
+		newNodeWithLabel("node-2", "v0", "v0", map[string]string{"node-role/worker": ""}),
+		newNodeWithLabel("node-3", "v1", "v1", map[string]string{"node-role/worker": ""}),
+		newNodeWithLabel("node-4", "v1", "v1", map[string]string{"node-role/worker": ""}),
+	}
+	// node-2 was next for a previous target of the pool
+	addNodeAnnotations(nodes[2], map[string]string{daemonconsts.PoolSettingsAnnotationKey: `{"version":1,"targetConfig":"v0"}`})
+	// node-4 is up to date
+	addNodeAnnotations(nodes[4], map[string]string{daemonconsts.PoolSettingsAnnotationKey: settings + "}"})
+	f.nodeLister = append(f.nodeLister, nodes...)
+	for _, node := range nodes {
+		f.kubeobjects = append(f.kubeobjects, node)
+	}
+	c := f.newController()
+
+	// node-0 was just targeted, node-1 is next and only it stages the config
+	err := c.setPoolSettingsAnnotation(mcp, nodes, nodes[:1], nextCandidateMachines(mcp, nodes, nodes[:1], 1))
+	assert.Nil(t, err)
+	actions := filterInformerActions(f.kubeclient.Actions())
+	assert.Len(t, actions, 3)
+	getSettings := func(name string) (string, bool) {
+		node, err := f.kubeclient.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+		require.Nil(t, err)
+		settings, ok := node.Annotations[daemonconsts.PoolSettingsAnnotationKey]
+		return settings, ok
+	}
+	value, _ := getSettings("node-1")
+	assert.Equal(t, settings+`,"targetConfig":"v1"}`, value)
+	for _, name := range []string{"node-2", "node-3", "node-4"} {
+		value, _ := getSettings(name)
+		assert.Equal(t, settings+"}", value, name)
+	}
+	_, ok := getSettings("node-0")
+	assert.False(t, ok)
+
+	// the desired config is set together with the settings, without a target config
+	require.Nil(t, c.setDesiredMachineConfigAnnotation("node-1", "v1", settings+"}"))
+	value, _ = getSettings("node-1")
+	assert.Equal(t, settings+"}", value)
+}
+
 // adds annotation to the node
 func addNodeAnnotations(node *corev1.Node, annotations map[string]string) {
 	if node.Annotations == nil {
@@ -0,0 +1,149 @@
+package node
+
+import (
+	"sort"
+	"strconv"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// getRolloutStrategy returns the rollout strategy of the pool, or an empty one if unset.
+func getRolloutStrategy(pool *mcfgv1.MachineConfigPool) mcfgv1.MachineConfigPoolRolloutStrategy {
+	if pool.Spec.RolloutStrategy == nil {
+		return mcfgv1.MachineConfigPoolRolloutStrategy{}
+	}
+	return *pool.Spec.RolloutStrategy
+}
+
+// getNodeZone returns the topology zone of the node, or an empty string if it has none.
+func getNodeZone(node *corev1.Node) string {
+	if zone, ok := node.Labels[corev1.LabelTopologyZone]; ok {
+		return zone
+	}
+	return node.Labels[corev1.LabelFailureDomainBetaZone]
+}
+
+// getNodePriority returns the integer priority held by the label of the node.
+// The second return value is false if the node doesn't have a valid priority.
+func getNodePriority(node *corev1.Node, label string) (int64, bool) {
+	if label == "" {
+		return 0, false
+	}
+	value, ok := node.Labels[label]
+	if !ok {
+		return 0, false
+	}
+	priority, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return priority, true
+}
+
+// orderCandidateMachines sorts the candidates according to the node ordering of the pool
+// rollout strategy, so that the nodes to update first come first.
+// With the default ordering the candidates are returned as is.
+func orderCandidateMachines(pool *mcfgv1.MachineConfigPool, candidates []*corev1.Node) []*corev1.Node {
+	strategy := getRolloutStrategy(pool)
+	ordered := make([]*corev1.Node, len(candidates))
+	copy(ordered, candidates)
+
+	switch strategy.NodeOrdering {
+	case mcfgv1.NodeOrderingZoneSpread:
+		return orderByZone(ordered)
+	case mcfgv1.NodeOrderingPriority:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			pi, iok := getNodePriority(ordered[i], strategy.PriorityLabel)
+			pj, jok := getNodePriority(ordered[j], strategy.PriorityLabel)
+			if iok != jok {
+				return iok
+			}
+			return pi > pj
+		})
+	case mcfgv1.NodeOrderingNodeAge:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].CreationTimestamp.Before(&ordered[j].CreationTimestamp)
+		})
+	}
+	return ordered
+}
+
+// orderByZone interleaves the nodes of the different zones, so that any prefix of the
+// result contains as few nodes of the same zone as possible. Zones are visited in the
+// order they first appear in, each node without a zone is treated as a zone of its own.
+func orderByZone(nodes []*corev1.Node) []*corev1.Node {
+	var groups [][]*corev1.Node
+	groupForZone := map[string]int{}
+	for _, node := range nodes {
+		zone := getNodeZone(node)
+		if idx, ok := groupForZone[zone]; ok && zone != "" {
+			groups[idx] = append(groups[idx], node)
+			continue
+		}
+		groupForZone[zone] = len(groups)
+		groups = append(groups, []*corev1.Node{node})
+	}
+
+	var ordered []*corev1.Node
+	for round := 0; len(ordered) < len(nodes); round++ {
+		for _, group := range groups {
+			if round < len(group) {
+				ordered = append(ordered, group[round])
+			}
+		}
+	}
+	return ordered
+}
+
+// filterZoneSpreadCandidates drops the candidates in zones which already have a node updating and
+// caps the capacity to the number of zones left, so that at most one node per zone is updated at once.
+func filterZoneSpreadCandidates(candidates, nodesInPool []*corev1.Node, capacity int) ([]*corev1.Node, int) {
+	busyZones := sets.NewString()
+	for _, node := range getUnavailableMachines(nodesInPool) {
+		if zone := getNodeZone(node); zone != "" {
+			busyZones.Insert(zone)
+		}
+	}
+
+	freeZones := sets.NewString()
+	unzoned := 0
+	var filtered []*corev1.Node
+	for _, node := range candidates {
+		zone := getNodeZone(node)
+		if zone == "" {
+			unzoned++
+		} else if busyZones.Has(zone) {
+			continue
+		} else {
+			freeZones.Insert(zone)
+		}
+		filtered = append(filtered, node)
+	}
+
+	if maxCapacity := freeZones.Len() + unzoned; capacity > maxCapacity {
+		capacity = maxCapacity
+	}
+	return filtered, capacity
+}
+
+// isCanaryPending checks whether the pool uses a canary and no node has completed the
+// update to the target config yet. It also returns whether a node is already updating
+// to the target config, in which case that node is the canary.
+func isCanaryPending(pool *mcfgv1.MachineConfigPool, nodesInPool []*corev1.Node) (pending, inProgress bool) {
+	if !getRolloutStrategy(pool).Canary {
+		return false, false
+	}
+	targetConfig := pool.Spec.Configuration.Name
+	if len(getUpdatedMachines(targetConfig, nodesInPool)) > 0 {
+		return false, false
+	}
+	for _, node := range nodesInPool {
+		if node.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey] == targetConfig {
+			return true, true
+		}
+	}
+	return true, false
+}
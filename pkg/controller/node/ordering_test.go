@@ -0,0 +1,178 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newNodeInZone(name string, currentConfig, desiredConfig string, status corev1.ConditionStatus, zone string) *corev1.Node {
+	node := newNodeWithReady(name, currentConfig, desiredConfig, status)
+	if zone != "" {
+		node.Labels = map[string]string{corev1.LabelTopologyZone: zone}
+	}
+	return node
+}
+
+func newPoolWithRolloutStrategy(target string, strategy *mcfgv1.MachineConfigPoolRolloutStrategy) *mcfgv1.MachineConfigPool {
+	return &mcfgv1.MachineConfigPool{
+		Spec: mcfgv1.MachineConfigPoolSpec{
+			Configuration:   mcfgv1.MachineConfigPoolStatusConfiguration{ObjectReference: corev1.ObjectReference{Name: target}},
+			RolloutStrategy: strategy,
+		},
+	}
+}
+
+func nodeNames(nodes []*corev1.Node) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+func TestOrderCandidateMachines(t *testing.T) {
+	now := time.Now()
+	priorityLabel := "example.com/update-priority"
+	newTestNode := func(name, zone, priority string, age time.Duration) *corev1.Node {
+		node := newNodeWithLabels(name, map[string]string{corev1.LabelTopologyZone: zone})
+		if priority != "" {
+			node.Labels[priorityLabel] = priority
+		}
+		node.CreationTimestamp = metav1.NewTime(now.Add(-age))
+		return node
+	}
+	nodes := []*corev1.Node{
+		newTestNode("node-0", "a", "1", 1*time.Hour),
+		newTestNode("node-1", "a", "", 3*time.Hour),
+		newTestNode("node-2", "b", "10", 2*time.Hour),
+		newTestNode("node-3", "c", "not-a-number", 5*time.Hour),
+		newTestNode("node-4", "b", "5", 4*time.Hour),
+	}
+
+	tests := []struct {
+		strategy *mcfgv1.MachineConfigPoolRolloutStrategy
+		expected []string
+	}{{
+		strategy: nil,
+		expected: []string{"node-0", "node-1", "node-2", "node-3", "node-4"},
+	}, {
+		strategy: &mcfgv1.MachineConfigPoolRolloutStrategy{NodeOrdering: mcfgv1.NodeOrderingZoneSpread},
+		expected: []string{"node-0", "node-2", "node-3", "node-1", "node-4"},
+	}, {
+		strategy: &mcfgv1.MachineConfigPoolRolloutStrategy{NodeOrdering: mcfgv1.NodeOrderingPriority, PriorityLabel: priorityLabel},
+		expected: []string{"node-2", "node-4", "node-0", "node-1", "node-3"},
+	}, {
+		strategy: &mcfgv1.MachineConfigPoolRolloutStrategy{NodeOrdering: mcfgv1.NodeOrderingNodeAge},
+		expected: []string{"node-3", "node-4", "node-1", "node-2", "node-0"},
+	}}
+
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("case#%d", idx), func(t *testing.T) {
+			pool := newPoolWithRolloutStrategy("v1", test.strategy)
+			assert.Equal(t, test.expected, nodeNames(orderCandidateMachines(pool, nodes)))
+		})
+	}
+}
+
+func TestGetCandidateMachinesZoneSpread(t *testing.T) {
+	tests := []struct {
+		nodes    []*corev1.Node
+		progress int
+		expected []string
+	}{{
+		// one node per zone at most
+		progress: 3,
+		nodes: []*corev1.Node{
+			newNodeInZone("node-0", "v0", "v0", corev1.ConditionTrue, "a"),
+			newNodeInZone("node-1", "v0", "v0", corev1.ConditionTrue, "a"),
+			newNodeInZone("node-2", "v0", "v0", corev1.ConditionTrue, "b"),
+			newNodeInZone("node-3", "v0", "v0", corev1.ConditionTrue, "b"),
+		},
+		expected: []string{"node-0", "node-2"},
+	}, {
+		// zone a is already updating
+		progress: 3,
+		nodes: []*corev1.Node{
+			newNodeInZone("node-0", "v0", "v1", corev1.ConditionTrue, "a"),
+			newNodeInZone("node-1", "v0", "v0", corev1.ConditionTrue, "a"),
+			newNodeInZone("node-2", "v0", "v0", corev1.ConditionTrue, "b"),
+			newNodeInZone("node-3", "v0", "v0", corev1.ConditionTrue, "c"),
+		},
+		expected: []string{"node-2", "node-3"},
+	}, {
+		// all zones are busy
+		progress: 3,
+		nodes: []*corev1.Node{
+			newNodeInZone("node-0", "v0", "v1", corev1.ConditionTrue, "a"),
+			newNodeInZone("node-1", "v0", "v0", corev1.ConditionTrue, "a"),
+			newNodeInZone("node-2", "v0", "v0", corev1.ConditionFalse, "b"),
+			newNodeInZone("node-3", "v0", "v0", corev1.ConditionTrue, "b"),
+		},
+		expected: nil,
+	}, {
+		// nodes without a zone are not constrained
+		progress: 3,
+		nodes: []*corev1.Node{
+			newNodeInZone("node-0", "v0", "v0", corev1.ConditionTrue, ""),
+			newNodeInZone("node-1", "v0", "v0", corev1.ConditionTrue, "a"),
+			newNodeInZone("node-2", "v0", "v0", corev1.ConditionTrue, ""),
+			newNodeInZone("node-3", "v0", "v0", corev1.ConditionTrue, "a"),
+		},
+		expected: []string{"node-0", "node-1", "node-2"},
+	}}
+
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("case#%d", idx), func(t *testing.T) {
+			pool := newPoolWithRolloutStrategy("v1", &mcfgv1.MachineConfigPoolRolloutStrategy{NodeOrdering: mcfgv1.NodeOrderingZoneSpread})
+			assert.Equal(t, test.expected, nodeNames(getCandidateMachines(pool, test.nodes, test.progress)))
+		})
+	}
+}
+
+func TestGetCandidateMachinesCanary(t *testing.T) {
+	tests := []struct {
+		nodes    []*corev1.Node
+		progress int
+		expected []string
+	}{{
+		// only the canary starts
+		progress: 3,
+		nodes: []*corev1.Node{
+			newNodeWithReady("node-0", "v0", "v0", corev1.ConditionTrue),
+			newNodeWithReady("node-1", "v0", "v0", corev1.ConditionTrue),
+			newNodeWithReady("node-2", "v0", "v0", corev1.ConditionTrue),
+		},
+		expected: []string{"node-0"},
+	}, {
+		// the canary is still updating
+		progress: 3,
+		nodes: []*corev1.Node{
+			newNodeWithReady("node-0", "v0", "v1", corev1.ConditionTrue),
+			newNodeWithReady("node-1", "v0", "v0", corev1.ConditionTrue),
+			newNodeWithReady("node-2", "v0", "v0", corev1.ConditionTrue),
+		},
+		expected: nil,
+	}, {
+		// the canary is done, go on with the rest
+		progress: 3,
+		nodes: []*corev1.Node{
+			newNodeWithReady("node-0", "v1", "v1", corev1.ConditionTrue),
+			newNodeWithReady("node-1", "v0", "v0", corev1.ConditionTrue),
+			newNodeWithReady("node-2", "v0", "v0", corev1.ConditionTrue),
+		},
+		expected: []string{"node-1", "node-2"},
+	}}
+
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("case#%d", idx), func(t *testing.T) {
+			pool := newPoolWithRolloutStrategy("v1", &mcfgv1.MachineConfigPoolRolloutStrategy{Canary: true})
+			assert.Equal(t, test.expected, nodeNames(getCandidateMachines(pool, test.nodes, test.progress)))
+		})
+	}
+}
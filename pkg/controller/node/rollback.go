@@ -0,0 +1,144 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/openshift/machine-config-operator/internal"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// updateFailedSinceAnnotationKey is set by the node controller on the nodes failing to update to
+// the target config of their pool, to the JSON encoded nodeFailure, so that the time they started
+// failing survives controller restarts.
+const updateFailedSinceAnnotationKey = "machineconfiguration.openshift.io/updateFailedSince"
+
+// nodeFailure records when a node was first seen failing to update to a config.
+type nodeFailure struct {
+	Config string      `json:"config"`
+	Since  metav1.Time `json:"since"`
+}
+
+// getNodeFailure returns the failure recorded on the node, if any.
+func getNodeFailure(node *corev1.Node) (nodeFailure, bool) {
+	var failure nodeFailure
+	data, ok := node.Annotations[updateFailedSinceAnnotationKey]
+	if !ok {
+		return failure, false
+	}
+	if err := json.Unmarshal([]byte(data), &failure); err != nil {
+		glog.Warningf("Ignoring invalid %s annotation of node %s: %v", updateFailedSinceAnnotationKey, node.Name, err)
+		return failure, false
+	}
+	return failure, true
+}
+
+// recordNodeFailures records on the nodes of the pool when they started failing to update to the
+// target config, clearing it from the nodes which aren't failing anymore, and returns how many of
+// them started failing within the window. A zero window counts all of them.
+func (ctrl *Controller) recordNodeFailures(pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node, window time.Duration, now time.Time) (int, error) {
+	targetConfig := pool.Spec.Configuration.Name
+
+	count := 0
+	for _, node := range nodes {
+		failure, recorded := getNodeFailure(node)
+		if node.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey] != targetConfig || !isNodeMCDFailing(node) {
+			if _, ok := node.Annotations[updateFailedSinceAnnotationKey]; ok {
+				if err := ctrl.setNodeFailureAnnotation(node.Name, ""); err != nil {
+					return 0, err
+				}
+			}
+			continue
+		}
+		if !recorded || failure.Config != targetConfig {
+			failure = nodeFailure{Config: targetConfig, Since: metav1.NewTime(now)}
+			data, err := json.Marshal(failure)
+			if err != nil {
+				return 0, err
+			}
+			if err := ctrl.setNodeFailureAnnotation(node.Name, string(data)); err != nil {
+				return 0, err
+			}
+		}
+		if window == 0 || now.Sub(failure.Since.Time) <= window {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// setNodeFailureAnnotation sets the failure annotation of the node to value, removing it if value is empty.
+func (ctrl *Controller) setNodeFailureAnnotation(nodeName, value string) error {
+	_, err := internal.UpdateNodeRetry(ctrl.kubeClient.CoreV1().Nodes(), ctrl.nodeLister, nodeName, func(node *corev1.Node) {
+		if value == "" {
+			delete(node.Annotations, updateFailedSinceAnnotationKey)
+			return
+		}
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[updateFailedSinceAnnotationKey] = value
+	})
+	return err
+}
+
+// rollbackIfNeeded rolls the pool back to the configuration in its status when more nodes than
+// allowed by the pool rollback policy fail to update to the targeted configuration.
+// It returns whether the pool was rolled back.
+func (ctrl *Controller) rollbackIfNeeded(pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node) (bool, error) {
+	policy := pool.Spec.RollbackPolicy
+	if policy == nil {
+		return false, nil
+	}
+
+	var window time.Duration
+	if policy.Window != nil {
+		window = policy.Window.Duration
+	}
+	failed, err := ctrl.recordNodeFailures(pool, nodes, window, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if failed <= int(policy.MaxFailedNodes) {
+		return false, nil
+	}
+
+	targetConfig := pool.Spec.Configuration.Name
+	previousConfig := pool.Status.Configuration.Name
+	if previousConfig == "" || previousConfig == targetConfig {
+		ctrl.logPool(pool, "%d nodes failed to update to %s but there is no previous config to roll back to", failed, targetConfig)
+		return false, nil
+	}
+
+	msg := fmt.Sprintf("Rolled back from %s to %s: %d nodes failed to update", targetConfig, previousConfig, failed)
+	if window != 0 {
+		msg = fmt.Sprintf("%s within %v", msg, window)
+	}
+	ctrl.logPool(pool, "%s", msg)
+
+	newPool := pool.DeepCopy()
+	newPool.Spec.Configuration = pool.Status.Configuration
+	if newPool.Annotations == nil {
+		newPool.Annotations = map[string]string{}
+	}
+	newPool.Annotations[ctrlcommon.RolledBackConfigAnnotationKey] = targetConfig
+	newPool, err = ctrl.client.MachineconfigurationV1().MachineConfigPools().Update(context.TODO(), newPool, metav1.UpdateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to roll back pool %s to %s: %v", pool.Name, previousConfig, err)
+	}
+	ctrl.eventRecorder.Eventf(pool, corev1.EventTypeWarning, "RolledBack", "%s", msg)
+
+	rolledBack := mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolRolledBack, corev1.ConditionTrue, "TooManyFailedNodes", msg)
+	mcfgv1.SetMachineConfigPoolCondition(&newPool.Status, *rolledBack)
+	if _, err := ctrl.client.MachineconfigurationV1().MachineConfigPools().UpdateStatus(context.TODO(), newPool, metav1.UpdateOptions{}); err != nil {
+		glog.Errorf("Error updating status of rolled back MachineConfigPool %s: %v", pool.Name, err)
+	}
+	return true, nil
+}
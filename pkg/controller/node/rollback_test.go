@@ -0,0 +1,143 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	core "k8s.io/client-go/testing"
+)
+
+func TestRecordNodeFailures(t *testing.T) {
+	f := newFixture(t)
+	pool := helpers.NewMachineConfigPool(masterPoolName, nil, helpers.MasterSelector, "v1")
+
+	now := time.Now().Truncate(time.Second)
+	failing := newNodeWithReadyAndDaemonState("node-0", "v0", "v1", corev1.ConditionTrue, daemonconsts.MachineConfigDaemonStateDegraded)
+	// recovered, its failure is cleared
+	recovered := newNodeWithReady("node-1", "v1", "v1", corev1.ConditionTrue)
+	recovered.Annotations[updateFailedSinceAnnotationKey] = `{"config":"v1","since":"2021-01-01T00:00:00Z"}`
+	nodes := []*corev1.Node{
+		failing,
+		recovered,
+		newNodeWithReady("node-2", "v0", "v1", corev1.ConditionTrue),
+		// failing, but not on the target config
+		newNodeWithReadyAndDaemonState("node-3", "v0", "v0.1", corev1.ConditionTrue, daemonconsts.MachineConfigDaemonStateDegraded),
+	}
+	for _, node := range nodes {
+		f.nodeLister = append(f.nodeLister, node)
+		f.kubeobjects = append(f.kubeobjects, node)
+	}
+	c := f.newController()
+
+	count, err := c.recordNodeFailures(pool, nodes, time.Hour, now)
+	require.Nil(t, err)
+	assert.Equal(t, 1, count)
+	actions := filterInformerActions(f.kubeclient.Actions())
+	require.Len(t, actions, 2)
+	assert.Equal(t, "node-0", actions[0].(core.PatchAction).GetName())
+	assert.Equal(t, "node-1", actions[1].(core.PatchAction).GetName())
+	for i := range nodes[:2] {
+		nodes[i], err = f.kubeclient.CoreV1().Nodes().Get(context.TODO(), nodes[i].Name, metav1.GetOptions{})
+		require.Nil(t, err)
+	}
+	failure, ok := getNodeFailure(nodes[0])
+	require.True(t, ok)
+	assert.Equal(t, "v1", failure.Config)
+	assert.True(t, failure.Since.Time.Equal(now))
+	assert.NotContains(t, nodes[1].Annotations, updateFailedSinceAnnotationKey)
+
+	// the failure is kept with its original time, as read back from the node
+	f.kubeclient.ClearActions()
+	count, err = c.recordNodeFailures(pool, nodes, time.Hour, now.Add(30*time.Minute))
+	require.Nil(t, err)
+	assert.Equal(t, 1, count)
+	count, err = c.recordNodeFailures(pool, nodes, time.Hour, now.Add(2*time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, 0, count)
+	count, err = c.recordNodeFailures(pool, nodes, 0, now.Add(2*time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Empty(t, filterInformerActions(f.kubeclient.Actions()))
+
+	// a new target config resets the failures
+	pool.Spec.Configuration.Name = "v2"
+	nodes[0].Annotations[daemonconsts.DesiredMachineConfigAnnotationKey] = "v2"
+	count, err = c.recordNodeFailures(pool, nodes, time.Hour, now.Add(2*time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestRollbackIfNeeded(t *testing.T) {
+	f := newFixture(t)
+	pool := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v1")
+	pool.Spec.Configuration.Name = "v2"
+	pool.Spec.RollbackPolicy = &mcfgv1.MachineConfigPoolRollbackPolicy{
+		MaxFailedNodes: 1,
+		Window:         &metav1.Duration{Duration: time.Hour},
+	}
+	nodes := []*corev1.Node{
+		newNodeWithReadyAndDaemonState("node-0", "v1", "v2", corev1.ConditionTrue, daemonconsts.MachineConfigDaemonStateDegraded),
+		newNodeWithReady("node-1", "v1", "v1", corev1.ConditionTrue),
+		newNodeWithReady("node-2", "v1", "v1", corev1.ConditionTrue),
+	}
+	f.mcpLister = append(f.mcpLister, pool)
+	f.objects = append(f.objects, pool)
+	for _, node := range nodes {
+		f.nodeLister = append(f.nodeLister, node)
+		f.kubeobjects = append(f.kubeobjects, node)
+	}
+	c := f.newController()
+
+	// a single failure is allowed
+	rolledBack, err := c.rollbackIfNeeded(pool, nodes)
+	require.Nil(t, err)
+	assert.False(t, rolledBack)
+	assert.Empty(t, filterInformerActions(f.client.Actions()))
+
+	nodes[1] = newNodeWithReadyAndDaemonState("node-1", "v1", "v2", corev1.ConditionTrue, daemonconsts.MachineConfigDaemonStateUnreconcilable)
+	rolledBack, err = c.rollbackIfNeeded(pool, nodes)
+	require.Nil(t, err)
+	assert.True(t, rolledBack)
+
+	actions := filterInformerActions(f.client.Actions())
+	require.Len(t, actions, 2)
+	require.True(t, actions[0].Matches("update", "machineconfigpools"))
+	updated := actions[0].(core.UpdateAction).GetObject().(*mcfgv1.MachineConfigPool)
+	assert.Equal(t, "v1", updated.Spec.Configuration.Name)
+	assert.Equal(t, "v2", updated.Annotations[ctrlcommon.RolledBackConfigAnnotationKey])
+
+	require.True(t, actions[1].Matches("update", "machineconfigpools"))
+	require.Equal(t, "status", actions[1].GetSubresource())
+	updated = actions[1].(core.UpdateAction).GetObject().(*mcfgv1.MachineConfigPool)
+	assert.True(t, mcfgv1.IsMachineConfigPoolConditionTrue(updated.Status.Conditions, mcfgv1.MachineConfigPoolRolledBack))
+}
+
+func TestRollbackIfNeededNoPreviousConfig(t *testing.T) {
+	f := newFixture(t)
+	pool := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v1")
+	pool.Spec.RollbackPolicy = &mcfgv1.MachineConfigPoolRollbackPolicy{}
+	nodes := []*corev1.Node{
+		newNodeWithReadyAndDaemonState("node-0", "v0", "v1", corev1.ConditionTrue, daemonconsts.MachineConfigDaemonStateDegraded),
+	}
+	f.mcpLister = append(f.mcpLister, pool)
+	f.objects = append(f.objects, pool)
+	for _, node := range nodes {
+		f.nodeLister = append(f.nodeLister, node)
+		f.kubeobjects = append(f.kubeobjects, node)
+	}
+	c := f.newController()
+
+	rolledBack, err := c.rollbackIfNeeded(pool, nodes)
+	require.Nil(t, err)
+	assert.False(t, rolledBack)
+	assert.Empty(t, filterInformerActions(f.client.Actions()))
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/golang/glog"
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
@@ -14,6 +16,11 @@ import (
 )
 
 func (ctrl *Controller) syncStatusOnly(pool *mcfgv1.MachineConfigPool) error {
+	// The usage of the disruption budgets changes with the nodes of any pool.
+	if err := ctrl.syncDisruptionBudgetStatus(); err != nil {
+		return err
+	}
+
 	nodes, err := ctrl.getNodesForPool(pool)
 	if err != nil {
 		return err
@@ -102,6 +109,8 @@ func calculateStatus(pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node) mcfgv
 		}
 	}
 
+	setMaintenanceWindowStatus(pool, &status, allUpdated, time.Now())
+
 	var nodeDegraded bool
 	if degradedMachineCount > 0 {
 		nodeDegraded = true
@@ -112,6 +121,12 @@ func calculateStatus(pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node) mcfgv
 		mcfgv1.SetMachineConfigPoolCondition(&status, *sdegraded)
 	}
 
+	// The rollback is over once the pool targets a new config.
+	if _, ok := pool.Annotations[ctrlcommon.RolledBackConfigAnnotationKey]; !ok && mcfgv1.IsMachineConfigPoolConditionTrue(status.Conditions, mcfgv1.MachineConfigPoolRolledBack) {
+		srolledback := mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolRolledBack, corev1.ConditionFalse, "", "")
+		mcfgv1.SetMachineConfigPoolCondition(&status, *srolledback)
+	}
+
 	// here we now set the MCP Degraded field, the node_controller is the one making the call right now
 	// but we might have a dedicated controller or control loop somewhere else that understands how to
 	// set Degraded. For now, the node_controller understand NodeDegraded & RenderDegraded = Degraded.
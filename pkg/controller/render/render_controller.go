@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -22,9 +24,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
@@ -41,6 +46,10 @@ const (
 	// renderDelay is a pause to avoid churn in MachineConfigs; see
 	// https://github.com/openshift/machine-config-operator/issues/301
 	renderDelay = 5 * time.Second
+
+	// defaultRenderedConfigHistoryLimit is the number of unreferenced rendered MachineConfigs
+	// kept per pool when the pool does not set renderedConfigHistoryLimit.
+	defaultRenderedConfigHistoryLimit = 5
 )
 
 var (
@@ -58,11 +67,13 @@ type Controller struct {
 	syncHandler              func(mcp string) error
 	enqueueMachineConfigPool func(*mcfgv1.MachineConfigPool)
 
-	mcpLister mcfglistersv1.MachineConfigPoolLister
-	mcLister  mcfglistersv1.MachineConfigLister
+	mcpLister  mcfglistersv1.MachineConfigPoolLister
+	mcLister   mcfglistersv1.MachineConfigLister
+	nodeLister corelisterv1.NodeLister
 
-	mcpListerSynced cache.InformerSynced
-	mcListerSynced  cache.InformerSynced
+	mcpListerSynced  cache.InformerSynced
+	mcListerSynced   cache.InformerSynced
+	nodeListerSynced cache.InformerSynced
 
 	ccLister       mcfglistersv1.ControllerConfigLister
 	ccListerSynced cache.InformerSynced
@@ -75,6 +86,7 @@ func New(
 	mcpInformer mcfginformersv1.MachineConfigPoolInformer,
 	mcInformer mcfginformersv1.MachineConfigInformer,
 	ccInformer mcfginformersv1.ControllerConfigInformer,
+	nodeInformer coreinformersv1.NodeInformer,
 	kubeClient clientset.Interface,
 	mcfgClient mcfgclientset.Interface,
 ) *Controller {
@@ -108,6 +120,8 @@ func New(
 	ctrl.mcListerSynced = mcInformer.Informer().HasSynced
 	ctrl.ccLister = ccInformer.Lister()
 	ctrl.ccListerSynced = ccInformer.Informer().HasSynced
+	ctrl.nodeLister = nodeInformer.Lister()
+	ctrl.nodeListerSynced = nodeInformer.Informer().HasSynced
 
 	return ctrl
 }
@@ -117,7 +131,7 @@ func (ctrl *Controller) Run(workers int, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer ctrl.queue.ShutDown()
 
-	if !cache.WaitForCacheSync(stopCh, ctrl.mcpListerSynced, ctrl.mcListerSynced, ctrl.ccListerSynced) {
+	if !cache.WaitForCacheSync(stopCh, ctrl.mcpListerSynced, ctrl.mcListerSynced, ctrl.ccListerSynced, ctrl.nodeListerSynced) {
 		return
 	}
 
@@ -462,16 +476,108 @@ func (ctrl *Controller) syncFailingStatus(pool *mcfgv1.MachineConfigPool, err er
 	return err
 }
 
-// This function will eventually contain a sane garbage collection policy for rendered MachineConfigs;
-// see https://github.com/openshift/machine-config-operator/issues/301
-// It will probably involve making sure we're only GCing a config after all nodes don't have it
-// in either desired or current config.
+// garbageCollectRenderedConfigs deletes the rendered MachineConfigs generated for the pool
+// which are no longer needed; see https://github.com/openshift/machine-config-operator/issues/301
+// A rendered MachineConfig is kept if any node has it in either desired or current config, if any
+// pool targets it in spec or status, or if it is among the renderedConfigHistoryLimit most recent
+// rendered MachineConfigs of the pool, so that it remains available for a rollback.
 func (ctrl *Controller) garbageCollectRenderedConfigs(pool *mcfgv1.MachineConfigPool) error {
-	// Temporarily until https://github.com/openshift/machine-config-operator/pull/318
-	// which depends on the strategy for https://github.com/openshift/machine-config-operator/issues/301
+	inUse, err := ctrl.getRenderedConfigsInUse()
+	if err != nil {
+		return err
+	}
+
+	mcs, err := ctrl.mcLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	var rendered []*mcfgv1.MachineConfig
+	for _, mc := range mcs {
+		if mc.DeletionTimestamp != nil || !isRenderedConfigForPool(mc, pool) {
+			continue
+		}
+		rendered = append(rendered, mc)
+	}
+
+	// Newest first, so the history we keep is the most recent one.
+	sort.SliceStable(rendered, func(i, j int) bool {
+		ti, tj := rendered[i].CreationTimestamp, rendered[j].CreationTimestamp
+		if ti.Equal(&tj) {
+			return rendered[i].Name < rendered[j].Name
+		}
+		return tj.Before(&ti)
+	})
+
+	// The referenced configs are always kept, only the unreferenced ones count toward the history limit.
+	historyLimit := getRenderedConfigHistoryLimit(pool)
+	kept := 0
+	for _, mc := range rendered {
+		if inUse.Has(mc.Name) {
+			continue
+		}
+		if kept < historyLimit {
+			kept++
+			continue
+		}
+		err := ctrl.client.MachineconfigurationV1().MachineConfigs().Delete(context.TODO(), mc.Name, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete rendered MachineConfig %s: %v", mc.Name, err)
+		}
+		glog.Infof("Pool %s: deleted unused rendered MachineConfig %s", pool.Name, mc.Name)
+		ctrl.eventRecorder.Eventf(pool, corev1.EventTypeNormal, "DeletedRenderedConfig", "Deleted unused rendered MachineConfig %s", mc.Name)
+	}
 	return nil
 }
 
+// getRenderedConfigsInUse returns the names of the rendered MachineConfigs that are referenced
+// by any node annotation or by the spec or status configuration of any pool.
+func (ctrl *Controller) getRenderedConfigsInUse() (sets.String, error) {
+	inUse := sets.NewString()
+
+	pools, err := ctrl.mcpLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pools {
+		inUse.Insert(p.Spec.Configuration.Name, p.Status.Configuration.Name)
+	}
+
+	nodes, err := ctrl.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		inUse.Insert(node.Annotations[daemonconsts.CurrentMachineConfigAnnotationKey], node.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey])
+	}
+
+	inUse.Delete("")
+	return inUse, nil
+}
+
+// isRenderedConfigForPool checks whether the MachineConfig was rendered by this controller for the pool.
+func isRenderedConfigForPool(mc *mcfgv1.MachineConfig, pool *mcfgv1.MachineConfigPool) bool {
+	controllerRef := metav1.GetControllerOf(mc)
+	if controllerRef == nil || controllerRef.Kind != controllerKind.Kind || controllerRef.Name != pool.Name {
+		return false
+	}
+	// Configs rendered at bootstrap carry an owner reference without UID.
+	if controllerRef.UID != "" && controllerRef.UID != pool.UID {
+		return false
+	}
+	return strings.HasPrefix(mc.Name, fmt.Sprintf("rendered-%s-", pool.Name))
+}
+
+// getRenderedConfigHistoryLimit returns the number of rendered MachineConfigs to keep for the pool.
+func getRenderedConfigHistoryLimit(pool *mcfgv1.MachineConfigPool) int {
+	if pool.Spec.RenderedConfigHistoryLimit == nil || *pool.Spec.RenderedConfigHistoryLimit < 0 {
+		return defaultRenderedConfigHistoryLimit
+	}
+	return int(*pool.Spec.RenderedConfigHistoryLimit)
+}
+
 func (ctrl *Controller) syncGeneratedMachineConfig(pool *mcfgv1.MachineConfigPool, configs []*mcfgv1.MachineConfig) error {
 	if len(configs) == 0 {
 		return nil
@@ -501,6 +607,11 @@ func (ctrl *Controller) syncGeneratedMachineConfig(pool *mcfgv1.MachineConfigPoo
 		return err
 	}
 
+	if pool.Annotations[ctrlcommon.RolledBackConfigAnnotationKey] == generated.Name {
+		glog.V(2).Infof("Pool %s: not targeting %s, the pool was rolled back from it", pool.Name, generated.Name)
+		return nil
+	}
+
 	newPool := pool.DeepCopy()
 	newPool.Spec.Configuration.Source = source
 
@@ -509,11 +620,15 @@ func (ctrl *Controller) syncGeneratedMachineConfig(pool *mcfgv1.MachineConfigPoo
 		if err != nil {
 			return err
 		}
-		_, err = ctrl.client.MachineconfigurationV1().MachineConfigPools().Update(context.TODO(), newPool, metav1.UpdateOptions{})
-		return err
+		pool, err = ctrl.client.MachineconfigurationV1().MachineConfigPools().Update(context.TODO(), newPool, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		return ctrl.garbageCollectRenderedConfigs(pool)
 	}
 
 	newPool.Spec.Configuration.Name = generated.Name
+	delete(newPool.Annotations, ctrlcommon.RolledBackConfigAnnotationKey)
 	// TODO(walters) Use subresource or JSON patch, but the latter isn't supported by the unit test mocks
 	pool, err = ctrl.client.MachineconfigurationV1().MachineConfigPools().Update(context.TODO(), newPool, metav1.UpdateOptions{})
 	if err != nil {
@@ -19,6 +19,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/diff"
 	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	kubeinformers "k8s.io/client-go/informers"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	core "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
@@ -41,11 +42,13 @@ var (
 type fixture struct {
 	t *testing.T
 
-	client *fake.Clientset
+	client     *fake.Clientset
+	kubeclient *k8sfake.Clientset
 
-	mcpLister []*mcfgv1.MachineConfigPool
-	mcLister  []*mcfgv1.MachineConfig
-	ccLister  []*mcfgv1.ControllerConfig
+	mcpLister  []*mcfgv1.MachineConfigPool
+	mcLister   []*mcfgv1.MachineConfig
+	ccLister   []*mcfgv1.ControllerConfig
+	nodeLister []*corev1.Node
 
 	actions []core.Action
 
@@ -61,21 +64,26 @@ func newFixture(t *testing.T) *fixture {
 
 func (f *fixture) newController() *Controller {
 	f.client = fake.NewSimpleClientset(f.objects...)
+	f.kubeclient = k8sfake.NewSimpleClientset()
 
 	i := informers.NewSharedInformerFactory(f.client, noResyncPeriodFunc())
+	k8sI := kubeinformers.NewSharedInformerFactory(f.kubeclient, noResyncPeriodFunc())
 
 	c := New(i.Machineconfiguration().V1().MachineConfigPools(), i.Machineconfiguration().V1().MachineConfigs(),
-		i.Machineconfiguration().V1().ControllerConfigs(), k8sfake.NewSimpleClientset(), f.client)
+		i.Machineconfiguration().V1().ControllerConfigs(), k8sI.Core().V1().Nodes(), f.kubeclient, f.client)
 
 	c.mcpListerSynced = alwaysReady
 	c.mcListerSynced = alwaysReady
 	c.ccListerSynced = alwaysReady
+	c.nodeListerSynced = alwaysReady
 	c.eventRecorder = &record.FakeRecorder{}
 
 	stopCh := make(chan struct{})
 	defer close(stopCh)
 	i.Start(stopCh)
 	i.WaitForCacheSync(stopCh)
+	k8sI.Start(stopCh)
+	k8sI.WaitForCacheSync(stopCh)
 
 	for _, c := range f.ccLister {
 		i.Machineconfiguration().V1().ControllerConfigs().Informer().GetIndexer().Add(c)
@@ -90,6 +98,9 @@ func (f *fixture) newController() *Controller {
 	for _, m := range f.ccLister {
 		i.Machineconfiguration().V1().ControllerConfigs().Informer().GetIndexer().Add(m)
 	}
+	for _, n := range f.nodeLister {
+		k8sI.Core().V1().Nodes().Informer().GetIndexer().Add(n)
+	}
 
 	return c
 }
@@ -515,3 +526,102 @@ func TestMachineConfigsNoBailWithoutPool(t *testing.T) {
 	c.deleteMachineConfig(mc)
 	require.Len(t, queue, 3)
 }
+
+func newRenderedMachineConfig(pool *mcfgv1.MachineConfigPool, hash string, created time.Time) *mcfgv1.MachineConfig {
+	mc := helpers.NewMachineConfig(fmt.Sprintf("rendered-%s-%s", pool.Name, hash), nil, "dummy://", []ign3types.File{})
+	mc.CreationTimestamp = metav1.NewTime(created)
+	mc.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(pool, controllerKind)})
+	return mc
+}
+
+func TestGarbageCollectRenderedConfigs(t *testing.T) {
+	f := newFixture(t)
+	mcp := helpers.NewMachineConfigPool("test-cluster-worker", helpers.WorkerSelector, nil, "")
+	mcp.UID = types.UID(utilrand.String(5))
+	mcp.Spec.RenderedConfigHistoryLimit = new(int32)
+	*mcp.Spec.RenderedConfigHistoryLimit = 1
+
+	now := time.Now()
+	mcs := []*mcfgv1.MachineConfig{
+		newRenderedMachineConfig(mcp, "a", now.Add(-5*time.Hour)),
+		newRenderedMachineConfig(mcp, "b", now.Add(-4*time.Hour)),
+		newRenderedMachineConfig(mcp, "c", now.Add(-3*time.Hour)),
+		newRenderedMachineConfig(mcp, "d", now.Add(-2*time.Hour)),
+		newRenderedMachineConfig(mcp, "e", now.Add(-1*time.Hour)),
+		// Not owned by the pool, must be left alone.
+		helpers.NewMachineConfig("rendered-test-cluster-worker-z", nil, "dummy://", []ign3types.File{}),
+	}
+	mcs[5].CreationTimestamp = metav1.NewTime(now.Add(-10 * time.Hour))
+	mcp.Spec.Configuration.Name = mcs[3].Name
+	mcp.Status.Configuration.Name = mcs[2].Name
+
+	n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0", Annotations: map[string]string{
+		daemonconsts.CurrentMachineConfigAnnotationKey: mcs[0].Name,
+		daemonconsts.DesiredMachineConfigAnnotationKey: mcs[0].Name,
+	}}}
+
+	f.mcpLister = append(f.mcpLister, mcp)
+	f.objects = append(f.objects, mcp)
+	f.nodeLister = append(f.nodeLister, n)
+	f.mcLister = append(f.mcLister, mcs...)
+	for idx := range mcs {
+		f.objects = append(f.objects, mcs[idx])
+	}
+
+	c := f.newController()
+	require.Nil(t, c.garbageCollectRenderedConfigs(mcp))
+
+	// rendered-...-a is on a node, c and d are referenced by the pool, e is the most recent one.
+	actions := filterInformerActions(f.client.Actions())
+	require.Len(t, actions, 1)
+	require.True(t, actions[0].Matches("delete", "machineconfigs"))
+	assert.Equal(t, mcs[1].Name, actions[0].(core.DeleteAction).GetName())
+}
+
+func TestGarbageCollectRenderedConfigsReferencedNewest(t *testing.T) {
+	f := newFixture(t)
+	mcp := helpers.NewMachineConfigPool("test-cluster-worker", helpers.WorkerSelector, nil, "")
+	mcp.UID = types.UID(utilrand.String(5))
+	mcp.Spec.RenderedConfigHistoryLimit = new(int32)
+	*mcp.Spec.RenderedConfigHistoryLimit = 1
+
+	now := time.Now()
+	mcs := []*mcfgv1.MachineConfig{
+		newRenderedMachineConfig(mcp, "a", now.Add(-4*time.Hour)),
+		newRenderedMachineConfig(mcp, "b", now.Add(-3*time.Hour)),
+		newRenderedMachineConfig(mcp, "c", now.Add(-2*time.Hour)),
+		newRenderedMachineConfig(mcp, "d", now.Add(-1*time.Hour)),
+	}
+	// The pool is rolling out its newest config.
+	mcp.Spec.Configuration.Name = mcs[3].Name
+	mcp.Status.Configuration.Name = mcs[3].Name
+
+	f.mcpLister = append(f.mcpLister, mcp)
+	f.objects = append(f.objects, mcp)
+	f.mcLister = append(f.mcLister, mcs...)
+	for idx := range mcs {
+		f.objects = append(f.objects, mcs[idx])
+	}
+
+	c := f.newController()
+	require.Nil(t, c.garbageCollectRenderedConfigs(mcp))
+
+	// d is referenced by the pool and doesn't count toward the limit: c is kept for a rollback.
+	var deleted []string
+	for _, action := range filterInformerActions(f.client.Actions()) {
+		require.True(t, action.Matches("delete", "machineconfigs"))
+		deleted = append(deleted, action.(core.DeleteAction).GetName())
+	}
+	assert.ElementsMatch(t, []string{mcs[0].Name, mcs[1].Name}, deleted)
+}
+
+func TestRenderedConfigHistoryLimit(t *testing.T) {
+	mcp := helpers.NewMachineConfigPool("test-cluster-worker", helpers.WorkerSelector, nil, "")
+	assert.Equal(t, defaultRenderedConfigHistoryLimit, getRenderedConfigHistoryLimit(mcp))
+
+	mcp.Spec.RenderedConfigHistoryLimit = new(int32)
+	assert.Equal(t, 0, getRenderedConfigHistoryLimit(mcp))
+
+	*mcp.Spec.RenderedConfigHistoryLimit = 10
+	assert.Equal(t, 10, getRenderedConfigHistoryLimit(mcp))
+}
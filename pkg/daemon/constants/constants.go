@@ -17,6 +17,9 @@ const (
 	// ClusterControlPlaneTopologyAnnotationKey is set by the node controller by reading value from
 	// controllerConfig. MCD uses the annotation value to decide drain action on the node.
 	ClusterControlPlaneTopologyAnnotationKey = "machineconfiguration.openshift.io/controlPlaneTopology"
+	// PoolSettingsAnnotationKey is set by the node controller to the JSON encoded settings of the pool of the
+	// node, see common.PoolSettings. MCD uses them to drain, check and update the node.
+	PoolSettingsAnnotationKey = "machineconfiguration.openshift.io/poolSettings"
 	// OpenShiftOperatorManagedLabel is used to filter out kube objects that don't need to be synced by the MCO
 	OpenShiftOperatorManagedLabel = "openshift.io/operator-managed"
 
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -67,8 +66,8 @@ func setRootDeviceSchedulerBFQ() error {
 
 // updateOstreeObjectSync enables "per-object-fsync" which helps avoid
 // latency spikes for etcd; see https://github.com/ostreedev/ostree/pull/2152
-func updateOstreeObjectSync() error {
-	if err := exec.Command("ostree", "--repo=/sysroot/ostree/repo", "config", "set", "core.per-object-fsync", "true").Run(); err != nil {
+func updateOstreeObjectSync(runner HostRunner) error {
+	if _, err := runner.Run("ostree", "--repo=/sysroot/ostree/repo", "config", "set", "core.per-object-fsync", "true"); err != nil {
 		return errors.Wrapf(err, "Failed to set per-object-fsync for ostree")
 	}
 	return nil
@@ -79,7 +78,7 @@ func updateOstreeObjectSync() error {
 // scheduler too but we now only do that late in the process when
 // we go to start an OS update.
 func (dn *Daemon) initializeControlPlane() error {
-	if err := updateOstreeObjectSync(); err != nil {
+	if err := updateOstreeObjectSync(dn.runner); err != nil {
 		return err
 	}
 	return nil
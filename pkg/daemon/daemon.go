@@ -10,7 +10,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strings"
@@ -64,6 +63,19 @@ type Daemon struct {
 	// NodeUpdaterClient an instance of the client which interfaces with host content deployments
 	NodeUpdaterClient NodeUpdaterClient
 
+	// runner runs the commands on the host
+	runner HostRunner
+
+	// systemd manages the systemd units of the host
+	systemd SystemdClient
+
+	// root is the directory the host filesystem is accessed through.
+	// It's only changed by the tests, which run the daemon in a temporary directory.
+	root string
+
+	// waitForReboot blocks until the MCD is killed by the reboot.
+	waitForReboot func()
+
 	// bootID is a unique value per boot (generated by the kernel)
 	bootID string
 
@@ -92,6 +104,9 @@ type Daemon struct {
 	updateActive     bool
 	updateActiveLock sync.Mutex
 
+	// lastDriftCheck is when the node was last checked for drift from its current config
+	lastDriftCheck time.Time
+
 	nodeWriter NodeWriter
 
 	// channel used by callbacks to signal Run() of an error
@@ -120,6 +135,15 @@ type Daemon struct {
 
 	currentConfigPath string
 
+	pendingStatePath string
+
+	passwdStatePath string
+
+	healthCheckFailurePath string
+
+	// osImageStager extracts OS images ahead of the updates
+	osImageStager *osImageStager
+
 	loggerSupportsJournal bool
 
 	drainer *drain.Helper
@@ -133,8 +157,10 @@ const (
 	// currentConfigPath is where we store the current config on disk to validate
 	// against annotations changes
 	currentConfigPath = "/etc/machine-config-daemon/currentconfig"
-	// pendingStateMessageID is the id we store the pending state in journal. We use it to
-	// also retrieve the pending config after a reboot
+	// pendingStatePath is where we store the state of the update across reboots
+	pendingStatePath = "/etc/machine-config-daemon/pending-state.json"
+	// pendingStateMessageID is the id the pending state used to be stored with in the journal.
+	// We only read it to migrate to the pending state file.
 	pendingStateMessageID = "machine-config-daemon-pending-state"
 
 	kubeletHealthzPollingInterval = 30 * time.Second
@@ -163,16 +189,22 @@ var (
 	defaultRebootTimeout = 24 * time.Hour
 )
 
-// rebootCommand creates a new transient systemd unit to reboot the system.
+// hostPath returns where the path of the host filesystem is accessed from.
+func (dn *Daemon) hostPath(path string) string {
+	return filepath.Join(dn.root, path)
+}
+
+// rebootCommand returns the arguments of systemd-run creating a new transient
+// systemd unit to reboot the system.
 // We explicitly try to stop kubelet.service first, before anything else; this
 // way we ensure the rest of system stays running, because kubelet may need
 // to do "graceful" shutdown by e.g. de-registering with a load balancer.
 // However note we use `;` instead of `&&` so we keep rebooting even
 // if kubelet failed to shutdown - that way the machine will still eventually reboot
 // as systemd will time out the stop invocation.
-func rebootCommand(rationale string) *exec.Cmd {
-	return exec.Command("systemd-run", "--unit", "machine-config-daemon-reboot",
-		"--description", fmt.Sprintf("machine-config-daemon: %s", rationale), "/bin/sh", "-c", "systemctl stop kubelet.service; systemctl reboot")
+func rebootCommand(rationale string) []string {
+	return []string{"--unit", "machine-config-daemon-reboot",
+		"--description", fmt.Sprintf("machine-config-daemon: %s", rationale), "/bin/sh", "-c", "systemctl stop kubelet.service; systemctl reboot"}
 }
 
 // getBootID loads the unique "boot id" which is generated by the Linux kernel.
@@ -227,11 +259,17 @@ func New(
 		}
 	}
 
+	runner := execRunner{}
+	var systemd SystemdClient = &systemctlClient{runner: runner}
+	if !mock {
+		systemd = newSystemdClient(runner)
+	}
+
 	// RHEL 7.6/Centos 7 logger (util-linux) doesn't have the --journald flag
 	loggerSupportsJournal := true
 	if !mock {
 		if os.IsLikeTraditionalRHEL7() {
-			loggerOutput, err := exec.Command("logger", "--help").CombinedOutput()
+			loggerOutput, err := runner.Run("logger", "--help")
 			if err != nil {
 				return nil, errors.Wrapf(err, "running logger --help")
 			}
@@ -242,17 +280,26 @@ func New(
 	// report OS & version (if RHCOS or FCOS) to prometheus
 	HostOS.WithLabelValues(os.ToPrometheusLabel(), osVersion).Set(1)
 
-	return &Daemon{
-		mock:                  mock,
-		booting:               true,
-		os:                    os,
-		NodeUpdaterClient:     nodeUpdaterClient,
-		bootedOSImageURL:      osImageURL,
-		bootID:                bootID,
-		exitCh:                exitCh,
-		currentConfigPath:     currentConfigPath,
-		loggerSupportsJournal: loggerSupportsJournal,
-	}, nil
+	dn := &Daemon{
+		mock:                   mock,
+		booting:                true,
+		os:                     os,
+		NodeUpdaterClient:      nodeUpdaterClient,
+		runner:                 runner,
+		systemd:                systemd,
+		bootedOSImageURL:       osImageURL,
+		bootID:                 bootID,
+		exitCh:                 exitCh,
+		currentConfigPath:      currentConfigPath,
+		pendingStatePath:       pendingStatePath,
+		passwdStatePath:        passwdStatePath,
+		healthCheckFailurePath: healthCheckFailurePath,
+		root:                   "/",
+		waitForReboot:          func() { time.Sleep(defaultRebootTimeout) },
+		loggerSupportsJournal:  loggerSupportsJournal,
+	}
+	dn.osImageStager = newOSImageStager(dn.hostPath(stagedOSImageContentBaseDir), dn.extractOSImage)
+	return dn, nil
 }
 
 // ClusterConnect sets up the systemd and kubernetes connections needed to update the
@@ -440,10 +487,10 @@ func (dn *Daemon) syncNode(key string) error {
 	// finalizing an update and/or reconciling the current and desired machine configs.
 	if dn.booting {
 		// Be sure only the MCD is running now, disable -firstboot.service
-		if err := upgradeHackFor44AndBelow(); err != nil {
+		if err := dn.upgradeHackFor44AndBelow(); err != nil {
 			return err
 		}
-		if err := removeIgnitionArtifacts(); err != nil {
+		if err := dn.removeIgnitionArtifacts(); err != nil {
 			return err
 		}
 		if err := dn.checkStateOnFirstRun(); err != nil {
@@ -456,6 +503,8 @@ func (dn *Daemon) syncNode(key string) error {
 		return nil
 	}
 
+	dn.stagePoolTargetOSImage()
+
 	// Pass to the shared update prep method
 	current, desired, err := dn.prepUpdateFromCluster()
 	if err != nil {
@@ -467,6 +516,7 @@ func (dn *Daemon) syncNode(key string) error {
 		}
 	}
 	glog.V(2).Infof("Node %s is already synced", node.Name)
+	dn.syncDrift()
 	return nil
 }
 
@@ -488,7 +538,7 @@ const (
 
 // detectEarlySSHAccessesFromBoot annotates the node if we find a login before the daemon started up.
 func (dn *Daemon) detectEarlySSHAccessesFromBoot() error {
-	journalOutput, err := exec.Command("journalctl", "-b", "-o", "cat", "-u", logindUnit, "MESSAGE_ID="+sdMessageSessionStart).CombinedOutput()
+	journalOutput, err := dn.runner.Run("journalctl", "-b", "-o", "cat", "-u", logindUnit, "MESSAGE_ID="+sdMessageSessionStart)
 	if err != nil {
 		return err
 	}
@@ -524,7 +574,7 @@ func (dn *Daemon) RunOnceFrom(onceFrom string, skipReboot bool) error {
 // RunFirstbootCompleteMachineconfig is run via systemd on the first boot
 // to complete processing of the target MachineConfig.
 func (dn *Daemon) RunFirstbootCompleteMachineconfig() error {
-	data, err := ioutil.ReadFile(constants.MachineConfigEncapsulatedPath)
+	data, err := ioutil.ReadFile(dn.hostPath(constants.MachineConfigEncapsulatedPath))
 	if err != nil {
 		return err
 	}
@@ -547,7 +597,7 @@ func (dn *Daemon) RunFirstbootCompleteMachineconfig() error {
 	}
 	if !mcDiffNotEmpty {
 		// Removing this file signals completion of the initial MC processing.
-		if err := os.Remove(constants.MachineConfigEncapsulatedPath); err != nil {
+		if err := os.Remove(dn.hostPath(constants.MachineConfigEncapsulatedPath)); err != nil {
 			return errors.Wrapf(err, "failed to remove %s", constants.MachineConfigEncapsulatedPath)
 		}
 		return nil
@@ -560,7 +610,7 @@ func (dn *Daemon) RunFirstbootCompleteMachineconfig() error {
 	}
 
 	// Removing this file signals completion of the initial MC processing.
-	if err := os.Rename(constants.MachineConfigEncapsulatedPath, constants.MachineConfigEncapsulatedBakPath); err != nil {
+	if err := os.Rename(dn.hostPath(constants.MachineConfigEncapsulatedPath), dn.hostPath(constants.MachineConfigEncapsulatedBakPath)); err != nil {
 		return errors.Wrap(err, "failed to rename encapsulated MachineConfig after processing on firstboot")
 	}
 
@@ -621,6 +671,7 @@ func (dn *Daemon) Run(stopCh <-chan struct{}, exitCh <-chan error) error {
 	}
 
 	go wait.Until(dn.worker, time.Second, stopCh)
+	go dn.runDriftMonitor(stopCh)
 
 	for {
 		select {
@@ -636,16 +687,13 @@ func (dn *Daemon) Run(stopCh <-chan struct{}, exitCh <-chan error) error {
 }
 
 func (dn *Daemon) runLoginMonitor(stopCh <-chan struct{}, exitCh chan<- error) {
-	cmd := exec.Command("journalctl", "-b", "-f", "-o", "cat", "-u", logindUnit, "MESSAGE_ID="+sdMessageSessionStart)
-	stdout, err := cmd.StdoutPipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stdout, err := dn.runner.Start(ctx, "journalctl", "-b", "-f", "-o", "cat", "-u", logindUnit, "MESSAGE_ID="+sdMessageSessionStart)
 	if err != nil {
 		exitCh <- err
 		return
 	}
-	if err := cmd.Start(); err != nil {
-		exitCh <- err
-		return
-	}
 	worker := make(chan struct{})
 	go func() {
 		for {
@@ -675,7 +723,8 @@ func (dn *Daemon) runLoginMonitor(stopCh <-chan struct{}, exitCh chan<- error) {
 	}()
 	<-stopCh
 	close(worker)
-	cmd.Process.Kill()
+	cancel()
+	stdout.Close()
 }
 
 func (dn *Daemon) applySSHAccessedAnnotation() error {
@@ -754,7 +803,7 @@ type stateAndConfigs struct {
 }
 
 func (dn *Daemon) getStateAndConfigs(pendingConfigName string) (*stateAndConfigs, error) {
-	_, err := os.Lstat(constants.InitialNodeAnnotationsFilePath)
+	_, err := os.Lstat(dn.hostPath(constants.InitialNodeAnnotationsFilePath))
 	var bootstrapping bool
 	if err != nil {
 		if !os.IsNotExist(err) {
@@ -849,7 +898,7 @@ func (dn *Daemon) LogSystemData() {
 		glog.Info(status)
 	}
 
-	boots, err := runGetOut("journalctl", "--list-boots")
+	boots, err := dn.runner.Run("journalctl", "--list-boots")
 	if err != nil {
 		glog.Errorf("Listing boots: %v", err)
 	}
@@ -862,58 +911,18 @@ func (dn *Daemon) LogSystemData() {
 	// to also watch dynamically of course.
 	//
 	// also xref https://github.com/coreos/console-login-helper-messages/blob/e8a849f4c23910e7c556c10719911cc59873fc23/usr/share/console-login-helper-messages/profile.sh
-	failedServices, err := runGetOut("systemctl", "list-units", "--state=failed", "--no-legend")
+	failedServices, err := dn.systemd.FailedUnits()
 	if err != nil {
 		glog.Errorf("Listing failed systemd services: %v", err)
 	} else if len(failedServices) > 0 {
-		glog.Infof("systemctl --failed:\n" + string(failedServices))
+		glog.Infof("Failed systemd services: %s", strings.Join(failedServices, " "))
 	} else {
 		glog.Info("systemd service state: OK")
 	}
 }
 
-const (
-	pendingConfigPath = "/etc/machine-config-daemon/state.json"
-)
-
-type pendingConfigState struct {
-	PendingConfig string `json:"pendingConfig,omitempty"`
-	BootID        string `json:"bootID,omitempty"`
-}
-
-// XXX: drop this
-func (dn *Daemon) writePendingConfig(desiredConfig *mcfgv1.MachineConfig) error {
-	t := &pendingConfigState{
-		PendingConfig: desiredConfig.GetName(),
-		BootID:        dn.bootID,
-	}
-	b, err := json.Marshal(t)
-	if err != nil {
-		return err
-	}
-	return writeFileAtomicallyWithDefaults(pendingConfigPath, b)
-}
-
-// XXX: drop this
-// we need this compatibility layer for now
-func (dn *Daemon) getPendingConfig() (*pendingConfigState, error) {
-	s, err := ioutil.ReadFile("/etc/machine-config-daemon/state.json")
-	if err != nil {
-		if !os.IsNotExist(err) {
-			return nil, errors.Wrapf(err, "loading transient state")
-		}
-		return nil, nil
-	}
-	var p pendingConfigState
-	if err := json.Unmarshal(s, &p); err != nil {
-		return nil, errors.Wrapf(err, "parsing transient state")
-	}
-
-	return &p, nil
-}
-
 func (dn *Daemon) getCurrentConfigOnDisk() (*mcfgv1.MachineConfig, error) {
-	mcJSON, err := os.Open(dn.currentConfigPath)
+	mcJSON, err := os.Open(dn.hostPath(dn.currentConfigPath))
 	if err != nil {
 		return nil, err
 	}
@@ -930,7 +939,7 @@ func (dn *Daemon) storeCurrentConfigOnDisk(current *mcfgv1.MachineConfig) error
 	if err != nil {
 		return err
 	}
-	return writeFileAtomicallyWithDefaults(dn.currentConfigPath, mcJSON)
+	return dn.writeFileAtomicallyWithDefaults(dn.currentConfigPath, mcJSON)
 }
 
 // https://bugzilla.redhat.com/show_bug.cgi?id=1842906
@@ -939,12 +948,12 @@ func (dn *Daemon) storeCurrentConfigOnDisk(current *mcfgv1.MachineConfig) error
 // things.  At this point we should have already applied all target
 // changes, so just rename the file to .bak the same as the -firstboot
 // path does.
-func upgradeHackFor44AndBelow() error {
-	_, err := os.Stat(constants.MachineConfigEncapsulatedPath)
+func (dn *Daemon) upgradeHackFor44AndBelow() error {
+	_, err := os.Stat(dn.hostPath(constants.MachineConfigEncapsulatedPath))
 	if err == nil {
 		glog.Warningf("Failed to complete machine-config-daemon-firstboot before joining cluster!")
 		// Removing this file signals completion of the initial MC processing.
-		if err := os.Rename(constants.MachineConfigEncapsulatedPath, constants.MachineConfigEncapsulatedBakPath); err != nil {
+		if err := os.Rename(dn.hostPath(constants.MachineConfigEncapsulatedPath), dn.hostPath(constants.MachineConfigEncapsulatedBakPath)); err != nil {
 			return errors.Wrap(err, "failed to rename encapsulated MachineConfig after processing on firstboot")
 		}
 	}
@@ -954,8 +963,8 @@ func upgradeHackFor44AndBelow() error {
 // Remove artifacts used by ignition, that the MCO should no longer
 // use since the machine is up.
 // Currently removes the systemd preset file written by Ignition.
-func removeIgnitionArtifacts() error {
-	if err := os.Remove(constants.IgnitionSystemdPresetFile); err != nil && !os.IsNotExist(err) {
+func (dn *Daemon) removeIgnitionArtifacts() error {
+	if err := os.Remove(dn.hostPath(constants.IgnitionSystemdPresetFile)); err != nil && !os.IsNotExist(err) {
 		return errors.Wrap(err, "failed to remove Ignition-written systemd preset file")
 	}
 	return nil
@@ -983,27 +992,19 @@ func (dn *Daemon) checkStateOnFirstRun() error {
 	}
 	var pendingConfigName, bootID string
 	if pendingState != nil {
-		pendingConfigName = pendingState.Message
+		pendingConfigName = pendingState.Config
 		bootID = pendingState.BootID
 	}
-	// XXX: drop this
-	// we need this compatibility layer for now
-	if pendingState == nil {
-		legacyPendingState, err := dn.getPendingConfig()
-		if err != nil {
-			return err
-		}
-		if legacyPendingState != nil {
-			pendingConfigName = legacyPendingState.PendingConfig
-			bootID = legacyPendingState.BootID
-		}
-	}
 
 	state, err := dn.getStateAndConfigs(pendingConfigName)
 	if err != nil {
 		return err
 	}
 
+	if err := dn.checkHealthCheckFailure(state); err != nil {
+		return err
+	}
+
 	// if we have a pendingConfig but we're into the same bootid, we failed to drain or reboot
 	// and if we still have a pendingConfig it means we've been killed by kube after 600s
 	// take a stab at that and re-run the drain+reboot routine
@@ -1029,7 +1030,7 @@ func (dn *Daemon) checkStateOnFirstRun() error {
 		if !osMatch {
 			glog.Infof("Bootstrap pivot required to: %s", targetOSImageURL)
 			// This only returns on error
-			osImageContentDir, err := ExtractOSImage(targetOSImageURL)
+			osImageContentDir, err := ExtractOSImage(dn.runner, targetOSImageURL)
 			if err != nil {
 				return err
 			}
@@ -1049,7 +1050,7 @@ func (dn *Daemon) checkStateOnFirstRun() error {
 		// Rename the bootstrap node annotations; the
 		// currentConfig's osImageURL should now be *truth*.
 		// In other words if it drifts somehow, we go degraded.
-		if err := os.Rename(constants.InitialNodeAnnotationsFilePath, constants.InitialNodeAnnotationsBakPath); err != nil {
+		if err := os.Rename(dn.hostPath(constants.InitialNodeAnnotationsFilePath), dn.hostPath(constants.InitialNodeAnnotationsBakPath)); err != nil {
 			return errors.Wrap(err, "renaming initial node annotation file")
 		}
 	}
@@ -1089,11 +1090,19 @@ func (dn *Daemon) checkStateOnFirstRun() error {
 		expectedConfig = state.currentConfig
 	}
 
-	if _, err := os.Stat(constants.MachineConfigDaemonForceFile); err != nil {
+	if _, err := os.Stat(dn.hostPath(constants.MachineConfigDaemonForceFile)); err != nil {
 		if err := dn.validateOnDiskState(expectedConfig); err != nil {
 			return fmt.Errorf("unexpected on-disk state validating against %s: %v", expectedConfig.GetName(), err)
 		}
 		glog.Info("Validated on-disk state")
+		if state.pendingConfig != nil {
+			if err := dn.verifyBootHealth(state.pendingConfig, state.currentConfig); err != nil {
+				return err
+			}
+			if err := dn.runUpdateHooks(mcfgv1.UpdateHookPhaseAfterBoot, state.currentConfig, state.pendingConfig); err != nil {
+				return err
+			}
+		}
 	} else {
 		glog.Infof("Skipping on-disk validation; %s present", constants.MachineConfigDaemonForceFile)
 		return dn.triggerUpdateWithMachineConfig(state.currentConfig, state.desiredConfig)
@@ -1127,8 +1136,8 @@ func (dn *Daemon) updateConfigAndState(state *stateAndConfigs) (bool, error) {
 		if err := dn.nodeWriter.SetDone(dn.kubeClient.CoreV1().Nodes(), dn.nodeLister, dn.name, state.pendingConfig.GetName()); err != nil {
 			return true, errors.Wrap(err, "error setting node's state to Done")
 		}
-		if out, err := dn.storePendingState(state.pendingConfig, 0); err != nil {
-			return true, errors.Wrapf(err, "failed to reset pending config: %s", string(out))
+		if err := dn.storePendingState(state.pendingConfig, updatePhaseDone); err != nil {
+			return true, errors.Wrap(err, "failed to reset pending config")
 		}
 
 		state.currentConfig = state.pendingConfig
@@ -1153,6 +1162,10 @@ func (dn *Daemon) updateConfigAndState(state *stateAndConfigs) (bool, error) {
 				MCDUpdateState.WithLabelValues("", err.Error()).SetToCurrentTime()
 				return inDesiredConfig, err
 			}
+			// the OS image was staged for the update, it's booted now
+			if dn.osImageStager != nil {
+				dn.osImageStager.remove(state.pendingConfig.Spec.OSImageURL)
+			}
 		}
 		// If we're degraded here, it means we got an error likely on startup and we retried.
 		// If that's the case, clear it out.
@@ -1207,7 +1220,11 @@ func (dn *Daemon) runOnceFromMachineConfig(machineConfig mcfgv1.MachineConfig, c
 // runOnceFromIgnition executes MCD's subset of Ignition functionality in onceFrom mode
 func (dn *Daemon) runOnceFromIgnition(ignConfig ign3types.Config) error {
 	// Execute update without hitting the cluster
-	if err := dn.writeFiles(ignConfig.Storage.Files); err != nil {
+	fetched, err := fetchRemoteFiles(ignConfig.Storage.Files)
+	if err != nil {
+		return err
+	}
+	if err := dn.writeFiles(ignConfig.Storage.Files, fetched); err != nil {
 		return err
 	}
 	if err := dn.writeUnits(ignConfig.Systemd.Units); err != nil {
@@ -1217,9 +1234,9 @@ func (dn *Daemon) runOnceFromIgnition(ignConfig ign3types.Config) error {
 	// case.  We use this file to suppress things like kubelet and SDN
 	// starting on CoreOS during the firstboot/pivot boot, but there's
 	// no such thing on classic RHEL.
-	_, err := os.Stat(constants.MachineConfigEncapsulatedPath)
+	_, err = os.Stat(dn.hostPath(constants.MachineConfigEncapsulatedPath))
 	if err == nil {
-		if err := os.Remove(constants.MachineConfigEncapsulatedPath); err != nil {
+		if err := os.Remove(dn.hostPath(constants.MachineConfigEncapsulatedPath)); err != nil {
 			return errors.Wrapf(err, "failed to remove %s", constants.MachineConfigEncapsulatedPath)
 		}
 	}
@@ -1340,18 +1357,18 @@ func (dn *Daemon) validateOnDiskState(currentConfig *mcfgv1.MachineConfig) error
 
 	switch typedConfig := ignconfigi.(type) {
 	case ign3types.Config:
-		if err := checkV3Files(ignconfigi.(ign3types.Config).Storage.Files); err != nil {
+		if err := checkV3Files(dn.root, ignconfigi.(ign3types.Config).Storage.Files); err != nil {
 			return err
 		}
-		if err := checkV3Units(ignconfigi.(ign3types.Config).Systemd.Units); err != nil {
+		if err := checkV3Units(dn.root, ignconfigi.(ign3types.Config).Systemd.Units); err != nil {
 			return err
 		}
 		return nil
 	case ign2types.Config:
-		if err := checkV2Files(ignconfigi.(ign2types.Config).Storage.Files); err != nil {
+		if err := checkV2Files(dn.root, ignconfigi.(ign2types.Config).Storage.Files); err != nil {
 			return err
 		}
-		if err := checkV2Units(ignconfigi.(ign2types.Config).Systemd.Units); err != nil {
+		if err := checkV2Units(dn.root, ignconfigi.(ign2types.Config).Systemd.Units); err != nil {
 			return err
 		}
 		return nil
@@ -1387,11 +1404,12 @@ func (dn *Daemon) checkOS(osImageURL string) bool {
 }
 
 // checkUnits validates the contents of all the units in the
-// target config and returns true if they match.
-func checkV3Units(units []ign3types.Unit) error {
+// target config, in the systemd directory of the root directory,
+// and returns true if they match.
+func checkV3Units(root string, units []ign3types.Unit) error {
 	for _, u := range units {
 		for j := range u.Dropins {
-			path := filepath.Join(pathSystemd, u.Name+".d", u.Dropins[j].Name)
+			path := filepath.Join(root, pathSystemd, u.Name+".d", u.Dropins[j].Name)
 
 			var content string
 			if u.Dropins[j].Contents == nil {
@@ -1419,7 +1437,7 @@ func checkV3Units(units []ign3types.Unit) error {
 			continue
 		}
 
-		path := filepath.Join(pathSystemd, u.Name)
+		path := filepath.Join(root, pathSystemd, u.Name)
 		if u.Mask != nil && *u.Mask {
 			link, err := filepath.EvalSymlinks(path)
 			if err != nil {
@@ -1437,10 +1455,10 @@ func checkV3Units(units []ign3types.Unit) error {
 	return nil
 }
 
-func checkV2Units(units []ign2types.Unit) error {
+func checkV2Units(root string, units []ign2types.Unit) error {
 	for _, u := range units {
 		for j := range u.Dropins {
-			path := filepath.Join(pathSystemd, u.Name+".d", u.Dropins[j].Name)
+			path := filepath.Join(root, pathSystemd, u.Name+".d", u.Dropins[j].Name)
 			if err := checkFileContentsAndMode(path, []byte(u.Dropins[j].Contents), defaultFilePermissions); err != nil {
 				return err
 			}
@@ -1450,7 +1468,7 @@ func checkV2Units(units []ign2types.Unit) error {
 			continue
 		}
 
-		path := filepath.Join(pathSystemd, u.Name)
+		path := filepath.Join(root, pathSystemd, u.Name)
 		if u.Mask {
 			link, err := filepath.EvalSymlinks(path)
 			if err != nil {
@@ -1473,7 +1491,7 @@ func checkV2Units(units []ign2types.Unit) error {
 
 // V3 files should not have any duplication anymore, so there is
 // no need to check for overwrites.
-func checkV3Files(files []ign3types.File) error {
+func checkV3Files(root string, files []ign3types.File) error {
 	for _, f := range files {
 		if len(f.Append) > 0 {
 			return fmt.Errorf("found an append section when checking files. Append is not supported")
@@ -1482,6 +1500,13 @@ func checkV3Files(files []ign3types.File) error {
 		if f.Mode != nil {
 			mode = os.FileMode(*f.Mode)
 		}
+		if isRemoteSource(f.Contents.Source) {
+			// we don't fetch remote files again, we only check their hash
+			if err := checkRemoteFileHashAndMode(root, f, mode); err != nil {
+				return err
+			}
+			continue
+		}
 		contents := &dataurl.DataURL{}
 		if f.Contents.Source != nil {
 			var err error
@@ -1490,14 +1515,14 @@ func checkV3Files(files []ign3types.File) error {
 				return errors.Wrapf(err, "couldn't parse file %q", f.Path)
 			}
 		}
-		if err := checkFileContentsAndMode(f.Path, contents.Data, mode); err != nil {
+		if err := checkFileContentsAndMode(filepath.Join(root, f.Path), contents.Data, mode); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func checkV2Files(files []ign2types.File) error {
+func checkV2Files(root string, files []ign2types.File) error {
 	checkedFiles := make(map[string]bool)
 	for i := len(files) - 1; i >= 0; i-- {
 		f := files[i]
@@ -1516,7 +1541,7 @@ func checkV2Files(files []ign2types.File) error {
 		if err != nil {
 			return errors.Wrapf(err, "couldn't parse file %q", f.Path)
 		}
-		if err := checkFileContentsAndMode(f.Path, contents.Data, mode); err != nil {
+		if err := checkFileContentsAndMode(filepath.Join(root, f.Path), contents.Data, mode); err != nil {
 			return err
 		}
 		checkedFiles[f.Path] = true
@@ -1524,6 +1549,29 @@ func checkV2Files(files []ign2types.File) error {
 	return nil
 }
 
+// checkRemoteFileHashAndMode checks the mode of a file fetched from a remote
+// source, and its contents against the verification hash of the file, if any.
+func checkRemoteFileHashAndMode(root string, f ign3types.File, mode os.FileMode) error {
+	fi, err := os.Lstat(filepath.Join(root, f.Path))
+	if err != nil {
+		return errors.Wrapf(err, "could not stat file %q", f.Path)
+	}
+	if fi.Mode() != mode {
+		return errors.Errorf("mode mismatch for file: %q; expected: %[2]v/%[2]d/%#[2]o; received: %[3]v/%[3]d/%#[3]o", f.Path, mode, fi.Mode())
+	}
+	if f.Contents.Verification.Hash == nil {
+		return nil
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(root, f.Path))
+	if err != nil {
+		return errors.Wrapf(err, "could not read file %q", f.Path)
+	}
+	if err := verifyHash(contents, *f.Contents.Verification.Hash); err != nil {
+		return errors.Wrapf(err, "content mismatch for file %q", f.Path)
+	}
+	return nil
+}
+
 // checkFileContentsAndMode reads the file from the filepath and compares its
 // contents and mode with the expectedContent and mode parameters. It logs an
 // error in case of an error or mismatch and returns the status of the
@@ -75,7 +75,7 @@ func TestValidateFiles(t *testing.T) {
 		},
 	}
 
-	if err := checkV3Files(filesV3); err != nil {
+	if err := checkV3Files(".", filesV3); err != nil {
 		t.Errorf("Invalid files: %v", err)
 	}
 
@@ -105,7 +105,7 @@ func TestValidateFiles(t *testing.T) {
 		},
 	}
 
-	if err := checkV2Files(filesV2); err != nil {
+	if err := checkV2Files(".", filesV2); err != nil {
 		t.Errorf("Validating an overwritten file failed: %v", err)
 	}
 }
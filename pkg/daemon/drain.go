@@ -2,11 +2,16 @@ package daemon
 
 import (
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/golang/glog"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/kubectl/pkg/drain"
 )
@@ -44,32 +49,137 @@ func (dn *Daemon) cordonOrUncordonNode(desired bool) error {
 	return nil
 }
 
-func (dn *Daemon) drain() error {
+// maxDrainBackoff caps the delay between drain attempts when a drain policy timeout is set.
+const maxDrainBackoff = 2 * time.Minute
+
+// getDrainPolicy returns the drain policy set on the node by the node controller from its pool, if any.
+func (dn *Daemon) getDrainPolicy() *mcfgv1.MachineConfigPoolDrainPolicy {
+	settings := dn.getPoolSettings()
+	if settings == nil {
+		return nil
+	}
+	return settings.DrainPolicy
+}
+
+// newDrainer returns a copy of the drain helper configured with the drain policy.
+func newDrainer(base *drain.Helper, policy *mcfgv1.MachineConfigPoolDrainPolicy) (*drain.Helper, error) {
+	drainer := *base
+	if policy == nil {
+		return &drainer, nil
+	}
+
+	if policy.GracePeriodSeconds != nil {
+		drainer.GracePeriodSeconds = int(*policy.GracePeriodSeconds)
+	}
+	filters := append([]drain.PodFilter{}, base.AdditionalFilters...)
+	if policy.SkipPodSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(policy.SkipPodSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid skipPodSelector")
+		}
+		filters = append(filters, func(pod corev1.Pod) drain.PodDeleteStatus {
+			if selector.Matches(labels.Set(pod.Labels)) {
+				return drain.MakePodDeleteStatusSkip()
+			}
+			return drain.MakePodDeleteStatusOkay()
+		})
+	}
+	if len(policy.SkipNamespaces) > 0 {
+		namespaces := sets.NewString(policy.SkipNamespaces...)
+		filters = append(filters, func(pod corev1.Pod) drain.PodDeleteStatus {
+			if namespaces.Has(pod.Namespace) {
+				return drain.MakePodDeleteStatusSkip()
+			}
+			return drain.MakePodDeleteStatusOkay()
+		})
+	}
+	// The emptyDir check runs after the skip filters so that skipped pods don't fail the drain.
+	if policy.DeleteEmptyDirData != nil && !*policy.DeleteEmptyDirData {
+		filters = append(filters, func(pod corev1.Pod) drain.PodDeleteStatus {
+			for _, volume := range pod.Spec.Volumes {
+				if volume.EmptyDir != nil {
+					return drain.MakePodDeleteStatusWithError("pod uses emptyDir volumes and the drain policy doesn't allow deleting their data")
+				}
+			}
+			return drain.MakePodDeleteStatusOkay()
+		})
+	}
+	drainer.AdditionalFilters = filters
+	return &drainer, nil
+}
+
+// retryDrain drains the node until it succeeds. Without a timeout the drain is attempted 5 times,
+// otherwise until the timeout expires. It returns the number of attempts and the last error.
+func (dn *Daemon) retryDrain(drainer *drain.Helper, timeout time.Duration) (int, error) {
 	backoff := wait.Backoff{
 		Steps:    5,
 		Duration: 10 * time.Second,
 		Factor:   2,
 	}
-	var lastErr error
-	if err := wait.ExponentialBackoff(backoff, func() (bool, error) {
-		err := drain.RunNodeDrain(dn.drainer, dn.node.Name)
-		if err != nil {
-			lastErr = err
-			glog.Infof("Draining failed with: %v, retrying", err)
-			return false, nil
+	var deadline time.Time
+	if timeout > 0 {
+		backoff.Steps = math.MaxInt32
+		deadline = time.Now().Add(timeout)
+	}
+
+	for tries := 1; ; tries++ {
+		err := drain.RunNodeDrain(drainer, dn.node.Name)
+		if err == nil {
+			return tries, nil
 		}
-		return true, nil
+		glog.Infof("Draining failed with: %v, retrying", err)
 
-	}); err != nil {
-		if err == wait.ErrWaitTimeout {
-			failMsg := fmt.Sprintf("%d tries: %v", backoff.Steps, lastErr)
-			MCDDrainErr.WithLabelValues(dn.node.Name, "WaitTimeout").Set(float64(backoff.Steps))
-			dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeWarning, "FailedToDrain", failMsg)
-			return errors.Wrapf(lastErr, "failed to drain node (%d tries): %v", backoff.Steps, err)
+		delay := backoff.Step()
+		if deadline.IsZero() {
+			if backoff.Steps == 0 {
+				return tries, err
+			}
+		} else {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return tries, err
+			}
+			if delay > maxDrainBackoff {
+				delay = maxDrainBackoff
+				backoff.Duration = maxDrainBackoff
+			}
+			if delay > remaining {
+				delay = remaining
+			}
 		}
-		MCDDrainErr.WithLabelValues(dn.node.Name, "UnknownError").Set(float64(backoff.Steps))
+		time.Sleep(delay)
+	}
+}
+
+func (dn *Daemon) drain() error {
+	policy := dn.getDrainPolicy()
+	drainer, err := newDrainer(dn.drainer, policy)
+	if err != nil {
 		dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeWarning, "FailedToDrain", err.Error())
-		return errors.Wrap(err, "failed to drain node")
+		return errors.Wrap(err, "invalid drain policy")
+	}
+	var timeout time.Duration
+	if policy != nil && policy.Timeout != nil {
+		timeout = policy.Timeout.Duration
+	}
+
+	tries, err := dn.retryDrain(drainer, timeout)
+	// retryDrain only fails once it ran out of tries or time.
+	reason := "WaitTimeout"
+	if err != nil && policy != nil && policy.ForceAfterTimeout {
+		dn.logSystem("Drain failed after %d tries: %v, forcing it", tries, err)
+		dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeWarning, "ForcingDrain", "Deleting the remaining pods, bypassing PodDisruptionBudgets")
+		// Deleting pods instead of evicting them ignores PodDisruptionBudgets.
+		drainer.DisableEviction = true
+		tries++
+		err = drain.RunNodeDrain(drainer, dn.node.Name)
+		reason = "UnknownError"
+	}
+	if err != nil {
+		failMsg := fmt.Sprintf("%d tries: %v", tries, err)
+		MCDDrainErr.WithLabelValues(dn.node.Name, reason).Set(float64(tries))
+		dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeWarning, "FailedToDrain", failMsg)
+		return errors.Wrapf(err, "failed to drain node (%d tries)", tries)
 	}
 
 	return nil
@@ -0,0 +1,97 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kubectl/pkg/drain"
+)
+
+func newDrainTestPod(namespace, name string, labels map[string]string, emptyDir bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Spec:       corev1.PodSpec{NodeName: "node-0"},
+	}
+	if emptyDir {
+		pod.Spec.Volumes = []corev1.Volume{{
+			Name:         "scratch",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		}}
+	}
+	return pod
+}
+
+func TestGetDrainPolicy(t *testing.T) {
+	dn := &Daemon{node: &corev1.Node{}}
+	assert.Nil(t, dn.getDrainPolicy())
+
+	dn.node.Annotations = map[string]string{constants.PoolSettingsAnnotationKey: `{"version":1,"drainPolicy":{"timeout":"10m","forceAfterTimeout":true,"skipNamespaces":["monitoring"]}}`}
+	policy := dn.getDrainPolicy()
+	require.NotNil(t, policy)
+	assert.Equal(t, 10*time.Minute, policy.Timeout.Duration)
+	assert.True(t, policy.ForceAfterTimeout)
+	assert.Equal(t, []string{"monitoring"}, policy.SkipNamespaces)
+
+	dn.node.Annotations[constants.PoolSettingsAnnotationKey] = "{"
+	assert.Nil(t, dn.getDrainPolicy())
+
+	// settings written by another version of the node controller are ignored
+	dn.node.Annotations[constants.PoolSettingsAnnotationKey] = `{"version":2,"drainPolicy":{"timeout":"10m"}}`
+	assert.Nil(t, dn.getDrainPolicy())
+}
+
+func TestNewDrainer(t *testing.T) {
+	deleteEmptyDirData := false
+	gracePeriod := int32(30)
+	policy := &mcfgv1.MachineConfigPoolDrainPolicy{
+		GracePeriodSeconds: &gracePeriod,
+		DeleteEmptyDirData: &deleteEmptyDirData,
+		SkipPodSelector:    &metav1.LabelSelector{MatchLabels: map[string]string{"app": "singleton"}},
+		SkipNamespaces:     []string{"monitoring"},
+	}
+	client := k8sfake.NewSimpleClientset(
+		newDrainTestPod("default", "web", nil, false),
+		newDrainTestPod("default", "singleton", map[string]string{"app": "singleton"}, true),
+		newDrainTestPod("monitoring", "prometheus", nil, true),
+	)
+	base := &drain.Helper{
+		Client:             client,
+		Force:              true,
+		DeleteEmptyDirData: true,
+		GracePeriodSeconds: -1,
+	}
+
+	drainer, err := newDrainer(base, policy)
+	require.Nil(t, err)
+	assert.Equal(t, 30, drainer.GracePeriodSeconds)
+	assert.Equal(t, -1, base.GracePeriodSeconds)
+	assert.Empty(t, base.AdditionalFilters)
+
+	list, errs := drainer.GetPodsForDeletion("node-0")
+	require.Empty(t, errs)
+	var names []string
+	for _, pod := range list.Pods() {
+		names = append(names, pod.Name)
+	}
+	assert.Equal(t, []string{"web"}, names)
+
+	// pods using emptyDir fail the drain unless skipped
+	policy.SkipNamespaces = nil
+	drainer, err = newDrainer(base, policy)
+	require.Nil(t, err)
+	_, errs = drainer.GetPodsForDeletion("node-0")
+	assert.Len(t, errs, 1)
+
+	drainer, err = newDrainer(base, nil)
+	require.Nil(t, err)
+	list, errs = drainer.GetPodsForDeletion("node-0")
+	require.Empty(t, errs)
+	assert.Len(t, list.Pods(), 3)
+}
@@ -0,0 +1,181 @@
+package daemon
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+const (
+	// defaultDriftCheckInterval is how often the node is checked for drift when its pool doesn't set it
+	defaultDriftCheckInterval = 10 * time.Minute
+	// driftPollInterval is how often the drift monitor wakes up the worker to check
+	// whether a drift check is due
+	driftPollInterval = time.Minute
+)
+
+// getDriftPolicy returns the drift policy set on the node by the node controller from its pool,
+// defaulting to reporting drift every defaultDriftCheckInterval.
+func (dn *Daemon) getDriftPolicy() mcfgv1.MachineConfigPoolDriftPolicy {
+	policy := mcfgv1.MachineConfigPoolDriftPolicy{}
+	if settings := dn.getPoolSettings(); settings != nil && settings.DriftPolicy != nil {
+		policy = *settings.DriftPolicy
+	}
+	if policy.Mode == "" {
+		policy.Mode = mcfgv1.DriftModeReport
+	}
+	if policy.Interval == nil || policy.Interval.Duration <= 0 {
+		policy.Interval = nil
+	}
+	return policy
+}
+
+// runDriftMonitor periodically enqueues the node, so that the worker checks it for drift
+// between updates. Going through the queue ensures the check never runs during an update.
+func (dn *Daemon) runDriftMonitor(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		dn.queue.Add(dn.name)
+	}, driftPollInterval, stopCh)
+}
+
+// syncDrift checks the node for drift from its current config if the last check is
+// older than the interval of the drift policy, rewriting the drifted files and units
+// if the policy asks for it. It is only called by syncNode when the node is up to date.
+func (dn *Daemon) syncDrift() {
+	policy := dn.getDriftPolicy()
+	if policy.Mode == mcfgv1.DriftModeDisabled {
+		dn.reportDrift(nil)
+		return
+	}
+	interval := defaultDriftCheckInterval
+	if policy.Interval != nil {
+		interval = policy.Interval.Duration
+	}
+	if time.Since(dn.lastDriftCheck) < interval {
+		return
+	}
+	dn.lastDriftCheck = time.Now()
+
+	currentConfigName, err := getNodeAnnotation(dn.node, constants.CurrentMachineConfigAnnotationKey)
+	if err != nil {
+		glog.Warningf("Failed to check for drift: %v", err)
+		return
+	}
+	currentConfig, err := dn.mcLister.Get(currentConfigName)
+	if err != nil {
+		glog.Warningf("Failed to check for drift: %v", err)
+		return
+	}
+	drifted, err := dn.checkDrift(currentConfig, policy.Mode == mcfgv1.DriftModeRemediate)
+	if err != nil {
+		glog.Warningf("Failed to check for drift from %s: %v", currentConfigName, err)
+	}
+	dn.reportDrift(drifted)
+}
+
+// checkDrift returns the paths of the files and units which don't match the config.
+// If remediate is set, they are rewritten first and only the ones which still don't
+// match are returned.
+func (dn *Daemon) checkDrift(config *mcfgv1.MachineConfig, remediate bool) ([]string, error) {
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(config.Spec.Config.Raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing Ignition config")
+	}
+	files, units := findDrift(dn.root, ignConfig)
+	if remediate && (len(files) > 0 || len(units) > 0) {
+		paths := driftedPaths(files, units)
+		dn.logSystem("Rewriting drifted paths from %s: %s", config.GetName(), strings.Join(paths, ", "))
+		if err := dn.remediateDrift(files, units); err != nil {
+			return paths, err
+		}
+		MCDDriftRemediations.Add(float64(len(paths)))
+		if dn.recorder != nil && dn.node != nil {
+			dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeNormal, "DriftRemediated", fmt.Sprintf("Rewrote %d drifted paths from %s", len(paths), config.GetName()))
+		}
+		files, units = findDrift(dn.root, ignConfig)
+	}
+	return driftedPaths(files, units), nil
+}
+
+// findDrift returns the files and units of the config which don't match the disk in root.
+func findDrift(root string, ignConfig ign3types.Config) ([]ign3types.File, []ign3types.Unit) {
+	var files []ign3types.File
+	var units []ign3types.Unit
+	for _, f := range ignConfig.Storage.Files {
+		if err := checkV3Files(root, []ign3types.File{f}); err != nil {
+			glog.Infof("Drift detected: %v", err)
+			files = append(files, f)
+		}
+	}
+	for _, u := range ignConfig.Systemd.Units {
+		if err := checkV3Units(root, []ign3types.Unit{u}); err != nil {
+			glog.Infof("Drift detected: %v", err)
+			units = append(units, u)
+		}
+	}
+	return files, units
+}
+
+func driftedPaths(files []ign3types.File, units []ign3types.Unit) []string {
+	paths := []string{}
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	for _, u := range units {
+		paths = append(paths, filepath.Join(pathSystemd, u.Name))
+	}
+	return paths
+}
+
+// remediateDrift rewrites the drifted files and units. Services aren't restarted,
+// but systemd is reloaded when units are rewritten.
+func (dn *Daemon) remediateDrift(files []ign3types.File, units []ign3types.Unit) error {
+	if len(files) > 0 {
+		fetched, err := fetchRemoteFiles(files)
+		if err != nil {
+			return err
+		}
+		if err := dn.writeFiles(files, fetched); err != nil {
+			return err
+		}
+	}
+	if len(units) > 0 {
+		if err := dn.writeUnits(units); err != nil {
+			return err
+		}
+		if err := dn.systemd.DaemonReload(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportDrift exposes the drifted paths in the metrics and in the node annotations.
+func (dn *Daemon) reportDrift(paths []string) {
+	MCDDriftedPaths.Reset()
+	for _, path := range paths {
+		MCDDriftedPaths.WithLabelValues(path).Set(1)
+	}
+	if dn.nodeWriter == nil {
+		return
+	}
+	// truncate to limit the risk of hitting the total annotation size limit
+	value := fmt.Sprintf("%.2000s", strings.Join(paths, ","))
+	if dn.node.Annotations[machineConfigDaemonDriftedPathsAnnotationKey] == value {
+		return
+	}
+	if err := dn.nodeWriter.SetDrifted(paths, dn.kubeClient.CoreV1().Nodes(), dn.nodeLister, dn.name); err != nil {
+		glog.Warningf("Failed to report drifted paths: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vincent-petithory/dataurl"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func countMetrics(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric, 10)
+	c.Collect(ch)
+	close(ch)
+	return len(ch)
+}
+
+func TestGetDriftPolicy(t *testing.T) {
+	dn := &Daemon{node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}}
+	policy := dn.getDriftPolicy()
+	assert.Equal(t, mcfgv1.DriftModeReport, policy.Mode)
+	assert.Nil(t, policy.Interval)
+
+	dn.node.Annotations[constants.PoolSettingsAnnotationKey] = `{"version":1,"driftPolicy":{"mode":"Remediate","interval":"5m0s"}}`
+	policy = dn.getDriftPolicy()
+	assert.Equal(t, mcfgv1.DriftModeRemediate, policy.Mode)
+	assert.Equal(t, 5*time.Minute, policy.Interval.Duration)
+
+	dn.node.Annotations[constants.PoolSettingsAnnotationKey] = `{"version":1,"driftPolicy":{"mode":`
+	assert.Equal(t, mcfgv1.DriftModeReport, dn.getDriftPolicy().Mode)
+}
+
+func TestCheckDrift(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drift")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	mode := 0644
+	newFile := func(name, contents string) ign3types.File {
+		f := newRemoteFile(filepath.Join(dir, name), dataurl.EncodeBytes([]byte(contents)), "")
+		f.Mode = &mode
+		return f
+	}
+	ignCfg := ctrlcommon.NewIgnConfig()
+	ignCfg.Storage.Files = []ign3types.File{
+		newFile("unchanged", "unchanged\n"),
+		newFile("edited", "original\n"),
+		newFile("deleted", "deleted\n"),
+	}
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "unchanged"), []byte("unchanged\n"), 0644))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "edited"), []byte("edited by hand\n"), 0644))
+	mc := helpers.CreateMachineConfigFromIgnition(ignCfg)
+
+	dn := &Daemon{mock: true}
+	drifted, err := dn.checkDrift(mc, false)
+	require.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "edited"), filepath.Join(dir, "deleted")}, drifted)
+
+	dn.reportDrift(drifted)
+	assert.Equal(t, 2, countMetrics(MCDDriftedPaths))
+	dn.reportDrift(nil)
+	assert.Equal(t, 0, countMetrics(MCDDriftedPaths))
+}
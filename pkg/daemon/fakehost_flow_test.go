@@ -0,0 +1,201 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+const (
+	fakeHostBootImage = "registry.example.com/os@sha256:boot"
+	fakeHostImageV1   = "registry.example.com/os@sha256:v1"
+	fakeHostImageV2   = "registry.example.com/os@sha256:v2"
+)
+
+func newFakeHostNode(annotations map[string]string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Annotations: annotations}}
+}
+
+func newFakeHostConfig(name, osImageURL string, files []ign3types.File, units ...ign3types.Unit) *mcfgv1.MachineConfig {
+	sshKeys := []ign3types.SSHAuthorizedKey{"ssh-rsa AAAA core@example.com"}
+	return helpers.NewMachineConfigExtended(name, nil, files, units, sshKeys, nil, false, nil, "", osImageURL)
+}
+
+func TestFakeHostFirstboot(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("the SSH keys of the core user are owned by root")
+	}
+	h := newFakeHost(t, fakeHostBootImage)
+	config := newFakeHostConfig("rendered-worker-1", fakeHostImageV1,
+		[]ign3types.File{fakeHostFile("/etc/worker.conf", "worker\n")},
+		fakeHostUnit("worker.service"))
+	encapsulated, err := json.Marshal(config)
+	require.Nil(t, err)
+	h.writeFile(constants.MachineConfigEncapsulatedPath, string(encapsulated))
+
+	// machine-config-daemon-firstboot.service applies the config and reboots into its OS
+	rebooted, err := h.run(h.dn.RunFirstbootCompleteMachineconfig)
+	require.Nil(t, err)
+	assert.True(t, rebooted)
+	assert.Equal(t, "worker\n", h.readFile("/etc/worker.conf"))
+	assert.True(t, h.exists(noOrigFileStampName("/etc/worker.conf")))
+	assert.Equal(t, "ssh-rsa AAAA core@example.com\n", h.readFile(filepath.Join(coreUserSSHPath, "authorized_keys")))
+	assert.True(t, h.exists("/etc/systemd/system/worker.service"))
+	assert.True(t, h.ran("systemctl", "enable", "worker.service"))
+	assert.True(t, h.ran("oc", "image", "extract"))
+	assert.True(t, h.ran("systemd-run", "--unit", "machine-config-daemon-reboot"))
+	assert.False(t, h.exists(constants.MachineConfigEncapsulatedPath))
+	assert.True(t, h.exists(constants.MachineConfigEncapsulatedBakPath))
+	assert.Equal(t, fakeHostImageV1, h.osImageURL)
+
+	// the MCD then sets the node annotations written by the MCS
+	initial, err := json.Marshal(map[string]string{
+		constants.CurrentMachineConfigAnnotationKey:     "rendered-worker-1",
+		constants.DesiredMachineConfigAnnotationKey:     "rendered-worker-1",
+		constants.MachineConfigDaemonStateAnnotationKey: constants.MachineConfigDaemonStateDone,
+	})
+	require.Nil(t, err)
+	h.writeFile(constants.InitialNodeAnnotationsFilePath, string(initial))
+	h.joinCluster(newFakeHostNode(map[string]string{}), config)
+	rebooted, err = h.sync()
+	require.Nil(t, err)
+	assert.False(t, rebooted)
+	node := h.node()
+	assert.Equal(t, "rendered-worker-1", node.Annotations[constants.CurrentMachineConfigAnnotationKey])
+	assert.Equal(t, constants.MachineConfigDaemonStateDone, node.Annotations[constants.MachineConfigDaemonStateAnnotationKey])
+	assert.False(t, h.exists(constants.InitialNodeAnnotationsFilePath))
+	onDisk, err := h.dn.getCurrentConfigOnDisk()
+	require.Nil(t, err)
+	assert.Equal(t, "rendered-worker-1", onDisk.GetName())
+}
+
+func TestFakeHostUpdate(t *testing.T) {
+	h := newFakeHost(t, fakeHostImageV1)
+	h.writeFile("/etc/chrony.conf", "pool 2.rhel.pool.ntp.org iburst\n")
+	h.writeFile("/usr/etc/chrony.conf", "pool 2.rhel.pool.ntp.org iburst\n")
+	oldConfig := newFakeHostConfig("rendered-worker-1", fakeHostImageV1,
+		[]ign3types.File{
+			fakeHostFile("/etc/chrony.conf", "server ntp.example.com iburst\n"),
+			fakeHostFile("/etc/kept.conf", "v1\n"),
+			fakeHostFile("/etc/removed.conf", "removed\n"),
+		},
+		fakeHostUnit("removed.service"))
+	newConfig := newFakeHostConfig("rendered-worker-2", fakeHostImageV2,
+		[]ign3types.File{
+			fakeHostFile("/etc/kept.conf", "v2\n"),
+			fakeHostFile("/etc/added.conf", "added\n"),
+		},
+		fakeHostUnit("added.service"))
+	h.install(oldConfig)
+	h.joinCluster(newFakeHostNode(map[string]string{
+		constants.CurrentMachineConfigAnnotationKey:     "rendered-worker-1",
+		constants.DesiredMachineConfigAnnotationKey:     "rendered-worker-2",
+		constants.MachineConfigDaemonStateAnnotationKey: constants.MachineConfigDaemonStateDone,
+	}), oldConfig, newConfig)
+
+	// the MCD validates the current config on startup, then updates and reboots
+	rebooted, err := h.sync()
+	require.Nil(t, err)
+	assert.True(t, rebooted)
+	assert.Equal(t, "v2\n", h.readFile("/etc/kept.conf"))
+	assert.Equal(t, "added\n", h.readFile("/etc/added.conf"))
+	assert.True(t, h.exists("/etc/systemd/system/added.service"))
+	assert.True(t, h.ran("systemctl", "enable", "added.service"))
+
+	// the stale files and units are removed, and the files of the OS restored
+	assert.False(t, h.exists("/etc/removed.conf"))
+	assert.False(t, h.exists(noOrigFileStampName("/etc/removed.conf")))
+	assert.False(t, h.exists("/etc/systemd/system/removed.service"))
+	assert.True(t, h.ran("systemctl", "preset", "removed.service"))
+	assert.Equal(t, "pool 2.rhel.pool.ntp.org iburst\n", h.readFile("/etc/chrony.conf"))
+	assert.False(t, h.exists(origFileName("/etc/chrony.conf")))
+
+	assert.True(t, h.ran("rpm-ostree", "rebase"))
+	stagedDir := h.dn.osImageStager.stagedDir(fakeHostImageV2)
+	assert.True(t, strings.HasPrefix(stagedDir, filepath.Join(h.root, "/var")))
+	_, err = os.Stat(stagedDir)
+	assert.Nil(t, err)
+	assert.True(t, h.logged("initiating reboot: Node will reboot into config rendered-worker-2"))
+	assert.True(t, h.ran("systemd-run", "--unit", "machine-config-daemon-reboot"))
+	assert.Equal(t, fakeHostImageV2, h.osImageURL)
+	node := h.node()
+	assert.True(t, node.Spec.Unschedulable)
+	assert.Equal(t, constants.MachineConfigDaemonStateWorking, node.Annotations[constants.MachineConfigDaemonStateAnnotationKey])
+	pending, err := h.dn.loadPendingState()
+	require.Nil(t, err)
+	assert.Equal(t, "rendered-worker-2", pending.Config)
+	assert.Equal(t, updatePhaseRebooting, pending.Phase)
+
+	// after the reboot, the MCD validates the pending config and completes the update
+	h.resetActions()
+	rebooted, err = h.sync()
+	require.Nil(t, err)
+	assert.False(t, rebooted)
+	node = h.node()
+	assert.False(t, node.Spec.Unschedulable)
+	assert.Equal(t, "rendered-worker-2", node.Annotations[constants.CurrentMachineConfigAnnotationKey])
+	assert.Equal(t, constants.MachineConfigDaemonStateDone, node.Annotations[constants.MachineConfigDaemonStateAnnotationKey])
+	pending, err = h.dn.loadPendingState()
+	require.Nil(t, err)
+	assert.Equal(t, updatePhaseDone, pending.Phase)
+	assert.True(t, h.logged("completed update for config rendered-worker-2"))
+	assert.False(t, h.ran("systemd-run"))
+	// the staged OS image content is removed once booted into it
+	_, err = os.Stat(stagedDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFakeHostDrift(t *testing.T) {
+	h := newFakeHost(t, fakeHostImageV1)
+	config := newFakeHostConfig("rendered-worker-1", fakeHostImageV1,
+		[]ign3types.File{fakeHostFile("/etc/worker.conf", "worker\n")},
+		fakeHostUnit("worker.service"))
+	h.install(config)
+	h.joinCluster(newFakeHostNode(map[string]string{
+		constants.CurrentMachineConfigAnnotationKey:     "rendered-worker-1",
+		constants.DesiredMachineConfigAnnotationKey:     "rendered-worker-1",
+		constants.MachineConfigDaemonStateAnnotationKey: constants.MachineConfigDaemonStateDone,
+	}), config)
+	_, err := h.sync()
+	require.Nil(t, err)
+
+	// drift is reported between updates
+	h.writeFile("/etc/worker.conf", "edited by hand\n")
+	_, err = h.sync()
+	require.Nil(t, err)
+	assert.Equal(t, "/etc/worker.conf", h.node().Annotations[machineConfigDaemonDriftedPathsAnnotationKey])
+	assert.Equal(t, "edited by hand\n", h.readFile("/etc/worker.conf"))
+
+	// and rewritten if the pool asks for it
+	node := h.node()
+	node.Annotations[constants.PoolSettingsAnnotationKey] = `{"version":1,"driftPolicy":{"mode":"Remediate"}}`
+	_, err = h.kubeClient.CoreV1().Nodes().Update(context.TODO(), node, metav1.UpdateOptions{})
+	require.Nil(t, err)
+	h.dn.lastDriftCheck = time.Time{}
+	_, err = h.sync()
+	require.Nil(t, err)
+	assert.Equal(t, "worker\n", h.readFile("/etc/worker.conf"))
+	assert.Equal(t, "", h.node().Annotations[machineConfigDaemonDriftedPathsAnnotationKey])
+
+	// the on-disk state is validated when the MCD starts
+	h.writeFile("/etc/systemd/system/worker.service", "[Unit]\n")
+	h.reboot()
+	_, err = h.sync()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unexpected on-disk state validating against rendered-worker-1")
+	assert.Contains(t, err.Error(), "worker.service")
+}
@@ -0,0 +1,442 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/golang/glog"
+	"github.com/stretchr/testify/require"
+	"github.com/vincent-petithory/dataurl"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubectl/pkg/drain"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/pkg/generated/clientset/versioned/fake"
+	informers "github.com/openshift/machine-config-operator/pkg/generated/informers/externalversions"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+// fakeHostEnv is set to the state directory of the fake host in the environment
+// of the fake host commands.
+const fakeHostEnv = "MCD_FAKE_HOST"
+
+// fakeHostCommands are the host commands the daemon runs which are replaced by the
+// test binary in the fake host. The real cp is used to back up the files.
+var fakeHostCommands = []string{
+	"chcon",
+	"groupadd",
+	"groupdel",
+	"groupmod",
+	"journalctl",
+	"logger",
+	"oc",
+	"ostree",
+	"podman",
+	"rpm",
+	"rpm-ostree",
+	"systemctl",
+	"systemd-run",
+	"useradd",
+	"userdel",
+	"usermod",
+}
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(fakeHostEnv); dir != "" {
+		os.Exit(runFakeHostCommand(dir, filepath.Base(os.Args[0]), os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// fakeHostAction is a host command run by the daemon on the fake host.
+type fakeHostAction struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Stdin   string   `json:"stdin,omitempty"`
+}
+
+// fakeHostResponse is the output of the host commands starting with Argv.
+type fakeHostResponse struct {
+	Argv     []string `json:"argv"`
+	Stdout   string   `json:"stdout"`
+	ExitCode int      `json:"exitCode"`
+}
+
+// runFakeHostCommand records the command in the state directory of the fake host and
+// returns the last response added for it, which defaults to succeeding without output.
+func runFakeHostCommand(dir, command string, args []string) int {
+	action := fakeHostAction{Command: command, Args: args}
+	if command == "logger" {
+		stdin, _ := ioutil.ReadAll(os.Stdin)
+		action.Stdin = string(stdin)
+	}
+	b, err := json.Marshal(action)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 127
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "actions.jsonl"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 127
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 127
+	}
+
+	var responses []fakeHostResponse
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "responses.json")); err == nil {
+		if err := json.Unmarshal(b, &responses); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 127
+		}
+	}
+	argv := append([]string{command}, args...)
+	for i := len(responses) - 1; i >= 0; i-- {
+		if hasArgvPrefix(argv, responses[i].Argv) {
+			fmt.Print(responses[i].Stdout)
+			return responses[i].ExitCode
+		}
+	}
+	return 0
+}
+
+func hasArgvPrefix(argv, prefix []string) bool {
+	if len(prefix) > len(argv) {
+		return false
+	}
+	for i := range prefix {
+		if argv[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fakeHost runs the daemon against a temporary root directory, with the host commands
+// replaced by fakes recording what they are asked to do, and an optional fake cluster.
+// Reboots stop the flow which initiated them, and boot a new daemon.
+type fakeHost struct {
+	t *testing.T
+	// root is the root directory of the host filesystem
+	root string
+	// dir holds the fake commands, the actions they recorded and their responses
+	dir string
+
+	boots      int
+	osImageURL string
+
+	kubeClient *k8sfake.Clientset
+	mcInformer informers.SharedInformerFactory
+	k8sI       kubeinformers.SharedInformerFactory
+	nodeName   string
+	stopCh     chan struct{}
+
+	dn *Daemon
+}
+
+// newFakeHost boots a daemon on a fake CoreOS host running osImageURL.
+func newFakeHost(t *testing.T, osImageURL string) *fakeHost {
+	dir, err := ioutil.TempDir("", "fake-host")
+	require.Nil(t, err)
+	h := &fakeHost{
+		t:          t,
+		root:       filepath.Join(dir, "root"),
+		dir:        filepath.Join(dir, "host"),
+		osImageURL: osImageURL,
+		stopCh:     make(chan struct{}),
+	}
+	bin := filepath.Join(h.dir, "bin")
+	require.Nil(t, os.MkdirAll(bin, 0755))
+	require.Nil(t, os.MkdirAll(h.root, 0755))
+	self, err := os.Executable()
+	require.Nil(t, err)
+	for _, command := range fakeHostCommands {
+		require.Nil(t, os.Symlink(self, filepath.Join(bin, command)))
+	}
+
+	path := os.Getenv("PATH")
+	t.Cleanup(func() {
+		close(h.stopCh)
+		os.Setenv("PATH", path)
+		os.Unsetenv(fakeHostEnv)
+		os.RemoveAll(dir)
+	})
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+path)
+	os.Setenv(fakeHostEnv, h.dir)
+
+	// a package doesn't own the files by default
+	h.respond("", 1, "rpm", "-qf")
+	h.boot()
+	return h
+}
+
+// boot starts a new daemon, as the MCD does when the host boots.
+func (h *fakeHost) boot() {
+	h.boots++
+	status, err := json.Marshal(rpmOstreeState{Deployments: []RpmOstreeDeployment{{
+		ID:           fmt.Sprintf("rhcos-%d", h.boots),
+		OSName:       "rhcos",
+		Booted:       true,
+		CustomOrigin: []string{"pivot://" + h.osImageURL},
+	}}})
+	require.Nil(h.t, err)
+	h.respond(string(status), 0, "rpm-ostree", "status", "--json")
+
+	nodeUpdaterClient := &fakeNodeUpdaterClient{RpmOstreeClient{runner: execRunner{}}}
+	osImageURL, _, err := nodeUpdaterClient.GetBootedOSImageURL()
+	require.Nil(h.t, err)
+	h.dn = &Daemon{
+		booting:                true,
+		os:                     OperatingSystem{ID: "rhcos"},
+		NodeUpdaterClient:      nodeUpdaterClient,
+		runner:                 execRunner{},
+		systemd:                &systemctlClient{runner: execRunner{}},
+		bootedOSImageURL:       osImageURL,
+		bootID:                 fmt.Sprintf("boot-%d", h.boots),
+		currentConfigPath:      currentConfigPath,
+		pendingStatePath:       pendingStatePath,
+		passwdStatePath:        passwdStatePath,
+		healthCheckFailurePath: healthCheckFailurePath,
+		root:                   h.root,
+		// the daemon is killed by the reboot
+		waitForReboot:         runtime.Goexit,
+		loggerSupportsJournal: true,
+	}
+	h.dn.osImageStager = newOSImageStager(h.dn.hostPath(stagedOSImageContentBaseDir), h.dn.extractOSImage)
+	if h.kubeClient != nil {
+		h.connect()
+	}
+}
+
+// joinCluster connects the daemon to a fake cluster with the node and the configs.
+func (h *fakeHost) joinCluster(node *corev1.Node, configs ...*mcfgv1.MachineConfig) {
+	h.nodeName = node.Name
+	h.kubeClient = k8sfake.NewSimpleClientset(node)
+	h.k8sI = kubeinformers.NewSharedInformerFactory(h.kubeClient, noResyncPeriodFunc())
+	h.k8sI.Core().V1().Nodes().Informer()
+	h.k8sI.Start(h.stopCh)
+	h.k8sI.WaitForCacheSync(h.stopCh)
+	client := fake.NewSimpleClientset()
+	for _, config := range configs {
+		_, err := client.MachineconfigurationV1().MachineConfigs().Create(context.TODO(), config, metav1.CreateOptions{})
+		require.Nil(h.t, err)
+	}
+	h.mcInformer = informers.NewSharedInformerFactory(client, noResyncPeriodFunc())
+	h.mcInformer.Machineconfiguration().V1().MachineConfigs().Informer()
+	h.mcInformer.Start(h.stopCh)
+	h.mcInformer.WaitForCacheSync(h.stopCh)
+	h.connect()
+}
+
+func (h *fakeHost) connect() {
+	dn := h.dn
+	dn.name = h.nodeName
+	dn.kubeClient = h.kubeClient
+	dn.recorder = &record.FakeRecorder{}
+	dn.nodeWriter = newNodeWriter()
+	go dn.nodeWriter.Run(h.stopCh)
+	dn.nodeLister = h.k8sI.Core().V1().Nodes().Lister()
+	dn.nodeListerSynced = alwaysReady
+	dn.mcLister = h.mcInformer.Machineconfiguration().V1().MachineConfigs().Lister()
+	dn.mcListerSynced = alwaysReady
+	dn.drainer = &drain.Helper{
+		Client:              h.kubeClient,
+		Force:               true,
+		IgnoreAllDaemonSets: true,
+		DeleteEmptyDirData:  true,
+		GracePeriodSeconds:  -1,
+		Timeout:             time.Minute,
+		Out:                 writer{glog.Info},
+		ErrOut:              writer{glog.Error},
+		Ctx:                 context.TODO(),
+	}
+}
+
+// run runs the flow until it returns, or until it reboots the host. In the latter case
+// the host is booted again.
+func (h *fakeHost) run(flow func() error) (rebooted bool, err error) {
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+		done <- flow()
+	}()
+	err, returned := <-done
+	if !returned {
+		h.reboot()
+		return true, nil
+	}
+	return false, err
+}
+
+// sync runs the sync of the node the MCD runs when the node is updated.
+func (h *fakeHost) sync() (rebooted bool, err error) {
+	h.waitForNode()
+	return h.run(func() error { return h.dn.syncNode(h.nodeName) })
+}
+
+// reboot boots the last OS image rpm-ostree was asked to rebase to.
+func (h *fakeHost) reboot() {
+	for _, action := range h.actions() {
+		if action.Command != "rpm-ostree" || len(action.Args) == 0 || action.Args[0] != "rebase" {
+			continue
+		}
+		for i, arg := range action.Args {
+			if arg == "--custom-origin-url" && i+1 < len(action.Args) {
+				h.osImageURL = strings.TrimPrefix(action.Args[i+1], "pivot://")
+			}
+		}
+	}
+	h.boot()
+}
+
+// waitForNode waits for the node informer to catch up with the node in the cluster.
+func (h *fakeHost) waitForNode() {
+	node := h.node()
+	require.Eventually(h.t, func() bool {
+		cached, err := h.dn.nodeLister.Get(h.nodeName)
+		return err == nil && cached.ResourceVersion == node.ResourceVersion
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+// node returns the node in the fake cluster.
+func (h *fakeHost) node() *corev1.Node {
+	node, err := h.kubeClient.CoreV1().Nodes().Get(context.TODO(), h.nodeName, metav1.GetOptions{})
+	require.Nil(h.t, err)
+	return node
+}
+
+// respond makes the host commands starting with argv print stdout and exit with exitCode.
+func (h *fakeHost) respond(stdout string, exitCode int, argv ...string) {
+	path := filepath.Join(h.dir, "responses.json")
+	var responses []fakeHostResponse
+	if b, err := ioutil.ReadFile(path); err == nil {
+		require.Nil(h.t, json.Unmarshal(b, &responses))
+	}
+	responses = append(responses, fakeHostResponse{Argv: argv, Stdout: stdout, ExitCode: exitCode})
+	b, err := json.Marshal(responses)
+	require.Nil(h.t, err)
+	require.Nil(h.t, ioutil.WriteFile(path, b, 0644))
+}
+
+// actions returns the host commands run since the host was created or the actions were reset.
+func (h *fakeHost) actions() []fakeHostAction {
+	f, err := os.Open(filepath.Join(h.dir, "actions.jsonl"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.Nil(h.t, err)
+	defer f.Close()
+	var actions []fakeHostAction
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var action fakeHostAction
+		require.Nil(h.t, json.Unmarshal(scanner.Bytes(), &action))
+		actions = append(actions, action)
+	}
+	require.Nil(h.t, scanner.Err())
+	return actions
+}
+
+// ran returns whether a host command starting with argv was run.
+func (h *fakeHost) ran(argv ...string) bool {
+	for _, action := range h.actions() {
+		if hasArgvPrefix(append([]string{action.Command}, action.Args...), argv) {
+			return true
+		}
+	}
+	return false
+}
+
+// logged returns whether the daemon logged a message containing s to the journal.
+func (h *fakeHost) logged(s string) bool {
+	for _, action := range h.actions() {
+		if action.Command == "logger" && strings.Contains(action.Stdin, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *fakeHost) resetActions() {
+	err := os.Remove(filepath.Join(h.dir, "actions.jsonl"))
+	if !os.IsNotExist(err) {
+		require.Nil(h.t, err)
+	}
+}
+
+func (h *fakeHost) writeFile(path, contents string) {
+	require.Nil(h.t, os.MkdirAll(filepath.Dir(filepath.Join(h.root, path)), 0755))
+	require.Nil(h.t, ioutil.WriteFile(filepath.Join(h.root, path), []byte(contents), 0644))
+}
+
+func (h *fakeHost) readFile(path string) string {
+	b, err := ioutil.ReadFile(filepath.Join(h.root, path))
+	require.Nil(h.t, err)
+	return string(b)
+}
+
+func (h *fakeHost) exists(path string) bool {
+	_, err := os.Lstat(filepath.Join(h.root, path))
+	return err == nil
+}
+
+// install writes the files and units of the config, as if the host had been updated to it.
+func (h *fakeHost) install(config *mcfgv1.MachineConfig) {
+	require.Nil(h.t, h.dn.updateFiles(canonicalizeEmptyMC(nil), config, nil))
+	require.Nil(h.t, h.dn.storeCurrentConfigOnDisk(config))
+	h.resetActions()
+}
+
+// fakeHostFile returns a file owned by the user running the tests, so that they don't need root.
+func fakeHostFile(path, contents string) ign3types.File {
+	uid, gid, mode := os.Getuid(), os.Getgid(), 0644
+	return ign3types.File{
+		Node: ign3types.Node{
+			Path:  path,
+			User:  ign3types.NodeUser{ID: &uid},
+			Group: ign3types.NodeGroup{ID: &gid},
+		},
+		FileEmbedded1: ign3types.FileEmbedded1{
+			Contents: ign3types.Resource{Source: helpers.StrToPtr(dataurl.EncodeBytes([]byte(contents)))},
+			Mode:     &mode,
+		},
+	}
+}
+
+// fakeHostUnit returns an enabled unit.
+func fakeHostUnit(name string) ign3types.Unit {
+	return ign3types.Unit{
+		Name:     name,
+		Contents: helpers.StrToPtr(fmt.Sprintf("[Unit]\nDescription=%s\n", name)),
+		Enabled:  helpers.BoolToPtr(true),
+	}
+}
+
+// fakeNodeUpdaterClient is the rpm-ostree client, except that it doesn't inspect the
+// OS image before rebasing to it, as there's no registry.
+type fakeNodeUpdaterClient struct {
+	RpmOstreeClient
+}
+
+func (c *fakeNodeUpdaterClient) Rebase(imgURL, osImageContentDir string) (bool, error) {
+	_, err := c.runner.Run("rpm-ostree", "rebase", "--experimental", osImageContentDir+"/srv/repo:fake-commit",
+		"--custom-origin-url", "pivot://"+imgURL, "--custom-origin-description", "Managed by machine-config-operator")
+	return err == nil, err
+}
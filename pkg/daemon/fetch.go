@@ -0,0 +1,206 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/vincent-petithory/dataurl"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// remoteContents maps the source URLs of the remote files of a config to their contents.
+type remoteContents map[string][]byte
+
+// fetchBackoff is how we retry fetching a remote file.
+var fetchBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 5 * time.Second,
+	Factor:   2,
+}
+
+// fetchTimeout is the timeout of a single attempt to fetch a remote file.
+const fetchTimeout = 2 * time.Minute
+
+// isRemoteSource returns true if the file contents are fetched from the given source
+// rather than inlined in the config.
+func isRemoteSource(source *string) bool {
+	if source == nil {
+		return false
+	}
+	u, err := url.Parse(*source)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// verifyRemoteFile checks that the source of the file is one the MCD can fetch.
+func verifyRemoteFile(f ign3types.File) error {
+	if f.Contents.Source == nil {
+		return nil
+	}
+	u, err := url.Parse(*f.Contents.Source)
+	if err != nil {
+		return errors.Wrapf(err, "ignition file %q has an invalid source", f.Path)
+	}
+	switch u.Scheme {
+	case "data":
+		return nil
+	case "http", "https":
+		if f.Contents.Compression != nil && *f.Contents.Compression != "" {
+			return fmt.Errorf("ignition file %q includes compression for a remote source", f.Path)
+		}
+		return nil
+	default:
+		return fmt.Errorf("ignition file %q has an unsupported source scheme %q", f.Path, u.Scheme)
+	}
+}
+
+// fetchRemoteFiles downloads the contents of the files with an http(s) source, verifying
+// their hash if the config includes one. The proxy settings of the cluster are taken from
+// the environment of the MCD container, see the MCD daemonset.
+func fetchRemoteFiles(files []ign3types.File) (remoteContents, error) {
+	contents := remoteContents{}
+	for _, f := range files {
+		if !isRemoteSource(f.Contents.Source) {
+			continue
+		}
+		source := *f.Contents.Source
+		if _, ok := contents[source]; ok {
+			continue
+		}
+		glog.Infof("Fetching contents of file %q from %s", f.Path, source)
+		data, err := fetchRemoteFile(source, f.Contents.HTTPHeaders)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetching contents of file %q", f.Path)
+		}
+		if f.Contents.Verification.Hash != nil {
+			if err := verifyHash(data, *f.Contents.Verification.Hash); err != nil {
+				return nil, errors.Wrapf(err, "verifying contents of file %q", f.Path)
+			}
+		}
+		contents[source] = data
+	}
+	return contents, nil
+}
+
+// readRemoteFiles reads the contents of the files with an http(s) source from the disk
+// in root, so that we can roll back to them without fetching them again.
+func readRemoteFiles(root string, files []ign3types.File) remoteContents {
+	contents := remoteContents{}
+	for _, f := range files {
+		if !isRemoteSource(f.Contents.Source) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(root, f.Path))
+		if err != nil {
+			glog.Warningf("Failed to read remote file %q, it can't be rolled back: %v", f.Path, err)
+			continue
+		}
+		contents[*f.Contents.Source] = data
+	}
+	return contents
+}
+
+func fetchRemoteFile(source string, headers ign3types.HTTPHeaders) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range headers {
+		if h.Value != nil {
+			req.Header.Set(h.Name, *h.Value)
+		}
+	}
+	client := &http.Client{Timeout: fetchTimeout}
+
+	var data []byte
+	var lastErr error
+	if err := wait.ExponentialBackoff(fetchBackoff, func() (bool, error) {
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			glog.Infof("Fetching %s failed with: %v, retrying", source, err)
+			return false, nil
+		}
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusOK:
+		case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout:
+			lastErr = fmt.Errorf("server responded with %s", resp.Status)
+			glog.Infof("Fetching %s failed with: %v, retrying", source, lastErr)
+			return false, nil
+		default:
+			return false, fmt.Errorf("server responded with %s", resp.Status)
+		}
+		data, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			lastErr = err
+			glog.Infof("Reading %s failed with: %v, retrying", source, err)
+			return false, nil
+		}
+		return true, nil
+	}); err != nil {
+		if err == wait.ErrWaitTimeout {
+			return nil, errors.Wrapf(lastErr, "failed to fetch %s (%d tries)", source, fetchBackoff.Steps)
+		}
+		return nil, errors.Wrapf(err, "failed to fetch %s", source)
+	}
+	return data, nil
+}
+
+// verifyHash checks the data against an Ignition verification hash, in the
+// form <function>-<hex digest>.
+func verifyHash(data []byte, verification string) error {
+	parts := strings.SplitN(verification, "-", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid verification hash %q", verification)
+	}
+	var h hash.Hash
+	switch parts[0] {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported hash function %q", parts[0])
+	}
+	h.Write(data)
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != strings.ToLower(parts[1]) {
+		return fmt.Errorf("hash mismatch: expected %s, got %s-%s", verification, parts[0], sum)
+	}
+	return nil
+}
+
+// decodeFileContents returns the contents of the file, decoding its data URL or
+// looking up the contents fetched from its remote source.
+func decodeFileContents(f ign3types.File, fetched remoteContents) ([]byte, error) {
+	// To allow writing of "empty" files we'll allow source to be nil
+	if f.Contents.Source == nil {
+		return nil, nil
+	}
+	if isRemoteSource(f.Contents.Source) {
+		data, ok := fetched[*f.Contents.Source]
+		if !ok {
+			return nil, fmt.Errorf("contents of file %q weren't fetched from %s", f.Path, *f.Contents.Source)
+		}
+		return data, nil
+	}
+	contents, err := dataurl.DecodeString(*f.Contents.Source)
+	if err != nil {
+		return nil, err
+	}
+	return contents.Data, nil
+}
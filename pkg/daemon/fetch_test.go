@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vincent-petithory/dataurl"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func newRemoteFile(path, source, hash string) ign3types.File {
+	f := ign3types.File{
+		Node: ign3types.Node{Path: path},
+		FileEmbedded1: ign3types.FileEmbedded1{
+			Contents: ign3types.Resource{Source: helpers.StrToPtr(source)},
+		},
+	}
+	if hash != "" {
+		f.Contents.Verification.Hash = helpers.StrToPtr(hash)
+	}
+	return f
+}
+
+func TestFetchRemoteFiles(t *testing.T) {
+	defer func(b wait.Backoff) { fetchBackoff = b }(fetchBackoff)
+	fetchBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond, Factor: 1}
+
+	contents := []byte("ca bundle\n")
+	sum := sha512.Sum512(contents)
+	hash := "sha512-" + hex.EncodeToString(sum[:])
+
+	var flakyCalls, headerCalls, downCalls int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ca.crt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(contents)
+	})
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		flakyCalls++
+		if flakyCalls < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(contents)
+	})
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) {
+		headerCalls++
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write(contents)
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		downCalls++
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	// files sharing a source are fetched once, data URLs aren't fetched
+	inline := newRemoteFile("/etc/inline", dataurl.EncodeBytes([]byte("inline")), "")
+	files := []ign3types.File{
+		newRemoteFile("/etc/pki/ca.crt", ts.URL+"/ca.crt", hash),
+		newRemoteFile("/etc/pki/ca-copy.crt", ts.URL+"/ca.crt", ""),
+		newRemoteFile("/etc/flaky", ts.URL+"/flaky", ""),
+		inline,
+	}
+	fetched, err := fetchRemoteFiles(files)
+	require.Nil(t, err)
+	assert.Len(t, fetched, 2)
+	assert.Equal(t, 2, flakyCalls)
+	for _, f := range files[:3] {
+		data, err := decodeFileContents(f, fetched)
+		require.Nil(t, err)
+		assert.Equal(t, contents, data)
+	}
+	data, err := decodeFileContents(inline, fetched)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("inline"), data)
+	_, err = decodeFileContents(newRemoteFile("/etc/other", ts.URL+"/other", ""), fetched)
+	assert.NotNil(t, err)
+
+	// a hash mismatch fails the update
+	_, err = fetchRemoteFiles([]ign3types.File{newRemoteFile("/etc/pki/ca.crt", ts.URL+"/ca.crt", "sha512-0123")})
+	assert.NotNil(t, err)
+
+	// client errors aren't retried, and headers are sent
+	private := newRemoteFile("/etc/private", ts.URL+"/private", "")
+	_, err = fetchRemoteFiles([]ign3types.File{private})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, headerCalls)
+	private.Contents.HTTPHeaders = ign3types.HTTPHeaders{{Name: "Authorization", Value: helpers.StrToPtr("Bearer token")}}
+	fetched, err = fetchRemoteFiles([]ign3types.File{private})
+	require.Nil(t, err)
+	assert.Equal(t, contents, fetched[ts.URL+"/private"])
+
+	// server errors are retried until the backoff is exhausted
+	_, err = fetchRemoteFiles([]ign3types.File{newRemoteFile("/etc/down", ts.URL+"/down", "")})
+	assert.NotNil(t, err)
+	assert.Equal(t, fetchBackoff.Steps, downCalls)
+}
+
+func TestVerifyHash(t *testing.T) {
+	data := []byte("hello world\n")
+	assert.Nil(t, verifyHash(data, "sha256-a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"))
+	assert.Nil(t, verifyHash(data, "sha256-A948904F2F0F479B8F8197694B30184B0D2ED1C1CD2A1EC0FB85D299A192A447"))
+	assert.NotNil(t, verifyHash(data, "sha256-0000"))
+	assert.NotNil(t, verifyHash(data, "md5-6f5902ac237024bdd0c176cb93063dc4"))
+	assert.NotNil(t, verifyHash(data, "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"))
+}
+
+func TestVerifyRemoteFile(t *testing.T) {
+	assert.Nil(t, verifyRemoteFile(newRemoteFile("/etc/a", "https://example.com/a", "")))
+	assert.Nil(t, verifyRemoteFile(newRemoteFile("/etc/a", "data:,a", "")))
+	assert.NotNil(t, verifyRemoteFile(newRemoteFile("/etc/a", "s3://bucket/a", "")))
+	assert.NotNil(t, verifyRemoteFile(newRemoteFile("/etc/a", "tftp://example.com/a", "")))
+	compressed := newRemoteFile("/etc/a", "https://example.com/a.gz", "")
+	compressed.Contents.Compression = helpers.StrToPtr("gzip")
+	assert.NotNil(t, verifyRemoteFile(compressed))
+}
+
+func TestCheckRemoteFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote-files")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ca.crt")
+	require.Nil(t, ioutil.WriteFile(path, []byte("hello world\n"), 0644))
+	mode := 0644
+	f := newRemoteFile(path, "https://example.com/ca.crt", "sha256-a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447")
+	f.Mode = &mode
+	assert.Nil(t, checkV3Files("/", []ign3types.File{f}))
+
+	// the contents are read back from disk when rolling back
+	assert.Equal(t, remoteContents{"https://example.com/ca.crt": []byte("hello world\n")}, readRemoteFiles("/", []ign3types.File{f}))
+
+	require.Nil(t, ioutil.WriteFile(path, []byte("changed\n"), 0644))
+	assert.NotNil(t, checkV3Files("/", []ign3types.File{f}))
+
+	// without a hash only the mode is checked
+	f.Contents.Verification.Hash = nil
+	assert.Nil(t, checkV3Files("/", []ign3types.File{f}))
+}
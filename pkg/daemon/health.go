@@ -0,0 +1,319 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+const (
+	// defaultHealthCheckTimeout is how long the node has after booting to pass the health check
+	// when its pool doesn't set it
+	defaultHealthCheckTimeout = 10 * time.Minute
+	// healthCheckInterval is how often the health check is retried until it passes
+	healthCheckInterval = 10 * time.Second
+	// crioSocketPath is where CRI-O serves its API
+	crioSocketPath = "/var/run/crio/crio.sock"
+	// healthCheckFailurePath is where we record the config the node rolled back from.
+	// It is under /var, which, unlike /etc, is shared by the OS deployments.
+	healthCheckFailurePath = "/var/lib/machine-config-daemon/health-check-failure.json"
+)
+
+// healthCheckFailure records that the node failed its health check after rebooting into a config.
+type healthCheckFailure struct {
+	// Config is the config the node failed its health check in
+	Config string `json:"config"`
+	// PreviousConfig is the config the node rolls back to
+	PreviousConfig string `json:"previousConfig"`
+	// BootID is the boot which failed the health check
+	BootID string `json:"bootID"`
+	// Reason is why the health check failed
+	Reason string `json:"reason"`
+	// OSRolledBack is set once the previous OS deployment is the default one
+	OSRolledBack bool `json:"osRolledBack,omitempty"`
+	// FailedAt is when the health check failed
+	FailedAt time.Time `json:"failedAt"`
+}
+
+// healthCheck is a named check of the health of the node.
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// loadHealthCheckFailure reads the health check failure, returning nil if there is none.
+func (dn *Daemon) loadHealthCheckFailure() (*healthCheckFailure, error) {
+	b, err := ioutil.ReadFile(dn.hostPath(dn.healthCheckFailurePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "reading health check failure %s", dn.healthCheckFailurePath)
+	}
+	failure := &healthCheckFailure{}
+	if err := json.Unmarshal(b, failure); err != nil {
+		return nil, errors.Wrapf(err, "parsing health check failure %s", dn.healthCheckFailurePath)
+	}
+	return failure, nil
+}
+
+func (dn *Daemon) storeHealthCheckFailure(failure *healthCheckFailure) error {
+	b, err := json.Marshal(failure)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dn.hostPath(filepath.Dir(dn.healthCheckFailurePath)), 0755); err != nil {
+		return err
+	}
+	return dn.writeFileAtomicallyWithDefaults(dn.healthCheckFailurePath, b)
+}
+
+// getHealthCheckPolicy returns the health check policy set on the node by the node controller
+// from its pool, or nil if the node isn't checked.
+func (dn *Daemon) getHealthCheckPolicy() *mcfgv1.MachineConfigPoolHealthCheckPolicy {
+	settings := dn.getPoolSettings()
+	if settings == nil {
+		// still check the node, with the defaults
+		return &mcfgv1.MachineConfigPoolHealthCheckPolicy{}
+	}
+	return settings.HealthCheckPolicy
+}
+
+// bootHealthChecks returns the checks the node must pass after rebooting into a new config.
+func (dn *Daemon) bootHealthChecks(policy *mcfgv1.MachineConfigPoolHealthCheckPolicy) []healthCheck {
+	checks := []healthCheck{
+		{name: "kubelet", check: dn.getHealth},
+		{name: "crio", check: func() error { return checkCRIO(crioSocketPath) }},
+	}
+	for _, unit := range policy.Units {
+		unit := unit
+		checks = append(checks, healthCheck{name: unit, check: func() error { return dn.checkUnitActive(unit) }})
+	}
+	return checks
+}
+
+// waitForHealthy runs the checks every interval until they all pass, or until the deadline,
+// in which case the failures of the last attempt are returned.
+func waitForHealthy(checks []healthCheck, deadline time.Time, interval time.Duration) error {
+	for {
+		var failures []string
+		for _, c := range checks {
+			if err := c.check(); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", c.name, err))
+			}
+		}
+		if len(failures) == 0 {
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return errors.New(strings.Join(failures, "; "))
+		}
+		glog.Infof("Waiting for the node to be healthy: %s", strings.Join(failures, "; "))
+		if remaining < interval {
+			interval = remaining
+		}
+		time.Sleep(interval)
+	}
+}
+
+// checkCRIO returns an error if CRI-O doesn't answer on its socket.
+func checkCRIO(socket string) error {
+	client := http.Client{
+		Timeout: kubeletHealthzTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	resp, err := client.Get("http://crio/info")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", socket, resp.Status)
+	}
+	return nil
+}
+
+// checkUnitActive returns an error if the systemd unit isn't active.
+func (dn *Daemon) checkUnitActive(unit string) error {
+	state, err := dn.systemd.ActiveState(unit)
+	if err != nil {
+		return err
+	}
+	if state != "active" {
+		return fmt.Errorf("unit is %s", state)
+	}
+	return nil
+}
+
+// getBootTime returns when the node booted.
+func getBootTime() (time.Time, error) {
+	b, err := ioutil.ReadFile("/proc/uptime")
+	if err != nil {
+		return time.Time{}, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("unexpected /proc/uptime contents %q", string(b))
+	}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "parsing /proc/uptime")
+	}
+	return time.Now().Add(-time.Duration(uptime * float64(time.Second))), nil
+}
+
+// verifyBootHealth waits for the node to pass its health check after rebooting into the pending
+// config, if its pool asks for it. If the node isn't healthy before the deadline, it is rolled
+// back to the current config.
+func (dn *Daemon) verifyBootHealth(pending, current *mcfgv1.MachineConfig) error {
+	policy := dn.getHealthCheckPolicy()
+	if policy == nil {
+		return nil
+	}
+	timeout := defaultHealthCheckTimeout
+	if policy.Timeout != nil && policy.Timeout.Duration > 0 {
+		timeout = policy.Timeout.Duration
+	}
+	bootTime, err := getBootTime()
+	if err != nil {
+		return err
+	}
+	glog.Infof("Checking the health of the node in config %s", pending.GetName())
+	if err := waitForHealthy(dn.bootHealthChecks(policy), bootTime.Add(timeout), healthCheckInterval); err != nil {
+		return dn.rollBackFailedBoot(&healthCheckFailure{
+			Config:         pending.GetName(),
+			PreviousConfig: current.GetName(),
+			BootID:         dn.bootID,
+			Reason:         err.Error(),
+			FailedAt:       time.Now().UTC(),
+		}, pending, current)
+	}
+	glog.Infof("Node is healthy in config %s", pending.GetName())
+	return nil
+}
+
+// rollBackFailedBoot makes the previous OS deployment the default one if the update to the failed
+// config changed it, reverts the files, units and users of the failed config, marks the node
+// Degraded and reboots.
+func (dn *Daemon) rollBackFailedBoot(failure *healthCheckFailure, failed, previous *mcfgv1.MachineConfig) error {
+	reason := fmt.Errorf("node failed its health check in config %s, rolling back to %s: %s", failure.Config, failure.PreviousConfig, failure.Reason)
+	dn.logSystem("%v", reason)
+	if dn.recorder != nil {
+		dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeWarning, "HealthCheckFailed", reason.Error())
+	}
+	if err := dn.storeHealthCheckFailure(failure); err != nil {
+		return errors.Wrap(err, "recording health check failure")
+	}
+
+	diff, err := newMachineConfigDiff(previous, failed)
+	if err != nil {
+		return err
+	}
+	if !failure.OSRolledBack && dn.os.IsCoreOSVariant() && (diff.osUpdate || diff.kargs || diff.extensions || diff.kernelType) {
+		if err := dn.NodeUpdaterClient.Rollback(); err != nil {
+			return errors.Wrap(err, "rolling back OS deployment")
+		}
+		failure.OSRolledBack = true
+		if err := dn.storeHealthCheckFailure(failure); err != nil {
+			return errors.Wrap(err, "recording health check failure")
+		}
+	}
+	// when the OS deployment is rolled back, this is done again after rebooting,
+	// as the previous deployment has its own /etc
+	if err := dn.revertOnDiskState(failed, previous); err != nil {
+		return err
+	}
+	if err := dn.nodeWriter.SetDegraded(reason, dn.kubeClient.CoreV1().Nodes(), dn.nodeLister, dn.name); err != nil {
+		glog.Warningf("Failed to set the node Degraded: %v", err)
+	}
+	return dn.reboot(fmt.Sprintf("Node will reboot into config %s after failing its health check", previous.GetName()))
+}
+
+// revertOnDiskState writes back the files, units and users of the previous config over the ones
+// of the failed config. The OS deployment, and so the kernel arguments and extensions, are left as is.
+func (dn *Daemon) revertOnDiskState(failed, previous *mcfgv1.MachineConfig) error {
+	failedIgnConfig, err := ctrlcommon.ParseAndConvertConfig(failed.Spec.Config.Raw)
+	if err != nil {
+		return errors.Wrapf(err, "parsing Ignition config of %s", failed.GetName())
+	}
+	previousIgnConfig, err := ctrlcommon.ParseAndConvertConfig(previous.Spec.Config.Raw)
+	if err != nil {
+		return errors.Wrapf(err, "parsing Ignition config of %s", previous.GetName())
+	}
+	fetched, err := fetchRemoteFiles(previousIgnConfig.Storage.Files)
+	if err != nil {
+		return err
+	}
+	if err := dn.updateFiles(failed, previous, fetched); err != nil {
+		return err
+	}
+	if err := dn.updateSSHKeys(previousIgnConfig.Passwd.Users); err != nil {
+		return err
+	}
+	if err := dn.updatePasswd(failedIgnConfig.Passwd, previousIgnConfig.Passwd); err != nil {
+		return err
+	}
+	return dn.storeCurrentConfigOnDisk(previous)
+}
+
+// checkHealthCheckFailure handles a previous health check failure when the daemon starts: it finishes
+// rolling back the node, and refuses to update it to the failed config again.
+func (dn *Daemon) checkHealthCheckFailure(state *stateAndConfigs) error {
+	failure, err := dn.loadHealthCheckFailure()
+	if err != nil || failure == nil {
+		return err
+	}
+	if state.pendingConfig != nil && state.pendingConfig.GetName() == failure.Config {
+		if failure.BootID == dn.bootID {
+			// the daemon restarted before the node rebooted
+			return dn.rollBackFailedBoot(failure, state.pendingConfig, state.currentConfig)
+		}
+		// we're back into the previous OS deployment, but its /etc may still have the files of the failed config
+		reboot := false
+		if err := dn.validateOnDiskState(state.currentConfig); err != nil {
+			glog.Infof("Reverting the on-disk state of %s: %v", failure.Config, err)
+			if err := dn.revertOnDiskState(state.pendingConfig, state.currentConfig); err != nil {
+				return err
+			}
+			reboot = true
+		}
+		if err := dn.storePendingState(state.pendingConfig, updatePhaseRolledBack); err != nil {
+			return errors.Wrap(err, "failed to store pending state")
+		}
+		if reboot {
+			// restart the services with the files of the previous config
+			return dn.reboot(fmt.Sprintf("Node will reboot into config %s after reverting config %s", state.currentConfig.GetName(), failure.Config))
+		}
+		state.pendingConfig = nil
+	}
+	if state.desiredConfig.GetName() == failure.Config {
+		return fmt.Errorf("node rolled back from config %s to %s after failing its health check: %s", failure.Config, failure.PreviousConfig, failure.Reason)
+	}
+	// the pool moved on from the failed config
+	glog.Infof("Desired config changed from %s, clearing its health check failure", failure.Config)
+	if err := os.Remove(dn.hostPath(dn.healthCheckFailurePath)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
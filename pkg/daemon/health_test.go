@@ -0,0 +1,133 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestGetHealthCheckPolicy(t *testing.T) {
+	dn := &Daemon{node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}}
+	assert.Nil(t, dn.getHealthCheckPolicy())
+
+	dn.node.Annotations[constants.PoolSettingsAnnotationKey] = `{"version":1,"healthCheckPolicy":{"timeout":"5m0s","units":["ovs-vswitchd.service"]}}`
+	policy := dn.getHealthCheckPolicy()
+	require.NotNil(t, policy)
+	assert.Equal(t, 5*time.Minute, policy.Timeout.Duration)
+	assert.Equal(t, []string{"ovs-vswitchd.service"}, policy.Units)
+	dn.systemd = &fakeSystemdClient{states: map[string]string{"ovs-vswitchd.service": "failed"}}
+	checks := dn.bootHealthChecks(policy)
+	require.Len(t, checks, 3)
+	assert.Equal(t, "ovs-vswitchd.service", checks[2].name)
+	err := checks[2].check()
+	require.NotNil(t, err)
+	assert.Equal(t, "unit is failed", err.Error())
+
+	// invalid settings still check the node
+	dn.node.Annotations[constants.PoolSettingsAnnotationKey] = `{"version":1,"healthCheckPolicy":{"timeout":`
+	policy = dn.getHealthCheckPolicy()
+	require.NotNil(t, policy)
+	assert.Nil(t, policy.Timeout)
+}
+
+func TestWaitForHealthy(t *testing.T) {
+	calls := 0
+	flaky := healthCheck{name: "flaky", check: func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("not yet")
+		}
+		return nil
+	}}
+	healthy := healthCheck{name: "healthy", check: func() error { return nil }}
+	assert.Nil(t, waitForHealthy([]healthCheck{healthy, flaky}, time.Now().Add(time.Minute), time.Millisecond))
+	assert.Equal(t, 3, calls)
+
+	broken := healthCheck{name: "broken", check: func() error { return fmt.Errorf("down") }}
+	err := waitForHealthy([]healthCheck{healthy, broken}, time.Now().Add(10*time.Millisecond), time.Millisecond)
+	require.NotNil(t, err)
+	assert.Equal(t, "broken: down", err.Error())
+
+	// a past deadline still runs the checks once
+	assert.Nil(t, waitForHealthy([]healthCheck{healthy}, time.Now().Add(-time.Minute), time.Millisecond))
+}
+
+func TestCheckCRIO(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crio")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "crio.sock")
+	assert.NotNil(t, checkCRIO(socket))
+
+	l, err := net.Listen("unix", socket)
+	require.Nil(t, err)
+	status := http.StatusOK
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/info" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+
+	assert.Nil(t, checkCRIO(socket))
+	status = http.StatusInternalServerError
+	assert.NotNil(t, checkCRIO(socket))
+}
+
+func TestHealthCheckFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	dn := &Daemon{healthCheckFailurePath: filepath.Join(dir, "var/lib/machine-config-daemon/health-check-failure.json")}
+	failure, err := dn.loadHealthCheckFailure()
+	require.Nil(t, err)
+	assert.Nil(t, failure)
+
+	stored := &healthCheckFailure{
+		Config:         "rendered-worker-2",
+		PreviousConfig: "rendered-worker-1",
+		BootID:         "boot",
+		Reason:         "crio: connection refused",
+		FailedAt:       time.Now().UTC().Truncate(time.Second),
+	}
+	require.Nil(t, dn.storeHealthCheckFailure(stored))
+	failure, err = dn.loadHealthCheckFailure()
+	require.Nil(t, err)
+	assert.Equal(t, stored, failure)
+
+	// the node isn't updated to the failed config again
+	state := &stateAndConfigs{
+		currentConfig: helpers.NewMachineConfig("rendered-worker-1", nil, "", nil),
+		desiredConfig: helpers.NewMachineConfig("rendered-worker-2", nil, "", nil),
+	}
+	err = dn.checkHealthCheckFailure(state)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "crio: connection refused")
+
+	// until the pool moves on
+	state.desiredConfig = helpers.NewMachineConfig("rendered-worker-3", nil, "", nil)
+	assert.Nil(t, dn.checkHealthCheckFailure(state))
+	failure, err = dn.loadHealthCheckFailure()
+	require.Nil(t, err)
+	assert.Nil(t, failure)
+}
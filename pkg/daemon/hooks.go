@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+// defaultUpdateHookTimeout is how long a hook can run when it doesn't set it
+const defaultUpdateHookTimeout = 5 * time.Minute
+
+// getUpdateHooks returns the update hooks set on the node by the node controller from its pool.
+func (dn *Daemon) getUpdateHooks() []mcfgv1.MachineConfigPoolUpdateHook {
+	settings := dn.getPoolSettings()
+	if settings == nil {
+		return nil
+	}
+	return settings.UpdateHooks
+}
+
+// runUpdateHooks runs the hooks of the phase in order, reporting their results as node events.
+// It returns an error as soon as a hook with the Abort failure policy fails.
+func (dn *Daemon) runUpdateHooks(phase mcfgv1.UpdateHookPhase, oldConfig, newConfig *mcfgv1.MachineConfig) error {
+	for _, hook := range dn.getUpdateHooks() {
+		if hook.Phase != phase {
+			continue
+		}
+		dn.logSystem("Running %s update hook %s", phase, hook.Name)
+		start := time.Now()
+		err := runUpdateHook(dn.runner, dn.systemd, hook, oldConfig.GetName(), newConfig.GetName())
+		if err == nil {
+			glog.Infof("Update hook %s succeeded in %v", hook.Name, time.Since(start).Round(time.Second))
+			if dn.recorder != nil {
+				dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeNormal, "UpdateHookSucceeded", fmt.Sprintf("%s update hook %s succeeded", phase, hook.Name))
+			}
+			continue
+		}
+		dn.logSystem("%s update hook %s failed: %v", phase, hook.Name, err)
+		if dn.recorder != nil {
+			dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeWarning, "UpdateHookFailed", fmt.Sprintf("%s update hook %s failed: %v", phase, hook.Name, err))
+		}
+		if hook.FailurePolicy != mcfgv1.UpdateHookFailurePolicyContinue {
+			return errors.Wrapf(err, "%s update hook %s failed", phase, hook.Name)
+		}
+	}
+	return nil
+}
+
+// runUpdateHook runs the unit or the command of the hook until it completes or times out.
+// Commands get the phase and the configs of the update in their environment.
+func runUpdateHook(runner HostRunner, systemd SystemdClient, hook mcfgv1.MachineConfigPoolUpdateHook, oldConfigName, newConfigName string) error {
+	if (hook.Unit == "") == (len(hook.Command) == 0) {
+		return fmt.Errorf("exactly one of unit and command must be set")
+	}
+	timeout := defaultUpdateHookTimeout
+	if hook.Timeout != nil && hook.Timeout.Duration > 0 {
+		timeout = hook.Timeout.Duration
+	}
+	if hook.Unit != "" {
+		return runUpdateHookUnit(systemd, hook.Unit, timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	env := []string{
+		"MCD_UPDATE_HOOK_PHASE=" + string(hook.Phase),
+		"MCD_CURRENT_CONFIG=" + oldConfigName,
+		"MCD_DESIRED_CONFIG=" + newConfigName,
+	}
+	out, err := runner.RunWithEnv(ctx, env, hook.Command[0], hook.Command[1:]...)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+	if err != nil {
+		if output := strings.TrimSpace(string(out)); output != "" {
+			return fmt.Errorf("%v: %.1000s", err, output)
+		}
+		return err
+	}
+	return nil
+}
+
+// runUpdateHookUnit starts the unit of a hook, stopping it if it doesn't complete in time.
+func runUpdateHookUnit(systemd SystemdClient, unit string, timeout time.Duration) error {
+	// starting a unit waits for oneshot services to exit
+	done := make(chan error, 1)
+	go func() {
+		done <- systemd.StartUnit(unit)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		if err := systemd.StopUnit(unit); err != nil {
+			glog.Warningf("Failed to stop timed out update hook unit %s: %v", unit, err)
+		}
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+}
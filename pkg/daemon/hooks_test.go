@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestRunUpdateHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	runner := &fakeRunner{}
+	systemd := &systemctlClient{runner: runner}
+	out := filepath.Join(dir, "env")
+	hook := mcfgv1.MachineConfigPoolUpdateHook{
+		Name:    "env",
+		Phase:   mcfgv1.UpdateHookPhaseBeforeDrain,
+		Command: []string{"sh", "-c", `echo "$MCD_UPDATE_HOOK_PHASE $MCD_CURRENT_CONFIG $MCD_DESIRED_CONFIG" > ` + out},
+	}
+	require.Nil(t, runUpdateHook(execRunner{}, systemd, hook, "rendered-worker-1", "rendered-worker-2"))
+	env, err := ioutil.ReadFile(out)
+	require.Nil(t, err)
+	assert.Equal(t, "BeforeDrain rendered-worker-1 rendered-worker-2\n", string(env))
+
+	hook.Command = []string{"sh", "-c", "echo cache is busy; exit 3"}
+	err = runUpdateHook(execRunner{}, systemd, hook, "rendered-worker-1", "rendered-worker-2")
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "cache is busy")
+
+	hook.Command = []string{"sleep", "10"}
+	hook.Timeout = &metav1.Duration{Duration: 10 * time.Millisecond}
+	err = runUpdateHook(execRunner{}, systemd, hook, "rendered-worker-1", "rendered-worker-2")
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+
+	hook.Unit = "flush-cache.service"
+	assert.NotNil(t, runUpdateHook(execRunner{}, systemd, hook, "rendered-worker-1", "rendered-worker-2"))
+	hook.Unit = ""
+	hook.Command = nil
+	assert.NotNil(t, runUpdateHook(execRunner{}, systemd, hook, "rendered-worker-1", "rendered-worker-2"))
+
+	// units are started through systemd, and stopped when they time out
+	hook.Unit = "flush-cache.service"
+	require.Nil(t, runUpdateHook(execRunner{}, systemd, hook, "rendered-worker-1", "rendered-worker-2"))
+	runner.respond("systemctl start flush-cache.service", "", "Job for flush-cache.service failed.", 1)
+	err = runUpdateHook(execRunner{}, systemd, hook, "rendered-worker-1", "rendered-worker-2")
+	require.NotNil(t, err)
+	assert.Equal(t, 1, commandExitCode(err))
+	assert.Equal(t, []string{"systemctl start flush-cache.service", "systemctl start flush-cache.service"}, runner.ran)
+
+	runner = &fakeRunner{}
+	slow := &slowStartSystemd{systemctlClient: systemctlClient{runner: runner}, started: make(chan struct{})}
+	defer close(slow.started)
+	err = runUpdateHook(execRunner{}, slow, hook, "rendered-worker-1", "rendered-worker-2")
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+	assert.Equal(t, []string{"systemctl stop flush-cache.service"}, runner.ran)
+}
+
+// slowStartSystemd is a SystemdClient whose units don't complete until started is closed.
+type slowStartSystemd struct {
+	systemctlClient
+	started chan struct{}
+}
+
+func (s *slowStartSystemd) StartUnit(unit string) error {
+	<-s.started
+	return nil
+}
+
+func TestRunUpdateHooks(t *testing.T) {
+	hooks := []mcfgv1.MachineConfigPoolUpdateHook{
+		{Name: "snapshot", Phase: mcfgv1.UpdateHookPhaseBeforeReboot, Command: []string{"snapshot"}},
+		{Name: "optional", Phase: mcfgv1.UpdateHookPhaseBeforeDrain, Command: []string{"optional"}, FailurePolicy: mcfgv1.UpdateHookFailurePolicyContinue},
+		{Name: "deregister", Phase: mcfgv1.UpdateHookPhaseBeforeDrain, Command: []string{"deregister", "--node", "node-0"}},
+		{Name: "required", Phase: mcfgv1.UpdateHookPhaseAfterBoot, Command: []string{"required"}},
+		{Name: "register", Phase: mcfgv1.UpdateHookPhaseAfterBoot, Command: []string{"register"}},
+	}
+	data, err := json.Marshal(ctrlcommon.PoolSettings{Version: ctrlcommon.PoolSettingsVersion, UpdateHooks: hooks})
+	require.Nil(t, err)
+	runner := &fakeRunner{}
+	runner.respond("optional", "", "", 1)
+	runner.respond("required", "", "", 1)
+	dn := &Daemon{runner: runner, node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		constants.PoolSettingsAnnotationKey: string(data),
+	}}}}
+	oldConfig := helpers.NewMachineConfig("rendered-worker-1", nil, "", nil)
+	newConfig := helpers.NewMachineConfig("rendered-worker-2", nil, "", nil)
+	ran := func(cmdline string) bool {
+		for _, c := range runner.ran {
+			if c == cmdline {
+				return true
+			}
+		}
+		return false
+	}
+
+	// only the hooks of the phase run, and failures can be ignored
+	assert.Nil(t, dn.runUpdateHooks(mcfgv1.UpdateHookPhaseBeforeDrain, oldConfig, newConfig))
+	assert.True(t, ran("deregister --node node-0"))
+	assert.False(t, ran("snapshot"))
+	require.Len(t, runner.stdin, 3)
+	assert.Contains(t, runner.stdin[1], "BeforeDrain update hook optional failed: error running optional")
+
+	// failures abort the phase by default
+	err = dn.runUpdateHooks(mcfgv1.UpdateHookPhaseAfterBoot, oldConfig, newConfig)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "AfterBoot update hook required failed")
+	assert.False(t, ran("register"))
+
+	// nodes without hooks aren't affected
+	dn.node.Annotations = map[string]string{}
+	assert.Nil(t, dn.runUpdateHooks(mcfgv1.UpdateHookPhaseAfterBoot, oldConfig, newConfig))
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"strings"
 
 	// Enable sha256 in container image references
@@ -144,7 +143,7 @@ func parseTuningFile(tuningFilePath, cmdLinePath string) ([]types.TuneArgument,
 }
 
 // UpdateTuningArgs executes additions and removals of kernel tuning arguments
-func UpdateTuningArgs(tuningFilePath, cmdLinePath string) (bool, error) {
+func UpdateTuningArgs(runner HostRunner, tuningFilePath, cmdLinePath string) (bool, error) {
 	if cmdLinePath == "" {
 		cmdLinePath = CmdLineFile
 	}
@@ -158,7 +157,7 @@ func UpdateTuningArgs(tuningFilePath, cmdLinePath string) (bool, error) {
 	for _, toAdd := range additions {
 		if toAdd.Bare {
 			changed = true
-			err := exec.Command("rpm-ostree", "kargs", fmt.Sprintf("--append=%s", toAdd.Key)).Run()
+			_, err := runner.Run("rpm-ostree", "kargs", fmt.Sprintf("--append=%s", toAdd.Key))
 			if err != nil {
 				return false, errors.Wrapf(err, "adding karg")
 			}
@@ -170,7 +169,7 @@ func UpdateTuningArgs(tuningFilePath, cmdLinePath string) (bool, error) {
 	for _, toDelete := range deletions {
 		if toDelete.Bare {
 			changed = true
-			err := exec.Command("rpm-ostree", "kargs", fmt.Sprintf("--delete=%s", toDelete.Key)).Run()
+			_, err := runner.Run("rpm-ostree", "kargs", fmt.Sprintf("--delete=%s", toDelete.Key))
 			if err != nil {
 				return false, errors.Wrapf(err, "deleting karg")
 			}
@@ -68,6 +68,20 @@ var (
 			Help: "completed update config or error",
 		}, []string{"config", "err"})
 
+	// MCDDriftedPaths shows the files and units which drifted from the current config
+	MCDDriftedPaths = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mcd_drifted_paths",
+			Help: "files and units which drifted from the current config",
+		}, []string{"path"})
+
+	// MCDDriftRemediations counts the drifted files and units rewritten from the current config
+	MCDDriftRemediations = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mcd_drift_remediations",
+			Help: "drifted files and units rewritten from the current config",
+		})
+
 	metricsList = []prometheus.Collector{
 		HostOS,
 		MCDSSHAccessed,
@@ -77,6 +91,8 @@ var (
 		KubeletHealthState,
 		MCDRebootErr,
 		MCDUpdateState,
+		MCDDriftedPaths,
+		MCDDriftRemediations,
 	}
 )
 
@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/golang/glog"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	corev1 "k8s.io/api/core/v1"
 )
@@ -23,7 +24,7 @@ func (dn *Daemon) loadNodeAnnotations(node *corev1.Node) (*corev1.Node, error) {
 
 	glog.Infof("No %s annotation on node %s: %v, in cluster bootstrap, loading initial node annotation from %s", constants.CurrentMachineConfigAnnotationKey, node.Name, node.Annotations, constants.InitialNodeAnnotationsFilePath)
 
-	d, err := ioutil.ReadFile(constants.InitialNodeAnnotationsFilePath)
+	d, err := ioutil.ReadFile(dn.hostPath(constants.InitialNodeAnnotationsFilePath))
 	if err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("failed to read initial annotations from %q: %v", constants.InitialNodeAnnotationsFilePath, err)
 	}
@@ -67,3 +68,18 @@ func getNodeAnnotationExt(node *corev1.Node, k string, allowNoent bool) (string,
 
 	return v, nil
 }
+
+// getPoolSettings returns the settings of the pool set on the node by the node controller,
+// empty if they're unset, or nil if they're invalid.
+func (dn *Daemon) getPoolSettings() *ctrlcommon.PoolSettings {
+	if dn.node == nil {
+		return &ctrlcommon.PoolSettings{}
+	}
+	data := dn.node.Annotations[constants.PoolSettingsAnnotationKey]
+	settings, err := ctrlcommon.ParsePoolSettings(data)
+	if err != nil {
+		glog.Warningf("Ignoring invalid pool settings %q: %v", data, err)
+		return nil
+	}
+	return settings
+}
@@ -0,0 +1,177 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/machine-config-operator/lib/resourceread"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// The functions in this file let the MCD checks run without a cluster nor a node,
+// e.g. to gate MachineConfig changes in CI.
+
+// ConfigChanges lists the sections which differ between two MachineConfigs.
+type ConfigChanges struct {
+	OSUpdate    bool `json:"osUpdate"`
+	KernelArgs  bool `json:"kernelArguments"`
+	FIPS        bool `json:"fips"`
+	Passwd      bool `json:"passwd"`
+	Files       bool `json:"files"`
+	Directories bool `json:"directories"`
+	Links       bool `json:"links"`
+	Units       bool `json:"units"`
+	KernelType  bool `json:"kernelType"`
+	Extensions  bool `json:"extensions"`
+}
+
+// DiffReport is the result of comparing two MachineConfigs as the MCD does before an update.
+type DiffReport struct {
+	OldConfig string `json:"oldConfig"`
+	NewConfig string `json:"newConfig"`
+	// Changes is unset if the configs can't be parsed
+	Changes *ConfigChanges `json:"changes,omitempty"`
+	// Reconcilable is false if the MCD can't update the node in place, see Error
+	Reconcilable bool   `json:"reconcilable"`
+	Error        string `json:"error,omitempty"`
+	// PostConfigChangeActions are the actions the MCD takes after writing the new config
+	PostConfigChangeActions []string `json:"postConfigChangeActions,omitempty"`
+	// Reboot is true if the node reboots into the new config
+	Reboot bool `json:"reboot"`
+}
+
+// PathReport is the validation result of a file or unit.
+type PathReport struct {
+	Path  string `json:"path"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// ValidationReport is the result of validating a root directory against a MachineConfig,
+// as the MCD does when it starts.
+type ValidationReport struct {
+	Config string       `json:"config"`
+	Root   string       `json:"root"`
+	Valid  bool         `json:"valid"`
+	Files  []PathReport `json:"files"`
+	Units  []PathReport `json:"units"`
+}
+
+// LoadMachineConfig reads a MachineConfig, or an Ignition config which is wrapped
+// into a MachineConfig named after the file.
+func LoadMachineConfig(path string) (*mcfgv1.MachineConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ignConfig, ignErr := ctrlcommon.ParseAndConvertConfig(content)
+	if ignErr == nil && ignConfig.Ignition.Version != "" {
+		return &mcfgv1.MachineConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))},
+			Spec: mcfgv1.MachineConfigSpec{
+				Config: runtime.RawExtension{Raw: content},
+			},
+		}, nil
+	}
+	mc, err := resourceread.ReadMachineConfigV1(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither an Ignition config (%v) nor a MachineConfig (%v)", path, ignErr, err)
+	}
+	return mc, nil
+}
+
+// checkFIPSUnchanged refuses changes to the FIPS flag without looking at the system.
+func checkFIPSUnchanged(current, desired *mcfgv1.MachineConfig) error {
+	if current.Spec.FIPS != desired.Spec.FIPS {
+		return errors.New("detected change to FIPS flag; refusing to modify FIPS on a running cluster")
+	}
+	return nil
+}
+
+// DiffMachineConfigs reports the changes between two MachineConfigs, whether the MCD can
+// apply them in place, and the actions it takes after writing the new config.
+func DiffMachineConfigs(oldConfig, newConfig *mcfgv1.MachineConfig) *DiffReport {
+	oldConfig = canonicalizeEmptyMC(oldConfig)
+	report := &DiffReport{
+		OldConfig: oldConfig.GetName(),
+		NewConfig: newConfig.GetName(),
+	}
+	diff, err := newMachineConfigDiff(oldConfig, newConfig)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Changes = &ConfigChanges{
+		OSUpdate:    diff.osUpdate,
+		KernelArgs:  diff.kargs,
+		FIPS:        diff.fips,
+		Passwd:      diff.passwd,
+		Files:       diff.files,
+		Directories: diff.directories,
+		Links:       diff.links,
+		Units:       diff.units,
+		KernelType:  diff.kernelType,
+		Extensions:  diff.extensions,
+	}
+	if _, err := reconcilableWithFIPSCheck(oldConfig, newConfig, checkFIPSUnchanged); err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Reconcilable = true
+	actions, err := calculatePostConfigChangeActionFromConfigs(oldConfig, newConfig)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.PostConfigChangeActions = actions
+	report.Reboot = ctrlcommon.InSlice(postConfigChangeActionReboot, actions)
+	return report
+}
+
+// ValidateOnDiskState reports whether the files and units of the MachineConfig match the
+// ones in the root directory. Unlike the MCD, it doesn't check the booted OS image, and it
+// keeps going after the first mismatch.
+func ValidateOnDiskState(config *mcfgv1.MachineConfig, root string) (*ValidationReport, error) {
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(config.Spec.Config.Raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing Ignition config of %s", config.GetName())
+	}
+	report := &ValidationReport{
+		Config: config.GetName(),
+		Root:   root,
+		Valid:  true,
+		Files:  []PathReport{},
+		Units:  []PathReport{},
+	}
+	for _, f := range ignConfig.Storage.Files {
+		pr := newPathReport(f.Path, checkV3Files(root, []ign3types.File{f}), root)
+		report.Valid = report.Valid && pr.Valid
+		report.Files = append(report.Files, pr)
+	}
+	for _, u := range ignConfig.Systemd.Units {
+		pr := newPathReport(filepath.Join(pathSystemd, u.Name), checkV3Units(root, []ign3types.Unit{u}), root)
+		report.Valid = report.Valid && pr.Valid
+		report.Units = append(report.Units, pr)
+	}
+	return report, nil
+}
+
+func newPathReport(path string, err error, root string) PathReport {
+	if err == nil {
+		return PathReport{Path: path, Valid: true}
+	}
+	msg := err.Error()
+	if root != "/" {
+		// report the paths as they are in the config
+		msg = strings.ReplaceAll(msg, filepath.Clean(root), "")
+	}
+	return PathReport{Path: path, Error: msg}
+}
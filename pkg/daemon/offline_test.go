@@ -0,0 +1,139 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vincent-petithory/dataurl"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestLoadMachineConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "load-mc")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ignCfg := ctrlcommon.NewIgnConfig()
+	ignCfg.Storage.Files = []ign3types.File{newRemoteFile("/etc/foo", dataurl.EncodeBytes([]byte("foo")), "")}
+	mc := helpers.CreateMachineConfigFromIgnition(ignCfg)
+	mc.Name = "rendered-worker-1"
+	mc.APIVersion = "machineconfiguration.openshift.io/v1"
+	mc.Kind = "MachineConfig"
+
+	ignPath := filepath.Join(dir, "config.ign")
+	data, err := json.Marshal(ignCfg)
+	require.Nil(t, err)
+	require.Nil(t, ioutil.WriteFile(ignPath, data, 0644))
+	loaded, err := LoadMachineConfig(ignPath)
+	require.Nil(t, err)
+	assert.Equal(t, "config", loaded.Name)
+	assert.Equal(t, data, loaded.Spec.Config.Raw)
+
+	mcPath := filepath.Join(dir, "mc.yaml")
+	data, err = yaml.Marshal(mc)
+	require.Nil(t, err)
+	require.Nil(t, ioutil.WriteFile(mcPath, data, 0644))
+	loaded, err = LoadMachineConfig(mcPath)
+	require.Nil(t, err)
+	assert.Equal(t, "rendered-worker-1", loaded.Name)
+
+	invalidPath := filepath.Join(dir, "invalid")
+	require.Nil(t, ioutil.WriteFile(invalidPath, []byte("foo: bar"), 0644))
+	_, err = LoadMachineConfig(invalidPath)
+	assert.NotNil(t, err)
+}
+
+func TestDiffMachineConfigs(t *testing.T) {
+	oldIgnCfg := ctrlcommon.NewIgnConfig()
+	oldIgnCfg.Storage.Files = []ign3types.File{newRemoteFile("/etc/kubernetes/kubelet-ca.crt", dataurl.EncodeBytes([]byte("old")), "")}
+	oldConfig := helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
+
+	// a rebootless change
+	newIgnCfg := ctrlcommon.NewIgnConfig()
+	newIgnCfg.Storage.Files = []ign3types.File{newRemoteFile("/etc/kubernetes/kubelet-ca.crt", dataurl.EncodeBytes([]byte("new")), "")}
+	newConfig := helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	report := DiffMachineConfigs(oldConfig, newConfig)
+	assert.True(t, report.Reconcilable)
+	assert.Empty(t, report.Error)
+	assert.True(t, report.Changes.Files)
+	assert.False(t, report.Changes.Units)
+	assert.Equal(t, []string{postConfigChangeActionNone}, report.PostConfigChangeActions)
+	assert.False(t, report.Reboot)
+
+	// a change which reboots
+	newConfig.Spec.KernelArguments = []string{"nosmt"}
+	report = DiffMachineConfigs(oldConfig, newConfig)
+	assert.True(t, report.Reconcilable)
+	assert.True(t, report.Changes.KernelArgs)
+	assert.True(t, report.Reboot)
+
+	// FIPS can't be changed, whatever the system running the check
+	newConfig.Spec.FIPS = true
+	report = DiffMachineConfigs(oldConfig, newConfig)
+	assert.False(t, report.Reconcilable)
+	assert.Contains(t, report.Error, "FIPS")
+	assert.True(t, report.Changes.FIPS)
+	assert.Empty(t, report.PostConfigChangeActions)
+
+	// an irreconcilable change
+	newIgnCfg.Storage.Disks = []ign3types.Disk{{Device: "/dev/sdb"}}
+	newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	report = DiffMachineConfigs(oldConfig, newConfig)
+	assert.False(t, report.Reconcilable)
+	assert.Contains(t, report.Error, "disks")
+}
+
+func TestValidateOnDiskState(t *testing.T) {
+	root, err := ioutil.TempDir("", "validate-root")
+	require.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	mode := 0644
+	newFile := func(path, contents string) ign3types.File {
+		f := newRemoteFile(path, dataurl.EncodeBytes([]byte(contents)), "")
+		f.Mode = &mode
+		return f
+	}
+	ignCfg := ctrlcommon.NewIgnConfig()
+	ignCfg.Storage.Files = []ign3types.File{
+		newFile("/etc/valid", "valid\n"),
+		newFile("/etc/edited", "original\n"),
+	}
+	ignCfg.Systemd.Units = []ign3types.Unit{
+		{Name: "valid.service", Contents: helpers.StrToPtr("[Unit]\n")},
+		{Name: "missing.service", Contents: helpers.StrToPtr("[Unit]\n")},
+	}
+	require.Nil(t, os.MkdirAll(filepath.Join(root, "etc/systemd/system"), 0755))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(root, "etc/valid"), []byte("valid\n"), 0644))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(root, "etc/edited"), []byte("edited\n"), 0644))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(root, "etc/systemd/system/valid.service"), []byte("[Unit]\n"), 0644))
+	mc := helpers.CreateMachineConfigFromIgnition(ignCfg)
+
+	report, err := ValidateOnDiskState(mc, root)
+	require.Nil(t, err)
+	assert.False(t, report.Valid)
+	require.Len(t, report.Files, 2)
+	assert.Equal(t, PathReport{Path: "/etc/valid", Valid: true}, report.Files[0])
+	assert.Equal(t, "/etc/edited", report.Files[1].Path)
+	assert.False(t, report.Files[1].Valid)
+	assert.NotContains(t, report.Files[1].Error, root)
+	require.Len(t, report.Units, 2)
+	assert.True(t, report.Units[0].Valid)
+	assert.Equal(t, "/etc/systemd/system/missing.service", report.Units[1].Path)
+	assert.False(t, report.Units[1].Valid)
+
+	require.Nil(t, ioutil.WriteFile(filepath.Join(root, "etc/edited"), []byte("original\n"), 0644))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(root, "etc/systemd/system/missing.service"), []byte("[Unit]\n"), 0644))
+	report, err = ValidateOnDiskState(mc, root)
+	require.Nil(t, err)
+	assert.True(t, report.Valid)
+}
@@ -0,0 +1,196 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// stagedOSImageContentBaseDir holds the content of the staged OS images. Unlike the
+	// content extracted by the pivot command, it's kept on disk rather than in memory, as
+	// it can stay there while the node waits for its update.
+	stagedOSImageContentBaseDir = "/var/lib/machine-config-daemon/os-content/"
+
+	// stagedOSImagePrefix prefixes the directories holding the extracted content of an OS image,
+	// which are named after the image so that they are found again after the MCD restarts.
+	stagedOSImagePrefix = "staged-"
+)
+
+// osImageStager pulls and extracts OS images in the background, so that the content is
+// ready before the node is cordoned. The extracted content is kept under baseDir, and
+// reused until another image is staged or the update to the image completes.
+type osImageStager struct {
+	baseDir string
+	// extract extracts the image in a new directory under baseDir and returns its path
+	extract func(imgURL string) (string, error)
+
+	mu     sync.Mutex
+	stages map[string]*osImageStage
+}
+
+// osImageStage is the extraction of an image, dir and err are set once done is closed.
+type osImageStage struct {
+	done chan struct{}
+	dir  string
+	err  error
+}
+
+func newOSImageStager(baseDir string, extract func(imgURL string) (string, error)) *osImageStager {
+	return &osImageStager{
+		baseDir: baseDir,
+		extract: extract,
+		stages:  map[string]*osImageStage{},
+	}
+}
+
+// stagedDir returns the directory holding the extracted content of the image.
+func (s *osImageStager) stagedDir(imgURL string) string {
+	sum := sha256.Sum256([]byte(imgURL))
+	return filepath.Join(s.baseDir, stagedOSImagePrefix+hex.EncodeToString(sum[:8]))
+}
+
+// stage starts pulling and extracting the image unless it is already staged or being staged,
+// retrying a previously failed attempt. It doesn't wait for the content, see wait.
+func (s *osImageStager) stage(imgURL string) *osImageStage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st, ok := s.stages[imgURL]; ok {
+		select {
+		case <-st.done:
+			if st.err == nil {
+				return st
+			}
+		default:
+			return st
+		}
+	}
+	s.prune(imgURL)
+
+	st := &osImageStage{done: make(chan struct{})}
+	s.stages[imgURL] = st
+	dir := s.stagedDir(imgURL)
+	if _, err := os.Stat(dir); err == nil {
+		// staged before the MCD restarted
+		glog.Infof("Reusing extracted OS image %s from %s", imgURL, dir)
+		st.dir = dir
+		close(st.done)
+		return st
+	}
+	go func() {
+		defer close(st.done)
+		glog.Infof("Staging OS image %s", imgURL)
+		tmpDir, err := s.extract(imgURL)
+		if err != nil {
+			if tmpDir != "" {
+				os.RemoveAll(tmpDir)
+			}
+			st.err = errors.Wrapf(err, "extracting OS image %s", imgURL)
+			glog.Warningf("Failed staging OS image: %v", st.err)
+			return
+		}
+		// only complete content is renamed to the staged directory
+		if err := os.Rename(tmpDir, dir); err != nil {
+			os.RemoveAll(tmpDir)
+			st.err = errors.Wrapf(err, "staging OS image %s", imgURL)
+			glog.Warningf("Failed staging OS image: %v", st.err)
+			return
+		}
+		glog.Infof("Staged OS image %s in %s", imgURL, dir)
+		st.dir = dir
+	}()
+	return st
+}
+
+// wait stages the image if needed and returns the directory holding its content
+// once it is extracted.
+func (s *osImageStager) wait(imgURL string) (string, error) {
+	st := s.stage(imgURL)
+	<-st.done
+	return st.dir, st.err
+}
+
+// remove deletes the content of the image once the update to it completed, unless it is
+// being extracted again.
+func (s *osImageStager) remove(imgURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st, ok := s.stages[imgURL]; ok {
+		select {
+		case <-st.done:
+			delete(s.stages, imgURL)
+		default:
+			return
+		}
+	}
+	dir := s.stagedDir(imgURL)
+	if _, err := os.Stat(dir); err != nil {
+		return
+	}
+	glog.Infof("Removing staged OS image content %s", dir)
+	if err := os.RemoveAll(dir); err != nil {
+		glog.Warningf("Failed removing %s: %v", dir, err)
+	}
+}
+
+// prune removes the content of the images staged for other URLs than imgURL, except
+// the ones being extracted. It must be called with mu held.
+func (s *osImageStager) prune(imgURL string) {
+	keep := map[string]bool{s.stagedDir(imgURL): true}
+	for url, st := range s.stages {
+		select {
+		case <-st.done:
+			if url != imgURL {
+				delete(s.stages, url)
+			}
+		default:
+			keep[s.stagedDir(url)] = true
+		}
+	}
+	entries, err := ioutil.ReadDir(s.baseDir)
+	if err != nil {
+		// nothing was staged yet
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(s.baseDir, entry.Name())
+		if !strings.HasPrefix(entry.Name(), stagedOSImagePrefix) || keep[path] {
+			continue
+		}
+		glog.Infof("Removing previously staged OS image content %s", path)
+		if err := os.RemoveAll(path); err != nil {
+			glog.Warningf("Failed removing %s: %v", path, err)
+		}
+	}
+}
+
+// stagePoolTargetOSImage starts staging the OS image of the config targeted by the pool of the node,
+// before the node controller selects the node for the update.
+func (dn *Daemon) stagePoolTargetOSImage() {
+	if dn.osImageStager == nil || !dn.os.IsCoreOSVariant() {
+		return
+	}
+	settings := dn.getPoolSettings()
+	if settings == nil || settings.TargetConfig == "" {
+		return
+	}
+	targetConfigName := settings.TargetConfig
+	targetConfig, err := dn.mcLister.Get(targetConfigName)
+	if err != nil {
+		glog.V(2).Infof("Not staging the OS image of %s: %v", targetConfigName, err)
+		return
+	}
+	if compareOSImageURL(dn.bootedOSImageURL, targetConfig.Spec.OSImageURL) {
+		return
+	}
+	dn.osImageStager.stage(targetConfig.Spec.OSImageURL)
+}
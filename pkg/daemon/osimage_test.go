@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOSImageStager(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "os-content")
+	require.Nil(t, err)
+	defer os.RemoveAll(baseDir)
+
+	var mu sync.Mutex
+	extracted := map[string]int{}
+	release := make(chan struct{})
+	extract := func(imgURL string) (string, error) {
+		<-release
+		mu.Lock()
+		extracted[imgURL]++
+		mu.Unlock()
+		if imgURL == "registry.example.com/os@sha256:broken" {
+			return "", fmt.Errorf("manifest unknown")
+		}
+		dir, err := ioutil.TempDir(baseDir, "os-content-")
+		if err != nil {
+			return "", err
+		}
+		return dir, ioutil.WriteFile(filepath.Join(dir, "content"), []byte(imgURL), 0644)
+	}
+	s := newOSImageStager(baseDir, extract)
+
+	// staging doesn't block, and waiting reuses the extraction in progress
+	v1 := "registry.example.com/os@sha256:v1"
+	s.stage(v1)
+	s.stage(v1)
+	close(release)
+	dir, err := s.wait(v1)
+	require.Nil(t, err)
+	assert.Equal(t, s.stagedDir(v1), dir)
+	content, err := ioutil.ReadFile(filepath.Join(dir, "content"))
+	require.Nil(t, err)
+	assert.Equal(t, v1, string(content))
+
+	// retries reuse the content
+	dir, err = s.wait(v1)
+	require.Nil(t, err)
+	assert.Equal(t, s.stagedDir(v1), dir)
+	assert.Equal(t, 1, extracted[v1])
+
+	// and so does a restarted MCD
+	s = newOSImageStager(baseDir, extract)
+	dir, err = s.wait(v1)
+	require.Nil(t, err)
+	assert.Equal(t, s.stagedDir(v1), dir)
+	assert.Equal(t, 1, extracted[v1])
+
+	// failures are retried
+	broken := "registry.example.com/os@sha256:broken"
+	_, err = s.wait(broken)
+	assert.NotNil(t, err)
+	_, err = s.wait(broken)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, extracted[broken])
+
+	// staging another image removes the previous content
+	v2 := "registry.example.com/os@sha256:v2"
+	dir, err = s.wait(v2)
+	require.Nil(t, err)
+	_, err = os.Stat(s.stagedDir(v1))
+	assert.True(t, os.IsNotExist(err))
+	entries, err := ioutil.ReadDir(baseDir)
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, dir, filepath.Join(baseDir, entries[0].Name()))
+
+	// the content is removed once the update to the image completed
+	s.remove(v2)
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+	_, err = s.wait(v2)
+	require.Nil(t, err)
+	assert.Equal(t, 2, extracted[v2])
+}
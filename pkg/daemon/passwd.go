@@ -0,0 +1,338 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// passwdStatePath is where we track the users and groups managed by the MCD
+	passwdStatePath = "/etc/machine-config-daemon/passwd.json"
+	// passwdStateVersion is the version of the format of the passwd state file
+	passwdStateVersion = 1
+)
+
+// validPasswdName matches the user and group names useradd and groupadd accept by default.
+var validPasswdName = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,30}\$?$`)
+
+// passwdState tracks the users and groups declared in the MachineConfigs applied
+// by the MCD, and whether they were created from a MachineConfig. Only those are
+// deleted when they are removed from the MachineConfig, the ones which were already
+// on the system are left in place.
+type passwdState struct {
+	Version int             `json:"version"`
+	Users   map[string]bool `json:"users,omitempty"`
+	Groups  map[string]bool `json:"groups,omitempty"`
+}
+
+func (dn *Daemon) loadPasswdState() (*passwdState, error) {
+	state := &passwdState{
+		Version: passwdStateVersion,
+		Users:   map[string]bool{},
+		Groups:  map[string]bool{},
+	}
+	b, err := ioutil.ReadFile(dn.hostPath(dn.passwdStatePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, errors.Wrapf(err, "reading passwd state %s", dn.passwdStatePath)
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, errors.Wrapf(err, "parsing passwd state %s", dn.passwdStatePath)
+	}
+	if state.Version != passwdStateVersion {
+		return nil, fmt.Errorf("unsupported passwd state version %d in %s", state.Version, dn.passwdStatePath)
+	}
+	if state.Users == nil {
+		state.Users = map[string]bool{}
+	}
+	if state.Groups == nil {
+		state.Groups = map[string]bool{}
+	}
+	return state, nil
+}
+
+func (dn *Daemon) writePasswdState(state *passwdState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return dn.writeFileAtomicallyWithDefaults(dn.passwdStatePath, b)
+}
+
+// verifyPasswdFields checks that the non-core users and groups of the passwd section
+// can be managed by the MCD.
+func verifyPasswdFields(passwd ign3types.Passwd) error {
+	for _, g := range passwd.Groups {
+		if !validPasswdName.MatchString(g.Name) || g.Name == "root" {
+			return fmt.Errorf("ignition passwd group section contains unsupported changes: invalid group name %q", g.Name)
+		}
+		if g.ShouldExist != nil && !*g.ShouldExist {
+			return fmt.Errorf("ignition passwd group section contains unsupported changes: shouldExist is not supported, remove group %q instead", g.Name)
+		}
+	}
+	for _, u := range passwd.Users {
+		if u.Name == coreUserName {
+			continue
+		}
+		if !validPasswdName.MatchString(u.Name) || u.Name == "root" {
+			return fmt.Errorf("ignition passwd user section contains unsupported changes: invalid user name %q", u.Name)
+		}
+		if u.ShouldExist != nil && !*u.ShouldExist {
+			return fmt.Errorf("ignition passwd user section contains unsupported changes: shouldExist is not supported, remove user %q instead", u.Name)
+		}
+		if u.HomeDir != nil && !filepath.IsAbs(*u.HomeDir) {
+			return fmt.Errorf("ignition passwd user section contains unsupported changes: home directory of user %q is not absolute", u.Name)
+		}
+	}
+	return nil
+}
+
+// updatePasswd creates and updates the users and groups of the new passwd section, other
+// than core, and deletes the ones the MCD created which were removed from it.
+func (dn *Daemon) updatePasswd(oldPasswd, newPasswd ign3types.Passwd) (retErr error) {
+	if dn.mock {
+		return nil
+	}
+	state, err := dn.loadPasswdState()
+	if err != nil {
+		return err
+	}
+	// write the state even if we fail half way so that the users and groups
+	// created so far are still deleted when they're removed from the config
+	defer func() {
+		if err := dn.writePasswdState(state); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	// the users and groups already declared in the old config which aren't tracked
+	// yet were created by Ignition when the node was provisioned
+	oldUsers := map[string]bool{}
+	for _, u := range oldPasswd.Users {
+		oldUsers[u.Name] = true
+	}
+	oldGroups := map[string]bool{}
+	for _, g := range oldPasswd.Groups {
+		oldGroups[g.Name] = true
+	}
+
+	newGroups := map[string]bool{}
+	for _, g := range newPasswd.Groups {
+		newGroups[g.Name] = true
+		created, tracked := state.Groups[g.Name]
+		if !tracked {
+			_, err := user.LookupGroup(g.Name)
+			exists := err == nil
+			created = !exists || oldGroups[g.Name]
+			if !exists {
+				glog.Infof("Creating group %q", g.Name)
+				if _, err := dn.runner.Run("groupadd", groupAddArgs(g)...); err != nil {
+					return err
+				}
+				state.Groups[g.Name] = created
+				continue
+			}
+			state.Groups[g.Name] = created
+		}
+		if !created {
+			glog.Infof("Not updating group %q, it wasn't created from a MachineConfig", g.Name)
+			continue
+		}
+		if args := groupModArgs(g); len(args) > 1 {
+			if _, err := dn.runner.Run("groupmod", args...); err != nil {
+				return err
+			}
+		}
+	}
+
+	newUsers := map[string]bool{}
+	for _, u := range newPasswd.Users {
+		if u.Name == coreUserName {
+			continue
+		}
+		newUsers[u.Name] = true
+		created, tracked := state.Users[u.Name]
+		if !tracked {
+			_, err := user.Lookup(u.Name)
+			exists := err == nil
+			created = !exists || oldUsers[u.Name]
+			if !exists {
+				glog.Infof("Creating user %q", u.Name)
+				if _, err := dn.runner.Run("useradd", userAddArgs(u)...); err != nil {
+					return err
+				}
+			}
+			state.Users[u.Name] = created
+		}
+		if !created {
+			glog.Infof("Not updating user %q, it wasn't created from a MachineConfig", u.Name)
+			continue
+		}
+		glog.Infof("Updating user %q", u.Name)
+		if _, err := dn.runner.Run("usermod", userModArgs(u)...); err != nil {
+			return err
+		}
+		if err := dn.writeUserSSHKeys(u); err != nil {
+			return err
+		}
+	}
+
+	for _, u := range oldPasswd.Users {
+		if u.Name == coreUserName || newUsers[u.Name] {
+			continue
+		}
+		if state.Users[u.Name] {
+			glog.Infof("Deleting user %q", u.Name)
+			if _, err := dn.runner.Run("userdel", "--remove", u.Name); err != nil {
+				return err
+			}
+		} else {
+			glog.Infof("Not deleting user %q, it wasn't created from a MachineConfig", u.Name)
+		}
+		delete(state.Users, u.Name)
+	}
+	for _, g := range oldPasswd.Groups {
+		if newGroups[g.Name] {
+			continue
+		}
+		if state.Groups[g.Name] {
+			glog.Infof("Deleting group %q", g.Name)
+			if _, err := dn.runner.Run("groupdel", g.Name); err != nil {
+				return err
+			}
+		} else {
+			glog.Infof("Not deleting group %q, it wasn't created from a MachineConfig", g.Name)
+		}
+		delete(state.Groups, g.Name)
+	}
+	return nil
+}
+
+func groupAddArgs(g ign3types.PasswdGroup) []string {
+	args := []string{}
+	if g.Gid != nil {
+		args = append(args, "--gid", strconv.Itoa(*g.Gid))
+	}
+	if g.PasswordHash != nil {
+		args = append(args, "--password", *g.PasswordHash)
+	}
+	if g.System != nil && *g.System {
+		args = append(args, "--system")
+	}
+	return append(args, g.Name)
+}
+
+func groupModArgs(g ign3types.PasswdGroup) []string {
+	args := []string{}
+	if g.Gid != nil {
+		args = append(args, "--gid", strconv.Itoa(*g.Gid))
+	}
+	if g.PasswordHash != nil {
+		args = append(args, "--password", *g.PasswordHash)
+	}
+	return append(args, g.Name)
+}
+
+func userGroups(u ign3types.PasswdUser) string {
+	groups := make([]string, 0, len(u.Groups))
+	for _, g := range u.Groups {
+		groups = append(groups, string(g))
+	}
+	return strings.Join(groups, ",")
+}
+
+func userAddArgs(u ign3types.PasswdUser) []string {
+	args := []string{}
+	if u.UID != nil {
+		args = append(args, "--uid", strconv.Itoa(*u.UID))
+	}
+	if u.HomeDir != nil {
+		args = append(args, "--home-dir", *u.HomeDir)
+	}
+	if u.NoCreateHome != nil && *u.NoCreateHome {
+		args = append(args, "--no-create-home")
+	} else {
+		args = append(args, "--create-home")
+	}
+	if u.NoUserGroup != nil && *u.NoUserGroup {
+		args = append(args, "--no-user-group")
+	}
+	if u.NoLogInit != nil && *u.NoLogInit {
+		args = append(args, "--no-log-init")
+	}
+	if u.System != nil && *u.System {
+		args = append(args, "--system")
+	}
+	return append(args, u.Name)
+}
+
+// userModArgs returns the arguments of the usermod command setting the fields of the user
+// which can change after it was created.
+func userModArgs(u ign3types.PasswdUser) []string {
+	args := []string{"--groups", userGroups(u)}
+	if u.UID != nil {
+		args = append(args, "--uid", strconv.Itoa(*u.UID))
+	}
+	if u.HomeDir != nil {
+		args = append(args, "--home", *u.HomeDir)
+	}
+	if u.Gecos != nil {
+		args = append(args, "--comment", *u.Gecos)
+	}
+	if u.PrimaryGroup != nil {
+		args = append(args, "--gid", *u.PrimaryGroup)
+	}
+	if u.Shell != nil {
+		args = append(args, "--shell", *u.Shell)
+	}
+	if u.PasswordHash != nil {
+		args = append(args, "--password", *u.PasswordHash)
+	} else {
+		// lock the password of the users which don't declare one
+		args = append(args, "--password", "!")
+	}
+	return append(args, u.Name)
+}
+
+// writeUserSSHKeys writes the SSH keys of the user to ~/.ssh/authorized_keys in its home directory.
+func (dn *Daemon) writeUserSSHKeys(u ign3types.PasswdUser) error {
+	osUser, err := user.Lookup(u.Name)
+	if err != nil {
+		return errors.Wrapf(err, "looking up user %q", u.Name)
+	}
+	uid, _ := strconv.Atoi(osUser.Uid)
+	gid, _ := strconv.Atoi(osUser.Gid)
+	sshDir := filepath.Join(osUser.HomeDir, ".ssh")
+	authKeyPath := filepath.Join(sshDir, "authorized_keys")
+
+	if len(u.SSHAuthorizedKeys) == 0 {
+		if err := os.Remove(dn.hostPath(authKeyPath)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var keys string
+	for _, k := range u.SSHAuthorizedKeys {
+		keys = keys + string(k) + "\n"
+	}
+	glog.Infof("Writing SSHKeys of user %q at %q", u.Name, authKeyPath)
+	if err := os.MkdirAll(dn.hostPath(sshDir), 0700); err != nil {
+		return err
+	}
+	if err := os.Chown(dn.hostPath(sshDir), uid, gid); err != nil {
+		return err
+	}
+	return dn.writeFileAtomically(authKeyPath, []byte(keys), 0700, 0600, uid, gid)
+}
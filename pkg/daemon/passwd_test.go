@@ -0,0 +1,93 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestPasswdArgs(t *testing.T) {
+	uid := 1500
+	u := ign3types.PasswdUser{
+		Name:         "admin",
+		UID:          &uid,
+		Gecos:        helpers.StrToPtr("Admin"),
+		Groups:       []ign3types.Group{"wheel", "ops"},
+		HomeDir:      helpers.StrToPtr("/var/home/admin"),
+		PrimaryGroup: helpers.StrToPtr("ops"),
+		Shell:        helpers.StrToPtr("/bin/bash"),
+		NoUserGroup:  helpers.BoolToPtr(true),
+	}
+	assert.Equal(t, []string{"--uid", "1500", "--home-dir", "/var/home/admin", "--create-home", "--no-user-group", "admin"}, userAddArgs(u))
+	assert.Equal(t, []string{"--groups", "wheel,ops", "--uid", "1500", "--home", "/var/home/admin", "--comment", "Admin", "--gid", "ops", "--shell", "/bin/bash", "--password", "!", "admin"}, userModArgs(u))
+
+	// removing all the supplementary groups and setting a password
+	u = ign3types.PasswdUser{Name: "svc", PasswordHash: helpers.StrToPtr("$6$hash"), NoCreateHome: helpers.BoolToPtr(true), System: helpers.BoolToPtr(true)}
+	assert.Equal(t, []string{"--no-create-home", "--system", "svc"}, userAddArgs(u))
+	assert.Equal(t, []string{"--groups", "", "--password", "$6$hash", "svc"}, userModArgs(u))
+
+	gid := 1600
+	g := ign3types.PasswdGroup{Name: "ops", Gid: &gid, System: helpers.BoolToPtr(true)}
+	assert.Equal(t, []string{"--gid", "1600", "--system", "ops"}, groupAddArgs(g))
+	assert.Equal(t, []string{"--gid", "1600", "ops"}, groupModArgs(g))
+	assert.Equal(t, []string{"ops"}, groupModArgs(ign3types.PasswdGroup{Name: "ops"}))
+}
+
+func TestPasswdState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passwd-state")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	dn := &Daemon{passwdStatePath: filepath.Join(dir, "passwd.json")}
+
+	// no state file yet
+	state, err := dn.loadPasswdState()
+	require.Nil(t, err)
+	assert.Empty(t, state.Users)
+	assert.Empty(t, state.Groups)
+
+	state.Users["admin"] = true
+	state.Users["existing"] = false
+	state.Groups["ops"] = true
+	require.Nil(t, dn.writePasswdState(state))
+	loaded, err := dn.loadPasswdState()
+	require.Nil(t, err)
+	assert.Equal(t, state, loaded)
+
+	// an unknown version of the file is an error
+	require.Nil(t, ioutil.WriteFile(dn.passwdStatePath, []byte(`{"version": 2}`), 0644))
+	_, err = dn.loadPasswdState()
+	assert.NotNil(t, err)
+}
+
+func TestUpdatePasswdPartialFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passwd-state")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	runner := &fakeRunner{}
+	runner.respond("useradd --create-home admin", "", "useradd: UID 1500 is not unique", 4)
+	dn := &Daemon{passwdStatePath: filepath.Join(dir, "passwd.json"), runner: runner}
+
+	newPasswd := ign3types.Passwd{
+		Groups: []ign3types.PasswdGroup{{Name: "mco-test-ops"}},
+		Users: []ign3types.PasswdUser{
+			// root is already on the system, so it isn't updated
+			{Name: "root"},
+			{Name: "admin"},
+		},
+	}
+	require.NotNil(t, dn.updatePasswd(ign3types.Passwd{}, newPasswd))
+	assert.Equal(t, []string{"groupadd mco-test-ops", "useradd --create-home admin"}, runner.ran)
+
+	// the group created before the failure is tracked
+	state, err := dn.loadPasswdState()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]bool{"mco-test-ops": true}, state.Groups)
+	assert.Equal(t, map[string]bool{"root": false}, state.Users)
+}
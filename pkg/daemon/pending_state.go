@@ -0,0 +1,247 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+const (
+	// pendingStateVersion is the version of the format of the pending state file
+	pendingStateVersion = 1
+
+	// legacyPendingConfigPath is where the pending config was stored when logger
+	// didn't support --journald. We only read it to migrate to the pending state file.
+	legacyPendingConfigPath = "/etc/machine-config-daemon/state.json"
+)
+
+// updatePhase is the phase of the update recorded in the pending state file.
+type updatePhase string
+
+const (
+	// updatePhaseRebooting means the config has been written to disk
+	// and the node is rebooting into it
+	updatePhaseRebooting updatePhase = "Rebooting"
+	// updatePhaseRolledBack means the update failed before rebooting
+	updatePhaseRolledBack updatePhase = "RolledBack"
+	// updatePhaseDone means the node came back up into the config
+	updatePhaseDone updatePhase = "Done"
+)
+
+// pendingState is the state of the update we persist across attempting
+// to apply a config+reboot.
+type pendingState struct {
+	// Version is the version of the format of the file
+	Version int `json:"version"`
+	// Config is the name of the MachineConfig the update is to
+	Config string `json:"config,omitempty"`
+	// BootID is the boot the update was started from; if it is unchanged
+	// when the daemon starts, we failed to reboot.
+	BootID string `json:"bootID,omitempty"`
+	// Phase is the phase of the update
+	Phase updatePhase `json:"phase"`
+	// StartedAt is when the config was written to disk
+	StartedAt time.Time `json:"startedAt"`
+	// UpdatedAt is when the phase was last changed
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// loadPendingState reads the pending state file, returning nil if it doesn't exist.
+func (dn *Daemon) loadPendingState() (*pendingState, error) {
+	b, err := ioutil.ReadFile(dn.hostPath(dn.pendingStatePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "reading pending state %s", dn.pendingStatePath)
+	}
+	state := &pendingState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, errors.Wrapf(err, "parsing pending state %s", dn.pendingStatePath)
+	}
+	if state.Version != pendingStateVersion {
+		return nil, fmt.Errorf("unsupported pending state version %d in %s", state.Version, dn.pendingStatePath)
+	}
+	return state, nil
+}
+
+// writePendingState atomically writes the pending state file.
+func (dn *Daemon) writePendingState(state *pendingState) error {
+	state.Version = pendingStateVersion
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return dn.writeFileAtomicallyWithDefaults(dn.pendingStatePath, b)
+}
+
+// getPendingState loads the state we persist across attempting to apply
+// a config+reboot. If no update is pending, nil will be returned.
+// The bootID is stored in the pending state; if it is unchanged, we assume
+// that we failed to reboot; that for now should be a fatal error, in order to avoid
+// reboot loops.
+// If there is no pending state file yet, the state is migrated from the journal.
+func (dn *Daemon) getPendingState() (*pendingState, error) {
+	state, err := dn.loadPendingState()
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		entry, err := dn.getPendingStateFromJournal()
+		if err != nil {
+			return nil, err
+		}
+		legacy, err := dn.getLegacyPendingConfig()
+		if err != nil {
+			return nil, err
+		}
+		if state, err = dn.migratePendingState(entry, legacy); err != nil {
+			return nil, err
+		}
+	}
+	if state.Phase != updatePhaseRebooting {
+		return nil, nil
+	}
+	return state, nil
+}
+
+// storePendingState records the phase of the update to the pending config.
+func (dn *Daemon) storePendingState(pending *mcfgv1.MachineConfig, phase updatePhase) error {
+	now := time.Now().UTC()
+	state := &pendingState{
+		Config:    pending.GetName(),
+		BootID:    dn.bootID,
+		Phase:     phase,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	if phase != updatePhaseRebooting {
+		// keep track of when the update started
+		previous, err := dn.loadPendingState()
+		if err != nil {
+			glog.Warningf("Overwriting pending state: %v", err)
+		} else if previous != nil && previous.Config == state.Config {
+			state.StartedAt = previous.StartedAt
+			state.BootID = previous.BootID
+		}
+	}
+	return dn.writePendingState(state)
+}
+
+// migratePendingState writes the pending state file from the state previously
+// stored in the journal, or in the legacy state file, and removes the latter.
+func (dn *Daemon) migratePendingState(entry *journalMsg, legacy *legacyPendingConfig) (*pendingState, error) {
+	now := time.Now().UTC()
+	state := &pendingState{
+		Phase:     updatePhaseDone,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	switch {
+	case entry != nil:
+		state.Config = entry.Message
+		state.BootID = entry.BootID
+		state.Phase = updatePhaseRebooting
+	case legacy != nil:
+		state.Config = legacy.PendingConfig
+		state.BootID = legacy.BootID
+		state.Phase = updatePhaseRebooting
+	}
+	glog.Infof("Migrating pending state to %s (config: %q, phase: %s)", dn.pendingStatePath, state.Config, state.Phase)
+	if err := dn.writePendingState(state); err != nil {
+		return nil, errors.Wrap(err, "migrating pending state")
+	}
+	if err := os.Remove(dn.hostPath(legacyPendingConfigPath)); err != nil && !os.IsNotExist(err) {
+		glog.Warningf("Failed to remove legacy pending state %s: %v", legacyPendingConfigPath, err)
+	}
+	return state, nil
+}
+
+type legacyPendingConfig struct {
+	PendingConfig string `json:"pendingConfig,omitempty"`
+	BootID        string `json:"bootID,omitempty"`
+}
+
+// getLegacyPendingConfig reads the pending config written when logger didn't support --journald.
+func (dn *Daemon) getLegacyPendingConfig() (*legacyPendingConfig, error) {
+	s, err := ioutil.ReadFile(dn.hostPath(legacyPendingConfigPath))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "loading transient state")
+		}
+		return nil, nil
+	}
+	var p legacyPendingConfig
+	if err := json.Unmarshal(s, &p); err != nil {
+		return nil, errors.Wrapf(err, "parsing transient state")
+	}
+	return &p, nil
+}
+
+type journalMsg struct {
+	Message   string `json:"MESSAGE,omitempty"`
+	BootID    string `json:"BOOT_ID,omitempty"`
+	Pending   string `json:"PENDING,omitempty"`
+	OldLogger string `json:"OPENSHIFT_MACHINE_CONFIG_DAEMON_LEGACY_LOG_HACK,omitempty"` // unused today
+}
+
+func (dn *Daemon) processJournalOutput(journalOutput []byte) (*journalMsg, error) {
+	lines := strings.Split(strings.TrimSpace(string(journalOutput)), "\n")
+	last := lines[len(lines)-1]
+
+	entry := &journalMsg{}
+	if err := json.Unmarshal([]byte(last), entry); err != nil {
+		return nil, errors.Wrap(err, "getting pending state from journal")
+	}
+	if entry.Pending == "0" {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// getPendingStateFromJournal reads the pending state stored in the journal
+// by the previous versions of the daemon.
+func (dn *Daemon) getPendingStateFromJournal() (*journalMsg, error) {
+	if dn.mock {
+		return nil, nil
+	}
+	if !dn.loggerSupportsJournal {
+		return dn.getPendingStateLegacyLogger()
+	}
+	journalOutput, err := dn.runner.Run("journalctl", "-o", "json", "_UID=0", fmt.Sprintf("MESSAGE_ID=%s", pendingStateMessageID))
+	if err != nil {
+		return nil, err
+	}
+	if len(journalOutput) == 0 {
+		return nil, nil
+	}
+	return dn.processJournalOutput(journalOutput)
+}
+
+func (dn *Daemon) getPendingStateLegacyLogger() (*journalMsg, error) {
+	glog.Info("logger doesn't support --jounald, grepping the journal")
+
+	cmdLiteral := "journalctl -o cat _UID=0 | grep -v audit | grep OPENSHIFT_MACHINE_CONFIG_DAEMON_LEGACY_LOG_HACK"
+	journalOutput, err := dn.runner.Run("bash", "-c", cmdLiteral)
+	if err != nil {
+		// grep exit with 1 if it doesn't find anything
+		// from man: Normally, the exit status is 0 if selected lines are found and 1 otherwise. But the exit status is 2 if an error occurred
+		if commandExitCode(err) == 1 {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to grep on journal output")
+	}
+	// just an extra safety check?
+	if len(journalOutput) == 0 {
+		return nil, nil
+	}
+	return dn.processJournalOutput(journalOutput)
+}
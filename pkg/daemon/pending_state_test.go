@@ -0,0 +1,110 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func newPendingStateDaemon(t *testing.T) (*Daemon, func()) {
+	dir, err := ioutil.TempDir("", "pending-state")
+	require.Nil(t, err)
+	dn := &Daemon{
+		mock:             true,
+		bootID:           "boot-1",
+		pendingStatePath: filepath.Join(dir, "pending-state.json"),
+	}
+	return dn, func() { os.RemoveAll(dir) }
+}
+
+func TestPendingState(t *testing.T) {
+	dn, cleanup := newPendingStateDaemon(t)
+	defer cleanup()
+
+	// no state file and nothing in the journal: the state is migrated as Done
+	state, err := dn.getPendingState()
+	require.Nil(t, err)
+	assert.Nil(t, state)
+	onDisk, err := dn.loadPendingState()
+	require.Nil(t, err)
+	require.NotNil(t, onDisk)
+	assert.Equal(t, updatePhaseDone, onDisk.Phase)
+	assert.Equal(t, pendingStateVersion, onDisk.Version)
+
+	mc := helpers.NewMachineConfig("rendered-worker-1", nil, "", nil)
+	require.Nil(t, dn.storePendingState(mc, updatePhaseRebooting))
+	state, err = dn.getPendingState()
+	require.Nil(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, "rendered-worker-1", state.Config)
+	assert.Equal(t, "boot-1", state.BootID)
+	assert.Equal(t, updatePhaseRebooting, state.Phase)
+	assert.False(t, state.StartedAt.IsZero())
+	rebooting := state
+
+	// coming back up after the reboot keeps when the update started
+	dn.bootID = "boot-2"
+	require.Nil(t, dn.storePendingState(mc, updatePhaseDone))
+	state, err = dn.getPendingState()
+	require.Nil(t, err)
+	assert.Nil(t, state)
+	done, err := dn.loadPendingState()
+	require.Nil(t, err)
+	assert.Equal(t, updatePhaseDone, done.Phase)
+	assert.True(t, rebooting.StartedAt.Equal(done.StartedAt))
+	assert.Equal(t, "boot-1", done.BootID)
+	assert.False(t, done.UpdatedAt.Before(done.StartedAt))
+
+	// an unknown version of the file is an error
+	require.Nil(t, ioutil.WriteFile(dn.pendingStatePath, []byte(`{"version": 2, "phase": "Rebooting"}`), 0644))
+	_, err = dn.getPendingState()
+	assert.NotNil(t, err)
+}
+
+func TestMigratePendingState(t *testing.T) {
+	journalOutput := []byte(`{"MESSAGE": "rendered-worker-1", "BOOT_ID": "boot-0", "PENDING": "1"}
+{"MESSAGE": "rendered-worker-2", "BOOT_ID": "boot-1", "PENDING": "1"}
+`)
+
+	tests := []struct {
+		name    string
+		journal []byte
+		legacy  *legacyPendingConfig
+		config  string
+		bootID  string
+		phase   updatePhase
+	}{
+		{name: "journal", journal: journalOutput, config: "rendered-worker-2", bootID: "boot-1", phase: updatePhaseRebooting},
+		{name: "journal not pending", journal: []byte(`{"MESSAGE": "rendered-worker-2", "BOOT_ID": "boot-1", "PENDING": "0"}`), phase: updatePhaseDone},
+		{name: "legacy", legacy: &legacyPendingConfig{PendingConfig: "rendered-worker-3", BootID: "boot-2"}, config: "rendered-worker-3", bootID: "boot-2", phase: updatePhaseRebooting},
+		{name: "nothing", phase: updatePhaseDone},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dn, cleanup := newPendingStateDaemon(t)
+			defer cleanup()
+
+			var entry *journalMsg
+			if test.journal != nil {
+				var err error
+				entry, err = dn.processJournalOutput(test.journal)
+				require.Nil(t, err)
+			}
+			state, err := dn.migratePendingState(entry, test.legacy)
+			require.Nil(t, err)
+			onDisk, err := dn.loadPendingState()
+			require.Nil(t, err)
+			for _, s := range []*pendingState{state, onDisk} {
+				assert.Equal(t, test.config, s.Config)
+				assert.Equal(t, test.bootID, s.BootID)
+				assert.Equal(t, test.phase, s.Phase)
+			}
+		})
+	}
+}
@@ -4,14 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
 	"github.com/containers/image/v5/types"
 	"github.com/golang/glog"
 	"github.com/opencontainers/go-digest"
-	pivotutils "github.com/openshift/machine-config-operator/pkg/daemon/pivot/utils"
 	"github.com/pkg/errors"
 )
 
@@ -64,23 +62,26 @@ type NodeUpdaterClient interface {
 	GetBootedOSImageURL() (string, string, error)
 	Rebase(string, string) (bool, error)
 	GetBootedDeployment() (*RpmOstreeDeployment, error)
+	Rollback() error
 }
 
 // RpmOstreeClient provides all RpmOstree related methods in one structure.
 // This structure implements DeploymentClient
 //
 // TODO(runcom): make this private to pkg/daemon!!!
-type RpmOstreeClient struct{}
+type RpmOstreeClient struct {
+	runner HostRunner
+}
 
 // NewNodeUpdaterClient returns a new instance of the default DeploymentClient (RpmOstreeClient)
 func NewNodeUpdaterClient() NodeUpdaterClient {
-	return &RpmOstreeClient{}
+	return &RpmOstreeClient{runner: execRunner{}}
 }
 
 // GetBootedDeployment returns the current deployment found
 func (r *RpmOstreeClient) GetBootedDeployment() (*RpmOstreeDeployment, error) {
 	var rosState rpmOstreeState
-	output, err := runGetOut("rpm-ostree", "status", "--json")
+	output, err := r.runner.Run("rpm-ostree", "status", "--json")
 	if err != nil {
 		return nil, err
 	}
@@ -101,7 +102,7 @@ func (r *RpmOstreeClient) GetBootedDeployment() (*RpmOstreeDeployment, error) {
 
 // GetStatus returns multi-line human-readable text describing system status
 func (r *RpmOstreeClient) GetStatus() (string, error) {
-	output, err := runGetOut("rpm-ostree", "status")
+	output, err := r.runner.Run("rpm-ostree", "status")
 	if err != nil {
 		return "", err
 	}
@@ -129,7 +130,7 @@ func (r *RpmOstreeClient) GetBootedOSImageURL() (string, string, error) {
 	return osImageURL, bootedDeployment.Version, nil
 }
 
-func podmanInspect(imgURL string) (imgdata *imageInspection, err error) {
+func podmanInspect(runner HostRunner, imgURL string) (imgdata *imageInspection, err error) {
 	// Pull the container image if not already available
 	var authArgs []string
 	if _, err := os.Stat(kubeletAuthFile); err == nil {
@@ -138,7 +139,7 @@ func podmanInspect(imgURL string) (imgdata *imageInspection, err error) {
 	args := []string{"pull", "-q"}
 	args = append(args, authArgs...)
 	args = append(args, imgURL)
-	_, err = pivotutils.RunExt(numRetriesNetCommands, "podman", args...)
+	_, err = runWithRetries(runner, numRetriesNetCommands, "podman", args...)
 	if err != nil {
 		return
 	}
@@ -146,7 +147,7 @@ func podmanInspect(imgURL string) (imgdata *imageInspection, err error) {
 	inspectArgs := []string{"inspect", "--type=image"}
 	inspectArgs = append(inspectArgs, fmt.Sprintf("%s", imgURL))
 	var output []byte
-	output, err = runGetOut("podman", inspectArgs...)
+	output, err = runner.Run("podman", inspectArgs...)
 	if err != nil {
 		return
 	}
@@ -189,7 +190,7 @@ func (r *RpmOstreeClient) Rebase(imgURL, osImageContentDir string) (changed bool
 		if err != nil {
 			var podmanImgData *imageInspection
 			glog.Infof("Falling back to using podman inspect")
-			if podmanImgData, err = podmanInspect(imgURL); err != nil {
+			if podmanImgData, err = podmanInspect(r.runner, imgURL); err != nil {
 				return
 			}
 			ostreeCsum = podmanImgData.Labels["com.coreos.ostree-commit"]
@@ -200,7 +201,7 @@ func (r *RpmOstreeClient) Rebase(imgURL, osImageContentDir string) (changed bool
 		ostreeVersion = imageData.Labels["version"]
 	}
 	// We may have pulled in OSContainer image as fallback during podmanCopy() or podmanInspect()
-	defer exec.Command("podman", "rmi", imgURL).Run()
+	defer r.runner.Run("podman", "rmi", imgURL)
 
 	repo := fmt.Sprintf("%s/srv/repo", osImageContentDir)
 
@@ -215,7 +216,7 @@ func (r *RpmOstreeClient) Rebase(imgURL, osImageContentDir string) (changed bool
 	} else {
 		glog.Infof("No com.coreos.ostree-commit label found in metadata! Inspecting...")
 		var refText []byte
-		refText, err = runGetOut("ostree", "refs", "--repo", repo)
+		refText, err = r.runner.Run("ostree", "refs", "--repo", repo)
 		if err != nil {
 			return
 		}
@@ -223,7 +224,7 @@ func (r *RpmOstreeClient) Rebase(imgURL, osImageContentDir string) (changed bool
 		if len(refs) == 1 {
 			glog.Infof("Using ref %s", refs[0])
 			var ostreeCsumBytes []byte
-			ostreeCsumBytes, err = runGetOut("ostree", "rev-parse", "--repo", repo, refs[0])
+			ostreeCsumBytes, err = r.runner.Run("ostree", "rev-parse", "--repo", repo, refs[0])
 			if err != nil {
 				return
 			}
@@ -245,7 +246,7 @@ func (r *RpmOstreeClient) Rebase(imgURL, osImageContentDir string) (changed bool
 	args := []string{"rebase", "--experimental", fmt.Sprintf("%s:%s", repo, ostreeCsum),
 		"--custom-origin-url", customURL, "--custom-origin-description", "Managed by machine-config-operator"}
 
-	if _, err = runGetOut("rpm-ostree", args...); err != nil {
+	if _, err = r.runner.Run("rpm-ostree", args...); err != nil {
 		return
 	}
 
@@ -253,13 +254,8 @@ func (r *RpmOstreeClient) Rebase(imgURL, osImageContentDir string) (changed bool
 	return
 }
 
-// runGetOut executes a command, logging it, and return the stdout output.
-func runGetOut(command string, args ...string) ([]byte, error) {
-	glog.Infof("Running captured: %s %s", command, strings.Join(args, " "))
-	cmd := exec.Command(command, args...)
-	rawOut, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, errors.Wrapf(err, "error running %s %s: %s", command, strings.Join(args, " "), string(rawOut))
-	}
-	return rawOut, nil
+// Rollback makes the previous deployment the default one, which is booted into on the next reboot.
+func (r *RpmOstreeClient) Rollback() error {
+	_, err := r.runner.Run("rpm-ostree", "rollback")
+	return err
 }
@@ -0,0 +1,165 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// HostRunner runs commands on the host. The daemon runs its commands through it
+// rather than os/exec so that its behavior can be tested without a host.
+type HostRunner interface {
+	// Run runs the command and returns its stdout.
+	// If the command fails, the error is a *CommandError.
+	Run(name string, args ...string) ([]byte, error)
+	// RunWithStdin is like Run, with stdin as the input of the command.
+	RunWithStdin(stdin io.Reader, name string, args ...string) ([]byte, error)
+	// RunWithEnv is like Run, with env added to the environment of the command.
+	// The command is killed if ctx is done before it completes.
+	RunWithEnv(ctx context.Context, env []string, name string, args ...string) ([]byte, error)
+	// Start starts the command and returns its stdout. The command runs until it
+	// exits or ctx is done, closing the output waits for it.
+	Start(ctx context.Context, name string, args ...string) (io.ReadCloser, error)
+}
+
+// CommandError is returned by a HostRunner when a command fails.
+type CommandError struct {
+	Command string
+	Args    []string
+	// ExitCode is the exit code of the command, or -1 if it couldn't be run
+	// or was killed by a signal.
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("error running %s %s: %v", e.Command, strings.Join(e.Args, " "), e.Err)
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// commandExitCode returns the exit code of the command which failed with err,
+// or -1 if err doesn't come from a command.
+func commandExitCode(err error) int {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.ExitCode
+	}
+	return -1
+}
+
+// execRunner runs the commands with os/exec.
+type execRunner struct{}
+
+func (r execRunner) Run(name string, args ...string) ([]byte, error) {
+	glog.Infof("Running captured: %s %s", name, strings.Join(args, " "))
+	return r.RunWithStdin(nil, name, args...)
+}
+
+func (execRunner) RunWithStdin(stdin io.Reader, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = stdin
+	return runCommand(cmd, name, args)
+}
+
+func (execRunner) RunWithEnv(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+	glog.Infof("Running captured: %s %s", name, strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	return runCommand(cmd, name, args)
+}
+
+func (execRunner) Start(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	glog.Infof("Starting: %s %s", name, strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, &CommandError{Command: name, Args: args, ExitCode: -1, Err: err}
+	}
+	return &commandOutput{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+// commandOutput is the stdout of a started command.
+type commandOutput struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (o *commandOutput) Close() error {
+	o.ReadCloser.Close()
+	return o.cmd.Wait()
+}
+
+// runCommand runs cmd, returning a *CommandError if it fails.
+func runCommand(cmd *exec.Cmd, name string, args []string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		exitCode := -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+		return stdout.Bytes(), &CommandError{
+			Command:  name,
+			Args:     args,
+			ExitCode: exitCode,
+			Stderr:   stderr.String(),
+			Err:      err,
+		}
+	}
+	return stdout.Bytes(), nil
+}
+
+// runWithRetries runs the command until it succeeds or it failed retries more
+// times, waiting between the tries with an exponential backoff.
+func runWithRetries(runner HostRunner, retries int, name string, args ...string) ([]byte, error) {
+	var out []byte
+	var runErr error
+	err := wait.ExponentialBackoff(wait.Backoff{
+		Steps:    retries + 1,
+		Duration: 5 * time.Second,
+		Factor:   2,
+	}, func() (bool, error) {
+		out, runErr = runner.Run(name, args...)
+		if runErr != nil {
+			glog.Warningf("%s failed: %v; retrying...", name, runErr)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return out, errors.Wrapf(runErr, "failed to run %s after %d tries", name, retries+1)
+	}
+	return out, err
+}
+
+// runInBackgroundWithRetries is runWithRetries with the command running at
+// low CPU and I/O priority, so that it doesn't disturb the workloads.
+func runInBackgroundWithRetries(runner HostRunner, retries int, name string, args ...string) ([]byte, error) {
+	return runWithRetries(runner, retries, "nice", append([]string{"--", "ionice", "-c", "3", name}, args...)...)
+}
+
+// NewHostRunner returns a HostRunner running the commands with os/exec.
+func NewHostRunner() HostRunner {
+	return execRunner{}
+}
@@ -0,0 +1,127 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner is a HostRunner recording the commands it's asked to run.
+// The commands succeed without output unless they have a result.
+type fakeRunner struct {
+	// ran is the command lines which ran, e.g. "systemctl enable crio.service"
+	ran []string
+	// stdin is the input of the commands which ran with some
+	stdin []string
+	// results is the result of the command lines
+	results map[string]fakeRunnerResult
+}
+
+type fakeRunnerResult struct {
+	stdout   string
+	stderr   string
+	exitCode int
+}
+
+// respond sets the result of the command line.
+func (r *fakeRunner) respond(cmdline, stdout, stderr string, exitCode int) {
+	if r.results == nil {
+		r.results = map[string]fakeRunnerResult{}
+	}
+	r.results[cmdline] = fakeRunnerResult{stdout: stdout, stderr: stderr, exitCode: exitCode}
+}
+
+func (r *fakeRunner) Run(name string, args ...string) ([]byte, error) {
+	return r.RunWithStdin(nil, name, args...)
+}
+
+func (r *fakeRunner) RunWithStdin(stdin io.Reader, name string, args ...string) ([]byte, error) {
+	cmdline := strings.Join(append([]string{name}, args...), " ")
+	r.ran = append(r.ran, cmdline)
+	if stdin != nil {
+		input, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return nil, err
+		}
+		r.stdin = append(r.stdin, string(input))
+	}
+	result := r.results[cmdline]
+	if result.exitCode != 0 {
+		return []byte(result.stdout), &CommandError{
+			Command:  name,
+			Args:     args,
+			ExitCode: result.exitCode,
+			Stderr:   result.stderr,
+			Err:      fmt.Errorf("exit status %d", result.exitCode),
+		}
+	}
+	return []byte(result.stdout), nil
+}
+
+func (r *fakeRunner) RunWithEnv(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+	return r.RunWithStdin(nil, name, args...)
+}
+
+func (r *fakeRunner) Start(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	out, err := r.Run(name, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(out)), nil
+}
+
+func TestExecRunner(t *testing.T) {
+	runner := execRunner{}
+	out, err := runner.Run("sh", "-c", "echo out; echo err >&2")
+	require.Nil(t, err)
+	assert.Equal(t, "out\n", string(out))
+
+	out, err = runner.RunWithStdin(strings.NewReader("in\n"), "cat")
+	require.Nil(t, err)
+	assert.Equal(t, "in\n", string(out))
+
+	// failures carry the exit code and stderr of the command
+	out, err = runner.Run("sh", "-c", "echo out; echo unit not found >&2; exit 5")
+	require.NotNil(t, err)
+	assert.Equal(t, "out\n", string(out))
+	cmdErr, ok := err.(*CommandError)
+	require.True(t, ok)
+	assert.Equal(t, 5, cmdErr.ExitCode)
+	assert.Equal(t, "unit not found\n", cmdErr.Stderr)
+	assert.Equal(t, "error running sh -c echo out; echo unit not found >&2; exit 5: exit status 5: unit not found", err.Error())
+	assert.Equal(t, 5, commandExitCode(fmt.Errorf("wrapped: %w", err)))
+
+	// the environment is added to the one of the daemon
+	out, err = runner.RunWithEnv(context.Background(), []string{"HOOK_PHASE=BeforeDrain"}, "sh", "-c", `echo "$HOOK_PHASE $HOME"`)
+	require.Nil(t, err)
+	assert.Equal(t, "BeforeDrain "+os.Getenv("HOME")+"\n", string(out))
+
+	// started commands are killed when their context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	stdout, err := runner.Start(ctx, "sh", "-c", "echo started; sleep 60")
+	require.Nil(t, err)
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	require.Nil(t, err)
+	assert.Equal(t, "started\n", line)
+	cancel()
+	assert.NotNil(t, stdout.Close())
+
+	// retried commands fail with the last error
+	_, err = runWithRetries(runner, 0, "sh", "-c", "exit 4")
+	require.NotNil(t, err)
+	assert.Equal(t, 4, commandExitCode(err))
+
+	_, err = runner.Run("/nonexistent/command")
+	require.NotNil(t, err)
+	assert.Equal(t, -1, commandExitCode(err))
+	assert.Equal(t, -1, commandExitCode(fmt.Errorf("not a command")))
+}
@@ -0,0 +1,258 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	sddbus "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// SystemdClient manages the systemd units of the host.
+type SystemdClient interface {
+	// EnableUnits enables the units and reloads the unit files.
+	EnableUnits(units []string) error
+	// DisableUnits disables the units and reloads the unit files.
+	DisableUnits(units []string) error
+	// PresetUnits resets the units to their presets and reloads the unit files.
+	PresetUnits(units []string) error
+	// DaemonReload reloads the unit files.
+	DaemonReload() error
+	// StartUnit, StopUnit, RestartUnit and ReloadUnit wait for the job to complete.
+	StartUnit(unit string) error
+	StopUnit(unit string) error
+	RestartUnit(unit string) error
+	ReloadUnit(unit string) error
+	// ActiveState returns the active state of the unit, e.g. active or failed.
+	ActiveState(unit string) (string, error)
+	// FailedUnits returns the names of the units in the failed state.
+	FailedUnits() ([]string, error)
+}
+
+// newSystemdClient connects to systemd through D-Bus, falling back to systemctl
+// if D-Bus isn't available.
+func newSystemdClient(runner HostRunner) SystemdClient {
+	client, err := newDBusSystemdClient()
+	if err != nil {
+		glog.Warningf("Failed to connect to systemd through D-Bus, falling back to systemctl: %v", err)
+		return &systemctlClient{runner: runner}
+	}
+	return client
+}
+
+// runSystemdAction runs a post config change action handled by systemd,
+// i.e. daemon-reload or a reload, restart or stop of a unit.
+func runSystemdAction(systemd SystemdClient, action string) error {
+	if action == postConfigChangeActionDaemonReload {
+		return systemd.DaemonReload()
+	}
+	fields := strings.Fields(action)
+	if len(fields) != 2 {
+		return fmt.Errorf("invalid systemd action %q", action)
+	}
+	switch verb, unit := fields[0]+" ", fields[1]; verb {
+	case postConfigChangeActionReloadPrefix:
+		return systemd.ReloadUnit(unit)
+	case postConfigChangeActionRestartPrefix:
+		return systemd.RestartUnit(unit)
+	case postConfigChangeActionStopPrefix:
+		return systemd.StopUnit(unit)
+	}
+	return fmt.Errorf("invalid systemd action %q", action)
+}
+
+// dbusSystemdClient talks to systemd through its D-Bus API.
+type dbusSystemdClient struct {
+	conn *sddbus.Conn
+	// manager is the systemd manager on the connection of conn,
+	// for the methods go-systemd doesn't wrap.
+	manager dbus.BusObject
+}
+
+func newDBusSystemdClient() (*dbusSystemdClient, error) {
+	var bus *dbus.Conn
+	conn, err := sddbus.NewConnection(func() (*dbus.Conn, error) {
+		c, err := dbus.SystemBusPrivate()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Auth([]dbus.Auth{dbus.AuthExternal(strconv.Itoa(os.Getuid()))}); err != nil {
+			c.Close()
+			return nil, err
+		}
+		if err := c.Hello(); err != nil {
+			c.Close()
+			return nil, err
+		}
+		if bus == nil {
+			bus = c
+		}
+		return c, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dbusSystemdClient{
+		conn:    conn,
+		manager: bus.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1"),
+	}, nil
+}
+
+func (c *dbusSystemdClient) EnableUnits(units []string) error {
+	if _, _, err := c.conn.EnableUnitFiles(units, false, false); err != nil {
+		return errors.Wrapf(err, "enabling units %v", units)
+	}
+	return c.DaemonReload()
+}
+
+func (c *dbusSystemdClient) DisableUnits(units []string) error {
+	if _, err := c.conn.DisableUnitFiles(units, false); err != nil {
+		return errors.Wrapf(err, "disabling units %v", units)
+	}
+	return c.DaemonReload()
+}
+
+func (c *dbusSystemdClient) PresetUnits(units []string) error {
+	// like systemctl preset, replace the symlinks which conflict with the presets
+	if err := c.manager.Call("org.freedesktop.systemd1.Manager.PresetUnitFiles", 0, units, false, true).Err; err != nil {
+		return errors.Wrapf(err, "running preset on units %v", units)
+	}
+	return c.DaemonReload()
+}
+
+func (c *dbusSystemdClient) DaemonReload() error {
+	return errors.Wrap(c.conn.Reload(), "reloading systemd")
+}
+
+func (c *dbusSystemdClient) StartUnit(unit string) error {
+	return c.runJob("starting", unit, c.conn.StartUnit)
+}
+
+func (c *dbusSystemdClient) StopUnit(unit string) error {
+	return c.runJob("stopping", unit, c.conn.StopUnit)
+}
+
+func (c *dbusSystemdClient) RestartUnit(unit string) error {
+	return c.runJob("restarting", unit, c.conn.RestartUnit)
+}
+
+func (c *dbusSystemdClient) ReloadUnit(unit string) error {
+	return c.runJob("reloading", unit, c.conn.ReloadUnit)
+}
+
+// runJob queues the job of the unit and waits for its result.
+func (c *dbusSystemdClient) runJob(verb, unit string, queue func(string, string, chan<- string) (int, error)) error {
+	result := make(chan string, 1)
+	if _, err := queue(unit, "replace", result); err != nil {
+		return errors.Wrapf(err, "%s %s", verb, unit)
+	}
+	if r := <-result; r != "done" {
+		return fmt.Errorf("%s %s: job %s", verb, unit, r)
+	}
+	return nil
+}
+
+func (c *dbusSystemdClient) ActiveState(unit string) (string, error) {
+	prop, err := c.conn.GetUnitProperty(unit, "ActiveState")
+	if err != nil {
+		return "", errors.Wrapf(err, "getting the state of %s", unit)
+	}
+	state, ok := prop.Value.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected ActiveState %v of %s", prop.Value, unit)
+	}
+	return state, nil
+}
+
+func (c *dbusSystemdClient) FailedUnits() ([]string, error) {
+	statuses, err := c.conn.ListUnitsFiltered([]string{"failed"})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing failed units")
+	}
+	var units []string
+	for _, status := range statuses {
+		units = append(units, status.Name)
+	}
+	return units, nil
+}
+
+// systemctlClient manages the units by running systemctl. It's used when
+// systemd isn't reachable through D-Bus. Like the D-Bus client, it reloads
+// systemd after changing the unit files.
+type systemctlClient struct {
+	runner HostRunner
+}
+
+func (c *systemctlClient) systemctl(args ...string) error {
+	_, err := c.runner.Run("systemctl", args...)
+	return err
+}
+
+func (c *systemctlClient) EnableUnits(units []string) error {
+	if err := c.systemctl(append([]string{"enable"}, units...)...); err != nil {
+		return err
+	}
+	return c.DaemonReload()
+}
+
+func (c *systemctlClient) DisableUnits(units []string) error {
+	if err := c.systemctl(append([]string{"disable"}, units...)...); err != nil {
+		return err
+	}
+	return c.DaemonReload()
+}
+
+func (c *systemctlClient) PresetUnits(units []string) error {
+	if err := c.systemctl(append([]string{"preset"}, units...)...); err != nil {
+		return err
+	}
+	return c.DaemonReload()
+}
+
+func (c *systemctlClient) DaemonReload() error {
+	return c.systemctl("daemon-reload")
+}
+
+func (c *systemctlClient) StartUnit(unit string) error {
+	return c.systemctl("start", unit)
+}
+
+func (c *systemctlClient) StopUnit(unit string) error {
+	return c.systemctl("stop", unit)
+}
+
+func (c *systemctlClient) RestartUnit(unit string) error {
+	return c.systemctl("restart", unit)
+}
+
+func (c *systemctlClient) ReloadUnit(unit string) error {
+	return c.systemctl("reload", unit)
+}
+
+func (c *systemctlClient) ActiveState(unit string) (string, error) {
+	// is-active exits non-zero for the inactive units, but still prints their state
+	out, err := c.runner.Run("systemctl", "is-active", unit)
+	state := strings.TrimSpace(string(out))
+	if err != nil && (commandExitCode(err) == -1 || state == "") {
+		return "", err
+	}
+	return state, nil
+}
+
+func (c *systemctlClient) FailedUnits() ([]string, error) {
+	out, err := c.runner.Run("systemctl", "list-units", "--state=failed", "--no-legend", "--plain")
+	if err != nil {
+		return nil, err
+	}
+	var units []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			units = append(units, fields[0])
+		}
+	}
+	return units, nil
+}
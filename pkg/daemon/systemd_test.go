@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSystemdClient is a SystemdClient recording the calls made to it,
+// e.g. "enable crio.service kubelet.service". The calls in failures fail.
+type fakeSystemdClient struct {
+	calls    []string
+	failures map[string]bool
+	// states is the active state of the units, defaulting to active
+	states map[string]string
+}
+
+func (c *fakeSystemdClient) call(verb string, units ...string) error {
+	call := strings.Join(append([]string{verb}, units...), " ")
+	c.calls = append(c.calls, call)
+	if c.failures[call] {
+		return fmt.Errorf("%s failed", call)
+	}
+	return nil
+}
+
+func (c *fakeSystemdClient) EnableUnits(units []string) error  { return c.call("enable", units...) }
+func (c *fakeSystemdClient) DisableUnits(units []string) error { return c.call("disable", units...) }
+func (c *fakeSystemdClient) PresetUnits(units []string) error  { return c.call("preset", units...) }
+func (c *fakeSystemdClient) DaemonReload() error               { return c.call("daemon-reload") }
+func (c *fakeSystemdClient) StartUnit(unit string) error       { return c.call("start", unit) }
+func (c *fakeSystemdClient) StopUnit(unit string) error        { return c.call("stop", unit) }
+func (c *fakeSystemdClient) RestartUnit(unit string) error     { return c.call("restart", unit) }
+func (c *fakeSystemdClient) ReloadUnit(unit string) error      { return c.call("reload", unit) }
+
+func (c *fakeSystemdClient) ActiveState(unit string) (string, error) {
+	if err := c.call("is-active", unit); err != nil {
+		return "", err
+	}
+	if state, ok := c.states[unit]; ok {
+		return state, nil
+	}
+	return "active", nil
+}
+
+func (c *fakeSystemdClient) FailedUnits() ([]string, error) {
+	var units []string
+	for unit, state := range c.states {
+		if state == "failed" {
+			units = append(units, unit)
+		}
+	}
+	sort.Strings(units)
+	return units, c.call("list-failed")
+}
+
+func TestSystemctlClient(t *testing.T) {
+	runner := &fakeRunner{}
+	client := &systemctlClient{runner: runner}
+	require.Nil(t, client.EnableUnits([]string{"a.service", "b.service"}))
+	require.Nil(t, client.DisableUnits([]string{"d.service"}))
+	require.Nil(t, client.PresetUnits([]string{"c.service"}))
+	require.Nil(t, client.RestartUnit("crio.service"))
+	assert.Equal(t, []string{
+		"systemctl enable a.service b.service",
+		"systemctl daemon-reload",
+		"systemctl disable d.service",
+		"systemctl daemon-reload",
+		"systemctl preset c.service",
+		"systemctl daemon-reload",
+		"systemctl restart crio.service",
+	}, runner.ran)
+
+	// failing to change the unit files doesn't reload systemd
+	runner.ran = nil
+	runner.respond("systemctl enable missing.service", "", "Unit file missing.service does not exist.", 1)
+	require.NotNil(t, client.EnableUnits([]string{"missing.service"}))
+	assert.Equal(t, []string{"systemctl enable missing.service"}, runner.ran)
+
+	// is-active exits with 3 for the inactive units
+	runner.respond("systemctl is-active ovs-vswitchd.service", "failed\n", "", 3)
+	state, err := client.ActiveState("ovs-vswitchd.service")
+	require.Nil(t, err)
+	assert.Equal(t, "failed", state)
+	runner.respond("systemctl is-active missing.service", "", "Failed to connect to bus", 1)
+	_, err = client.ActiveState("missing.service")
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Failed to connect to bus")
+
+	runner.respond("systemctl list-units --state=failed --no-legend --plain",
+		"ovs-vswitchd.service loaded failed failed Open vSwitch Forwarding Unit\nkdump.service loaded failed failed Crash recovery kernel arming\n", "", 0)
+	units, err := client.FailedUnits()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"ovs-vswitchd.service", "kdump.service"}, units)
+}
+
+func TestRunSystemdAction(t *testing.T) {
+	systemd := &fakeSystemdClient{}
+	for _, action := range []string{
+		postConfigChangeActionReloadCrio,
+		postConfigChangeActionRestartPrefix + "chronyd.service",
+		postConfigChangeActionStopPrefix + "old.service",
+		postConfigChangeActionDaemonReload,
+	} {
+		require.Nil(t, runSystemdAction(systemd, action))
+	}
+	assert.Equal(t, []string{"reload crio", "restart chronyd.service", "stop old.service", "daemon-reload"}, systemd.calls)
+	assert.NotNil(t, runSystemdAction(systemd, "kill crio"))
+	assert.NotNil(t, runSystemdAction(systemd, postConfigChangeActionReboot))
+}
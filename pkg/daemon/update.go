@@ -6,21 +6,19 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"os/user"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
-	"time"
 
 	"github.com/clarketm/json"
 	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
 	"github.com/golang/glog"
 	"github.com/google/renameio"
 	errors "github.com/pkg/errors"
-	"github.com/vincent-petithory/dataurl"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -30,7 +28,6 @@ import (
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	pivottypes "github.com/openshift/machine-config-operator/pkg/daemon/pivot/types"
-	pivotutils "github.com/openshift/machine-config-operator/pkg/daemon/pivot/utils"
 )
 
 const (
@@ -56,20 +53,42 @@ const (
 	// Crio reload will happen when /etc/containers/registries.conf is changed. This will cause
 	// a "systemctl reload crio"
 	postConfigChangeActionReloadCrio = "reload crio"
+	// Unit reloads and restarts are requested by the postConfigChangeActions of the MachineConfig,
+	// as "reload <unit>" and "restart <unit>"
+	postConfigChangeActionReloadPrefix  = "reload "
+	postConfigChangeActionRestartPrefix = "restart "
+	// Changes to the liveRestartUnits of the MachineConfig "stop <unit>" or "restart <unit>"
+	// after a "daemon-reload"
+	postConfigChangeActionStopPrefix   = "stop "
+	postConfigChangeActionDaemonReload = "daemon-reload"
 )
 
-func writeFileAtomicallyWithDefaults(fpath string, b []byte) error {
-	return writeFileAtomically(fpath, b, defaultDirectoryPermissions, defaultFilePermissions, -1, -1)
+// defaultPostConfigChangeActionRules are the rules applied to the changed files before the
+// postConfigChangeActions of the MachineConfig, which can override them.
+var defaultPostConfigChangeActionRules = []mcfgv1.PostConfigChangeActionRule{
+	{
+		Paths:  []string{"/etc/kubernetes/kubelet-ca.crt", "/var/lib/kubelet/config.json"},
+		Action: mcfgv1.PostConfigChangeActionNone,
+	},
+	{
+		Paths:  []string{"/etc/containers/registries.conf"},
+		Action: mcfgv1.PostConfigChangeActionReload,
+		Unit:   "crio",
+	},
+}
+
+func (dn *Daemon) writeFileAtomicallyWithDefaults(fpath string, b []byte) error {
+	return dn.writeFileAtomically(fpath, b, defaultDirectoryPermissions, defaultFilePermissions, -1, -1)
 }
 
 // writeFileAtomically uses the renameio package to provide atomic file writing, we can't use renameio.WriteFile
 // directly since we need to 1) Chown 2) go through a buffer since files provided can be big
-func writeFileAtomically(fpath string, b []byte, dirMode, fileMode os.FileMode, uid, gid int) error {
-	dir := filepath.Dir(fpath)
+func (dn *Daemon) writeFileAtomically(fpath string, b []byte, dirMode, fileMode os.FileMode, uid, gid int) error {
+	dir := filepath.Dir(dn.hostPath(fpath))
 	if err := os.MkdirAll(dir, dirMode); err != nil {
 		return fmt.Errorf("failed to create directory %q: %v", filepath.Dir(fpath), err)
 	}
-	t, err := renameio.TempFile(dir, fpath)
+	t, err := renameio.TempFile(dir, dn.hostPath(fpath))
 	if err != nil {
 		return err
 	}
@@ -101,9 +120,13 @@ func getNodeRef(node *corev1.Node) *corev1.ObjectReference {
 	}
 }
 
-func reloadService(name string) error {
-	_, err := runGetOut("systemctl", "reload", name)
-	return err
+// isSystemctlAction returns whether the action is run as a systemctl command,
+// e.g. "reload crio" runs "systemctl reload crio".
+func isSystemctlAction(action string) bool {
+	return action == postConfigChangeActionDaemonReload ||
+		strings.HasPrefix(action, postConfigChangeActionReloadPrefix) ||
+		strings.HasPrefix(action, postConfigChangeActionRestartPrefix) ||
+		strings.HasPrefix(action, postConfigChangeActionStopPrefix)
 }
 
 // performPostConfigChangeAction takes action based on what postConfigChangeAction has been asked.
@@ -123,20 +146,27 @@ func (dn *Daemon) performPostConfigChangeAction(postConfigChangeActions []string
 		dn.logSystem("Node has Desired Config %s, skipping reboot", configName)
 	}
 
-	if ctrlcommon.InSlice(postConfigChangeActionReloadCrio, postConfigChangeActions) {
-		serviceName := "crio"
+	for _, action := range postConfigChangeActions {
+		if !isSystemctlAction(action) {
+			continue
+		}
 
-		if err := reloadService(serviceName); err != nil {
+		if err := runSystemdAction(dn.systemd, action); err != nil {
+			// the config is already on disk, reboot into it
 			if dn.recorder != nil {
-				dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeWarning, "FailedServiceReload", fmt.Sprintf("Reloading %s service failed. Error: %v", serviceName, err))
+				dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeWarning, "FailedServiceAction", fmt.Sprintf("Running systemctl %s failed, rebooting. Error: %v", action, err))
 			}
-			return fmt.Errorf("Could not apply update: reloading %s configuration failed. Error: %v", serviceName, err)
+			dn.logSystem("Running systemctl %s failed, falling back to a reboot: %v", action, err)
+			if err := dn.performDrain(); err != nil {
+				return err
+			}
+			return dn.reboot(fmt.Sprintf("Node will reboot into config %s", configName))
 		}
 
 		if dn.recorder != nil {
-			dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeNormal, "SkipReboot", "Config changes do not require reboot. Service %s was reloaded.", serviceName)
+			dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeNormal, "SkipReboot", "Config changes do not require reboot. Ran systemctl %s.", action)
 		}
-		dn.logSystem("%s config reloaded successfully! Desired config %s has been applied, skipping reboot", serviceName, configName)
+		dn.logSystem("systemctl %s ran successfully! Desired config %s has been applied, skipping reboot", action, configName)
 	}
 
 	// We are here, which means reboot was not needed to apply the configuration.
@@ -162,16 +192,16 @@ func (dn *Daemon) performPostConfigChangeAction(postConfigChangeActions []string
 // finalizeBeforeReboot is the last step in an update() and then we take appropriate postConfigChangeAction.
 // It can also be called as a special case for the "bootstrap pivot".
 func (dn *Daemon) finalizeBeforeReboot(newConfig *mcfgv1.MachineConfig) (retErr error) {
-	if out, err := dn.storePendingState(newConfig, 1); err != nil {
-		return errors.Wrapf(err, "failed to log pending config: %s", string(out))
+	if err := dn.storePendingState(newConfig, updatePhaseRebooting); err != nil {
+		return errors.Wrap(err, "failed to store pending config")
 	}
 	defer func() {
 		if retErr != nil {
 			if dn.recorder != nil {
 				dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeNormal, "PendingConfigRollBack", fmt.Sprintf("Rolling back pending config %s: %v", newConfig.GetName(), retErr))
 			}
-			if out, err := dn.storePendingState(newConfig, 0); err != nil {
-				retErr = errors.Wrapf(retErr, "error rolling back pending config %v: %s", err, string(out))
+			if err := dn.storePendingState(newConfig, updatePhaseRolledBack); err != nil {
+				retErr = errors.Wrapf(retErr, "error rolling back pending config %v", err)
 				return
 			}
 		}
@@ -223,34 +253,34 @@ func (dn *Daemon) compareMachineConfig(oldConfig, newConfig *mcfgv1.MachineConfi
 
 // addExtensionsRepo adds a repo into /etc/yum.repos.d/ which we use later to
 // install extensions and rt-kernel
-func addExtensionsRepo(osImageContentDir string) error {
+func (dn *Daemon) addExtensionsRepo(osImageContentDir string) error {
 	repoContent := "[coreos-extensions]\nenabled=1\nmetadata_expire=1m\nbaseurl=" + osImageContentDir + "/extensions/\ngpgcheck=0\nskip_if_unavailable=False\n"
-	if err := writeFileAtomicallyWithDefaults(extensionsRepo, []byte(repoContent)); err != nil {
+	if err := dn.writeFileAtomicallyWithDefaults(extensionsRepo, []byte(repoContent)); err != nil {
 		return err
 	}
 	return nil
 }
 
 // podmanRemove kills and removes a container
-func podmanRemove(cid string) {
+func podmanRemove(runner HostRunner, cid string) {
 	// Ignore errors here
-	exec.Command("podman", "kill", cid).Run()
-	exec.Command("podman", "rm", "-f", cid).Run()
+	runner.Run("podman", "kill", cid)
+	runner.Run("podman", "rm", "-f", cid)
 }
 
-func podmanCopy(imgURL, osImageContentDir string) (err error) {
+func podmanCopy(runner HostRunner, root, imgURL, osImageContentDir string) (err error) {
 	// make sure that osImageContentDir doesn't exist
 	os.RemoveAll(osImageContentDir)
 
 	// Pull the container image
 	var authArgs []string
-	if _, err := os.Stat(kubeletAuthFile); err == nil {
+	if _, err := os.Stat(filepath.Join(root, kubeletAuthFile)); err == nil {
 		authArgs = append(authArgs, "--authfile", kubeletAuthFile)
 	}
 	args := []string{"pull", "-q"}
 	args = append(args, authArgs...)
 	args = append(args, imgURL)
-	_, err = pivotutils.RunExtBackground(numRetriesNetCommands, "podman", args...)
+	_, err = runInBackgroundWithRetries(runner, numRetriesNetCommands, "podman", args...)
 	if err != nil {
 		return
 	}
@@ -258,25 +288,25 @@ func podmanCopy(imgURL, osImageContentDir string) (err error) {
 	// create a container
 	var cidBuf []byte
 	containerName := pivottypes.PivotNamePrefix + string(uuid.NewUUID())
-	cidBuf, err = runGetOut("podman", "create", "--net=none", "--annotation=org.openshift.machineconfigoperator.pivot=true", "--name", containerName, imgURL)
+	cidBuf, err = runner.Run("podman", "create", "--net=none", "--annotation=org.openshift.machineconfigoperator.pivot=true", "--name", containerName, imgURL)
 	if err != nil {
 		return
 	}
 
 	// only delete created container, we will delete container image later as we may need it for podmanInspect()
-	defer podmanRemove(containerName)
+	defer podmanRemove(runner, containerName)
 
 	// copy the content from create container locally into a temp directory under /run/machine-os-content/
 	cid := strings.TrimSpace(string(cidBuf))
 	args = []string{"cp", fmt.Sprintf("%s:/", cid), osImageContentDir}
-	_, err = pivotutils.RunExtBackground(numRetriesNetCommands, "podman", args...)
+	_, err = runInBackgroundWithRetries(runner, numRetriesNetCommands, "podman", args...)
 	if err != nil {
 		return
 	}
 
 	// Set selinux context to var_run_t to avoid selinux denial
 	args = []string{"-R", "-t", "var_run_t", osImageContentDir}
-	_, err = runGetOut("chcon", args...)
+	_, err = runner.Run("chcon", args...)
 	if err != nil {
 		glog.Infof("Error changing selinux context on path %s  %v", osImageContentDir, err)
 		return
@@ -288,17 +318,23 @@ func podmanCopy(imgURL, osImageContentDir string) (err error) {
 // and returns the path on successful extraction.
 // Note that since we do this in the MCD container, cluster proxy configuration must also be injected
 // into the container. See the MCD daemonset.
-func ExtractOSImage(imgURL string) (osImageContentDir string, err error) {
+func ExtractOSImage(runner HostRunner, imgURL string) (osImageContentDir string, err error) {
+	return extractOSImage(runner, "/", osImageContentBaseDir, imgURL)
+}
+
+// extractOSImage is ExtractOSImage with the host filesystem accessed through root,
+// and the content extracted under baseDir.
+func extractOSImage(runner HostRunner, root, baseDir, imgURL string) (osImageContentDir string, err error) {
 	var registryConfig []string
-	if _, err := os.Stat(kubeletAuthFile); err == nil {
+	if _, err := os.Stat(filepath.Join(root, kubeletAuthFile)); err == nil {
 		registryConfig = append(registryConfig, "--registry-config", kubeletAuthFile)
 	}
-	if err = os.MkdirAll(osImageContentBaseDir, 0755); err != nil {
-		err = fmt.Errorf("error creating directory %s: %v", osImageContentBaseDir, err)
+	if err = os.MkdirAll(filepath.Join(root, baseDir), 0755); err != nil {
+		err = fmt.Errorf("error creating directory %s: %v", baseDir, err)
 		return
 	}
 
-	if osImageContentDir, err = ioutil.TempDir(osImageContentBaseDir, "os-content-"); err != nil {
+	if osImageContentDir, err = ioutil.TempDir(filepath.Join(root, baseDir), "os-content-"); err != nil {
 		return
 	}
 
@@ -311,11 +347,11 @@ func ExtractOSImage(imgURL string) (osImageContentDir string, err error) {
 	args := []string{"image", "extract", "--path", "/:" + osImageContentDir}
 	args = append(args, registryConfig...)
 	args = append(args, imgURL)
-	if _, err = pivotutils.RunExtBackground(cmdRetriesCount, "oc", args...); err != nil {
+	if _, err = runInBackgroundWithRetries(runner, cmdRetriesCount, "oc", args...); err != nil {
 		// Workaround fixes for the environment where oc image extract fails.
 		// See https://bugzilla.redhat.com/show_bug.cgi?id=1862979
 		glog.Infof("Falling back to using podman cp to fetch OS image content")
-		if err = podmanCopy(imgURL, osImageContentDir); err != nil {
+		if err = podmanCopy(runner, root, imgURL, osImageContentDir); err != nil {
 			return
 		}
 	}
@@ -323,10 +359,16 @@ func ExtractOSImage(imgURL string) (osImageContentDir string, err error) {
 	return
 }
 
+// extractOSImage extracts the OS image content in a new directory under the
+// staging directory of the host.
+func (dn *Daemon) extractOSImage(imgURL string) (string, error) {
+	return extractOSImage(dn.runner, dn.root, stagedOSImageContentBaseDir, imgURL)
+}
+
 // Remove pending deployment on OSTree based system
-func removePendingDeployment() error {
+func (dn *Daemon) removePendingDeployment() error {
 	args := []string{"cleanup", "-p"}
-	_, err := runGetOut("rpm-ostree", args...)
+	_, err := dn.runner.Run("rpm-ostree", args...)
 	return err
 }
 
@@ -361,17 +403,16 @@ func (dn *Daemon) applyOSChanges(oldConfig, newConfig *mcfgv1.MachineConfig) (re
 		if dn.recorder != nil {
 			dn.recorder.Eventf(getNodeRef(dn.node), corev1.EventTypeNormal, "InClusterUpgrade", fmt.Sprintf("Updating from oscontainer %s", newConfig.Spec.OSImageURL))
 		}
-		if osImageContentDir, err = ExtractOSImage(newConfig.Spec.OSImageURL); err != nil {
+		// The content is usually staged before the drain, and kept in case the update is retried.
+		if osImageContentDir, err = dn.osImageStager.wait(newConfig.Spec.OSImageURL); err != nil {
 			return err
 		}
-		// Delete extracted OS image once we are done.
-		defer os.RemoveAll(osImageContentDir)
 
 		if dn.os.IsCoreOSVariant() {
-			if err := addExtensionsRepo(osImageContentDir); err != nil {
+			if err := dn.addExtensionsRepo(osImageContentDir); err != nil {
 				return err
 			}
-			defer os.Remove(extensionsRepo)
+			defer os.Remove(dn.hostPath(extensionsRepo))
 		}
 	}
 
@@ -393,7 +434,7 @@ func (dn *Daemon) applyOSChanges(oldConfig, newConfig *mcfgv1.MachineConfig) (re
 		if retErr != nil {
 			// Print out the error now so that if we fail to cleanup -p, we don't lose it.
 			glog.Infof("Rolling back applied changes to OS due to error: %v", retErr)
-			if err := removePendingDeployment(); err != nil {
+			if err := dn.removePendingDeployment(); err != nil {
 				retErr = errors.Wrapf(retErr, "error removing staged deployment: %v", err)
 				return
 			}
@@ -424,15 +465,77 @@ func (dn *Daemon) applyOSChanges(oldConfig, newConfig *mcfgv1.MachineConfig) (re
 
 }
 
-func calculatePostConfigChangeActionFromFileDiffs(oldIgnConfig, newIgnConfig ign3types.Config) (actions []string) {
-	filesPostConfigChangeActionNone := []string{
-		"/etc/kubernetes/kubelet-ca.crt",
-		"/var/lib/kubelet/config.json",
+// postConfigChangeActionForFile returns the action of the last rule matching the path,
+// or reboot if no rule matches.
+func postConfigChangeActionForFile(path string, rules []mcfgv1.PostConfigChangeActionRule) string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		rule := rules[i]
+		for _, glob := range rule.Paths {
+			if matched, err := filepath.Match(glob, path); err != nil || !matched {
+				continue
+			}
+			switch rule.Action {
+			case mcfgv1.PostConfigChangeActionNone:
+				return postConfigChangeActionNone
+			case mcfgv1.PostConfigChangeActionReload:
+				return postConfigChangeActionReloadPrefix + rule.Unit
+			case mcfgv1.PostConfigChangeActionRestart:
+				return postConfigChangeActionRestartPrefix + rule.Unit
+			default:
+				return postConfigChangeActionReboot
+			}
+		}
 	}
-	filesPostConfigChangeActionReloadCrio := []string{
-		"/etc/containers/registries.conf",
+	return postConfigChangeActionReboot
+}
+
+// calculatePostConfigChangeActionFromUnitDiffs returns the actions applying the changed units
+// live: the units which were removed, masked or disabled are stopped, and the others restarted,
+// after reloading systemd. It returns reboot if any of the changed units isn't live restartable.
+func calculatePostConfigChangeActionFromUnitDiffs(oldIgnConfig, newIgnConfig ign3types.Config, liveRestartUnits []string) []string {
+	oldUnitSet := make(map[string]ign3types.Unit)
+	for _, u := range oldIgnConfig.Systemd.Units {
+		oldUnitSet[u.Name] = u
+	}
+	newUnitSet := make(map[string]ign3types.Unit)
+	for _, u := range newIgnConfig.Systemd.Units {
+		newUnitSet[u.Name] = u
+	}
+	diffUnitSet := []string{}
+	for name, oldUnit := range oldUnitSet {
+		if newUnit, ok := newUnitSet[name]; !ok || !reflect.DeepEqual(oldUnit, newUnit) {
+			diffUnitSet = append(diffUnitSet, name)
+		}
+	}
+	for name := range newUnitSet {
+		if _, ok := oldUnitSet[name]; !ok {
+			diffUnitSet = append(diffUnitSet, name)
+		}
+	}
+	sort.Strings(diffUnitSet)
+
+	var stops, restarts []string
+	for _, name := range diffUnitSet {
+		if !ctrlcommon.InSlice(name, liveRestartUnits) {
+			glog.Infof("Unit %s changed and isn't live restartable, requires a reboot", name)
+			return []string{postConfigChangeActionReboot}
+		}
+		u, ok := newUnitSet[name]
+		if !ok || (u.Mask != nil && *u.Mask) || (u.Enabled != nil && !*u.Enabled) {
+			stops = append(stops, postConfigChangeActionStopPrefix+name)
+		} else {
+			restarts = append(restarts, postConfigChangeActionRestartPrefix+name)
+		}
 	}
+	if len(diffUnitSet) == 0 {
+		return nil
+	}
+	// stop the units before reloading systemd, so the removed ones are still loaded
+	actions := append(stops, postConfigChangeActionDaemonReload)
+	return append(actions, restarts...)
+}
 
+func calculatePostConfigChangeActionFromFileDiffs(oldIgnConfig, newIgnConfig ign3types.Config, rules []mcfgv1.PostConfigChangeActionRule) (actions []string) {
 	oldFileSet := make(map[string]ign3types.File)
 	for _, f := range oldIgnConfig.Storage.Files {
 		oldFileSet[f.Path] = f
@@ -467,17 +570,47 @@ func calculatePostConfigChangeActionFromFileDiffs(oldIgnConfig, newIgnConfig ign
 		}
 	}
 
+	// Directories and links are matched against the rules like files
+	oldNodeSet := make(map[string]interface{})
+	for _, d := range oldIgnConfig.Storage.Directories {
+		oldNodeSet[d.Path] = d
+	}
+	for _, l := range oldIgnConfig.Storage.Links {
+		oldNodeSet[l.Path] = l
+	}
+	newNodeSet := make(map[string]interface{})
+	for _, d := range newIgnConfig.Storage.Directories {
+		newNodeSet[d.Path] = d
+	}
+	for _, l := range newIgnConfig.Storage.Links {
+		newNodeSet[l.Path] = l
+	}
+	for path, oldNode := range oldNodeSet {
+		if newNode, ok := newNodeSet[path]; !ok || !reflect.DeepEqual(oldNode, newNode) {
+			glog.Infof("File diff: detected change to %v", path)
+			diffFileSet = append(diffFileSet, path)
+		}
+	}
+	for path := range newNodeSet {
+		if _, ok := oldNodeSet[path]; !ok {
+			glog.Infof("File diff: %v was added", path)
+			diffFileSet = append(diffFileSet, path)
+		}
+	}
+	sort.Strings(diffFileSet)
+
 	// Now calculate action
+	rules = append(append([]mcfgv1.PostConfigChangeActionRule{}, defaultPostConfigChangeActionRules...), rules...)
 	for _, k := range diffFileSet {
-		if ctrlcommon.InSlice(k, filesPostConfigChangeActionNone) {
-			continue
-		} else if ctrlcommon.InSlice(k, filesPostConfigChangeActionReloadCrio) {
-			actions = []string{postConfigChangeActionReloadCrio}
-			continue
-		} else {
+		action := postConfigChangeActionForFile(k, rules)
+		if action == postConfigChangeActionReboot {
+			glog.Infof("File %s requires a reboot", k)
 			actions = []string{postConfigChangeActionReboot}
 			break
 		}
+		if action != postConfigChangeActionNone && !ctrlcommon.InSlice(action, actions) {
+			actions = append(actions, action)
+		}
 	}
 
 	if len(actions) == 0 {
@@ -486,23 +619,29 @@ func calculatePostConfigChangeActionFromFileDiffs(oldIgnConfig, newIgnConfig ign
 	return
 }
 
-func calculatePostConfigChangeAction(oldConfig, newConfig *mcfgv1.MachineConfig) ([]string, error) {
+func calculatePostConfigChangeAction(root string, oldConfig, newConfig *mcfgv1.MachineConfig) ([]string, error) {
 	// If a machine-config-daemon-force file is present, it means the user wants to
 	// move to desired state without additional validation. We will reboot the node in
 	// this case regardless of what MachineConfig diff is.
-	if _, err := os.Stat(constants.MachineConfigDaemonForceFile); err == nil {
-		if err := os.Remove(constants.MachineConfigDaemonForceFile); err != nil {
+	forceFile := filepath.Join(root, constants.MachineConfigDaemonForceFile)
+	if _, err := os.Stat(forceFile); err == nil {
+		if err := os.Remove(forceFile); err != nil {
 			return []string{}, errors.Wrap(err, "failed to remove force validation file")
 		}
 		glog.Infof("Setting post config change action to postConfigChangeActionReboot; %s present", constants.MachineConfigDaemonForceFile)
 		return []string{postConfigChangeActionReboot}, nil
 	}
+	return calculatePostConfigChangeActionFromConfigs(oldConfig, newConfig)
+}
 
+// calculatePostConfigChangeActionFromConfigs returns the actions needed to apply the
+// new config, only looking at the configs.
+func calculatePostConfigChangeActionFromConfigs(oldConfig, newConfig *mcfgv1.MachineConfig) ([]string, error) {
 	diff, err := newMachineConfigDiff(oldConfig, newConfig)
 	if err != nil {
 		return []string{}, err
 	}
-	if diff.osUpdate || diff.kargs || diff.fips || diff.units || diff.kernelType || diff.extensions {
+	if diff.osUpdate || diff.kargs || diff.fips || diff.kernelType || diff.extensions {
 		// must reboot
 		return []string{postConfigChangeActionReboot}, nil
 	}
@@ -516,8 +655,28 @@ func calculatePostConfigChangeAction(oldConfig, newConfig *mcfgv1.MachineConfig)
 		return []string{}, err
 	}
 
-	// We don't actually have to consider ssh keys changes, which is the only section of passwd that is allowed to change
-	return calculatePostConfigChangeActionFromFileDiffs(oldIgnConfig, newIgnConfig), nil
+	var actions []string
+	if diff.units {
+		actions = calculatePostConfigChangeActionFromUnitDiffs(oldIgnConfig, newIgnConfig, newConfig.Spec.LiveRestartUnits)
+		if ctrlcommon.InSlice(postConfigChangeActionReboot, actions) {
+			return actions, nil
+		}
+	}
+
+	// We don't actually have to consider passwd changes, users, groups and ssh keys are updated in place
+	fileActions := calculatePostConfigChangeActionFromFileDiffs(oldIgnConfig, newIgnConfig, newConfig.Spec.PostConfigChangeActions)
+	if ctrlcommon.InSlice(postConfigChangeActionReboot, fileActions) {
+		return fileActions, nil
+	}
+	for _, action := range fileActions {
+		if action != postConfigChangeActionNone && !ctrlcommon.InSlice(action, actions) {
+			actions = append(actions, action)
+		}
+	}
+	if len(actions) == 0 {
+		actions = []string{postConfigChangeActionNone}
+	}
+	return actions, nil
 }
 
 // update the node to the provided node configuration.
@@ -566,13 +725,47 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig) (retErr err
 
 	dn.logSystem("Starting update from %s to %s: %+v", oldConfigName, newConfigName, diff)
 
-	actions, err := calculatePostConfigChangeAction(oldConfig, newConfig)
+	if diff.osUpdate || diff.extensions || diff.kernelType {
+		// start pulling the OS image while the rest of the update is prepared
+		dn.osImageStager.stage(newConfig.Spec.OSImageURL)
+	}
+
+	actions, err := calculatePostConfigChangeAction(dn.root, oldConfig, newConfig)
+	if err != nil {
+		return err
+	}
+
+	oldIgnConfig, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
+	if err != nil {
+		return fmt.Errorf("parsing old Ignition config failed with error: %v", err)
+	}
+	newIgnConfig, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
+	if err != nil {
+		return fmt.Errorf("parsing new Ignition config failed with error: %v", err)
+	}
+
+	// fetch the remote files before draining, so that failing to fetch them
+	// doesn't disrupt the workloads. The files we roll back to are read from disk.
+	newContents, err := fetchRemoteFiles(newIgnConfig.Storage.Files)
 	if err != nil {
 		return err
 	}
+	oldContents := readRemoteFiles(dn.root, oldIgnConfig.Storage.Files)
+
+	// wait for the OS image to be pulled and extracted before draining, so that
+	// slow registries don't add to the time the node is unschedulable.
+	if diff.osUpdate || diff.extensions || diff.kernelType {
+		glog.Infof("Waiting for OS image %s to be staged", newConfig.Spec.OSImageURL)
+		if _, err := dn.osImageStager.wait(newConfig.Spec.OSImageURL); err != nil {
+			return err
+		}
+	}
 
 	// Drain if we need to reboot or reload crio configuration
 	if ctrlcommon.InSlice(postConfigChangeActionReboot, actions) || ctrlcommon.InSlice(postConfigChangeActionReloadCrio, actions) {
+		if err := dn.runUpdateHooks(mcfgv1.UpdateHookPhaseBeforeDrain, oldConfig, newConfig); err != nil {
+			return err
+		}
 		if err := dn.performDrain(); err != nil {
 			return err
 		}
@@ -581,28 +774,19 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig) (retErr err
 	}
 
 	// update files on disk that need updating
-	if err := dn.updateFiles(oldConfig, newConfig); err != nil {
+	if err := dn.updateFiles(oldConfig, newConfig, newContents); err != nil {
 		return err
 	}
 
 	defer func() {
 		if retErr != nil {
-			if err := dn.updateFiles(newConfig, oldConfig); err != nil {
+			if err := dn.updateFiles(newConfig, oldConfig, oldContents); err != nil {
 				retErr = errors.Wrapf(retErr, "error rolling back files writes %v", err)
 				return
 			}
 		}
 	}()
 
-	oldIgnConfig, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
-	if err != nil {
-		return fmt.Errorf("parsing old Ignition config failed with error: %v", err)
-	}
-	newIgnConfig, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
-	if err != nil {
-		return fmt.Errorf("parsing new Ignition config failed with error: %v", err)
-	}
-
 	if err := dn.updateSSHKeys(newIgnConfig.Passwd.Users); err != nil {
 		return err
 	}
@@ -616,6 +800,19 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig) (retErr err
 		}
 	}()
 
+	if err := dn.updatePasswd(oldIgnConfig.Passwd, newIgnConfig.Passwd); err != nil {
+		return err
+	}
+
+	defer func() {
+		if retErr != nil {
+			if err := dn.updatePasswd(newIgnConfig.Passwd, oldIgnConfig.Passwd); err != nil {
+				retErr = errors.Wrapf(retErr, "error rolling back users and groups updates %v", err)
+				return
+			}
+		}
+	}()
+
 	if err := dn.storeCurrentConfigOnDisk(newConfig); err != nil {
 		return err
 	}
@@ -643,7 +840,7 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig) (retErr err
 
 	// Ideally we would want to update kernelArguments only via MachineConfigs.
 	// We are keeping this to maintain compatibility and OKD requirement.
-	tuningChanged, err := UpdateTuningArgs(KernelTuningFile, CmdLineFile)
+	tuningChanged, err := UpdateTuningArgs(dn.runner, dn.hostPath(KernelTuningFile), CmdLineFile)
 	if err != nil {
 		return err
 	}
@@ -651,6 +848,12 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig) (retErr err
 		glog.Info("Updated kernel tuning arguments")
 	}
 
+	if ctrlcommon.InSlice(postConfigChangeActionReboot, actions) {
+		if err := dn.runUpdateHooks(mcfgv1.UpdateHookPhaseBeforeReboot, oldConfig, newConfig); err != nil {
+			return err
+		}
+	}
+
 	if err := dn.finalizeBeforeReboot(newConfig); err != nil {
 		return err
 	}
@@ -663,14 +866,16 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig) (retErr err
 // and the MCO would just operate on that.  For now we're just doing this to get
 // improved logging.
 type machineConfigDiff struct {
-	osUpdate   bool
-	kargs      bool
-	fips       bool
-	passwd     bool
-	files      bool
-	units      bool
-	kernelType bool
-	extensions bool
+	osUpdate    bool
+	kargs       bool
+	fips        bool
+	passwd      bool
+	files       bool
+	directories bool
+	links       bool
+	units       bool
+	kernelType  bool
+	extensions  bool
 }
 
 // isEmpty returns true if the machineConfigDiff has no changes, or
@@ -723,14 +928,16 @@ func newMachineConfigDiff(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineC
 	extensionsEmpty := len(oldConfig.Spec.Extensions) == 0 && len(newConfig.Spec.Extensions) == 0
 
 	return &machineConfigDiff{
-		osUpdate:   oldConfig.Spec.OSImageURL != newConfig.Spec.OSImageURL,
-		kargs:      !(kargsEmpty || reflect.DeepEqual(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments)),
-		fips:       oldConfig.Spec.FIPS != newConfig.Spec.FIPS,
-		passwd:     !reflect.DeepEqual(oldIgn.Passwd, newIgn.Passwd),
-		files:      !reflect.DeepEqual(oldIgn.Storage.Files, newIgn.Storage.Files),
-		units:      !reflect.DeepEqual(oldIgn.Systemd.Units, newIgn.Systemd.Units),
-		kernelType: canonicalizeKernelType(oldConfig.Spec.KernelType) != canonicalizeKernelType(newConfig.Spec.KernelType),
-		extensions: !(extensionsEmpty || reflect.DeepEqual(oldConfig.Spec.Extensions, newConfig.Spec.Extensions)),
+		osUpdate:    oldConfig.Spec.OSImageURL != newConfig.Spec.OSImageURL,
+		kargs:       !(kargsEmpty || reflect.DeepEqual(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments)),
+		fips:        oldConfig.Spec.FIPS != newConfig.Spec.FIPS,
+		passwd:      !reflect.DeepEqual(oldIgn.Passwd, newIgn.Passwd),
+		files:       !reflect.DeepEqual(oldIgn.Storage.Files, newIgn.Storage.Files),
+		directories: !reflect.DeepEqual(oldIgn.Storage.Directories, newIgn.Storage.Directories),
+		links:       !reflect.DeepEqual(oldIgn.Storage.Links, newIgn.Storage.Links),
+		units:       !reflect.DeepEqual(oldIgn.Systemd.Units, newIgn.Systemd.Units),
+		kernelType:  canonicalizeKernelType(oldConfig.Spec.KernelType) != canonicalizeKernelType(newConfig.Spec.KernelType),
+		extensions:  !(extensionsEmpty || reflect.DeepEqual(oldConfig.Spec.Extensions, newConfig.Spec.Extensions)),
 	}, nil
 }
 
@@ -743,6 +950,12 @@ func newMachineConfigDiff(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineC
 // directories, links, and systemd units sections of the included ignition
 // config currently.
 func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDiff, error) {
+	return reconcilableWithFIPSCheck(oldConfig, newConfig, checkFIPS)
+}
+
+// reconcilableWithFIPSCheck is reconcilable, with the check of the FIPS flag against the
+// system swapped for fipsCheck.
+func reconcilableWithFIPSCheck(oldConfig, newConfig *mcfgv1.MachineConfig, fipsCheck func(current, desired *mcfgv1.MachineConfig) error) (*machineConfigDiff, error) {
 	// The parser will try to translate versions less than maxVersion to maxVersion, or output an err.
 	// The ignition output in case of success will always have maxVersion
 	oldIgn, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
@@ -761,36 +974,32 @@ func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDif
 
 	// Passwd section
 
-	// we don't currently configure Groups in place. we don't configure Users except
-	// for setting/updating SSHAuthorizedKeys for the only allowed user "core".
-	// otherwise we can't fix it if something changed here.
+	// we configure groups and users other than "core" in place, creating, updating
+	// and deleting them. For the "core" user we only allow setting/updating
+	// SSHAuthorizedKeys. otherwise we can't fix it if something changed here.
 	passwdChanged := !reflect.DeepEqual(oldIgn.Passwd, newIgn.Passwd)
 	if passwdChanged {
-		if !reflect.DeepEqual(oldIgn.Passwd.Groups, newIgn.Passwd.Groups) {
-			return nil, errors.New("ignition Passwd Groups section contains changes")
+		if hasCoreUser(oldIgn.Passwd.Users) && !hasCoreUser(newIgn.Passwd.Users) {
+			return nil, errors.New("ignition passwd user section contains unsupported changes: user core may not be deleted")
 		}
-		if !reflect.DeepEqual(oldIgn.Passwd.Users, newIgn.Passwd.Users) {
-			if len(oldIgn.Passwd.Users) > 0 && len(newIgn.Passwd.Users) == 0 {
-				return nil, errors.New("ignition passwd user section contains unsupported changes: user core may not be deleted")
-			}
-			// there is an update to Users, we must verify that it is ONLY making an acceptable
-			// change to the SSHAuthorizedKeys for the user "core"
-			for _, user := range newIgn.Passwd.Users {
-				if user.Name != coreUserName {
-					return nil, errors.New("ignition passwd user section contains unsupported changes: non-core user")
-				}
+		for _, user := range newIgn.Passwd.Users {
+			if user.Name != coreUserName {
+				continue
 			}
-			glog.Infof("user data to be verified before ssh update: %v", newIgn.Passwd.Users[len(newIgn.Passwd.Users)-1])
-			if err := verifyUserFields(newIgn.Passwd.Users[len(newIgn.Passwd.Users)-1]); err != nil {
+			glog.Infof("user data to be verified before ssh update: %v", user)
+			if err := verifyUserFields(user); err != nil {
 				return nil, err
 			}
 		}
+		if err := verifyPasswdFields(newIgn.Passwd); err != nil {
+			return nil, err
+		}
 	}
 
 	// Storage section
 
-	// we can only reconcile files right now. make sure the sections we can't
-	// fix aren't changed.
+	// we can only reconcile files, directories and links right now. make sure
+	// the sections we can't fix aren't changed.
 	if !reflect.DeepEqual(oldIgn.Storage.Disks, newIgn.Storage.Disks) {
 		return nil, errors.New("ignition disks section contains changes")
 	}
@@ -800,24 +1009,15 @@ func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDif
 	if !reflect.DeepEqual(oldIgn.Storage.Raid, newIgn.Storage.Raid) {
 		return nil, errors.New("ignition raid section contains changes")
 	}
-	if !reflect.DeepEqual(oldIgn.Storage.Directories, newIgn.Storage.Directories) {
-		return nil, errors.New("ignition directories section contains changes")
-	}
-	if !reflect.DeepEqual(oldIgn.Storage.Links, newIgn.Storage.Links) {
-		// This means links have been added, as opposed as being removed as it happened with
-		// https://bugzilla.redhat.com/show_bug.cgi?id=1677198. This doesn't really change behavior
-		// since we still don't support links but we allow old MC to remove links when upgrading.
-		if len(newIgn.Storage.Links) != 0 {
-			return nil, errors.New("ignition links section contains changes")
-		}
-	}
-
 	// Special case files append: if the new config wants us to append, then we
 	// have to force a reprovision since it's not idempotent
 	for _, f := range newIgn.Storage.Files {
 		if len(f.Append) > 0 {
 			return nil, fmt.Errorf("ignition file %v includes append", f.Path)
 		}
+		if err := verifyRemoteFile(f); err != nil {
+			return nil, err
+		}
 	}
 
 	// Systemd section
@@ -826,7 +1026,7 @@ func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDif
 
 	// FIPS section
 	// We do not allow update to FIPS for a running cluster, so any changes here will be an error
-	if err := checkFIPS(oldConfig, newConfig); err != nil {
+	if err := fipsCheck(oldConfig, newConfig); err != nil {
 		return nil, err
 	}
 
@@ -839,6 +1039,16 @@ func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDif
 	return mcDiff, nil
 }
 
+// hasCoreUser returns true if the "core" user is in the list of users.
+func hasCoreUser(users []ign3types.PasswdUser) bool {
+	for _, u := range users {
+		if u.Name == coreUserName {
+			return true
+		}
+	}
+	return false
+}
+
 // verifyUserFields returns nil if the user Name = "core", if 1 or more SSHKeys exist for
 // this user and if all other fields in User are empty.
 // Otherwise, an error will be returned and the proposed config will not be reconcilable.
@@ -984,7 +1194,7 @@ func (dn *Daemon) updateKernelArguments(oldConfig, newConfig *mcfgv1.MachineConf
 
 	args := append([]string{"kargs"}, kargs...)
 	dn.logSystem("Running rpm-ostree %v", args)
-	_, err := runGetOut("rpm-ostree", args...)
+	_, err := dn.runner.Run("rpm-ostree", args...)
 	return err
 }
 
@@ -1097,7 +1307,7 @@ func (dn *Daemon) applyExtensions(oldConfig, newConfig *mcfgv1.MachineConfig) er
 
 	args := dn.generateExtensionsArgs(oldConfig, newConfig)
 	glog.Infof("Applying extensions : %+q", args)
-	_, err := runGetOut("rpm-ostree", args...)
+	_, err := dn.runner.Run("rpm-ostree", args...)
 
 	return err
 }
@@ -1128,7 +1338,7 @@ func (dn *Daemon) switchKernel(oldConfig, newConfig *mcfgv1.MachineConfig) error
 			args = append(args, "--uninstall", pkg)
 		}
 		dn.logSystem("Switching to kernelType=%s, invoking rpm-ostree %+q", newConfig.Spec.KernelType, args)
-		_, err := runGetOut("rpm-ostree", args...)
+		_, err := dn.runner.Run("rpm-ostree", args...)
 		return err
 	}
 
@@ -1141,7 +1351,7 @@ func (dn *Daemon) switchKernel(oldConfig, newConfig *mcfgv1.MachineConfig) error
 		}
 
 		dn.logSystem("Switching to kernelType=%s, invoking rpm-ostree %+q", newConfig.Spec.KernelType, args)
-		_, err := runGetOut("rpm-ostree", args...)
+		_, err := dn.runner.Run("rpm-ostree", args...)
 		return err
 	}
 
@@ -1149,7 +1359,7 @@ func (dn *Daemon) switchKernel(oldConfig, newConfig *mcfgv1.MachineConfig) error
 		if oldConfig.Spec.OSImageURL != newConfig.Spec.OSImageURL {
 			args := []string{"update"}
 			dn.logSystem("Updating rt-kernel packages on host: %+q", args)
-			_, err := runGetOut("rpm-ostree", args...)
+			_, err := dn.runner.Run("rpm-ostree", args...)
 			return err
 		}
 	}
@@ -1157,8 +1367,8 @@ func (dn *Daemon) switchKernel(oldConfig, newConfig *mcfgv1.MachineConfig) error
 	return nil
 }
 
-// updateFiles writes files specified by the nodeconfig to disk. it also writes
-// systemd units. there is no support for multiple filesystems at this point.
+// updateFiles writes files, directories and links specified by the nodeconfig
+// to disk. it also writes systemd units. there is no support for multiple filesystems at this point.
 //
 // in addition to files, we also write systemd units to disk. we mask, enable,
 // and disable unit files when appropriate. this function relies on the system
@@ -1172,9 +1382,9 @@ func (dn *Daemon) switchKernel(oldConfig, newConfig *mcfgv1.MachineConfig) error
 //
 // in the future, this function should do any additional work to confirm that
 // whatever has been written is picked up by the appropriate daemons, if
-// required. in particular, a daemon-reload and restart for any unit files
-// touched.
-func (dn *Daemon) updateFiles(oldConfig, newConfig *mcfgv1.MachineConfig) error {
+// required. the daemon-reload and restart of the liveRestartUnits is done
+// by performPostConfigChangeAction.
+func (dn *Daemon) updateFiles(oldConfig, newConfig *mcfgv1.MachineConfig, fetched remoteContents) error {
 	glog.Info("Updating files")
 	oldIgnConfig, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
 	if err != nil {
@@ -1184,7 +1394,13 @@ func (dn *Daemon) updateFiles(oldConfig, newConfig *mcfgv1.MachineConfig) error
 	if err != nil {
 		return fmt.Errorf("failed to update files. Parsing new Ignition config failed with error: %v", err)
 	}
-	if err := dn.writeFiles(newIgnConfig.Storage.Files); err != nil {
+	if err := dn.writeDirectories(newIgnConfig.Storage.Directories); err != nil {
+		return err
+	}
+	if err := dn.writeFiles(newIgnConfig.Storage.Files, fetched); err != nil {
+		return err
+	}
+	if err := dn.writeLinks(newIgnConfig.Storage.Links); err != nil {
 		return err
 	}
 	if err := dn.writeUnits(newIgnConfig.Systemd.Units); err != nil {
@@ -1196,11 +1412,11 @@ func (dn *Daemon) updateFiles(oldConfig, newConfig *mcfgv1.MachineConfig) error
 	return nil
 }
 
-func restorePath(path string) error {
-	if out, err := exec.Command("cp", "-a", "--reflink=auto", origFileName(path), path).CombinedOutput(); err != nil {
-		return errors.Wrapf(err, "restoring %q from orig file %q: %s", path, origFileName(path), string(out))
+func (dn *Daemon) restorePath(path string) error {
+	if _, err := dn.runner.Run("cp", "-a", "--reflink=auto", dn.hostPath(origFileName(path)), dn.hostPath(path)); err != nil {
+		return errors.Wrapf(err, "restoring %q from orig file %q", path, origFileName(path))
 	}
-	if err := os.Remove(origFileName(path)); err != nil {
+	if err := os.Remove(dn.hostPath(origFileName(path))); err != nil {
 		return errors.Wrapf(err, "deleting orig file %q: %v", origFileName(path), err)
 	}
 	return nil
@@ -1249,6 +1465,38 @@ func (dn *Daemon) isPathInDropins(path string, systemd *ign3types.Systemd) bool
 //nolint:gocyclo
 func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig *ign3types.Config) error {
 	glog.Info("Deleting stale data")
+	newLinkSet := make(map[string]struct{})
+	for _, l := range newIgnConfig.Storage.Links {
+		newLinkSet[l.Path] = struct{}{}
+	}
+
+	for _, l := range oldIgnConfig.Storage.Links {
+		if _, ok := newLinkSet[l.Path]; ok {
+			continue
+		}
+		glog.V(2).Infof("Deleting stale link: %s", l.Path)
+		if err := os.Remove(dn.hostPath(l.Path)); err != nil {
+			newErr := fmt.Errorf("unable to delete %s: %s", l.Path, err)
+			if !os.IsNotExist(err) {
+				return newErr
+			}
+			// otherwise, just warn
+			glog.Warningf("%v", newErr)
+		}
+		if _, err := os.Stat(dn.hostPath(noOrigFileStampName(l.Path))); err == nil {
+			if err := os.Remove(dn.hostPath(noOrigFileStampName(l.Path))); err != nil {
+				return errors.Wrapf(err, "deleting noorig file stamp %q: %v", noOrigFileStampName(l.Path), err)
+			}
+		} else if _, err := os.Lstat(dn.hostPath(origFileName(l.Path))); err == nil {
+			if err := dn.restorePath(l.Path); err != nil {
+				return err
+			}
+			glog.V(2).Infof("Restored link %q", l.Path)
+			continue
+		}
+		glog.Infof("Removed stale link %q", l.Path)
+	}
+
 	newFileSet := make(map[string]struct{})
 	for _, f := range newIgnConfig.Storage.Files {
 		newFileSet[f.Path] = struct{}{}
@@ -1258,21 +1506,21 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig *ign3types.Config)
 		if _, ok := newFileSet[f.Path]; ok {
 			continue
 		}
-		if _, err := os.Stat(noOrigFileStampName(f.Path)); err == nil {
-			if err := os.Remove(noOrigFileStampName(f.Path)); err != nil {
+		if _, err := os.Stat(dn.hostPath(noOrigFileStampName(f.Path))); err == nil {
+			if err := os.Remove(dn.hostPath(noOrigFileStampName(f.Path))); err != nil {
 				return errors.Wrapf(err, "deleting noorig file stamp %q: %v", noOrigFileStampName(f.Path), err)
 			}
 			glog.V(2).Infof("Removing file %q completely", f.Path)
-		} else if _, err := os.Stat(origFileName(f.Path)); err == nil {
+		} else if _, err := os.Stat(dn.hostPath(origFileName(f.Path))); err == nil {
 			// Add a check for backwards compatibility: basically if the file doesn't exist in /usr/etc (on FCOS/RHCOS)
 			// and no rpm is claiming it, we assume that the orig file came from a wrongful backup of a MachineConfig
 			// file instead of a file originally on disk. See https://bugzilla.redhat.com/show_bug.cgi?id=1814397
 			var restore bool
-			if _, err := exec.Command("rpm", "-qf", f.Path).CombinedOutput(); err == nil {
+			if _, err := dn.runner.Run("rpm", "-qf", f.Path); err == nil {
 				// File is owned by an rpm
 				restore = true
 			} else if strings.HasPrefix(f.Path, "/etc") && dn.os.IsCoreOSVariant() {
-				if _, err := os.Stat("/usr" + f.Path); err != nil {
+				if _, err := os.Stat(dn.hostPath("/usr" + f.Path)); err != nil {
 					if !os.IsNotExist(err) {
 						return err
 					}
@@ -1284,14 +1532,14 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig *ign3types.Config)
 			}
 
 			if restore {
-				if err := restorePath(f.Path); err != nil {
+				if err := dn.restorePath(f.Path); err != nil {
 					return err
 				}
 				glog.V(2).Infof("Restored file %q", f.Path)
 				continue
 			}
 
-			if err := os.Remove(origFileName(f.Path)); err != nil {
+			if err := os.Remove(dn.hostPath(origFileName(f.Path))); err != nil {
 				return errors.Wrapf(err, "deleting orig file %q: %v", origFileName(f.Path), err)
 			}
 		}
@@ -1303,7 +1551,7 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig *ign3types.Config)
 		}
 
 		glog.V(2).Infof("Deleting stale config file: %s", f.Path)
-		if err := os.Remove(f.Path); err != nil {
+		if err := os.Remove(dn.hostPath(f.Path)); err != nil {
 			newErr := fmt.Errorf("unable to delete %s: %s", f.Path, err)
 			if !os.IsNotExist(err) {
 				return newErr
@@ -1329,20 +1577,20 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig *ign3types.Config)
 		for j := range u.Dropins {
 			path := filepath.Join(pathSystemd, u.Name+".d", u.Dropins[j].Name)
 			if _, ok := newDropinSet[path]; !ok {
-				if _, err := os.Stat(noOrigFileStampName(path)); err == nil {
-					if err := os.Remove(noOrigFileStampName(path)); err != nil {
+				if _, err := os.Stat(dn.hostPath(noOrigFileStampName(path))); err == nil {
+					if err := os.Remove(dn.hostPath(noOrigFileStampName(path))); err != nil {
 						return errors.Wrapf(err, "deleting noorig file stamp %q: %v", noOrigFileStampName(path), err)
 					}
 					glog.V(2).Infof("Removing file %q completely", path)
-				} else if _, err := os.Stat(origFileName(path)); err == nil {
-					if err := restorePath(path); err != nil {
+				} else if _, err := os.Stat(dn.hostPath(origFileName(path))); err == nil {
+					if err := dn.restorePath(path); err != nil {
 						return err
 					}
 					glog.V(2).Infof("Restored file %q", path)
 					continue
 				}
 				glog.V(2).Infof("Deleting stale systemd dropin file: %s", path)
-				if err := os.Remove(path); err != nil {
+				if err := os.Remove(dn.hostPath(path)); err != nil {
 					newErr := fmt.Errorf("unable to delete %s: %s", path, err)
 					if !os.IsNotExist(err) {
 						return newErr
@@ -1362,20 +1610,20 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig *ign3types.Config)
 			if err := dn.presetUnit(u); err != nil {
 				glog.Infof("Did not restore preset for %s (may not exist): %s", u.Name, err)
 			}
-			if _, err := os.Stat(noOrigFileStampName(path)); err == nil {
-				if err := os.Remove(noOrigFileStampName(path)); err != nil {
+			if _, err := os.Stat(dn.hostPath(noOrigFileStampName(path))); err == nil {
+				if err := os.Remove(dn.hostPath(noOrigFileStampName(path))); err != nil {
 					return errors.Wrapf(err, "deleting noorig file stamp %q: %v", noOrigFileStampName(path), err)
 				}
 				glog.V(2).Infof("Removing file %q completely", path)
-			} else if _, err := os.Stat(origFileName(path)); err == nil {
-				if err := restorePath(path); err != nil {
+			} else if _, err := os.Stat(dn.hostPath(origFileName(path))); err == nil {
+				if err := dn.restorePath(path); err != nil {
 					return err
 				}
 				glog.V(2).Infof("Restored file %q", path)
 				continue
 			}
 			glog.V(2).Infof("Deleting stale systemd unit file: %s", path)
-			if err := os.Remove(path); err != nil {
+			if err := os.Remove(dn.hostPath(path)); err != nil {
 				newErr := fmt.Errorf("unable to delete %s: %s", path, err)
 				if !os.IsNotExist(err) {
 					return newErr
@@ -1387,16 +1635,51 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig *ign3types.Config)
 		}
 	}
 
+	newDirectorySet := make(map[string]struct{})
+	for _, d := range newIgnConfig.Storage.Directories {
+		newDirectorySet[d.Path] = struct{}{}
+	}
+	// remove the subdirectories before their parents
+	staleDirectories := []string{}
+	for _, d := range oldIgnConfig.Storage.Directories {
+		if _, ok := newDirectorySet[d.Path]; !ok {
+			staleDirectories = append(staleDirectories, d.Path)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(staleDirectories)))
+	for _, path := range staleDirectories {
+		if _, err := os.Stat(dn.hostPath(origFileName(path))); err == nil {
+			if err := dn.restoreDirectory(path); err != nil {
+				return err
+			}
+			glog.V(2).Infof("Restored directory %q", path)
+			continue
+		}
+		if _, err := os.Stat(dn.hostPath(noOrigFileStampName(path))); err == nil {
+			if err := os.Remove(dn.hostPath(noOrigFileStampName(path))); err != nil {
+				return errors.Wrapf(err, "deleting noorig file stamp %q: %v", noOrigFileStampName(path), err)
+			}
+		}
+		glog.V(2).Infof("Deleting stale directory: %s", path)
+		// only empty directories are removed, we don't know who wrote their contents
+		if err := os.Remove(dn.hostPath(path)); err != nil {
+			if !os.IsNotExist(err) {
+				glog.Warningf("Not removing stale directory %s: %v", path, err)
+			}
+			continue
+		}
+		glog.Infof("Removed stale directory %q", path)
+	}
+
 	return nil
 }
 
 // enableUnits enables a set of systemd units via systemctl, if any fail all fails.
 func (dn *Daemon) enableUnits(units []string) error {
-	args := append([]string{"enable"}, units...)
-	stdouterr, err := exec.Command("systemctl", args...).CombinedOutput()
+	err := dn.systemd.EnableUnits(units)
 	if err != nil {
 		if !dn.os.IsLikeTraditionalRHEL7() {
-			return fmt.Errorf("error enabling units: %s", stdouterr)
+			return fmt.Errorf("error enabling units: %v", err)
 		}
 		// In RHEL7, the systemd version is too low, so it is unable to handle broken
 		// symlinks during enable. Do a best-effort removal of potentially broken
@@ -1405,29 +1688,28 @@ func (dn *Daemon) enableUnits(units []string) error {
 		wantsPathSystemd := "/etc/systemd/system/multi-user.target.wants/"
 		for _, unit := range units {
 			unitLinkPath := filepath.Join(wantsPathSystemd, unit)
-			fi, fiErr := os.Lstat(unitLinkPath)
+			fi, fiErr := os.Lstat(dn.hostPath(unitLinkPath))
 			if fiErr != nil {
 				if !os.IsNotExist(fiErr) {
-					return fmt.Errorf("error trying to enable unit, fallback failed with %s (original error %s)",
-						fiErr, stdouterr)
+					return fmt.Errorf("error trying to enable unit, fallback failed with %s (original error %v)",
+						fiErr, err)
 				}
 				continue
 			}
 			if fi.Mode()&os.ModeSymlink == 0 {
-				return fmt.Errorf("error trying to enable unit, a non-symlink file exists at %s (original error %s)",
-					unitLinkPath, stdouterr)
+				return fmt.Errorf("error trying to enable unit, a non-symlink file exists at %s (original error %v)",
+					unitLinkPath, err)
 			}
-			if _, evalErr := filepath.EvalSymlinks(unitLinkPath); evalErr != nil {
+			if _, evalErr := filepath.EvalSymlinks(dn.hostPath(unitLinkPath)); evalErr != nil {
 				// this is a broken symlink, remove
-				if rmErr := os.Remove(unitLinkPath); rmErr != nil {
-					return fmt.Errorf("error trying to enable unit, cannot remove broken symlink: %s (original error %s)",
-						rmErr, stdouterr)
+				if rmErr := os.Remove(dn.hostPath(unitLinkPath)); rmErr != nil {
+					return fmt.Errorf("error trying to enable unit, cannot remove broken symlink: %s (original error %v)",
+						rmErr, err)
 				}
 			}
 		}
-		stdouterr, err := exec.Command("systemctl", args...).CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("error enabling units: %s", stdouterr)
+		if err := dn.systemd.EnableUnits(units); err != nil {
+			return fmt.Errorf("error enabling units: %v", err)
 		}
 	}
 	glog.Infof("Enabled systemd units: %v", units)
@@ -1436,10 +1718,8 @@ func (dn *Daemon) enableUnits(units []string) error {
 
 // disableUnits disables a set of systemd units via systemctl, if any fail all fails.
 func (dn *Daemon) disableUnits(units []string) error {
-	args := append([]string{"disable"}, units...)
-	stdouterr, err := exec.Command("systemctl", args...).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("error disabling unit: %s", stdouterr)
+	if err := dn.systemd.DisableUnits(units); err != nil {
+		return fmt.Errorf("error disabling unit: %v", err)
 	}
 	glog.Infof("Disabled systemd units %v", units)
 	return nil
@@ -1447,10 +1727,8 @@ func (dn *Daemon) disableUnits(units []string) error {
 
 // presetUnit resets a systemd unit to its preset via systemctl
 func (dn *Daemon) presetUnit(unit ign3types.Unit) error {
-	args := []string{"preset", unit.Name}
-	stdouterr, err := exec.Command("systemctl", args...).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("error running preset on unit: %s", stdouterr)
+	if err := dn.systemd.PresetUnits([]string{unit.Name}); err != nil {
+		return fmt.Errorf("error running preset on unit: %v", err)
 	}
 	glog.Infof("Preset systemd unit %s", unit.Name)
 	return nil
@@ -1466,27 +1744,27 @@ func (dn *Daemon) writeUnits(units []ign3types.Unit) error {
 			dpath := filepath.Join(pathSystemd, u.Name+".d", u.Dropins[i].Name)
 			if u.Dropins[i].Contents == nil || *u.Dropins[i].Contents == "" {
 				glog.Infof("Dropin for %s has no content, skipping write", u.Dropins[i].Name)
-				if _, err := os.Stat(dpath); err != nil {
+				if _, err := os.Stat(dn.hostPath(dpath)); err != nil {
 					if os.IsNotExist(err) {
 						continue
 					}
 					return err
 				}
 				glog.Infof("Removing %q, updated file has zero length", dpath)
-				if err := os.Remove(dpath); err != nil {
+				if err := os.Remove(dn.hostPath(dpath)); err != nil {
 					return err
 				}
 				continue
 			}
 
 			glog.Infof("Writing systemd unit dropin %q", u.Dropins[i].Name)
-			if _, err := os.Stat("/usr" + dpath); err == nil &&
+			if _, err := os.Stat(dn.hostPath("/usr" + dpath)); err == nil &&
 				dn.os.IsCoreOSVariant() {
-				if err := createOrigFile("/usr"+dpath, dpath); err != nil {
+				if err := dn.createOrigFile("/usr"+dpath, dpath); err != nil {
 					return err
 				}
 			}
-			if err := writeFileAtomicallyWithDefaults(dpath, []byte(*u.Dropins[i].Contents)); err != nil {
+			if err := dn.writeFileAtomicallyWithDefaults(dpath, []byte(*u.Dropins[i].Contents)); err != nil {
 				return fmt.Errorf("failed to write systemd unit dropin %q: %v", u.Dropins[i].Name, err)
 			}
 
@@ -1499,12 +1777,12 @@ func (dn *Daemon) writeUnits(units []ign3types.Unit) error {
 		// /dev/null and continue
 		if u.Mask != nil && *u.Mask {
 			glog.V(2).Info("Systemd unit masked")
-			if err := os.RemoveAll(fpath); err != nil {
+			if err := os.RemoveAll(dn.hostPath(fpath)); err != nil {
 				return fmt.Errorf("failed to remove unit %q: %v", u.Name, err)
 			}
 			glog.V(2).Infof("Removed unit %q", u.Name)
 
-			if err := renameio.Symlink(pathDevNull, fpath); err != nil {
+			if err := renameio.Symlink(pathDevNull, dn.hostPath(fpath)); err != nil {
 				return fmt.Errorf("failed to symlink unit %q to %s: %v", u.Name, pathDevNull, err)
 			}
 			glog.V(2).Infof("Created symlink unit %q to %s", u.Name, pathDevNull)
@@ -1514,14 +1792,14 @@ func (dn *Daemon) writeUnits(units []ign3types.Unit) error {
 
 		if u.Contents != nil && *u.Contents != "" {
 			glog.Infof("Writing systemd unit %q", u.Name)
-			if _, err := os.Stat("/usr" + fpath); err == nil &&
+			if _, err := os.Stat(dn.hostPath("/usr" + fpath)); err == nil &&
 				dn.os.IsCoreOSVariant() {
-				if err := createOrigFile("/usr"+fpath, fpath); err != nil {
+				if err := dn.createOrigFile("/usr"+fpath, fpath); err != nil {
 					return err
 				}
 			}
 			// write the unit to disk
-			if err := writeFileAtomicallyWithDefaults(fpath, []byte(*u.Contents)); err != nil {
+			if err := dn.writeFileAtomicallyWithDefaults(fpath, []byte(*u.Contents)); err != nil {
 				return fmt.Errorf("failed to write systemd unit %q: %v", u.Name, err)
 			}
 
@@ -1569,8 +1847,9 @@ func (dn *Daemon) writeUnits(units []ign3types.Unit) error {
 }
 
 // writeFiles writes the given files to disk.
-// it doesn't fetch remote files and expects a flattened config file.
-func (dn *Daemon) writeFiles(files []ign3types.File) error {
+// it doesn't fetch remote files, their contents must be in fetched,
+// and expects a flattened config file.
+func (dn *Daemon) writeFiles(files []ign3types.File, fetched remoteContents) error {
 	for _, file := range files {
 		glog.Infof("Writing file %q", file.Path)
 
@@ -1580,14 +1859,9 @@ func (dn *Daemon) writeFiles(files []ign3types.File) error {
 			return fmt.Errorf("found an append section when writing files. Append is not supported")
 		}
 
-		// To allow writing of "empty" files we'll allow source to be nil
-		contents := &dataurl.DataURL{}
-		if file.Contents.Source != nil {
-			var err error
-			contents, err = dataurl.DecodeString(*file.Contents.Source)
-			if err != nil {
-				return err
-			}
+		contents, err := decodeFileContents(file, fetched)
+		if err != nil {
+			return err
 		}
 		mode := defaultFilePermissions
 		if file.Mode != nil {
@@ -1595,20 +1869,85 @@ func (dn *Daemon) writeFiles(files []ign3types.File) error {
 		}
 
 		// set chown if file information is provided
-		uid, gid, err := getFileOwnership(file)
+		uid, gid, err := getFileOwnership(file.Node)
 		if err != nil {
 			return fmt.Errorf("failed to retrieve file ownership for file %q: %v", file.Path, err)
 		}
-		if err := createOrigFile(file.Path, file.Path); err != nil {
+		if err := dn.createOrigFile(file.Path, file.Path); err != nil {
 			return err
 		}
-		if err := writeFileAtomically(file.Path, contents.Data, defaultDirectoryPermissions, mode, uid, gid); err != nil {
+		if err := dn.writeFileAtomically(file.Path, contents, defaultDirectoryPermissions, mode, uid, gid); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// writeDirectories creates the given directories, and sets their mode and ownership.
+func (dn *Daemon) writeDirectories(dirs []ign3types.Directory) error {
+	for _, dir := range dirs {
+		glog.Infof("Writing directory %q", dir.Path)
+
+		mode := defaultDirectoryPermissions
+		if dir.Mode != nil {
+			mode = os.FileMode(*dir.Mode)
+		}
+		uid, gid, err := getFileOwnership(dir.Node)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve directory ownership for directory %q: %v", dir.Path, err)
+		}
+		if err := dn.createOrigDirectory(dir.Path); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dn.hostPath(dir.Path), mode); err != nil {
+			return fmt.Errorf("failed to create directory %q: %v", dir.Path, err)
+		}
+		// MkdirAll doesn't update existing directories and is subject to the umask
+		if err := os.Chmod(dn.hostPath(dir.Path), mode); err != nil {
+			return fmt.Errorf("failed to set mode of directory %q: %v", dir.Path, err)
+		}
+		if err := os.Chown(dn.hostPath(dir.Path), uid, gid); err != nil {
+			return fmt.Errorf("failed to set ownership of directory %q: %v", dir.Path, err)
+		}
+	}
+	return nil
+}
+
+// writeLinks creates the given symbolic and hard links, replacing what was at their path.
+func (dn *Daemon) writeLinks(links []ign3types.Link) error {
+	for _, link := range links {
+		glog.Infof("Writing link %q to %q", link.Path, link.Target)
+
+		uid, gid, err := getFileOwnership(link.Node)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve link ownership for link %q: %v", link.Path, err)
+		}
+		if err := dn.createOrigFile(link.Path, link.Path); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dn.hostPath(filepath.Dir(link.Path)), defaultDirectoryPermissions); err != nil {
+			return fmt.Errorf("failed to create directory %q: %v", filepath.Dir(link.Path), err)
+		}
+		if link.Hard != nil && *link.Hard {
+			if err := os.Remove(dn.hostPath(link.Path)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %q: %v", link.Path, err)
+			}
+			if err := os.Link(dn.hostPath(link.Target), dn.hostPath(link.Path)); err != nil {
+				return fmt.Errorf("failed to create hard link %q: %v", link.Path, err)
+			}
+			// the ownership of a hard link is the one of its target
+			continue
+		}
+		if err := renameio.Symlink(link.Target, dn.hostPath(link.Path)); err != nil {
+			return fmt.Errorf("failed to create symlink %q: %v", link.Path, err)
+		}
+		if err := os.Lchown(dn.hostPath(link.Path), uid, gid); err != nil {
+			return fmt.Errorf("failed to set ownership of symlink %q: %v", link.Path, err)
+		}
+	}
+	return nil
+}
+
 func origParentDir() string {
 	return filepath.Join("/etc", "machine-config-daemon", "orig")
 }
@@ -1632,35 +1971,89 @@ func noOrigFileStampName(fpath string) string {
 	return filepath.Join(noOrigParentDir(), fpath+".mcdnoorig")
 }
 
-func createOrigFile(fromPath, fpath string) error {
-	if _, err := os.Stat(noOrigFileStampName(fpath)); err == nil {
+// createNoOrigFileStamp creates a noorig file that tells the MCD that the file wasn't present on disk before MCD
+// took over so it can just remove it when deleting stale data, as opposed as restoring a file
+// that was shipped _with_ the underlying OS (e.g. a default chrony config).
+func (dn *Daemon) createNoOrigFileStamp(fpath string) error {
+	if err := os.MkdirAll(dn.hostPath(filepath.Dir(noOrigFileStampName(fpath))), 0755); err != nil {
+		return errors.Wrapf(err, "creating no orig parent dir: %v", err)
+	}
+	return dn.writeFileAtomicallyWithDefaults(noOrigFileStampName(fpath), nil)
+}
+
+func (dn *Daemon) createOrigFile(fromPath, fpath string) error {
+	if _, err := os.Stat(dn.hostPath(noOrigFileStampName(fpath))); err == nil {
 		// we already created the no orig file for this default file
 		return nil
 	}
-	if _, err := os.Stat(fpath); os.IsNotExist(err) {
-		// create a noorig file that tells the MCD that the file wasn't present on disk before MCD
-		// took over so it can just remove it when deleting stale data, as opposed as restoring a file
-		// that was shipped _with_ the underlying OS (e.g. a default chrony config).
-		if err := os.MkdirAll(filepath.Dir(noOrigFileStampName(fpath)), 0755); err != nil {
-			return errors.Wrapf(err, "creating no orig parent dir: %v", err)
-		}
-		return writeFileAtomicallyWithDefaults(noOrigFileStampName(fpath), nil)
+	if _, err := os.Lstat(dn.hostPath(fpath)); os.IsNotExist(err) {
+		return dn.createNoOrigFileStamp(fpath)
 	}
-	if _, err := os.Stat(origFileName(fpath)); err == nil {
+	// the orig file of a link can point to a path which doesn't exist
+	if _, err := os.Lstat(dn.hostPath(origFileName(fpath))); err == nil {
 		// the orig file is already there and we avoid creating a new one to preserve the real default
 		return nil
 	}
-	if err := os.MkdirAll(filepath.Dir(origFileName(fpath)), 0755); err != nil {
+	if err := os.MkdirAll(dn.hostPath(filepath.Dir(origFileName(fpath))), 0755); err != nil {
 		return errors.Wrapf(err, "creating orig parent dir: %v", err)
 	}
-	if out, err := exec.Command("cp", "-a", "--reflink=auto", fromPath, origFileName(fpath)).CombinedOutput(); err != nil {
-		return errors.Wrapf(err, "creating orig file for %q: %s", fpath, string(out))
+	if _, err := dn.runner.Run("cp", "-a", "--reflink=auto", dn.hostPath(fromPath), dn.hostPath(origFileName(fpath))); err != nil {
+		return errors.Wrapf(err, "creating orig file for %q", fpath)
+	}
+	return nil
+}
+
+// createOrigDirectory is the createOrigFile of directories. As the MCD doesn't manage
+// the contents of directories, the orig directory is empty and only keeps the mode
+// and ownership of the directory shipped with the OS.
+func (dn *Daemon) createOrigDirectory(fpath string) error {
+	if _, err := os.Stat(dn.hostPath(noOrigFileStampName(fpath))); err == nil {
+		// we already created the no orig file for this default directory
+		return nil
+	}
+	fi, err := os.Stat(dn.hostPath(fpath))
+	if os.IsNotExist(err) {
+		return dn.createNoOrigFileStamp(fpath)
+	} else if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dn.hostPath(origFileName(fpath))); err == nil {
+		// the orig directory is already there and we avoid creating a new one to preserve the real default
+		return nil
+	}
+	if err := os.MkdirAll(dn.hostPath(origFileName(fpath)), 0755); err != nil {
+		return errors.Wrapf(err, "creating orig directory for %q: %v", fpath, err)
+	}
+	return dn.copyModeAndOwnership(fi, origFileName(fpath))
+}
+
+// restoreDirectory restores the mode and ownership of a directory from its orig directory.
+func (dn *Daemon) restoreDirectory(fpath string) error {
+	fi, err := os.Stat(dn.hostPath(origFileName(fpath)))
+	if err != nil {
+		return errors.Wrapf(err, "reading orig directory for %q", fpath)
+	}
+	if err := dn.copyModeAndOwnership(fi, fpath); err != nil {
+		return errors.Wrapf(err, "restoring %q from orig directory %q", fpath, origFileName(fpath))
+	}
+	if err := os.Remove(dn.hostPath(origFileName(fpath))); err != nil {
+		return errors.Wrapf(err, "deleting orig directory %q: %v", origFileName(fpath), err)
+	}
+	return nil
+}
+
+func (dn *Daemon) copyModeAndOwnership(fi os.FileInfo, fpath string) error {
+	if err := os.Chmod(dn.hostPath(fpath), fi.Mode()); err != nil {
+		return err
+	}
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return os.Chown(dn.hostPath(fpath), int(stat.Uid), int(stat.Gid))
 	}
 	return nil
 }
 
 // This is essentially ResolveNodeUidAndGid() from Ignition; XXX should dedupe
-func getFileOwnership(file ign3types.File) (int, int, error) {
+func getFileOwnership(file ign3types.Node) (int, int, error) {
 	uid, gid := 0, 0 // default to root
 	if file.User.ID != nil {
 		uid = *file.User.ID
@@ -1693,7 +2086,7 @@ func (dn *Daemon) atomicallyWriteSSHKey(keys string) error {
 	// Once Users are supported fully this should be writing to PasswdUser.HomeDir
 	glog.Infof("Writing SSHKeys at %q", authKeyPath)
 
-	if err := writeFileAtomicallyWithDefaults(authKeyPath, []byte(keys)); err != nil {
+	if err := dn.writeFileAtomicallyWithDefaults(authKeyPath, []byte(keys)); err != nil {
 		return err
 	}
 
@@ -1708,11 +2101,13 @@ func (dn *Daemon) updateSSHKeys(newUsers []ign3types.PasswdUser) error {
 		return nil
 	}
 
-	// we're also appending all keys for any user to core, so for now
-	// we pass this to atomicallyWriteSSHKeys to write.
-	// we know these users are "core" ones also cause this slice went through Reconcilable
+	// the keys of the other users are written to their own home directory
+	// by updatePasswd, so we only pass the keys of "core" to atomicallyWriteSSHKeys.
 	var concatSSHKeys string
 	for _, u := range newUsers {
+		if u.Name != coreUserName {
+			continue
+		}
 		for _, k := range u.SSHAuthorizedKeys {
 			concatSSHKeys = concatSSHKeys + string(k) + "\n"
 		}
@@ -1739,124 +2134,13 @@ func (dn *Daemon) updateOS(config *mcfgv1.MachineConfig, osImageContentDir strin
 	}
 
 	glog.Infof("Updating OS to %s", newURL)
-	client := NewNodeUpdaterClient()
-	if _, err := client.Rebase(newURL, osImageContentDir); err != nil {
+	if _, err := dn.NodeUpdaterClient.Rebase(newURL, osImageContentDir); err != nil {
 		return fmt.Errorf("failed to update OS to %s : %v", newURL, err)
 	}
 
 	return nil
 }
 
-func (dn *Daemon) getPendingStateLegacyLogger() (*journalMsg, error) {
-	glog.Info("logger doesn't support --jounald, grepping the journal")
-
-	cmdLiteral := "journalctl -o cat _UID=0 | grep -v audit | grep OPENSHIFT_MACHINE_CONFIG_DAEMON_LEGACY_LOG_HACK"
-	cmd := exec.Command("bash", "-c", cmdLiteral)
-	var combinedOutput bytes.Buffer
-	cmd.Stdout = &combinedOutput
-	cmd.Stderr = &combinedOutput
-	if err := cmd.Start(); err != nil {
-		return nil, errors.Wrap(err, "failed shelling out to journalctl -o cat")
-	}
-	if err := cmd.Wait(); err != nil {
-		if exiterr, ok := err.(*exec.ExitError); ok {
-			// The program has exited with an exit code != 0
-			status, ok := exiterr.Sys().(syscall.WaitStatus)
-			if ok {
-				// grep exit with 1 if it doesn't find anything
-				// from man: Normally, the exit status is 0 if selected lines are found and 1 otherwise. But the exit status is 2 if an error occurred
-				if status.ExitStatus() == 1 {
-					return nil, nil
-				}
-				if status.ExitStatus() > 1 {
-					return nil, errors.Wrapf(fmt.Errorf("grep exited with %s", combinedOutput.Bytes()), "failed to grep on journal output: %v", exiterr)
-				}
-			}
-		} else {
-			return nil, errors.Wrap(err, "command wait error")
-		}
-	}
-	journalOutput := combinedOutput.Bytes()
-	// just an extra safety check?
-	if len(journalOutput) == 0 {
-		return nil, nil
-	}
-	return dn.processJournalOutput(journalOutput)
-}
-
-type journalMsg struct {
-	Message   string `json:"MESSAGE,omitempty"`
-	BootID    string `json:"BOOT_ID,omitempty"`
-	Pending   string `json:"PENDING,omitempty"`
-	OldLogger string `json:"OPENSHIFT_MACHINE_CONFIG_DAEMON_LEGACY_LOG_HACK,omitempty"` // unused today
-}
-
-func (dn *Daemon) processJournalOutput(journalOutput []byte) (*journalMsg, error) {
-	lines := strings.Split(strings.TrimSpace(string(journalOutput)), "\n")
-	last := lines[len(lines)-1]
-
-	entry := &journalMsg{}
-	if err := json.Unmarshal([]byte(last), entry); err != nil {
-		return nil, errors.Wrap(err, "getting pending state from journal")
-	}
-	if entry.Pending == "0" {
-		return nil, nil
-	}
-	return entry, nil
-}
-
-// getPendingState loads the JSON state we cache across attempting to apply
-// a config+reboot.  If no pending state is available, ("", nil) will be returned.
-// The bootID is stored in the pending state; if it is unchanged, we assume
-// that we failed to reboot; that for now should be a fatal error, in order to avoid
-// reboot loops.
-func (dn *Daemon) getPendingState() (*journalMsg, error) {
-	if !dn.loggerSupportsJournal {
-		return dn.getPendingStateLegacyLogger()
-	}
-	journalOutput, err := exec.Command("journalctl", "-o", "json", "_UID=0", fmt.Sprintf("MESSAGE_ID=%s", pendingStateMessageID)).CombinedOutput()
-	if err != nil {
-		return nil, errors.Wrap(err, "error running journalctl -o json")
-	}
-	if len(journalOutput) == 0 {
-		return nil, nil
-	}
-	return dn.processJournalOutput(journalOutput)
-}
-
-func (dn *Daemon) storePendingStateLegacyLogger(pending *mcfgv1.MachineConfig, isPending int) ([]byte, error) {
-	glog.Info("logger doesn't support --jounald, logging json directly")
-
-	if isPending == 1 {
-		if err := dn.writePendingConfig(pending); err != nil {
-			return nil, err
-		}
-	} else {
-		if err := os.Remove(pendingConfigPath); err != nil {
-			return nil, err
-		}
-	}
-
-	oldLogger := exec.Command("logger", fmt.Sprintf(`{"MESSAGE": "%s", "BOOT_ID": "%s", "PENDING": "%d", "OPENSHIFT_MACHINE_CONFIG_DAEMON_LEGACY_LOG_HACK": "1"}`, pending.GetName(), dn.bootID, isPending))
-	return oldLogger.CombinedOutput()
-}
-
-func (dn *Daemon) storePendingState(pending *mcfgv1.MachineConfig, isPending int) ([]byte, error) {
-	if !dn.loggerSupportsJournal {
-		return dn.storePendingStateLegacyLogger(pending, isPending)
-	}
-	logger := exec.Command("logger", "--journald")
-
-	var pendingState bytes.Buffer
-	pendingState.WriteString(fmt.Sprintf(`MESSAGE_ID=%s
-MESSAGE=%s
-BOOT_ID=%s
-PENDING=%d`, pendingStateMessageID, pending.GetName(), dn.bootID, isPending))
-
-	logger.Stdin = &pendingState
-	return logger.CombinedOutput()
-}
-
 // Log a message to the systemd journal as well as our stdout
 func (dn *Daemon) logSystem(format string, a ...interface{}) {
 	message := fmt.Sprintf(format, a...)
@@ -1865,13 +2149,10 @@ func (dn *Daemon) logSystem(format string, a ...interface{}) {
 	// we can just talk to the journald socket.  Doing this as a
 	// subprocess rather than talking to journald in process since
 	// I worry about the golang library having a connection pre-chroot.
-	logger := exec.Command("logger")
-
 	var log bytes.Buffer
 	log.WriteString(fmt.Sprintf("machine-config-daemon[%d]: %s", os.Getpid(), message))
 
-	logger.Stdin = &log
-	if err := logger.Run(); err != nil {
+	if _, err := dn.runner.RunWithStdin(&log, "logger"); err != nil {
 		glog.Errorf("failed to invoke logger: %v", err)
 	}
 }
@@ -1911,20 +2192,18 @@ func (dn *Daemon) reboot(rationale string) error {
 	}
 	dn.logSystem("initiating reboot: %s", rationale)
 
-	rebootCmd := rebootCommand(rationale)
-
 	// reboot, executed async via systemd-run so that the reboot command is executed
 	// in the context of the host asynchronously from us
 	// We're not returning the error from the reboot command as it can be terminated by
 	// the system itself with signal: terminated. We can't catch the subprocess termination signal
 	// either, we just have one for the MCD itself.
-	if err := rebootCmd.Run(); err != nil {
+	if _, err := dn.runner.Run("systemd-run", rebootCommand(rationale)...); err != nil {
 		dn.logSystem("failed to run reboot: %v", err)
 		MCDRebootErr.WithLabelValues(dn.node.Name, "failed to run reboot", err.Error()).SetToCurrentTime()
 	}
 
 	// wait to be killed via SIGTERM from the kubelet shutting down
-	time.Sleep(defaultRebootTimeout)
+	dn.waitForReboot()
 
 	// if everything went well, this should be unreachable.
 	MCDRebootErr.WithLabelValues(dn.node.Name, "reboot failed", "this error should be unreachable, something is seriously wrong").SetToCurrentTime()
@@ -2,8 +2,11 @@ package daemon
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"os"
 	"reflect"
+	"syscall"
 	"testing"
 	"time"
 
@@ -12,6 +15,7 @@ import (
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/test/helpers"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/vincent-petithory/dataurl"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
@@ -221,6 +225,156 @@ func TestReconcilableDiff(t *testing.T) {
 	assert.Equal(t, diff.files, false)
 }
 
+func TestReconcilableDirectoriesAndLinks(t *testing.T) {
+	mode := 0750
+	oldIgnCfg := ctrlcommon.NewIgnConfig()
+	oldConfig := helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
+	newIgnCfg := ctrlcommon.NewIgnConfig()
+	newIgnCfg.Storage.Directories = []ign3types.Directory{{
+		Node:               ign3types.Node{Path: "/etc/foo.d", User: ign3types.NodeUser{Name: helpers.StrToPtr("core")}},
+		DirectoryEmbedded1: ign3types.DirectoryEmbedded1{Mode: &mode},
+	}}
+	newIgnCfg.Storage.Links = []ign3types.Link{{
+		Node:          ign3types.Node{Path: "/etc/foo.d/bar.conf"},
+		LinkEmbedded1: ign3types.LinkEmbedded1{Target: "/etc/bar.conf"},
+	}}
+	newConfig := helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+
+	diff, err := reconcilable(oldConfig, newConfig)
+	checkReconcilableResults(t, "add directories and links", err)
+	assert.True(t, diff.directories)
+	assert.True(t, diff.links)
+	assert.False(t, diff.files)
+
+	// directories and links are applied like files: they reboot unless a rule matches
+	actions, err := calculatePostConfigChangeAction("/", oldConfig, newConfig)
+	require.Nil(t, err)
+	assert.Equal(t, []string{postConfigChangeActionReboot}, actions)
+	newConfig.Spec.PostConfigChangeActions = []mcfgv1.PostConfigChangeActionRule{
+		{Paths: []string{"/etc/foo.d", "/etc/foo.d/*"}, Action: mcfgv1.PostConfigChangeActionNone},
+	}
+	actions, err = calculatePostConfigChangeAction("/", oldConfig, newConfig)
+	require.Nil(t, err)
+	assert.Equal(t, []string{postConfigChangeActionNone}, actions)
+
+	diff, err = reconcilable(newConfig, oldConfig)
+	checkReconcilableResults(t, "remove directories and links", err)
+	assert.True(t, diff.directories)
+	assert.True(t, diff.links)
+}
+
+func TestWriteDirectoriesAndLinks(t *testing.T) {
+	root, err := ioutil.TempDir("", "directories-and-links")
+	require.Nil(t, err)
+	defer os.RemoveAll(root)
+	dn := &Daemon{root: root, runner: execRunner{}}
+
+	// only root can give the paths to another user
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		uid, gid = 1000, 1000
+	}
+	owner := func(path string) (int, int) {
+		fi, err := os.Lstat(dn.hostPath(path))
+		require.Nil(t, err)
+		stat := fi.Sys().(*syscall.Stat_t)
+		return int(stat.Uid), int(stat.Gid)
+	}
+	mode := func(path string) os.FileMode {
+		fi, err := os.Stat(dn.hostPath(path))
+		require.Nil(t, err)
+		return fi.Mode()
+	}
+	exists := func(path string) bool {
+		_, err := os.Lstat(dn.hostPath(path))
+		return err == nil
+	}
+	readlink := func(path string) string {
+		target, err := os.Readlink(dn.hostPath(path))
+		require.Nil(t, err)
+		return target
+	}
+	node := func(path string) ign3types.Node {
+		return ign3types.Node{Path: path, User: ign3types.NodeUser{ID: &uid}, Group: ign3types.NodeGroup{ID: &gid}}
+	}
+
+	// the OS ships a directory and a link
+	require.Nil(t, os.MkdirAll(dn.hostPath("/etc/os.d"), 0755))
+	require.Nil(t, os.Chmod(dn.hostPath("/etc/os.d"), 0755))
+	require.Nil(t, os.MkdirAll(dn.hostPath("/etc/alternatives"), 0755))
+	require.Nil(t, os.Symlink("/usr/libexec/os/editor", dn.hostPath("/etc/alternatives/editor")))
+	require.Nil(t, ioutil.WriteFile(dn.hostPath("/etc/bar.conf"), []byte("bar\n"), 0644))
+
+	dirMode := 0750
+	osDirMode := 0700
+	dirs := []ign3types.Directory{
+		{Node: node("/etc/foo.d"), DirectoryEmbedded1: ign3types.DirectoryEmbedded1{Mode: &dirMode}},
+		{Node: node("/etc/os.d"), DirectoryEmbedded1: ign3types.DirectoryEmbedded1{Mode: &osDirMode}},
+	}
+	links := []ign3types.Link{
+		{Node: node("/etc/foo.d/bar.conf"), LinkEmbedded1: ign3types.LinkEmbedded1{Target: "/etc/bar.conf"}},
+		{Node: node("/etc/alternatives/editor"), LinkEmbedded1: ign3types.LinkEmbedded1{Target: "/usr/libexec/mcd/editor"}},
+		{Node: node("/etc/hard.conf"), LinkEmbedded1: ign3types.LinkEmbedded1{Target: "/etc/bar.conf", Hard: helpers.BoolToPtr(true)}},
+	}
+	// writing them again keeps what the OS shipped
+	for i := 0; i < 2; i++ {
+		require.Nil(t, dn.writeDirectories(dirs))
+		require.Nil(t, dn.writeLinks(links))
+	}
+
+	// the directories are created or updated with their mode and owner
+	assert.True(t, mode("/etc/foo.d").IsDir())
+	assert.Equal(t, os.FileMode(0750), mode("/etc/foo.d").Perm())
+	assert.Equal(t, os.FileMode(0700), mode("/etc/os.d").Perm())
+	for _, path := range []string{"/etc/foo.d", "/etc/os.d"} {
+		u, g := owner(path)
+		assert.Equal(t, uid, u, path)
+		assert.Equal(t, gid, g, path)
+	}
+	assert.True(t, exists(noOrigFileStampName("/etc/foo.d")))
+	assert.Equal(t, os.FileMode(0755), mode(origFileName("/etc/os.d")).Perm())
+
+	// the symlinks are created or replaced with their owner
+	assert.Equal(t, "/etc/bar.conf", readlink("/etc/foo.d/bar.conf"))
+	assert.Equal(t, "/usr/libexec/mcd/editor", readlink("/etc/alternatives/editor"))
+	for _, path := range []string{"/etc/foo.d/bar.conf", "/etc/alternatives/editor"} {
+		u, g := owner(path)
+		assert.Equal(t, uid, u, path)
+		assert.Equal(t, gid, g, path)
+	}
+	assert.True(t, exists(noOrigFileStampName("/etc/foo.d/bar.conf")))
+	assert.Equal(t, "/usr/libexec/os/editor", readlink(origFileName("/etc/alternatives/editor")))
+
+	// the hard links share the file of their target
+	hard, err := os.Stat(dn.hostPath("/etc/hard.conf"))
+	require.Nil(t, err)
+	target, err := os.Stat(dn.hostPath("/etc/bar.conf"))
+	require.Nil(t, err)
+	assert.True(t, os.SameFile(hard, target))
+
+	// removing them from the config restores what the OS shipped, and deletes the rest
+	oldIgnConfig := ctrlcommon.NewIgnConfig()
+	oldIgnConfig.Storage.Directories = dirs
+	oldIgnConfig.Storage.Links = links
+	newIgnConfig := ctrlcommon.NewIgnConfig()
+	require.Nil(t, dn.deleteStaleData(&oldIgnConfig, &newIgnConfig))
+
+	assert.False(t, exists("/etc/foo.d/bar.conf"))
+	assert.False(t, exists(noOrigFileStampName("/etc/foo.d/bar.conf")))
+	assert.False(t, exists("/etc/hard.conf"))
+	assert.True(t, exists("/etc/bar.conf"))
+	assert.Equal(t, "/usr/libexec/os/editor", readlink("/etc/alternatives/editor"))
+	assert.False(t, exists(origFileName("/etc/alternatives/editor")))
+
+	assert.False(t, exists("/etc/foo.d"))
+	assert.False(t, exists(noOrigFileStampName("/etc/foo.d")))
+	assert.Equal(t, os.FileMode(0755), mode("/etc/os.d").Perm())
+	u, g := owner("/etc/os.d")
+	assert.Equal(t, os.Getuid(), u)
+	assert.Equal(t, os.Getgid(), g)
+	assert.False(t, exists(origFileName("/etc/os.d")))
+}
+
 func TestKernelAguments(t *testing.T) {
 	tests := []struct {
 		oldKargs []string
@@ -293,7 +447,7 @@ func TestReconcilableSSH(t *testing.T) {
 	_, errMsg := reconcilable(oldMcfg, newMcfg)
 	checkReconcilableResults(t, "SSH", errMsg)
 
-	// 	Check that updating User with User that is not core is not supported
+	// Check that replacing the core User with another User is not supported
 	tempUser2 := ign3types.PasswdUser{Name: "core", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"1234"}}
 	oldIgnCfg.Passwd.Users = append(oldIgnCfg.Passwd.Users, tempUser2)
 	oldMcfg = helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
@@ -310,7 +464,7 @@ func TestReconcilableSSH(t *testing.T) {
 	_, errMsg = reconcilable(oldMcfg, newMcfg)
 	checkIrreconcilableResults(t, "SSH", errMsg)
 
-	// check that we cannot add a user or have len(Passwd.Users)> 1
+	// check that adding a user doesn't allow changing other fields of core
 	tempUser5 := ign3types.PasswdUser{Name: "some user", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"5678"}}
 	newIgnCfg.Passwd.Users = append(newIgnCfg.Passwd.Users, tempUser5)
 	newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
@@ -332,6 +486,77 @@ func TestReconcilableSSH(t *testing.T) {
 	checkIrreconcilableResults(t, "SSH", errMsg)
 }
 
+func TestReconcilablePasswd(t *testing.T) {
+	coreUser := ign3types.PasswdUser{Name: "core", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"1234"}}
+	oldIgnCfg := ctrlcommon.NewIgnConfig()
+	oldIgnCfg.Passwd.Users = []ign3types.PasswdUser{coreUser}
+	oldMcfg := helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
+
+	tests := []struct {
+		name         string
+		users        []ign3types.PasswdUser
+		groups       []ign3types.PasswdGroup
+		reconcilable bool
+	}{
+		{
+			name: "add a user and a group",
+			users: []ign3types.PasswdUser{coreUser, {
+				Name:              "admin",
+				Groups:            []ign3types.Group{"wheel", "ops"},
+				HomeDir:           helpers.StrToPtr("/var/home/admin"),
+				SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"5678"},
+			}},
+			groups:       []ign3types.PasswdGroup{{Name: "ops"}},
+			reconcilable: true,
+		},
+		{
+			name:         "remove core",
+			users:        []ign3types.PasswdUser{{Name: "admin"}},
+			reconcilable: false,
+		},
+		{
+			name:         "manage root",
+			users:        []ign3types.PasswdUser{coreUser, {Name: "root"}},
+			reconcilable: false,
+		},
+		{
+			name:         "invalid user name",
+			users:        []ign3types.PasswdUser{coreUser, {Name: "some user"}},
+			reconcilable: false,
+		},
+		{
+			name:         "relative home directory",
+			users:        []ign3types.PasswdUser{coreUser, {Name: "admin", HomeDir: helpers.StrToPtr("admin")}},
+			reconcilable: false,
+		},
+		{
+			name:         "invalid group name",
+			users:        []ign3types.PasswdUser{coreUser},
+			groups:       []ign3types.PasswdGroup{{Name: "Ops"}},
+			reconcilable: false,
+		},
+		{
+			name:         "shouldExist false",
+			users:        []ign3types.PasswdUser{coreUser, {Name: "admin", ShouldExist: helpers.BoolToPtr(false)}},
+			reconcilable: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newIgnCfg := ctrlcommon.NewIgnConfig()
+			newIgnCfg.Passwd.Users = test.users
+			newIgnCfg.Passwd.Groups = test.groups
+			newMcfg := helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+			_, err := reconcilable(oldMcfg, newMcfg)
+			if test.reconcilable {
+				checkReconcilableResults(t, test.name, err)
+			} else {
+				checkIrreconcilableResults(t, test.name, err)
+			}
+		})
+	}
+}
+
 func TestUpdateSSHKeys(t *testing.T) {
 	// testClient is the NodeUpdaterClient mock instance that will front
 	// calls to update the host.
@@ -625,7 +850,7 @@ func TestCalculatePostConfigChangeAction(t *testing.T) {
 
 	for idx, test := range tests {
 		t.Run(fmt.Sprintf("case#%d", idx), func(t *testing.T) {
-			calculatedAction, err := calculatePostConfigChangeAction(test.oldConfig, test.newConfig)
+			calculatedAction, err := calculatePostConfigChangeAction("/", test.oldConfig, test.newConfig)
 
 			if !reflect.DeepEqual(test.expectedAction, calculatedAction) {
 				t.Errorf("Failed calculating config change action: expected: %v but result is: %v. Error: %v", test.expectedAction, calculatedAction, err)
@@ -634,6 +859,160 @@ func TestCalculatePostConfigChangeAction(t *testing.T) {
 	}
 }
 
+func TestCalculatePostConfigChangeActionRules(t *testing.T) {
+	newFile := func(path, contents string) ign3types.File {
+		return ign3types.File{
+			Node: ign3types.Node{Path: path},
+			FileEmbedded1: ign3types.FileEmbedded1{
+				Contents: ign3types.Resource{Source: helpers.StrToPtr(dataurl.EncodeBytes([]byte(contents)))},
+			},
+		}
+	}
+	newIgn := func(files ...ign3types.File) ign3types.Config {
+		ign := ctrlcommon.NewIgnConfig()
+		ign.Storage.Files = files
+		return ign
+	}
+	rules := []mcfgv1.PostConfigChangeActionRule{
+		{Paths: []string{"/etc/chrony.conf"}, Action: mcfgv1.PostConfigChangeActionRestart, Unit: "chronyd.service"},
+		{Paths: []string{"/etc/rsyslog.d/*.conf"}, Action: mcfgv1.PostConfigChangeActionReload, Unit: "rsyslog.service"},
+		{Paths: []string{"/etc/rsyslog.d/10-reboot.conf"}, Action: mcfgv1.PostConfigChangeActionReboot},
+		{Paths: []string{"/etc/containers/registries.conf"}, Action: mcfgv1.PostConfigChangeActionNone},
+	}
+
+	tests := []struct {
+		name           string
+		oldFiles       []ign3types.File
+		newFiles       []ign3types.File
+		expectedAction []string
+	}{
+		{
+			name:           "restart",
+			oldFiles:       []ign3types.File{newFile("/etc/chrony.conf", "1")},
+			newFiles:       []ign3types.File{newFile("/etc/chrony.conf", "2")},
+			expectedAction: []string{"restart chronyd.service"},
+		},
+		{
+			name:           "glob",
+			newFiles:       []ign3types.File{newFile("/etc/rsyslog.d/remote.conf", "1"), newFile("/etc/rsyslog.d/local.conf", "1")},
+			expectedAction: []string{"reload rsyslog.service"},
+		},
+		{
+			name:           "multiple units",
+			oldFiles:       []ign3types.File{newFile("/etc/chrony.conf", "1"), newFile("/etc/rsyslog.d/remote.conf", "1")},
+			expectedAction: []string{"restart chronyd.service", "reload rsyslog.service"},
+		},
+		{
+			name:           "last rule wins",
+			newFiles:       []ign3types.File{newFile("/etc/rsyslog.d/10-reboot.conf", "1")},
+			expectedAction: []string{postConfigChangeActionReboot},
+		},
+		{
+			name:           "overrides default",
+			oldFiles:       []ign3types.File{newFile("/etc/containers/registries.conf", "1")},
+			newFiles:       []ign3types.File{newFile("/etc/containers/registries.conf", "2")},
+			expectedAction: []string{postConfigChangeActionNone},
+		},
+		{
+			name:           "unmatched file reboots",
+			newFiles:       []ign3types.File{newFile("/etc/chrony.conf", "1"), newFile("/etc/random-reboot-file", "1")},
+			expectedAction: []string{postConfigChangeActionReboot},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actions := calculatePostConfigChangeActionFromFileDiffs(newIgn(test.oldFiles...), newIgn(test.newFiles...), rules)
+			assert.Equal(t, test.expectedAction, actions)
+		})
+	}
+
+	// the rules of the new config are used
+	oldConfig := helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{newFile("/etc/chrony.conf", "1")})
+	newConfig := helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{newFile("/etc/chrony.conf", "2")})
+	actions, err := calculatePostConfigChangeAction("/", oldConfig, newConfig)
+	require.Nil(t, err)
+	assert.Equal(t, []string{postConfigChangeActionReboot}, actions)
+	newConfig.Spec.PostConfigChangeActions = rules
+	actions, err = calculatePostConfigChangeAction("/", oldConfig, newConfig)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"restart chronyd.service"}, actions)
+}
+
+func TestCalculatePostConfigChangeActionLiveRestartUnits(t *testing.T) {
+	newUnit := func(name, contents string, enabled bool) ign3types.Unit {
+		return ign3types.Unit{Name: name, Contents: helpers.StrToPtr(contents), Enabled: &enabled}
+	}
+	newConfig := func(name string, liveRestartUnits []string, files []ign3types.File, units ...ign3types.Unit) *mcfgv1.MachineConfig {
+		mc := helpers.NewMachineConfigExtended(name, nil, files, units, []ign3types.SSHAuthorizedKey{}, []string{}, false, []string{}, "default", "dummy://")
+		mc.Spec.LiveRestartUnits = liveRestartUnits
+		return mc
+	}
+	live := []string{"foo.service", "bar.service"}
+	pullSecret := ign3types.File{
+		Node: ign3types.Node{Path: "/var/lib/kubelet/config.json"},
+		FileEmbedded1: ign3types.FileEmbedded1{
+			Contents: ign3types.Resource{Source: helpers.StrToPtr(dataurl.EncodeBytes([]byte("kubelet conf\n")))},
+		},
+	}
+	registries := ign3types.File{
+		Node: ign3types.Node{Path: "/etc/containers/registries.conf"},
+		FileEmbedded1: ign3types.FileEmbedded1{
+			Contents: ign3types.Resource{Source: helpers.StrToPtr(dataurl.EncodeBytes([]byte("registries\n")))},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		oldConfig      *mcfgv1.MachineConfig
+		newConfig      *mcfgv1.MachineConfig
+		expectedAction []string
+	}{
+		{
+			name:           "unit not live restartable",
+			oldConfig:      newConfig("00-test", nil, nil, newUnit("foo.service", "1", true)),
+			newConfig:      newConfig("01-test", nil, nil, newUnit("foo.service", "2", true)),
+			expectedAction: []string{postConfigChangeActionReboot},
+		},
+		{
+			name:           "restart",
+			oldConfig:      newConfig("00-test", live, nil, newUnit("foo.service", "1", true)),
+			newConfig:      newConfig("01-test", live, nil, newUnit("foo.service", "2", true)),
+			expectedAction: []string{postConfigChangeActionDaemonReload, "restart foo.service"},
+		},
+		{
+			name:           "added and removed",
+			oldConfig:      newConfig("00-test", live, nil, newUnit("foo.service", "1", true)),
+			newConfig:      newConfig("01-test", live, nil, newUnit("bar.service", "1", true)),
+			expectedAction: []string{"stop foo.service", postConfigChangeActionDaemonReload, "restart bar.service"},
+		},
+		{
+			name:           "disabled",
+			oldConfig:      newConfig("00-test", live, nil, newUnit("foo.service", "1", true)),
+			newConfig:      newConfig("01-test", live, nil, newUnit("foo.service", "1", false)),
+			expectedAction: []string{"stop foo.service", postConfigChangeActionDaemonReload},
+		},
+		{
+			name:           "with another unit",
+			oldConfig:      newConfig("00-test", live, nil, newUnit("foo.service", "1", true), newUnit("baz.service", "1", true)),
+			newConfig:      newConfig("01-test", live, nil, newUnit("foo.service", "2", true), newUnit("baz.service", "2", true)),
+			expectedAction: []string{postConfigChangeActionReboot},
+		},
+		{
+			name:           "with file changes",
+			oldConfig:      newConfig("00-test", live, nil, newUnit("foo.service", "1", true)),
+			newConfig:      newConfig("01-test", live, []ign3types.File{pullSecret, registries}, newUnit("foo.service", "2", true)),
+			expectedAction: []string{postConfigChangeActionDaemonReload, "restart foo.service", postConfigChangeActionReloadCrio},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actions, err := calculatePostConfigChangeAction("/", test.oldConfig, test.newConfig)
+			require.Nil(t, err)
+			assert.Equal(t, test.expectedAction, actions)
+		})
+	}
+}
+
 // checkReconcilableResults is a shortcut for verifying results that should be reconcilable
 func checkReconcilableResults(t *testing.T, key string, reconcilableError error) {
 	if reconcilableError != nil {
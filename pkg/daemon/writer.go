@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/golang/glog"
 	"github.com/openshift/machine-config-operator/internal"
@@ -19,6 +20,8 @@ const (
 	machineConfigDaemonSSHAccessAnnotationKey = "machineconfiguration.openshift.io/ssh"
 	// MachineConfigDaemonSSHAccessValue is the annotation value applied when ssh access is detected
 	machineConfigDaemonSSHAccessValue = "accessed"
+	// machineConfigDaemonDriftedPathsAnnotationKey lists the paths which drifted from the current config
+	machineConfigDaemonDriftedPathsAnnotationKey = "machineconfiguration.openshift.io/driftedPaths"
 )
 
 // message wraps a client and responseChannel
@@ -43,6 +46,7 @@ type NodeWriter interface {
 	SetUnreconcilable(err error, client corev1client.NodeInterface, lister corev1lister.NodeLister, node string) error
 	SetDegraded(err error, client corev1client.NodeInterface, lister corev1lister.NodeLister, node string) error
 	SetSSHAccessed(client corev1client.NodeInterface, lister corev1lister.NodeLister, node string) error
+	SetDrifted(paths []string, client corev1client.NodeInterface, lister corev1lister.NodeLister, node string) error
 }
 
 // newNodeWriter Create a new NodeWriter
@@ -173,6 +177,25 @@ func (nw *clusterNodeWriter) SetSSHAccessed(client corev1client.NodeInterface, l
 	return <-respChan
 }
 
+// SetDrifted sets the drifted paths annotation to the comma separated paths, clearing it if there are none.
+func (nw *clusterNodeWriter) SetDrifted(paths []string, client corev1client.NodeInterface, lister corev1lister.NodeLister, node string) error {
+	// truncatedPaths caps the list at a reasonable length to limit the risk of hitting the total
+	// annotation size limit (256 kb) at any point
+	truncatedPaths := fmt.Sprintf("%.2000s", strings.Join(paths, ","))
+	annos := map[string]string{
+		machineConfigDaemonDriftedPathsAnnotationKey: truncatedPaths,
+	}
+	respChan := make(chan error, 1)
+	nw.writer <- message{
+		client:          client,
+		lister:          lister,
+		node:            node,
+		annos:           annos,
+		responseChannel: respChan,
+	}
+	return <-respChan
+}
+
 func setNodeAnnotations(client corev1client.NodeInterface, lister corev1lister.NodeLister, nodeName string, m map[string]string) (*corev1.Node, error) {
 	node, err := internal.UpdateNodeRetry(client, lister, nodeName, func(node *corev1.Node) {
 		for k, v := range m {
@@ -0,0 +1,117 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	machineconfigurationopenshiftiov1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeMachineConfigDisruptionBudgets implements MachineConfigDisruptionBudgetInterface
+type FakeMachineConfigDisruptionBudgets struct {
+	Fake *FakeMachineconfigurationV1
+}
+
+var machineconfigdisruptionbudgetsResource = schema.GroupVersionResource{Group: "machineconfiguration.openshift.io", Version: "v1", Resource: "machineconfigdisruptionbudgets"}
+
+var machineconfigdisruptionbudgetsKind = schema.GroupVersionKind{Group: "machineconfiguration.openshift.io", Version: "v1", Kind: "MachineConfigDisruptionBudget"}
+
+// Get takes name of the machineConfigDisruptionBudget, and returns the corresponding machineConfigDisruptionBudget object, and an error if there is any.
+func (c *FakeMachineConfigDisruptionBudgets) Get(ctx context.Context, name string, options v1.GetOptions) (result *machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(machineconfigdisruptionbudgetsResource, name), &machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget), err
+}
+
+// List takes label and field selectors, and returns the list of MachineConfigDisruptionBudgets that match those selectors.
+func (c *FakeMachineConfigDisruptionBudgets) List(ctx context.Context, opts v1.ListOptions) (result *machineconfigurationopenshiftiov1.MachineConfigDisruptionBudgetList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(machineconfigdisruptionbudgetsResource, machineconfigdisruptionbudgetsKind, opts), &machineconfigurationopenshiftiov1.MachineConfigDisruptionBudgetList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &machineconfigurationopenshiftiov1.MachineConfigDisruptionBudgetList{ListMeta: obj.(*machineconfigurationopenshiftiov1.MachineConfigDisruptionBudgetList).ListMeta}
+	for _, item := range obj.(*machineconfigurationopenshiftiov1.MachineConfigDisruptionBudgetList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested machineConfigDisruptionBudgets.
+func (c *FakeMachineConfigDisruptionBudgets) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(machineconfigdisruptionbudgetsResource, opts))
+}
+
+// Create takes the representation of a machineConfigDisruptionBudget and creates it.  Returns the server's representation of the machineConfigDisruptionBudget, and an error, if there is any.
+func (c *FakeMachineConfigDisruptionBudgets) Create(ctx context.Context, machineConfigDisruptionBudget *machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget, opts v1.CreateOptions) (result *machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(machineconfigdisruptionbudgetsResource, machineConfigDisruptionBudget), &machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget), err
+}
+
+// Update takes the representation of a machineConfigDisruptionBudget and updates it. Returns the server's representation of the machineConfigDisruptionBudget, and an error, if there is any.
+func (c *FakeMachineConfigDisruptionBudgets) Update(ctx context.Context, machineConfigDisruptionBudget *machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget, opts v1.UpdateOptions) (result *machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(machineconfigdisruptionbudgetsResource, machineConfigDisruptionBudget), &machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeMachineConfigDisruptionBudgets) UpdateStatus(ctx context.Context, machineConfigDisruptionBudget *machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget, opts v1.UpdateOptions) (*machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceAction(machineconfigdisruptionbudgetsResource, "status", machineConfigDisruptionBudget), &machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget), err
+}
+
+// Delete takes name of the machineConfigDisruptionBudget and deletes it. Returns an error if one occurs.
+func (c *FakeMachineConfigDisruptionBudgets) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(machineconfigdisruptionbudgetsResource, name), &machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeMachineConfigDisruptionBudgets) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(machineconfigdisruptionbudgetsResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &machineconfigurationopenshiftiov1.MachineConfigDisruptionBudgetList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched machineConfigDisruptionBudget.
+func (c *FakeMachineConfigDisruptionBudgets) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(machineconfigdisruptionbudgetsResource, name, pt, data, subresources...), &machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*machineconfigurationopenshiftiov1.MachineConfigDisruptionBudget), err
+}
@@ -28,6 +28,10 @@ func (c *FakeMachineconfigurationV1) MachineConfigs() v1.MachineConfigInterface
 	return &FakeMachineConfigs{c}
 }
 
+func (c *FakeMachineconfigurationV1) MachineConfigDisruptionBudgets() v1.MachineConfigDisruptionBudgetInterface {
+	return &FakeMachineConfigDisruptionBudgets{c}
+}
+
 func (c *FakeMachineconfigurationV1) MachineConfigPools() v1.MachineConfigPoolInterface {
 	return &FakeMachineConfigPools{c}
 }
@@ -10,4 +10,6 @@ type KubeletConfigExpansion interface{}
 
 type MachineConfigExpansion interface{}
 
+type MachineConfigDisruptionBudgetExpansion interface{}
+
 type MachineConfigPoolExpansion interface{}
@@ -0,0 +1,168 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	scheme "github.com/openshift/machine-config-operator/pkg/generated/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// MachineConfigDisruptionBudgetsGetter has a method to return a MachineConfigDisruptionBudgetInterface.
+// A group's client should implement this interface.
+type MachineConfigDisruptionBudgetsGetter interface {
+	MachineConfigDisruptionBudgets() MachineConfigDisruptionBudgetInterface
+}
+
+// MachineConfigDisruptionBudgetInterface has methods to work with MachineConfigDisruptionBudget resources.
+type MachineConfigDisruptionBudgetInterface interface {
+	Create(ctx context.Context, machineConfigDisruptionBudget *v1.MachineConfigDisruptionBudget, opts metav1.CreateOptions) (*v1.MachineConfigDisruptionBudget, error)
+	Update(ctx context.Context, machineConfigDisruptionBudget *v1.MachineConfigDisruptionBudget, opts metav1.UpdateOptions) (*v1.MachineConfigDisruptionBudget, error)
+	UpdateStatus(ctx context.Context, machineConfigDisruptionBudget *v1.MachineConfigDisruptionBudget, opts metav1.UpdateOptions) (*v1.MachineConfigDisruptionBudget, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.MachineConfigDisruptionBudget, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.MachineConfigDisruptionBudgetList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.MachineConfigDisruptionBudget, err error)
+	MachineConfigDisruptionBudgetExpansion
+}
+
+// machineConfigDisruptionBudgets implements MachineConfigDisruptionBudgetInterface
+type machineConfigDisruptionBudgets struct {
+	client rest.Interface
+}
+
+// newMachineConfigDisruptionBudgets returns a MachineConfigDisruptionBudgets
+func newMachineConfigDisruptionBudgets(c *MachineconfigurationV1Client) *machineConfigDisruptionBudgets {
+	return &machineConfigDisruptionBudgets{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the machineConfigDisruptionBudget, and returns the corresponding machineConfigDisruptionBudget object, and an error if there is any.
+func (c *machineConfigDisruptionBudgets) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.MachineConfigDisruptionBudget, err error) {
+	result = &v1.MachineConfigDisruptionBudget{}
+	err = c.client.Get().
+		Resource("machineconfigdisruptionbudgets").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of MachineConfigDisruptionBudgets that match those selectors.
+func (c *machineConfigDisruptionBudgets) List(ctx context.Context, opts metav1.ListOptions) (result *v1.MachineConfigDisruptionBudgetList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.MachineConfigDisruptionBudgetList{}
+	err = c.client.Get().
+		Resource("machineconfigdisruptionbudgets").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested machineConfigDisruptionBudgets.
+func (c *machineConfigDisruptionBudgets) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("machineconfigdisruptionbudgets").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a machineConfigDisruptionBudget and creates it.  Returns the server's representation of the machineConfigDisruptionBudget, and an error, if there is any.
+func (c *machineConfigDisruptionBudgets) Create(ctx context.Context, machineConfigDisruptionBudget *v1.MachineConfigDisruptionBudget, opts metav1.CreateOptions) (result *v1.MachineConfigDisruptionBudget, err error) {
+	result = &v1.MachineConfigDisruptionBudget{}
+	err = c.client.Post().
+		Resource("machineconfigdisruptionbudgets").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(machineConfigDisruptionBudget).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a machineConfigDisruptionBudget and updates it. Returns the server's representation of the machineConfigDisruptionBudget, and an error, if there is any.
+func (c *machineConfigDisruptionBudgets) Update(ctx context.Context, machineConfigDisruptionBudget *v1.MachineConfigDisruptionBudget, opts metav1.UpdateOptions) (result *v1.MachineConfigDisruptionBudget, err error) {
+	result = &v1.MachineConfigDisruptionBudget{}
+	err = c.client.Put().
+		Resource("machineconfigdisruptionbudgets").
+		Name(machineConfigDisruptionBudget.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(machineConfigDisruptionBudget).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *machineConfigDisruptionBudgets) UpdateStatus(ctx context.Context, machineConfigDisruptionBudget *v1.MachineConfigDisruptionBudget, opts metav1.UpdateOptions) (result *v1.MachineConfigDisruptionBudget, err error) {
+	result = &v1.MachineConfigDisruptionBudget{}
+	err = c.client.Put().
+		Resource("machineconfigdisruptionbudgets").
+		Name(machineConfigDisruptionBudget.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(machineConfigDisruptionBudget).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the machineConfigDisruptionBudget and deletes it. Returns an error if one occurs.
+func (c *machineConfigDisruptionBudgets) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("machineconfigdisruptionbudgets").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *machineConfigDisruptionBudgets) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("machineconfigdisruptionbudgets").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched machineConfigDisruptionBudget.
+func (c *machineConfigDisruptionBudgets) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.MachineConfigDisruptionBudget, err error) {
+	result = &v1.MachineConfigDisruptionBudget{}
+	err = c.client.Patch(pt).
+		Resource("machineconfigdisruptionbudgets").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -14,6 +14,7 @@ type MachineconfigurationV1Interface interface {
 	ControllerConfigsGetter
 	KubeletConfigsGetter
 	MachineConfigsGetter
+	MachineConfigDisruptionBudgetsGetter
 	MachineConfigPoolsGetter
 }
 
@@ -38,6 +39,10 @@ func (c *MachineconfigurationV1Client) MachineConfigs() MachineConfigInterface {
 	return newMachineConfigs(c)
 }
 
+func (c *MachineconfigurationV1Client) MachineConfigDisruptionBudgets() MachineConfigDisruptionBudgetInterface {
+	return newMachineConfigDisruptionBudgets(c)
+}
+
 func (c *MachineconfigurationV1Client) MachineConfigPools() MachineConfigPoolInterface {
 	return newMachineConfigPools(c)
 }
@@ -16,6 +16,8 @@ type Interface interface {
 	KubeletConfigs() KubeletConfigInformer
 	// MachineConfigs returns a MachineConfigInformer.
 	MachineConfigs() MachineConfigInformer
+	// MachineConfigDisruptionBudgets returns a MachineConfigDisruptionBudgetInformer.
+	MachineConfigDisruptionBudgets() MachineConfigDisruptionBudgetInformer
 	// MachineConfigPools returns a MachineConfigPoolInformer.
 	MachineConfigPools() MachineConfigPoolInformer
 }
@@ -51,6 +53,11 @@ func (v *version) MachineConfigs() MachineConfigInformer {
 	return &machineConfigInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
 }
 
+// MachineConfigDisruptionBudgets returns a MachineConfigDisruptionBudgetInformer.
+func (v *version) MachineConfigDisruptionBudgets() MachineConfigDisruptionBudgetInformer {
+	return &machineConfigDisruptionBudgetInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
 // MachineConfigPools returns a MachineConfigPoolInformer.
 func (v *version) MachineConfigPools() MachineConfigPoolInformer {
 	return &machineConfigPoolInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
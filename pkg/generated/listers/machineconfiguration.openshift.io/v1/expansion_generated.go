@@ -18,6 +18,10 @@ type KubeletConfigListerExpansion interface{}
 // MachineConfigLister.
 type MachineConfigListerExpansion interface{}
 
+// MachineConfigDisruptionBudgetListerExpansion allows custom methods to be added to
+// MachineConfigDisruptionBudgetLister.
+type MachineConfigDisruptionBudgetListerExpansion interface{}
+
 // MachineConfigPoolListerExpansion allows custom methods to be added to
 // MachineConfigPoolLister.
 type MachineConfigPoolListerExpansion interface{}
@@ -0,0 +1,52 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// MachineConfigDisruptionBudgetLister helps list MachineConfigDisruptionBudgets.
+// All objects returned here must be treated as read-only.
+type MachineConfigDisruptionBudgetLister interface {
+	// List lists all MachineConfigDisruptionBudgets in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.MachineConfigDisruptionBudget, err error)
+	// Get retrieves the MachineConfigDisruptionBudget from the index for a given name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1.MachineConfigDisruptionBudget, error)
+	MachineConfigDisruptionBudgetListerExpansion
+}
+
+// machineConfigDisruptionBudgetLister implements the MachineConfigDisruptionBudgetLister interface.
+type machineConfigDisruptionBudgetLister struct {
+	indexer cache.Indexer
+}
+
+// NewMachineConfigDisruptionBudgetLister returns a new MachineConfigDisruptionBudgetLister.
+func NewMachineConfigDisruptionBudgetLister(indexer cache.Indexer) MachineConfigDisruptionBudgetLister {
+	return &machineConfigDisruptionBudgetLister{indexer: indexer}
+}
+
+// List lists all MachineConfigDisruptionBudgets in the indexer.
+func (s *machineConfigDisruptionBudgetLister) List(selector labels.Selector) (ret []*v1.MachineConfigDisruptionBudget, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.MachineConfigDisruptionBudget))
+	})
+	return ret, err
+}
+
+// Get retrieves the MachineConfigDisruptionBudget from the index for a given name.
+func (s *machineConfigDisruptionBudgetLister) Get(name string) (*v1.MachineConfigDisruptionBudget, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1.Resource("machineconfigdisruptionbudget"), name)
+	}
+	return obj.(*v1.MachineConfigDisruptionBudget), nil
+}
@@ -18,6 +18,8 @@
 // manifests/machineconfigdaemon/events-rolebinding-default.yaml
 // manifests/machineconfigdaemon/events-rolebinding-target.yaml
 // manifests/machineconfigdaemon/sa.yaml
+// manifests/machineconfigserver/bootstrap-tokens-clusterrole.yaml
+// manifests/machineconfigserver/bootstrap-tokens-rolebinding.yaml
 // manifests/machineconfigserver/clusterrole.yaml
 // manifests/machineconfigserver/clusterrolebinding.yaml
 // manifests/machineconfigserver/csr-bootstrap-role-binding.yaml
@@ -733,6 +735,19 @@ rules:
 - apiGroups: ["operator.openshift.io"]
   resources: ["etcds"]
   verbs: ["get", "list", "watch"]
+- apiGroups:
+  - authentication.k8s.io
+  resources:
+  - tokenreviews
+  - subjectaccessreviews
+  verbs:
+  - create
+- apiGroups:
+  - authorization.k8s.io
+  resources:
+  - subjectaccessreviews
+  verbs:
+  - create
 `)
 
 func manifestsMachineconfigcontrollerClusterroleYamlBytes() ([]byte, error) {
@@ -827,12 +842,40 @@ spec:
         args:
         - "start"
         - "--resourcelock-namespace={{.TargetNamespace}}"
+        {{- if .RequireAuth}}
+        - "--bootstrap-tokens"
+        {{- end}}
         - "--v=2"
         resources:
           requests:
             cpu: 20m
             memory: 50Mi
         terminationMessagePolicy: FallbackToLogsOnError
+      - name: oauth-proxy
+        image: {{.Images.OauthProxy}}
+        ports:
+        - containerPort: 9001
+          name: metrics
+          protocol: TCP
+        args:
+        - --https-address=:9001
+        - --provider=openshift
+        - --openshift-service-account=machine-config-controller
+        - --upstream=http://127.0.0.1:8799
+        - --tls-cert=/etc/tls/private/tls.crt
+        - --tls-key=/etc/tls/private/tls.key
+        - --cookie-secret-file=/etc/tls/cookie-secret/cookie-secret
+        - '--openshift-sar={"resource": "namespaces", "verb": "get"}'
+        - '--openshift-delegate-urls={"/": {"resource": "namespaces", "verb": "get"}}'
+        resources:
+          requests:
+            cpu: 20m
+            memory: 50Mi
+        volumeMounts:
+        - mountPath: /etc/tls/private
+          name: proxy-tls
+        - mountPath: /etc/tls/cookie-secret
+          name: cookie-secret
       serviceAccountName: machine-config-controller
       nodeSelector:
         node-role.kubernetes.io/master: ""
@@ -850,6 +893,13 @@ spec:
         operator: "Exists"
         effect: "NoExecute"
         tolerationSeconds: 120
+      volumes:
+      - name: proxy-tls
+        secret:
+          secretName: mcc-proxy-tls
+      - name: cookie-secret
+        secret:
+          secretName: cookie-secret
 `)
 
 func manifestsMachineconfigcontrollerDeploymentYamlBytes() ([]byte, error) {
@@ -1319,6 +1369,61 @@ func manifestsMachineconfigdaemonSaYaml() (*asset, error) {
 	return a, nil
 }
 
+var _manifestsMachineconfigserverBootstrapTokensClusterroleYaml = []byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: machine-config-server-bootstrap-tokens
+  namespace: {{.TargetNamespace}}
+rules:
+- apiGroups: [""]
+  resources: ["secrets"]
+  verbs: ["get", "list", "watch"]
+`)
+
+func manifestsMachineconfigserverBootstrapTokensClusterroleYamlBytes() ([]byte, error) {
+	return _manifestsMachineconfigserverBootstrapTokensClusterroleYaml, nil
+}
+
+func manifestsMachineconfigserverBootstrapTokensClusterroleYaml() (*asset, error) {
+	bytes, err := manifestsMachineconfigserverBootstrapTokensClusterroleYamlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "manifests/machineconfigserver/bootstrap-tokens-clusterrole.yaml", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _manifestsMachineconfigserverBootstrapTokensRolebindingYaml = []byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: machine-config-server-bootstrap-tokens
+  namespace: openshift-machine-config-bootstrap-tokens
+roleRef:
+  kind: ClusterRole
+  name: machine-config-server-bootstrap-tokens
+subjects:
+- kind: ServiceAccount
+  namespace: {{.TargetNamespace}}
+  name: machine-config-server
+`)
+
+func manifestsMachineconfigserverBootstrapTokensRolebindingYamlBytes() ([]byte, error) {
+	return _manifestsMachineconfigserverBootstrapTokensRolebindingYaml, nil
+}
+
+func manifestsMachineconfigserverBootstrapTokensRolebindingYaml() (*asset, error) {
+	bytes, err := manifestsMachineconfigserverBootstrapTokensRolebindingYamlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "manifests/machineconfigserver/bootstrap-tokens-rolebinding.yaml", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var _manifestsMachineconfigserverClusterroleYaml = []byte(`apiVersion: rbac.authorization.k8s.io/v1
 kind: ClusterRole
 metadata:
@@ -1328,6 +1433,22 @@ rules:
 - apiGroups: ["machineconfiguration.openshift.io"]
   resources: ["machineconfigs", "machineconfigpools"]
   verbs: ["*"]
+- apiGroups: [""]
+  resources: ["nodes"]
+  verbs: ["get", "list", "watch"]
+- apiGroups:
+  - authentication.k8s.io
+  resources:
+  - tokenreviews
+  - subjectaccessreviews
+  verbs:
+  - create
+- apiGroups:
+  - authorization.k8s.io
+  resources:
+  - subjectaccessreviews
+  verbs:
+  - create
 `)
 
 func manifestsMachineconfigserverClusterroleYamlBytes() ([]byte, error) {
@@ -1462,6 +1583,9 @@ spec:
         args:
           - "start"
           - "--apiserver-url={{.APIServerURL}}"
+          {{- if .RequireAuth}}
+          - "--require-auth"
+          {{- end}}
         resources:
           requests:
             cpu: 20m
@@ -1472,6 +1596,31 @@ spec:
           mountPath: /etc/ssl/mcs
         - name: node-bootstrap-token
           mountPath: /etc/mcs/bootstrap-token
+      - name: oauth-proxy
+        image: {{.Images.OauthProxy}}
+        ports:
+        - containerPort: 9002
+          name: metrics
+          protocol: TCP
+        args:
+        - --https-address=:9002
+        - --provider=openshift
+        - --openshift-service-account=machine-config-server
+        - --upstream=http://127.0.0.1:8798
+        - --tls-cert=/etc/tls/private/tls.crt
+        - --tls-key=/etc/tls/private/tls.key
+        - --cookie-secret-file=/etc/tls/cookie-secret/cookie-secret
+        - '--openshift-sar={"resource": "namespaces", "verb": "get"}'
+        - '--openshift-delegate-urls={"/": {"resource": "namespaces", "verb": "get"}}'
+        resources:
+          requests:
+            cpu: 20m
+            memory: 50Mi
+        volumeMounts:
+        - mountPath: /etc/tls/private
+          name: proxy-tls
+        - mountPath: /etc/tls/cookie-secret
+          name: cookie-secret
       hostNetwork: true
       nodeSelector:
         node-role.kubernetes.io/master: ""
@@ -1491,6 +1640,12 @@ spec:
       - name: certs
         secret:
           secretName: machine-config-server-tls
+      - name: proxy-tls
+        secret:
+          secretName: mcs-proxy-tls
+      - name: cookie-secret
+        secret:
+          secretName: cookie-secret
 `)
 
 func manifestsMachineconfigserverDaemonsetYamlBytes() ([]byte, error) {
@@ -2094,6 +2249,8 @@ var _bindata = map[string]func() (*asset, error){
 	"manifests/machineconfigdaemon/events-rolebinding-default.yaml":          manifestsMachineconfigdaemonEventsRolebindingDefaultYaml,
 	"manifests/machineconfigdaemon/events-rolebinding-target.yaml":           manifestsMachineconfigdaemonEventsRolebindingTargetYaml,
 	"manifests/machineconfigdaemon/sa.yaml":                                  manifestsMachineconfigdaemonSaYaml,
+	"manifests/machineconfigserver/bootstrap-tokens-clusterrole.yaml":        manifestsMachineconfigserverBootstrapTokensClusterroleYaml,
+	"manifests/machineconfigserver/bootstrap-tokens-rolebinding.yaml":        manifestsMachineconfigserverBootstrapTokensRolebindingYaml,
 	"manifests/machineconfigserver/clusterrole.yaml":                         manifestsMachineconfigserverClusterroleYaml,
 	"manifests/machineconfigserver/clusterrolebinding.yaml":                  manifestsMachineconfigserverClusterrolebindingYaml,
 	"manifests/machineconfigserver/csr-bootstrap-role-binding.yaml":          manifestsMachineconfigserverCsrBootstrapRoleBindingYaml,
@@ -2176,6 +2333,8 @@ var _bintree = &bintree{nil, map[string]*bintree{
 			"sa.yaml":                         &bintree{manifestsMachineconfigdaemonSaYaml, map[string]*bintree{}},
 		}},
 		"machineconfigserver": &bintree{nil, map[string]*bintree{
+			"bootstrap-tokens-clusterrole.yaml":        &bintree{manifestsMachineconfigserverBootstrapTokensClusterroleYaml, map[string]*bintree{}},
+			"bootstrap-tokens-rolebinding.yaml":        &bintree{manifestsMachineconfigserverBootstrapTokensRolebindingYaml, map[string]*bintree{}},
 			"clusterrole.yaml":                         &bintree{manifestsMachineconfigserverClusterroleYaml, map[string]*bintree{}},
 			"clusterrolebinding.yaml":                  &bintree{manifestsMachineconfigserverClusterrolebindingYaml, map[string]*bintree{}},
 			"csr-bootstrap-role-binding.yaml":          &bintree{manifestsMachineconfigserverCsrBootstrapRoleBindingYaml, map[string]*bintree{}},
@@ -49,6 +49,9 @@ const (
 
 	// osImageConfigMapName is the name of our configmap for the osImageURL
 	osImageConfigMapName = "machine-config-osimageurl"
+
+	// serverConfigMapName is the name of the configmap configuring the machine-config-server
+	serverConfigMapName = "machine-config-server"
 )
 
 // Operator defines machince config operator.
@@ -31,6 +31,7 @@ type renderConfig struct {
 	KubeAPIServerServingCA string
 	Infra                  configv1.Infrastructure
 	Constants              map[string]string
+	RequireAuth            bool
 }
 
 func renderAsset(config *renderConfig, path string) ([]byte, error) {
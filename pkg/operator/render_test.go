@@ -132,6 +132,18 @@ func TestRenderAsset(t *testing.T) {
 			"- name: HTTPS_PROXY\n            value: https://i.am.a.proxy.server",
 			"- name: NO_PROXY\n            value: \"*\"", // Ensure the * is quoted: "*": https://bugzilla.redhat.com/show_bug.cgi?id=1947066
 		},
+	}, {
+		// Test that the machine-config-server requires authentication when configured to
+		Path: "manifests/machineconfigserver/daemonset.yaml",
+		RenderConfig: &renderConfig{
+			TargetNamespace: "testing-namespace",
+			Images: &RenderConfigImages{
+				MachineConfigOperator: "mco-operator-image",
+				OauthProxy:            "oauth-proxy-image",
+			},
+			RequireAuth: true,
+		},
+		FindExpected: []string{"- \"--require-auth\""},
 	}, {
 		// Bad path, will cause asset error
 		Path:  "BAD PATH",
@@ -148,6 +160,9 @@ func TestRenderAsset(t *testing.T) {
 		"manifests/machineconfigdaemon/daemonset.yaml": func(objBytes []byte) {
 			resourceread.ReadDaemonSetV1OrDie(objBytes)
 		},
+		"manifests/machineconfigserver/daemonset.yaml": func(objBytes []byte) {
+			resourceread.ReadDaemonSetV1OrDie(objBytes)
+		},
 	}
 
 	for idx, test := range tests {
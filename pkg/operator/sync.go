@@ -8,6 +8,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,6 +29,7 @@ import (
 	"github.com/openshift/machine-config-operator/lib/resourceapply"
 	"github.com/openshift/machine-config-operator/lib/resourceread"
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	templatectrl "github.com/openshift/machine-config-operator/pkg/controller/template"
 	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	"github.com/openshift/machine-config-operator/pkg/operator/assets"
@@ -275,8 +277,14 @@ func (optr *Operator) syncRenderConfig(_ *renderConfig) error {
 		templatectrl.BaremetalRuntimeCfgKey: imgs.BaremetalRuntimeCfg,
 	}
 
+	requireAuth, err := optr.getServerRequireAuth(optr.namespace)
+	if err != nil {
+		return err
+	}
+
 	// create renderConfig
 	optr.renderConfig = getRenderConfig(optr.namespace, string(kubeAPIServerServingCABytes), spec, &imgs.RenderConfigImages, infra.Status.APIServerInternalURL)
+	optr.renderConfig.RequireAuth = requireAuth
 	return nil
 }
 
@@ -492,6 +500,34 @@ func (optr *Operator) syncMachineConfigDaemon(config *renderConfig) error {
 	return nil
 }
 
+// syncBootstrapTokensRBAC lets the machine-config-server read the bootstrap token Secrets when
+// it requires authentication, and revokes it otherwise.
+func (optr *Operator) syncBootstrapTokensRBAC(config *renderConfig) error {
+	rbBytes, err := renderAsset(config, "manifests/machineconfigserver/bootstrap-tokens-rolebinding.yaml")
+	if err != nil {
+		return err
+	}
+	rb := resourceread.ReadRoleBindingV1OrDie(rbBytes)
+	if !config.RequireAuth {
+		err := optr.kubeClient.RbacV1().RoleBindings(rb.Namespace).Delete(context.TODO(), rb.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	crBytes, err := renderAsset(config, "manifests/machineconfigserver/bootstrap-tokens-clusterrole.yaml")
+	if err != nil {
+		return err
+	}
+	cr := resourceread.ReadClusterRoleV1OrDie(crBytes)
+	if _, _, err := resourceapply.ApplyClusterRole(optr.kubeClient.RbacV1(), cr); err != nil {
+		return err
+	}
+	_, _, err = resourceapply.ApplyRoleBinding(optr.kubeClient.RbacV1(), rb)
+	return err
+}
+
 func (optr *Operator) syncMachineConfigServer(config *renderConfig) error {
 	crBytes, err := renderAsset(config, "manifests/machineconfigserver/clusterrole.yaml")
 	if err != nil {
@@ -503,6 +539,10 @@ func (optr *Operator) syncMachineConfigServer(config *renderConfig) error {
 		return err
 	}
 
+	if err := optr.syncBootstrapTokensRBAC(config); err != nil {
+		return err
+	}
+
 	crbs := []string{
 		"manifests/machineconfigserver/clusterrolebinding.yaml",
 		"manifests/machineconfigserver/csr-bootstrap-role-binding.yaml",
@@ -607,7 +647,8 @@ func (optr *Operator) syncRequiredMachineConfigPools(_ *renderConfig) error {
 
 			_, hasRequiredPoolLabel := pool.Labels[requiredForUpgradeMachineConfigPoolLabelKey]
 
-			if hasRequiredPoolLabel {
+			// The custom pools inheriting from master hold part of the control plane, they're required like the master pool.
+			if hasRequiredPoolLabel || ctrlcommon.IsControlPlanePool(pool) {
 				if err := isMachineConfigPoolConfigurationValid(pool, version.Hash, optr.mcLister.Get); err != nil {
 					lastErr = fmt.Errorf("pool %s has not progressed to latest configuration: %v, retrying", pool.Name, err)
 					glog.Info(lastErr.Error())
@@ -781,6 +822,27 @@ func (optr *Operator) getOsImageURL(namespace string) (string, error) {
 	return cm.Data["osImageURL"], nil
 }
 
+// getServerRequireAuth returns whether the machine-config-server requires bootstrap tokens or client
+// certificates, as set by the requireAuth key of its configmap.
+func (optr *Operator) getServerRequireAuth(namespace string) (bool, error) {
+	cm, err := optr.mcoCmLister.ConfigMaps(namespace).Get(serverConfigMapName)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	raw, ok := cm.Data["requireAuth"]
+	if !ok {
+		return false, nil
+	}
+	requireAuth, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid requireAuth %q in configmap %s/%s: %v", raw, namespace, serverConfigMapName, err)
+	}
+	return requireAuth, nil
+}
+
 func (optr *Operator) getCAsFromConfigMap(namespace, name, key string) ([]byte, error) {
 	cm, err := optr.clusterCmLister.ConfigMaps(namespace).Get(name)
 	if err != nil {
@@ -2,20 +2,17 @@ package server
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
-	"path"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/clarketm/json"
 	"github.com/coreos/go-semver/semver"
 	"github.com/golang/glog"
 	"github.com/pkg/errors"
-	"k8s.io/apimachinery/pkg/runtime"
-
-	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 )
 
 const (
@@ -27,7 +24,52 @@ const (
 
 type poolRequest struct {
 	machineConfigPool string
-	version           *semver.Version
+	// renderedConfig is set when a rendered MachineConfig is requested by name
+	// through /config/rendered/<name> instead of the config of a pool.
+	renderedConfig string
+	// node is set when the current config of a node is requested
+	// through /config/node/<name> instead of the config of a pool.
+	node    string
+	version *semver.Version
+}
+
+// newPoolRequest returns the request for the config at the given URL path, one of
+// /config/<pool>, /config/rendered/<name> or /config/node/<name>.
+func newPoolRequest(urlPath string) (poolRequest, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(urlPath, "/config/"), "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "rendered" && parts[1] != "":
+		return poolRequest{renderedConfig: parts[1]}, true
+	case len(parts) == 2 && parts[0] == "node" && parts[1] != "":
+		return poolRequest{node: parts[1]}, true
+	case len(parts) == 1 && parts[0] != "":
+		return poolRequest{machineConfigPool: parts[0]}, true
+	}
+	return poolRequest{}, false
+}
+
+// endpoint returns which endpoint the request is for: pool, rendered or node.
+func (cr poolRequest) endpoint() string {
+	switch {
+	case cr.renderedConfig != "":
+		return "rendered"
+	case cr.node != "":
+		return "node"
+	case cr.machineConfigPool != "":
+		return "pool"
+	}
+	return ""
+}
+
+// String describes the request for logging.
+func (cr poolRequest) String() string {
+	switch {
+	case cr.renderedConfig != "":
+		return fmt.Sprintf("Rendered config %s", cr.renderedConfig)
+	case cr.node != "":
+		return fmt.Sprintf("Config of node %s", cr.node)
+	}
+	return fmt.Sprintf("Pool %s", cr.machineConfigPool)
 }
 
 // APIServer provides the HTTP(s) endpoint
@@ -38,23 +80,27 @@ type APIServer struct {
 	insecure bool
 	cert     string
 	key      string
+	// clientCAs verify the client certificates presented to the secure server.
+	clientCAs *x509.CertPool
 }
 
 // NewAPIServer initializes a new API server
 // that runs the Machine Config Server as a
-// handler.
-func NewAPIServer(a *APIHandler, p int, is bool, c, k string) *APIServer {
+// handler. If clientCAs is set, the secure server
+// verifies the client certificates presented to it.
+func NewAPIServer(a *APIHandler, p int, is bool, c, k string, clientCAs *x509.CertPool) *APIServer {
 	mux := http.NewServeMux()
 	mux.Handle("/config/", a)
 	mux.Handle("/healthz", &healthHandler{})
 	mux.Handle("/", &defaultHandler{})
 
 	return &APIServer{
-		handler:  mux,
-		port:     p,
-		insecure: is,
-		cert:     c,
-		key:      k,
+		handler:   mux,
+		port:      p,
+		insecure:  is,
+		cert:      c,
+		key:       k,
+		clientCAs: clientCAs,
 	}
 }
 
@@ -69,6 +115,10 @@ func (a *APIServer) Serve() {
 			glog.Exitf("Machine Config Server exited with error: %v", err)
 		}
 	} else {
+		if a.clientCAs != nil {
+			mcs.TLSConfig.ClientCAs = a.clientCAs
+			mcs.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
 		if err := mcs.ListenAndServeTLS(a.cert, a.key); err != http.ErrServerClosed {
 			glog.Exitf("Machine Config Server exited with error: %v", err)
 		}
@@ -79,6 +129,8 @@ func (a *APIServer) Serve() {
 // Machine Config Server.
 type APIHandler struct {
 	server Server
+	// auth, if set, authenticates the requests before serving them.
+	auth *Authenticator
 }
 
 // NewServerAPIHandler initializes a new API handler
@@ -89,9 +141,27 @@ func NewServerAPIHandler(s Server) *APIHandler {
 	}
 }
 
+// NewAuthenticatedServerAPIHandler initializes a new API handler
+// for the Machine Config Server which only serves the requests
+// allowed by the Authenticator.
+func NewAuthenticatedServerAPIHandler(s Server, a *Authenticator) *APIHandler {
+	return &APIHandler{
+		server: s,
+		auth:   a,
+	}
+}
+
 // ServeHTTP handles the requests for the machine config server
 // API handler.
 func (sh *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rw := &responseRecorder{ResponseWriter: w}
+	w = rw
+	var cr poolRequest
+	defer func() {
+		observeRequest(cr, poolLabel(sh.server, cr), rw.status, rw.bytes, time.Since(start))
+	}()
+
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		w.Header().Set("Content-Length", "0")
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -104,10 +174,34 @@ func (sh *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	poolName := path.Base(r.URL.Path)
+	cr, ok := newPoolRequest(r.URL.Path)
+	if !ok {
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 	useragent := r.Header.Get("User-Agent")
 	acceptHeader := r.Header.Get("Accept")
-	glog.Infof("Pool %s requested by address:%q User-Agent:%q Accept-Header: %q", poolName, r.RemoteAddr, useragent, acceptHeader)
+	glog.Infof("%v requested by address:%q User-Agent:%q Accept-Header: %q", cr, r.RemoteAddr, useragent, acceptHeader)
+
+	if sh.auth != nil {
+		if err := sh.auth.authenticate(r, cr); err != nil {
+			status := http.StatusUnauthorized
+			if err == errTokenScope {
+				status = http.StatusForbidden
+			}
+			reason, ok := authDeniedReasons[err]
+			if !ok {
+				reason = "Error"
+				status = http.StatusInternalServerError
+			}
+			MCSRequestsDenied.WithLabelValues(reason).Inc()
+			glog.Warningf("Denied %v requested by address:%q: %v", cr, r.RemoteAddr, err)
+			w.Header().Set("Content-Length", "0")
+			w.WriteHeader(status)
+			return
+		}
+	}
 
 	reqConfigVer, err := detectSpecVersionFromAcceptHeader(acceptHeader)
 	if err != nil {
@@ -116,60 +210,27 @@ func (sh *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		glog.Error(err)
 		return
 	}
+	cr.version = reqConfigVer
 
-	cr := poolRequest{
-		machineConfigPool: poolName,
-		version:           reqConfigVer,
-	}
-
-	conf, err := sh.server.GetConfig(cr)
+	p, err := sh.getPayload(cr)
 	if err != nil {
 		w.Header().Set("Content-Length", "0")
 		w.WriteHeader(http.StatusInternalServerError)
 		glog.Errorf("couldn't get config for req: %v, error: %v", cr, err)
 		return
 	}
-	if conf == nil {
+	if p == nil {
 		w.Header().Set("Content-Length", "0")
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	// we know we're at 3.2 in code.. serve directly, parsing is expensive...
-	// we're doing it during an HTTP request, and most notably before we write the HTTP headers
-	var serveConf *runtime.RawExtension
-	if reqConfigVer.Equal(*semver.New("3.2.0")) {
-		serveConf = conf
-	} else if reqConfigVer.Equal(*semver.New("3.1.0")) {
-		converted31, err := ctrlcommon.ConvertRawExtIgnitionToV3_1(conf)
-		if err != nil {
-			w.Header().Set("Content-Length", "0")
-			w.WriteHeader(http.StatusInternalServerError)
-			glog.Errorf("couldn't convert config for req: %v, error: %v", cr, err)
-			return
-		}
-
-		serveConf = &converted31
-	} else {
-		// Can only be 2.2 here
-		converted2, err := ctrlcommon.ConvertRawExtIgnitionToV2(conf)
-		if err != nil {
-			w.Header().Set("Content-Length", "0")
-			w.WriteHeader(http.StatusInternalServerError)
-			glog.Errorf("couldn't convert config for req: %v, error: %v", cr, err)
-			return
-		}
-
-		serveConf = &converted2
-	}
 
-	data, err := json.Marshal(serveConf)
-	if err != nil {
-		w.Header().Set("Content-Length", "0")
-		w.WriteHeader(http.StatusInternalServerError)
-		glog.Errorf("failed to marshal %v config: %v", cr, err)
+	w.Header().Set("ETag", p.etag)
+	if etagMatches(r.Header.Get("If-None-Match"), p.etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
-
+	data := p.data
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
 	w.Header().Set("Content-Type", "application/json")
 	if r.Method == http.MethodHead {
@@ -177,12 +238,63 @@ func (sh *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = w.Write(data)
-	if err != nil {
+	if _, err := w.Write(data); err != nil {
 		glog.Errorf("failed to write %v response: %v", cr, err)
 	}
 }
 
+// responseRecorder records the status code and the size of a response for the metrics.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += n
+	return n, err
+}
+
+// getPayload returns the payload served for the request, or nil if there is none.
+// The payloads of a cachingServer are served from its cache when possible.
+func (sh *APIHandler) getPayload(cr poolRequest) (*payload, error) {
+	cs, ok := sh.server.(cachingServer)
+	if !ok {
+		conf, err := sh.server.GetConfig(cr)
+		if conf == nil || err != nil {
+			return nil, err
+		}
+		return newPayload(conf, cr.version)
+	}
+
+	key, getConfig, err := cs.resolveConfig(cr)
+	if key == nil || err != nil {
+		return nil, err
+	}
+	if p, ok := cs.configCache().get(*key); ok {
+		return p, nil
+	}
+	conf, err := getConfig()
+	if err != nil {
+		return nil, err
+	}
+	p, err := newPayload(conf, cr.version)
+	if err != nil {
+		return nil, err
+	}
+	cs.configCache().add(*key, p)
+	return p, nil
+}
+
 type healthHandler struct{}
 
 type acceptHeaderValue struct {
@@ -22,12 +22,26 @@ import (
 
 type mockServer struct {
 	GetConfigFn func(poolRequest) (*runtime.RawExtension, error)
+	// pools are the pools the server knows, all of them if nil.
+	pools []string
 }
 
 func (ms *mockServer) GetConfig(pr poolRequest) (*runtime.RawExtension, error) {
 	return ms.GetConfigFn(pr)
 }
 
+func (ms *mockServer) hasPool(name string) bool {
+	if ms.pools == nil {
+		return true
+	}
+	for _, pool := range ms.pools {
+		if pool == name {
+			return true
+		}
+	}
+	return false
+}
+
 type checkResponse func(t *testing.T, response *http.Response)
 
 type scenario struct {
@@ -210,6 +224,48 @@ func TestAPIHandler(t *testing.T) {
 				checkBodyLength(t, response, expectedContentLength)
 			},
 		},
+		{
+			name:    "get rendered config",
+			request: setAcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/rendered/rendered-master-1234", nil)),
+			serverFunc: func(cr poolRequest) (*runtime.RawExtension, error) {
+				if cr.renderedConfig != "rendered-master-1234" {
+					return nil, nil
+				}
+				return &runtime.RawExtension{
+					Raw: helpers.MarshalOrDie(ctrlcommon.NewIgnConfig()),
+				}, nil
+			},
+			checkResponse: func(t *testing.T, response *http.Response) {
+				checkStatus(t, response, http.StatusOK)
+				checkContentType(t, response, "application/json")
+				checkContentLength(t, response, expectedContentLength)
+				checkBodyLength(t, response, expectedContentLength)
+			},
+		},
+		{
+			name:    "get config of a node that does not exist",
+			request: setAcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/node/does-not-exist", nil)),
+			serverFunc: func(poolRequest) (*runtime.RawExtension, error) {
+				return nil, nil
+			},
+			checkResponse: func(t *testing.T, response *http.Response) {
+				checkStatus(t, response, http.StatusNotFound)
+				checkContentLength(t, response, 0)
+				checkBodyLength(t, response, 0)
+			},
+		},
+		{
+			name:    "get config path that is invalid",
+			request: setAcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/node/a/b", nil)),
+			serverFunc: func(poolRequest) (*runtime.RawExtension, error) {
+				return nil, fmt.Errorf("should not be called")
+			},
+			checkResponse: func(t *testing.T, response *http.Response) {
+				checkStatus(t, response, http.StatusNotFound)
+				checkContentLength(t, response, 0)
+				checkBodyLength(t, response, 0)
+			},
+		},
 		{
 			name:    "head config path that exists",
 			request: setAcceptHeaderOnReq(httptest.NewRequest(http.MethodHead, "http://testrequest/config/master", nil)),
@@ -287,6 +343,80 @@ func TestAPIHandler(t *testing.T) {
 	}
 }
 
+func TestNewPoolRequest(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected poolRequest
+		ok       bool
+	}{
+		{path: "/config/master", expected: poolRequest{machineConfigPool: "master"}, ok: true},
+		{path: "/config/worker/", expected: poolRequest{machineConfigPool: "worker"}, ok: true},
+		{path: "/config/rendered/rendered-worker-1234", expected: poolRequest{renderedConfig: "rendered-worker-1234"}, ok: true},
+		{path: "/config/node/ip-10-0-1-2", expected: poolRequest{node: "ip-10-0-1-2"}, ok: true},
+		{path: "/config/", ok: false},
+		{path: "/config/node/a/b", ok: false},
+		{path: "/config/worker/extra", ok: false},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			cr, ok := newPoolRequest(test.path)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.expected, cr)
+		})
+	}
+}
+
+func TestAPIHandlerMetrics(t *testing.T) {
+	ms := &mockServer{
+		GetConfigFn: func(cr poolRequest) (*runtime.RawExtension, error) {
+			if cr.machineConfigPool == "broken" {
+				return &runtime.RawExtension{Raw: []byte(`{"ignition":{"version":"3.2.0"},"storage":{"files":[{"path":"relative"}]}}`)}, nil
+			}
+			if cr.machineConfigPool == "master" || cr.machineConfigPool == "broken" {
+				return &runtime.RawExtension{Raw: helpers.MarshalOrDie(ctrlcommon.NewIgnConfig())}, nil
+			}
+			return nil, nil
+		},
+		pools: []string{"master", "broken"},
+	}
+	handler := NewServerAPIHandler(ms)
+	serve := func(req *http.Request) *http.Response {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	requests := MCSRequests.WithLabelValues("pool", "master", "3.1.0", "200")
+	bytes := MCSResponseBytes.WithLabelValues("pool", "master")
+	served, servedBytes := counterValue(t, requests), counterValue(t, bytes)
+	resp := serve(setV3_1AcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/master", nil)))
+	defer resp.Body.Close()
+	checkStatus(t, resp, http.StatusOK)
+	assert.Equal(t, served+1, counterValue(t, requests))
+	assert.Equal(t, servedBytes+float64(resp.ContentLength), counterValue(t, bytes))
+
+	notFound := MCSRequests.WithLabelValues("", "", "", "404")
+	count := counterValue(t, notFound)
+	resp = serve(httptest.NewRequest(http.MethodGet, "http://testrequest/config/", nil))
+	defer resp.Body.Close()
+	assert.Equal(t, count+1, counterValue(t, notFound))
+
+	// Unknown pools don't create new label values.
+	unknown := MCSRequests.WithLabelValues("pool", unknownPoolLabel, "2.2.0", "404")
+	count = counterValue(t, unknown)
+	resp = serve(httptest.NewRequest(http.MethodGet, "http://testrequest/config/made-up", nil))
+	defer resp.Body.Close()
+	checkStatus(t, resp, http.StatusNotFound)
+	assert.Equal(t, count+1, counterValue(t, unknown))
+
+	conversionErrors := MCSConversionErrors.WithLabelValues("2.2.0")
+	count = counterValue(t, conversionErrors)
+	resp = serve(httptest.NewRequest(http.MethodGet, "http://testrequest/config/broken", nil))
+	defer resp.Body.Close()
+	checkStatus(t, resp, http.StatusInternalServerError)
+	assert.Equal(t, count+1, counterValue(t, conversionErrors))
+}
+
 func TestHealthzHandler(t *testing.T) {
 	scenarios := []scenario{
 		{
@@ -554,7 +684,7 @@ func TestAPIServer(t *testing.T) {
 			ms := &mockServer{
 				GetConfigFn: scenario.serverFunc,
 			}
-			server := NewAPIServer(NewServerAPIHandler(ms), 0, false, "", "")
+			server := NewAPIServer(NewServerAPIHandler(ms), 0, false, "", "", nil)
 			server.handler.ServeHTTP(w, scenario.request)
 
 			resp := w.Result()
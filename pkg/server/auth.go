@@ -0,0 +1,136 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+var (
+	errNoCredentials = errors.New("no bootstrap token or client certificate")
+	errInvalidToken  = errors.New("invalid bootstrap token")
+	errExpiredToken  = errors.New("expired bootstrap token")
+	errTokenScope    = errors.New("bootstrap token not valid for the requested config")
+	errInsecureToken = errors.New("bootstrap token presented over plaintext")
+
+	// authDeniedReasons are the reasons the requests denied by the Authenticator are counted with.
+	authDeniedReasons = map[error]string{
+		errNoCredentials: "NoCredentials",
+		errInvalidToken:  "InvalidToken",
+		errExpiredToken:  "ExpiredToken",
+		errTokenScope:    "TokenScope",
+		errInsecureToken: "InsecureToken",
+	}
+)
+
+// Authenticator authenticates the requests to the Machine Config Server. A request
+// is allowed if it presents a client certificate verified by the secure server,
+// or a bootstrap token minted by the bootstrap token controller for the requested config.
+type Authenticator struct {
+	secretLister corelisterv1.SecretNamespaceLister
+	now          func() time.Time
+}
+
+// NewAuthenticator returns an Authenticator checking the bootstrap tokens
+// stored in the bootstrap token namespace of the cluster.
+// It accepts a kubeConfig, which is not required when it's
+// run from within a cluster(useful in testing).
+func NewAuthenticator(kubeConfig string, stopCh <-chan struct{}) (*Authenticator, error) {
+	restConfig, err := getClientConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create Kubernetes rest client: %v", err)
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(kubernetes.NewForConfigOrDie(restConfig), 0, informers.WithNamespace(ctrlcommon.BootstrapTokenNamespace))
+	secretInformer := factory.Core().V1().Secrets()
+	secretLister := secretInformer.Lister()
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, secretInformer.Informer().HasSynced) {
+		return nil, fmt.Errorf("failed to sync bootstrap token Secrets")
+	}
+	return newAuthenticator(secretLister.Secrets(ctrlcommon.BootstrapTokenNamespace)), nil
+}
+
+func newAuthenticator(secretLister corelisterv1.SecretNamespaceLister) *Authenticator {
+	return &Authenticator{
+		secretLister: secretLister,
+		now:          time.Now,
+	}
+}
+
+// authenticate returns nil if the request is allowed to fetch the requested config.
+func (a *Authenticator) authenticate(r *http.Request, cr poolRequest) error {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return nil
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return errNoCredentials
+	}
+	// the token could have been read by anyone on the machine network, and
+	// tokens of unexpired Secrets are reused, don't accept it
+	if r.TLS == nil {
+		return errInsecureToken
+	}
+
+	secret, err := a.findToken(token)
+	if err != nil {
+		return err
+	}
+	expiration, err := time.Parse(time.RFC3339, string(secret.Data[ctrlcommon.BootstrapTokenExpirationKey]))
+	if err != nil || !a.now().Before(expiration) {
+		return errExpiredToken
+	}
+	if !tokenAllows(secret, cr) {
+		return errTokenScope
+	}
+	return nil
+}
+
+// findToken returns the bootstrap token Secret holding the given token.
+func (a *Authenticator) findToken(token string) (*corev1.Secret, error) {
+	secrets, err := a.secretLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets {
+		if secret.Type != ctrlcommon.BootstrapTokenSecretType {
+			continue
+		}
+		minted := secret.Data[ctrlcommon.BootstrapTokenKey]
+		if len(minted) > 0 && subtle.ConstantTimeCompare(minted, []byte(token)) == 1 {
+			return secret, nil
+		}
+	}
+	return nil, errInvalidToken
+}
+
+// tokenAllows returns whether the bootstrap token Secret gives access to the requested config:
+// the config of its pool, the rendered configs of its pool, or the config of its node.
+func tokenAllows(secret *corev1.Secret, cr poolRequest) bool {
+	pool := string(secret.Data[ctrlcommon.BootstrapTokenPoolKey])
+	node := string(secret.Data[ctrlcommon.BootstrapTokenNodeKey])
+	switch {
+	case cr.renderedConfig != "":
+		// rendered configs are named rendered-<pool>-<hash>, don't match the ones of pool <pool>-<suffix>
+		prefix := fmt.Sprintf("rendered-%s-", pool)
+		hash := strings.TrimPrefix(cr.renderedConfig, prefix)
+		return pool != "" && strings.HasPrefix(cr.renderedConfig, prefix) && hash != "" && !strings.Contains(hash, "-")
+	case cr.node != "":
+		return node != "" && cr.node == node
+	}
+	return pool != "" && cr.machineConfigPool == pool
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func newTestAuthenticator(t *testing.T, now time.Time, secrets ...*corev1.Secret) *Authenticator {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, secret := range secrets {
+		require.Nil(t, indexer.Add(secret))
+	}
+	a := newAuthenticator(corelisterv1.NewSecretLister(indexer).Secrets(ctrlcommon.BootstrapTokenNamespace))
+	a.now = func() time.Time { return now }
+	return a
+}
+
+func newBootstrapTokenSecret(name, token, pool, node, expiration string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ctrlcommon.BootstrapTokenNamespace, Name: name},
+		Type:       ctrlcommon.BootstrapTokenSecretType,
+		Data: map[string][]byte{
+			ctrlcommon.BootstrapTokenKey:           []byte(token),
+			ctrlcommon.BootstrapTokenPoolKey:       []byte(pool),
+			ctrlcommon.BootstrapTokenNodeKey:       []byte(node),
+			ctrlcommon.BootstrapTokenExpirationKey: []byte(expiration),
+		},
+	}
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	var m dto.Metric
+	require.Nil(t, c.Write(&m))
+	return m.GetCounter().GetValue()
+}
+
+func TestAuthenticate(t *testing.T) {
+	now := time.Date(2021, time.March, 10, 10, 0, 0, 0, time.UTC)
+	opaque := newBootstrapTokenSecret("opaque", "opaque-token", "worker", "", "2021-03-10T11:00:00Z")
+	opaque.Type = corev1.SecretTypeOpaque
+	a := newTestAuthenticator(t, now,
+		newBootstrapTokenSecret("worker-0", "worker-token", "worker", "worker-0", "2021-03-10T11:00:00Z"),
+		newBootstrapTokenSecret("infra-0", "infra-token", "infra", "", "2021-03-10T11:00:00Z"),
+		newBootstrapTokenSecret("expired", "expired-token", "worker", "", "2021-03-10T10:00:00Z"),
+		newBootstrapTokenSecret("pending", "", "worker", "", ""),
+		opaque,
+	)
+
+	tests := []struct {
+		name      string
+		header    string
+		plaintext bool
+		request   poolRequest
+		expected  error
+	}{
+		{name: "pool", header: "Bearer worker-token", request: poolRequest{machineConfigPool: "worker"}},
+		{name: "rendered", header: "Bearer worker-token", request: poolRequest{renderedConfig: "rendered-worker-1234"}},
+		{name: "node", header: "Bearer worker-token", request: poolRequest{node: "worker-0"}},
+		{name: "no header", request: poolRequest{machineConfigPool: "worker"}, expected: errNoCredentials},
+		{name: "not a bearer token", header: "Basic worker-token", request: poolRequest{machineConfigPool: "worker"}, expected: errNoCredentials},
+		{name: "unknown token", header: "Bearer nope", request: poolRequest{machineConfigPool: "worker"}, expected: errInvalidToken},
+		{name: "empty token", header: "Bearer ", request: poolRequest{machineConfigPool: "worker"}, expected: errNoCredentials},
+		{name: "opaque secret", header: "Bearer opaque-token", request: poolRequest{machineConfigPool: "worker"}, expected: errInvalidToken},
+		{name: "expired", header: "Bearer expired-token", request: poolRequest{machineConfigPool: "worker"}, expected: errExpiredToken},
+		{name: "other pool", header: "Bearer worker-token", request: poolRequest{machineConfigPool: "master"}, expected: errTokenScope},
+		{name: "other pool rendered", header: "Bearer worker-token", request: poolRequest{renderedConfig: "rendered-master-1234"}, expected: errTokenScope},
+		{name: "pool with prefix rendered", header: "Bearer worker-token", request: poolRequest{renderedConfig: "rendered-worker-infra-1234"}, expected: errTokenScope},
+		{name: "other node", header: "Bearer worker-token", request: poolRequest{node: "worker-1"}, expected: errTokenScope},
+		{name: "no node", header: "Bearer infra-token", request: poolRequest{node: "infra-0"}, expected: errTokenScope},
+		{name: "plaintext", header: "Bearer worker-token", plaintext: true, request: poolRequest{machineConfigPool: "worker"}, expected: errInsecureToken},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url := "https://testrequest/config/worker"
+			if test.plaintext {
+				url = "http://testrequest/config/worker"
+			}
+			r := httptest.NewRequest(http.MethodGet, url, nil)
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+			assert.Equal(t, test.expected, a.authenticate(r, test.request))
+		})
+	}
+
+	// a verified client certificate gives access to every config
+	r := httptest.NewRequest(http.MethodGet, "http://testrequest/config/master", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	assert.Nil(t, a.authenticate(r, poolRequest{machineConfigPool: "master"}))
+}
+
+func TestAuthenticatedAPIHandler(t *testing.T) {
+	now := time.Now()
+	a := newTestAuthenticator(t, now,
+		newBootstrapTokenSecret("worker-0", "worker-token", "worker", "", now.Add(time.Hour).UTC().Format(time.RFC3339)),
+	)
+	ms := &mockServer{
+		GetConfigFn: func(poolRequest) (*runtime.RawExtension, error) {
+			return &runtime.RawExtension{Raw: helpers.MarshalOrDie(ctrlcommon.NewIgnConfig())}, nil
+		},
+	}
+	handler := NewAuthenticatedServerAPIHandler(ms, a)
+
+	tests := []struct {
+		url    string
+		header string
+		status int
+		reason string
+	}{
+		{url: "https://testrequest/config/worker", header: "Bearer worker-token", status: http.StatusOK},
+		{url: "https://testrequest/config/worker", status: http.StatusUnauthorized, reason: "NoCredentials"},
+		{url: "https://testrequest/config/worker", header: "Bearer nope", status: http.StatusUnauthorized, reason: "InvalidToken"},
+		{url: "https://testrequest/config/master", header: "Bearer worker-token", status: http.StatusForbidden, reason: "TokenScope"},
+		{url: "http://testrequest/config/worker", header: "Bearer worker-token", status: http.StatusUnauthorized, reason: "InsecureToken"},
+	}
+	for _, test := range tests {
+		t.Run(test.url+" "+test.header, func(t *testing.T) {
+			var denied float64
+			if test.reason != "" {
+				denied = counterValue(t, MCSRequestsDenied.WithLabelValues(test.reason))
+			}
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			checkStatus(t, resp, test.status)
+			if test.reason != "" {
+				assert.Equal(t, denied+1, counterValue(t, MCSRequestsDenied.WithLabelValues(test.reason)))
+			}
+		})
+	}
+}
@@ -58,6 +58,9 @@ func NewBootstrapServer(dir, kubeconfig string) (Server, error) {
 // 4. Append the machine annotations file.
 // 5. Append the KubeConfig file.
 func (bsc *bootstrapServer) GetConfig(cr poolRequest) (*runtime.RawExtension, error) {
+	if cr.renderedConfig != "" || cr.node != "" {
+		return nil, fmt.Errorf("refusing to serve bootstrap configuration for %v", cr)
+	}
 	if cr.machineConfigPool != "master" {
 		return nil, fmt.Errorf("refusing to serve bootstrap configuration to pool %q", cr.machineConfigPool)
 	}
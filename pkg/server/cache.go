@@ -0,0 +1,127 @@
+package server
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/clarketm/json"
+	"github.com/coreos/go-semver/semver"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// payload is the response body served for a config, along with its ETag.
+type payload struct {
+	data []byte
+	etag string
+}
+
+// newPayload converts the Ignition config to the requested spec version and serializes it.
+func newPayload(conf *runtime.RawExtension, version *semver.Version) (*payload, error) {
+	// we know we're at 3.2 in code.. serve directly, parsing is expensive...
+	// we're doing it during an HTTP request, and most notably before we write the HTTP headers
+	var serveConf *runtime.RawExtension
+	if version.Equal(*semver.New("3.2.0")) {
+		serveConf = conf
+	} else if version.Equal(*semver.New("3.1.0")) {
+		converted31, err := ctrlcommon.ConvertRawExtIgnitionToV3_1(conf)
+		if err != nil {
+			MCSConversionErrors.WithLabelValues(version.String()).Inc()
+			return nil, fmt.Errorf("couldn't convert config: %v", err)
+		}
+
+		serveConf = &converted31
+	} else {
+		// Can only be 2.2 here
+		converted2, err := ctrlcommon.ConvertRawExtIgnitionToV2(conf)
+		if err != nil {
+			MCSConversionErrors.WithLabelValues(version.String()).Inc()
+			return nil, fmt.Errorf("couldn't convert config: %v", err)
+		}
+
+		serveConf = &converted2
+	}
+
+	data, err := json.Marshal(serveConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %v", err)
+	}
+	return &payload{
+		data: data,
+		etag: fmt.Sprintf("%q", fmt.Sprintf("%x", sha256.Sum256(data))),
+	}, nil
+}
+
+// etagMatches returns whether the If-None-Match header matches the ETag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// configCacheKey identifies a payload: the rendered MachineConfig it is served from,
+// at a given resourceVersion, for an Ignition spec version and a kubeconfig.
+type configCacheKey struct {
+	machineConfig   string
+	resourceVersion string
+	version         string
+	kubeconfigHash  string
+}
+
+// configCache holds the payloads served by the APIHandler, so the requests for a
+// config which didn't change don't go through parsing, appenders and conversion again.
+type configCache struct {
+	mu       sync.Mutex
+	payloads map[configCacheKey]*payload
+}
+
+func newConfigCache() *configCache {
+	return &configCache{payloads: map[configCacheKey]*payload{}}
+}
+
+func (c *configCache) get(key configCacheKey) (*payload, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.payloads[key]
+	return p, ok
+}
+
+// add caches the payload, dropping the ones served for an older version of the
+// MachineConfig or with an older kubeconfig.
+func (c *configCache) add(key configCacheKey, p *payload) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.payloads {
+		if k.machineConfig == key.machineConfig && k.version == key.version {
+			delete(c.payloads, k)
+		}
+	}
+	c.payloads[key] = p
+}
+
+// invalidate drops the payloads served for the MachineConfig.
+func (c *configCache) invalidate(machineConfig string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.payloads {
+		if k.machineConfig == machineConfig {
+			delete(c.payloads, k)
+		}
+	}
+}
+
+// cachingServer is implemented by the Servers whose payloads are cached by the APIHandler.
+type cachingServer interface {
+	// resolveConfig returns the cache key of the payload served for the request, and a
+	// function getting its Ignition config, or a nil key if there is nothing to serve.
+	resolveConfig(poolRequest) (*configCacheKey, func() (*runtime.RawExtension, error), error)
+	// configCache returns the cache of the payloads.
+	configCache() *configCache
+}
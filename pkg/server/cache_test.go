@@ -0,0 +1,117 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	yaml "github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+func TestConfigCache(t *testing.T) {
+	c := newConfigCache()
+	v1 := configCacheKey{machineConfig: "rendered-worker-1", resourceVersion: "1", version: "3.2.0", kubeconfigHash: "a"}
+	v1Spec2 := configCacheKey{machineConfig: "rendered-worker-1", resourceVersion: "1", version: "2.2.0", kubeconfigHash: "a"}
+	other := configCacheKey{machineConfig: "rendered-worker-2", resourceVersion: "1", version: "3.2.0", kubeconfigHash: "a"}
+	c.add(v1, &payload{etag: "v1"})
+	c.add(v1Spec2, &payload{etag: "v1Spec2"})
+	c.add(other, &payload{etag: "other"})
+
+	p, ok := c.get(v1)
+	require.True(t, ok)
+	assert.Equal(t, "v1", p.etag)
+
+	// a new resourceVersion or kubeconfig replaces the payload for the same spec version
+	v2 := v1
+	v2.resourceVersion = "2"
+	c.add(v2, &payload{etag: "v2"})
+	_, ok = c.get(v1)
+	assert.False(t, ok)
+	_, ok = c.get(v1Spec2)
+	assert.True(t, ok)
+	newKubeconfig := v2
+	newKubeconfig.kubeconfigHash = "b"
+	c.add(newKubeconfig, &payload{etag: "b"})
+	_, ok = c.get(v2)
+	assert.False(t, ok)
+
+	c.invalidate("rendered-worker-1")
+	assert.Len(t, c.payloads, 1)
+	_, ok = c.get(other)
+	assert.True(t, ok)
+}
+
+func TestEtagMatches(t *testing.T) {
+	etag := `"1234"`
+	assert.True(t, etagMatches(`"1234"`, etag))
+	assert.True(t, etagMatches(`"abcd", W/"1234"`, etag))
+	assert.True(t, etagMatches("*", etag))
+	assert.False(t, etagMatches("", etag))
+	assert.False(t, etagMatches(`"abcd"`, etag))
+}
+
+func TestAPIHandlerCache(t *testing.T) {
+	mp, err := getTestMachineConfigPool()
+	require.Nil(t, err)
+	mcData, err := ioutil.ReadFile(filepath.Join(testDir, "machine-configs", testConfig+".yaml"))
+	require.Nil(t, err)
+	mc := new(mcfgv1.MachineConfig)
+	require.Nil(t, yaml.Unmarshal(mcData, mc))
+	mc.ResourceVersion = "1"
+
+	csc := newTestClusterServer(t, []runtime.Object{mp, mc}, nil)
+	handler := NewServerAPIHandler(csc)
+	serve := func(ifNoneMatch string) *http.Response {
+		req := setV3AcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/"+testPool, nil))
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	resp := serve("")
+	defer resp.Body.Close()
+	checkStatus(t, resp, http.StatusOK)
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Len(t, csc.cache.payloads, 1)
+
+	// the payload is served from the cache
+	for key, p := range csc.cache.payloads {
+		assert.Equal(t, mc.Name, key.machineConfig)
+		assert.Equal(t, "3.2.0", key.version)
+		p.data = []byte("{}")
+	}
+	resp = serve("")
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	assert.Equal(t, "{}", string(body))
+
+	resp = serve(etag)
+	defer resp.Body.Close()
+	checkStatus(t, resp, http.StatusNotModified)
+	checkBodyLength(t, resp, 0)
+
+	// updating the MachineConfig invalidates its payloads
+	updated := mc.DeepCopy()
+	updated.ResourceVersion = "2"
+	csc.updateMachineConfig(mc, updated)
+	assert.Empty(t, csc.cache.payloads)
+	resp = serve("")
+	defer resp.Body.Close()
+	checkStatus(t, resp, http.StatusOK)
+	assert.Equal(t, etag, resp.Header.Get("ETag"))
+	body, err = ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	assert.NotEqual(t, "{}", string(body))
+}
@@ -1,22 +1,35 @@
 package server
 
 import (
-	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
 
 	yaml "github.com/ghodss/yaml"
+	"github.com/golang/glog"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/informers"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
 	rest "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	clientcmd "k8s.io/client-go/tools/clientcmd"
 	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
 
-	v1 "github.com/openshift/machine-config-operator/pkg/generated/clientset/versioned/typed/machineconfiguration.openshift.io/v1"
+	mcfgclientset "github.com/openshift/machine-config-operator/pkg/generated/clientset/versioned"
+	mcfginformers "github.com/openshift/machine-config-operator/pkg/generated/informers/externalversions"
+	mcfginformersv1 "github.com/openshift/machine-config-operator/pkg/generated/informers/externalversions/machineconfiguration.openshift.io/v1"
+	mcfglistersv1 "github.com/openshift/machine-config-operator/pkg/generated/listers/machineconfiguration.openshift.io/v1"
 )
 
 const (
@@ -32,12 +45,25 @@ const (
 // Server interface.
 var _ = Server(&clusterServer{})
 
+// ensure clusterServer has its payloads
+// cached by the APIHandler.
+var _ = cachingServer(&clusterServer{})
+
+// ensure clusterServer only labels the metrics
+// with the pools it knows.
+var _ = poolChecker(&clusterServer{})
+
 type clusterServer struct {
-	// machineClient is used to interact with the
-	// machine config, pool objects.
-	machineClient v1.MachineconfigurationV1Interface
+	// mcpLister, mcLister and nodeLister fetch the pools, machine
+	// configs and nodes from the informers' caches.
+	mcpLister  mcfglistersv1.MachineConfigPoolLister
+	mcLister   mcfglistersv1.MachineConfigLister
+	nodeLister corelistersv1.NodeLister
 
 	kubeconfigFunc kubeconfigFunc
+
+	// cache holds the payloads served by the APIHandler.
+	cache *configCache
 }
 
 // NewClusterServer is used to initialize the machine config
@@ -46,23 +72,148 @@ type clusterServer struct {
 // It accepts a kubeConfig, which is not required when it's
 // run from within a cluster(useful in testing).
 // It accepts the apiserverURL which is the location of the KubeAPIServer.
-func NewClusterServer(kubeConfig, apiserverURL string) (Server, error) {
+// The informers it uses run until stopCh is closed.
+func NewClusterServer(kubeConfig, apiserverURL string, stopCh <-chan struct{}) (Server, error) {
 	restConfig, err := getClientConfig(kubeConfig)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create Kubernetes rest client: %v", err)
 	}
 
-	mc := v1.NewForConfigOrDie(restConfig)
-	return &clusterServer{
-		machineClient:  mc,
-		kubeconfigFunc: func() ([]byte, []byte, error) { return kubeconfigFromSecret(bootstrapTokenDir, apiserverURL) },
-	}, nil
+	mcfgInformers := mcfginformers.NewSharedInformerFactory(mcfgclientset.NewForConfigOrDie(restConfig), 0)
+	kubeInformers := informers.NewSharedInformerFactory(kubernetes.NewForConfigOrDie(restConfig), 0)
+	mcpInformer := mcfgInformers.Machineconfiguration().V1().MachineConfigPools()
+	mcInformer := mcfgInformers.Machineconfiguration().V1().MachineConfigs()
+	nodeInformer := kubeInformers.Core().V1().Nodes()
+	cs := newClusterServer(mcpInformer, mcInformer, nodeInformer,
+		func() ([]byte, []byte, error) { return kubeconfigFromSecret(bootstrapTokenDir, apiserverURL) })
+
+	mcfgInformers.Start(stopCh)
+	kubeInformers.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, mcpInformer.Informer().HasSynced, mcInformer.Informer().HasSynced, nodeInformer.Informer().HasSynced) {
+		return nil, fmt.Errorf("failed to sync informers")
+	}
+	return cs, nil
+}
+
+func newClusterServer(
+	mcpInformer mcfginformersv1.MachineConfigPoolInformer,
+	mcInformer mcfginformersv1.MachineConfigInformer,
+	nodeInformer coreinformersv1.NodeInformer,
+	kubeconfigFunc kubeconfigFunc,
+) *clusterServer {
+	cs := &clusterServer{
+		mcpLister:      mcpInformer.Lister(),
+		mcLister:       mcInformer.Lister(),
+		nodeLister:     nodeInformer.Lister(),
+		kubeconfigFunc: kubeconfigFunc,
+		cache:          newConfigCache(),
+	}
+
+	mcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: cs.updateMachineConfig,
+		DeleteFunc: cs.deleteMachineConfig,
+	})
+	return cs
+}
+
+func (cs *clusterServer) updateMachineConfig(old, cur interface{}) {
+	mc := old.(*mcfgv1.MachineConfig)
+	cs.cache.invalidate(mc.Name)
+}
+
+func (cs *clusterServer) deleteMachineConfig(obj interface{}) {
+	mc, ok := obj.(*mcfgv1.MachineConfig)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("Couldn't get object from tombstone %#v", obj))
+			return
+		}
+		mc, ok = tombstone.Obj.(*mcfgv1.MachineConfig)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("Tombstone contained object that is not a MachineConfig %#v", obj))
+			return
+		}
+	}
+	cs.cache.invalidate(mc.Name)
 }
 
 // GetConfig fetches the machine config(type - Ignition) from the cluster,
 // based on the pool request.
 func (cs *clusterServer) GetConfig(cr poolRequest) (*runtime.RawExtension, error) {
-	mp, err := cs.machineClient.MachineConfigPools().Get(context.TODO(), cr.machineConfigPool, metav1.GetOptions{})
+	key, getConfig, err := cs.resolveConfig(cr)
+	if key == nil || err != nil {
+		return nil, err
+	}
+	return getConfig()
+}
+
+func (cs *clusterServer) hasPool(name string) bool {
+	_, err := cs.mcpLister.Get(name)
+	return err == nil
+}
+
+func (cs *clusterServer) configCache() *configCache {
+	return cs.cache
+}
+
+// resolveConfig finds the MachineConfig served for the request. It returns the key
+// of the payload served from it, and a function getting its Ignition config.
+func (cs *clusterServer) resolveConfig(cr poolRequest) (*configCacheKey, func() (*runtime.RawExtension, error), error) {
+	var mc *mcfgv1.MachineConfig
+	var err error
+	switch {
+	case cr.renderedConfig != "":
+		mc, err = cs.getRenderedConfig(cr.renderedConfig)
+	case cr.node != "":
+		mc, err = cs.getNodeConfig(cr.node)
+	default:
+		mc, err = cs.getPoolConfig(cr.machineConfigPool)
+	}
+	if mc == nil || err != nil {
+		return nil, nil, err
+	}
+
+	// The kubeconfig is read once so the payload matches the key,
+	// it changes along with the node-bootstrapper token Secret.
+	kubeconfig, rootCA, err := cs.kubeconfigFunc()
+	if err != nil {
+		return nil, nil, err
+	}
+	key := &configCacheKey{
+		machineConfig:   mc.Name,
+		resourceVersion: mc.ResourceVersion,
+		kubeconfigHash:  fmt.Sprintf("%x", sha256.Sum256(kubeconfig)),
+	}
+	if cr.version != nil {
+		key.version = cr.version.String()
+	}
+
+	getConfig := func() (*runtime.RawExtension, error) {
+		ignConf, err := ctrlcommon.ParseAndConvertConfig(mc.Spec.Config.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("parsing Ignition config failed with error: %v", err)
+		}
+
+		appenders := getAppenders(mc.Name, cr.version, func() ([]byte, []byte, error) { return kubeconfig, rootCA, nil })
+		for _, a := range appenders {
+			if err := a(&ignConf, mc); err != nil {
+				return nil, err
+			}
+		}
+
+		rawConf, err := json.Marshal(ignConf)
+		if err != nil {
+			return nil, err
+		}
+		return &runtime.RawExtension{Raw: rawConf}, nil
+	}
+	return key, getConfig, nil
+}
+
+// getPoolConfig returns the MachineConfig served to new nodes of the pool.
+func (cs *clusterServer) getPoolConfig(pool string) (*mcfgv1.MachineConfig, error) {
+	mp, err := cs.mcpLister.Get(pool)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch pool. err: %v", err)
 	}
@@ -78,27 +229,52 @@ func (cs *clusterServer) GetConfig(cr poolRequest) (*runtime.RawExtension, error
 		currConf = mp.Status.Configuration.Name
 	}
 
-	mc, err := cs.machineClient.MachineConfigs().Get(context.TODO(), currConf, metav1.GetOptions{})
+	mc, err := cs.mcLister.Get(currConf)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch config %s, err: %v", currConf, err)
 	}
-	ignConf, err := ctrlcommon.ParseAndConvertConfig(mc.Spec.Config.Raw)
+	return mc, nil
+}
+
+// getRenderedConfig returns the rendered MachineConfig with the given name,
+// or nil if there is no such rendered MachineConfig.
+func (cs *clusterServer) getRenderedConfig(name string) (*mcfgv1.MachineConfig, error) {
+	mc, err := cs.mcLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("parsing Ignition config failed with error: %v", err)
+		return nil, fmt.Errorf("could not fetch config %s, err: %v", name, err)
+	}
+	// Only serve the configs rendered for a pool, not the MachineConfigs they are generated from.
+	if ref := metav1.GetControllerOf(mc); ref == nil || ref.Kind != "MachineConfigPool" {
+		glog.Infof("Refusing to serve %s, it is not a rendered config", name)
+		return nil, nil
 	}
+	return mc, nil
+}
 
-	appenders := getAppenders(currConf, cr.version, cs.kubeconfigFunc)
-	for _, a := range appenders {
-		if err := a(&ignConf, mc); err != nil {
-			return nil, err
-		}
+// getNodeConfig returns the MachineConfig the node with the given name is currently on,
+// or nil if there is no such node.
+func (cs *clusterServer) getNodeConfig(name string) (*mcfgv1.MachineConfig, error) {
+	node, err := cs.nodeLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch node %s, err: %v", name, err)
+	}
+	currConf := node.Annotations[daemonconsts.CurrentMachineConfigAnnotationKey]
+	if currConf == "" {
+		glog.Infof("Node %s has no current config", name)
+		return nil, nil
 	}
 
-	rawConf, err := json.Marshal(ignConf)
+	mc, err := cs.mcLister.Get(currConf)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not fetch config %s of node %s, err: %v", currConf, name, err)
 	}
-	return &runtime.RawExtension{Raw: rawConf}, nil
+	return mc, nil
 }
 
 // getClientConfig returns a Kubernetes client Config.
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// DefaultBindAddress is the port for the metrics listener
+	DefaultBindAddress = ":8798"
+
+	// MCSRequests counts the requests served by endpoint, pool, requested Ignition spec version and response code
+	MCSRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcs_requests_total",
+			Help: "requests served by endpoint, pool, requested Ignition spec version and response code",
+		}, []string{"endpoint", "pool", "version", "code"})
+
+	// MCSRequestDuration is the latency of the requests by endpoint and pool
+	MCSRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "mcs_request_duration_seconds",
+			Help:    "latency of the requests by endpoint and pool",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"endpoint", "pool"})
+
+	// MCSResponseBytes counts the bytes of the configs served by endpoint and pool
+	MCSResponseBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcs_response_bytes_total",
+			Help: "bytes of the configs served by endpoint and pool",
+		}, []string{"endpoint", "pool"})
+
+	// MCSConversionErrors counts the failures converting a config to the requested Ignition spec version
+	MCSConversionErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcs_conversion_errors_total",
+			Help: "failures converting a config to the requested Ignition spec version",
+		}, []string{"version"})
+
+	// MCSRequestsDenied counts the requests denied for lack of a valid bootstrap token or client certificate
+	MCSRequestsDenied = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcs_requests_denied_total",
+			Help: "requests denied for lack of a valid bootstrap token or client certificate",
+		}, []string{"reason"})
+
+	metricsList = []prometheus.Collector{
+		MCSRequests,
+		MCSRequestDuration,
+		MCSResponseBytes,
+		MCSConversionErrors,
+		MCSRequestsDenied,
+	}
+)
+
+// unknownPoolLabel is the pool label of the requests for pools which don't exist, so that
+// clients can't create new label values.
+const unknownPoolLabel = "unknown"
+
+// poolChecker is implemented by the Servers which can tell whether a pool exists.
+type poolChecker interface {
+	hasPool(name string) bool
+}
+
+// poolLabel returns the pool label of the metrics for the request: the requested pool
+// if the server knows it, unknownPoolLabel otherwise.
+func poolLabel(s Server, cr poolRequest) string {
+	if cr.machineConfigPool == "" {
+		return ""
+	}
+	if pc, ok := s.(poolChecker); ok && pc.hasPool(cr.machineConfigPool) {
+		return cr.machineConfigPool
+	}
+	return unknownPoolLabel
+}
+
+// observeRequest records a request served by the APIHandler.
+func observeRequest(cr poolRequest, pool string, status, bytes int, elapsed time.Duration) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	version := ""
+	if cr.version != nil {
+		version = cr.version.String()
+	}
+	MCSRequests.WithLabelValues(cr.endpoint(), pool, version, strconv.Itoa(status)).Inc()
+	MCSRequestDuration.WithLabelValues(cr.endpoint(), pool).Observe(elapsed.Seconds())
+	MCSResponseBytes.WithLabelValues(cr.endpoint(), pool).Add(float64(bytes))
+}
+
+func registerMCSMetrics() error {
+	for _, metric := range metricsList {
+		err := prometheus.Register(metric)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartMetricsListener is metrics listener via http
+func StartMetricsListener(addr string, stopCh <-chan struct{}) {
+	if addr == "" {
+		addr = DefaultBindAddress
+	}
+
+	glog.Info("Registering Prometheus metrics")
+	if err := registerMCSMetrics(); err != nil {
+		glog.Errorf("unable to register metrics: %v", err)
+	}
+
+	glog.Infof("Starting metrics listener on %s", addr)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	s := http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			glog.Errorf("metrics listener exited with error: %v", err)
+		}
+	}()
+	<-stopCh
+	if err := s.Shutdown(context.Background()); err != http.ErrServerClosed {
+		glog.Errorf("error stopping metrics listener: %v", err)
+	}
+}
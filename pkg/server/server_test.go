@@ -1,7 +1,6 @@
 package server
 
 import (
-	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -19,13 +18,18 @@ import (
 	ign3types "github.com/coreos/ignition/v2/config/v3_2/types"
 	yaml "github.com/ghodss/yaml"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	"github.com/openshift/machine-config-operator/pkg/generated/clientset/versioned/fake"
+	informers "github.com/openshift/machine-config-operator/pkg/generated/informers/externalversions"
 )
 
 const (
@@ -248,20 +252,7 @@ func TestClusterServer(t *testing.T) {
 		t.Fatalf("unexpected error while unmarshaling machine-config: %s, err: %v", mcPath, err)
 	}
 
-	cs := fake.NewSimpleClientset()
-	_, err = cs.MachineconfigurationV1().MachineConfigPools().Create(context.TODO(), mp, metav1.CreateOptions{})
-	if err != nil {
-		t.Logf("err: %v", err)
-	}
-	_, err = cs.MachineconfigurationV1().MachineConfigs().Create(context.TODO(), origMC, metav1.CreateOptions{})
-	if err != nil {
-		t.Logf("err: %v", err)
-	}
-
-	csc := &clusterServer{
-		machineClient:  cs.MachineconfigurationV1(),
-		kubeconfigFunc: func() ([]byte, []byte, error) { return getKubeConfigContent(t) },
-	}
+	csc := newTestClusterServer(t, []runtime.Object{mp, origMC}, nil)
 
 	mc := new(mcfgv1.MachineConfig)
 	err = yaml.Unmarshal([]byte(mcData), mc)
@@ -327,6 +318,95 @@ func TestClusterServer(t *testing.T) {
 	}
 }
 
+func TestClusterServerRenderedAndNodeConfig(t *testing.T) {
+	mp, err := getTestMachineConfigPool()
+	require.Nil(t, err)
+	mcData, err := ioutil.ReadFile(filepath.Join(testDir, "machine-configs", testConfig+".yaml"))
+	require.Nil(t, err)
+	mc := new(mcfgv1.MachineConfig)
+	require.Nil(t, yaml.Unmarshal(mcData, mc))
+
+	rendered := mc.DeepCopy()
+	rendered.Name = "rendered-test-pool-1234"
+	isController := true
+	rendered.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: mcfgv1.SchemeGroupVersion.String(),
+		Kind:       "MachineConfigPool",
+		Name:       mp.Name,
+		Controller: &isController,
+	}}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node-0",
+			Annotations: map[string]string{daemonconsts.CurrentMachineConfigAnnotationKey: mp.Status.Configuration.Name},
+		},
+	}
+
+	csc := newTestClusterServer(t, []runtime.Object{mp, mc, rendered}, []runtime.Object{node})
+	version := semver.New("3.2.0")
+
+	// a node gets the same config as the pool it is on
+	poolConf, err := csc.GetConfig(poolRequest{machineConfigPool: testPool, version: version})
+	require.Nil(t, err)
+	nodeConf, err := csc.GetConfig(poolRequest{node: "node-0", version: version})
+	require.Nil(t, err)
+	require.NotNil(t, nodeConf)
+	assert.Equal(t, string(poolConf.Raw), string(nodeConf.Raw))
+
+	renderedConf, err := csc.GetConfig(poolRequest{renderedConfig: rendered.Name, version: version})
+	require.Nil(t, err)
+	require.NotNil(t, renderedConf)
+	resCfg, err := ctrlcommon.ParseAndConvertConfig(renderedConf.Raw)
+	require.Nil(t, err)
+	anno, err := getNodeAnnotation(rendered.Name)
+	require.Nil(t, err)
+	foundAnnotations := false
+	for _, f := range resCfg.Storage.Files {
+		if f.Path == daemonconsts.InitialNodeAnnotationsFilePath {
+			foundAnnotations = true
+			contents, err := getDecodedContent(*f.Contents.Source)
+			require.Nil(t, err)
+			assert.Equal(t, anno, contents)
+		}
+	}
+	assert.True(t, foundAnnotations)
+
+	for _, cr := range []poolRequest{
+		{renderedConfig: "rendered-test-pool-does-not-exist"},
+		// not a rendered config
+		{renderedConfig: mc.Name},
+		{node: "node-does-not-exist"},
+	} {
+		conf, err := csc.GetConfig(cr)
+		assert.Nil(t, err)
+		assert.Nil(t, conf, "%v", cr)
+	}
+}
+
+// newTestClusterServer returns a clusterServer serving the given objects.
+func newTestClusterServer(t *testing.T, objects, kubeobjects []runtime.Object) *clusterServer {
+	mcfgInformers := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	kubeInformers := kubeinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0)
+	csc := newClusterServer(
+		mcfgInformers.Machineconfiguration().V1().MachineConfigPools(),
+		mcfgInformers.Machineconfiguration().V1().MachineConfigs(),
+		kubeInformers.Core().V1().Nodes(),
+		func() ([]byte, []byte, error) { return getKubeConfigContent(t) },
+	)
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *mcfgv1.MachineConfigPool:
+			require.Nil(t, mcfgInformers.Machineconfiguration().V1().MachineConfigPools().Informer().GetIndexer().Add(o))
+		case *mcfgv1.MachineConfig:
+			require.Nil(t, mcfgInformers.Machineconfiguration().V1().MachineConfigs().Informer().GetIndexer().Add(o))
+		}
+	}
+	for _, obj := range kubeobjects {
+		require.Nil(t, kubeInformers.Core().V1().Nodes().Informer().GetIndexer().Add(obj))
+	}
+	return csc
+}
+
 func getKubeConfigContent(t *testing.T) ([]byte, []byte, error) {
 	return []byte("dummy-kubeconfig"), []byte("dummy-root-ca"), nil
 }